@@ -0,0 +1,83 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/importer"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// importBtcBlocksCmd represents the import-btc-blocks command
+var importBtcBlocksCmd = &cobra.Command{
+	Use:   "import-btc-blocks",
+	Short: "Import bitcoin blocks from Bitcoin Core block files",
+	Long: `Use this command to backfill the ipfs-blockchain-watcher database with the blocks in the blk*.dat files of a
+Bitcoin Core blocks directory, instead of retrieving them from the node over rpc. The node should be stopped, or at
+least not be writing to the files being read. An interrupted import resumes from the last imported height.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		importBtcBlocks()
+	},
+}
+
+func importBtcBlocks() {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading import configuration variables")
+	iConfig, err := importer.NewConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("import config: %+v", iConfig)
+	if iConfig.IPFSMode == shared.LocalInterface {
+		if err := ipfs.InitIPFSPlugins(); err != nil {
+			logWithCommand.Fatal(err)
+		}
+	}
+	logWithCommand.Debug("initializing new import service")
+	iService, err := importer.NewImportService(iConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Info("starting up import process")
+	if err := iService.Import(); err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Info("btc block import finished")
+}
+
+func init() {
+	rootCmd.AddCommand(importBtcBlocksCmd)
+
+	// flags; the ipfs and bitcoin node settings are read from the config file or environment
+	importBtcBlocksCmd.PersistentFlags().String("import-blocks-dir", "", "Bitcoin Core blocks directory holding the blk*.dat files")
+	importBtcBlocksCmd.PersistentFlags().Int("import-stop", 0, "block height to stop the import at, 0 imports up to the tip of the block files")
+	importBtcBlocksCmd.PersistentFlags().Int("import-batch-size", 0, "number of blocks each worker imports at a time")
+	importBtcBlocksCmd.PersistentFlags().Int("import-batch-number", 0, "how many goroutines to import blocks concurrently")
+
+	// and their bindings
+	viper.BindPFlag("import.blocksDir", importBtcBlocksCmd.PersistentFlags().Lookup("import-blocks-dir"))
+	viper.BindPFlag("import.stop", importBtcBlocksCmd.PersistentFlags().Lookup("import-stop"))
+	viper.BindPFlag("import.batchSize", importBtcBlocksCmd.PersistentFlags().Lookup("import-batch-size"))
+	viper.BindPFlag("import.batchNumber", importBtcBlocksCmd.PersistentFlags().Lookup("import-batch-number"))
+}
@@ -80,18 +80,22 @@ func init() {
 	resyncCmd.PersistentFlags().Int("resync-timeout", 15, "timeout used for resync http requests")
 
 	resyncCmd.PersistentFlags().String("btc-http-path", "", "http url for bitcoin node")
+	resyncCmd.PersistentFlags().String("btc-p2p-path", "", "p2p address for bitcoin node, used instead of rpc to retrieve blocks if set")
 	resyncCmd.PersistentFlags().String("btc-password", "", "password for btc node")
 	resyncCmd.PersistentFlags().String("btc-username", "", "username for btc node")
 	resyncCmd.PersistentFlags().String("btc-node-id", "", "btc node id")
 	resyncCmd.PersistentFlags().String("btc-client-name", "", "btc client name")
 	resyncCmd.PersistentFlags().String("btc-genesis-block", "", "btc genesis block hash")
 	resyncCmd.PersistentFlags().String("btc-network-id", "", "btc network id")
+	resyncCmd.PersistentFlags().String("btc-network", "", "btc network params to use: mainnet, testnet, regtest, simnet or signet")
 
 	resyncCmd.PersistentFlags().String("eth-http-path", "", "http url for ethereum node")
 	resyncCmd.PersistentFlags().String("eth-node-id", "", "eth node id")
 	resyncCmd.PersistentFlags().String("eth-client-name", "", "eth client name")
 	resyncCmd.PersistentFlags().String("eth-genesis-block", "", "eth genesis block hash")
 	resyncCmd.PersistentFlags().String("eth-network-id", "", "eth network id")
+	resyncCmd.PersistentFlags().String("eth-network", "", "eth chain config to use: mainnet, ropsten, rinkeby, goerli or auto")
+	resyncCmd.PersistentFlags().String("eth-genesis-file", "", "path to a geth genesis json file to load the eth chain config from")
 
 	// and their bindings
 	viper.BindPFlag("ipfs.path", resyncCmd.PersistentFlags().Lookup("ipfs-path"))
@@ -107,16 +111,20 @@ func init() {
 	viper.BindPFlag("resync.timeout", resyncCmd.PersistentFlags().Lookup("resync-timeout"))
 
 	viper.BindPFlag("bitcoin.httpPath", resyncCmd.PersistentFlags().Lookup("btc-http-path"))
+	viper.BindPFlag("bitcoin.p2pPath", resyncCmd.PersistentFlags().Lookup("btc-p2p-path"))
 	viper.BindPFlag("bitcoin.pass", resyncCmd.PersistentFlags().Lookup("btc-password"))
 	viper.BindPFlag("bitcoin.user", resyncCmd.PersistentFlags().Lookup("btc-username"))
 	viper.BindPFlag("bitcoin.nodeID", resyncCmd.PersistentFlags().Lookup("btc-node-id"))
 	viper.BindPFlag("bitcoin.clientName", resyncCmd.PersistentFlags().Lookup("btc-client-name"))
 	viper.BindPFlag("bitcoin.genesisBlock", resyncCmd.PersistentFlags().Lookup("btc-genesis-block"))
 	viper.BindPFlag("bitcoin.networkID", resyncCmd.PersistentFlags().Lookup("btc-network-id"))
+	viper.BindPFlag("bitcoin.network", resyncCmd.PersistentFlags().Lookup("btc-network"))
 
 	viper.BindPFlag("ethereum.httpPath", resyncCmd.PersistentFlags().Lookup("eth-http-path"))
 	viper.BindPFlag("ethereum.nodeID", resyncCmd.PersistentFlags().Lookup("eth-node-id"))
 	viper.BindPFlag("ethereum.clientName", resyncCmd.PersistentFlags().Lookup("eth-client-name"))
 	viper.BindPFlag("ethereum.genesisBlock", resyncCmd.PersistentFlags().Lookup("eth-genesis-block"))
 	viper.BindPFlag("ethereum.networkID", resyncCmd.PersistentFlags().Lookup("eth-network-id"))
+	viper.BindPFlag("ethereum.network", resyncCmd.PersistentFlags().Lookup("eth-network"))
+	viper.BindPFlag("ethereum.genesisFile", resyncCmd.PersistentFlags().Lookup("eth-genesis-file"))
 }
@@ -85,6 +85,10 @@ func streamEthSubscription() {
 				logWithCommand.Error(payload.Err)
 				continue
 			}
+			if payload.Retracted() {
+				fmt.Printf("Header number %d, hash %s was orphaned by a reorg\n", payload.Height, payload.Hash)
+				continue
+			}
 			var ethData eth.IPLDs
 			if err := rlp.DecodeBytes(payload.Data, &ethData); err != nil {
 				logWithCommand.Error(err)
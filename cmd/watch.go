@@ -16,6 +16,9 @@
 package cmd
 
 import (
+	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	s "sync"
@@ -25,6 +28,11 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/electrum"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/esplora"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/graphql"
 	h "github.com/vulcanize/ipfs-blockchain-watcher/pkg/historical"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
@@ -104,6 +112,7 @@ func watch() {
 			logWithCommand.Fatal(err)
 		}
 		logWithCommand.Info("starting up watcher backfill process")
+		watcher.SetBackFillReporter(backFiller)
 		backFiller.BackFill(wg)
 	}
 
@@ -118,19 +127,129 @@ func watch() {
 }
 
 func startServers(watcher w.Watcher, settings *w.Config) error {
+	// The apis are built once, so that every server shares the same backend
+	apis := watcher.APIs()
 	logWithCommand.Debug("starting up IPC server")
-	_, _, err := rpc.StartIPCEndpoint(settings.IPCEndpoint, watcher.APIs())
+	_, _, err := rpc.StartIPCEndpoint(settings.IPCEndpoint, apis)
 	if err != nil {
 		return err
 	}
+	modules := []string{"vdb", settings.Chain.API(), "net", "web3"}
+	if settings.Chain == shared.Ethereum && settings.EthAPIConfig.DebugAPI {
+		modules = append(modules, eth.DebugAPIName)
+	}
 	logWithCommand.Debug("starting up WS server")
-	_, _, err = rpc.StartWSEndpoint(settings.WSEndpoint, watcher.APIs(), []string{"vdb"}, nil, true)
+	_, _, err = rpc.StartWSEndpoint(settings.WSEndpoint, apis, modules, nil, true)
 	if err != nil {
 		return err
 	}
 	logWithCommand.Debug("starting up HTTP server")
-	_, _, err = rpc.StartHTTPEndpoint(settings.HTTPEndpoint, watcher.APIs(), []string{settings.Chain.API()}, nil, nil, rpc.HTTPTimeouts{})
-	return err
+	if err := startHTTPEndpoint(apis, modules, settings); err != nil {
+		return err
+	}
+	if settings.GraphQL {
+		logWithCommand.Debug("starting up GraphQL server")
+		if err := startGraphQLServer(apis, watcher, settings); err != nil {
+			return err
+		}
+	}
+	if settings.Esplora {
+		logWithCommand.Debug("starting up Esplora server")
+		if err := startEsploraServer(apis, settings); err != nil {
+			return err
+		}
+	}
+	if settings.Electrum {
+		logWithCommand.Debug("starting up Electrum server")
+		return startElectrumServer(apis, watcher, settings)
+	}
+	return nil
+}
+
+// startHTTPEndpoint serves the apis of the provided modules over http
+// When an upstream eth node is configured, the allowed methods which the watcher does not implement are forwarded to it
+func startHTTPEndpoint(apis []rpc.API, modules []string, settings *w.Config) error {
+	whitelist := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		whitelist[module] = true
+	}
+	server := rpc.NewServer()
+	for _, api := range apis {
+		if whitelist[api.Namespace] {
+			if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+				return err
+			}
+		}
+	}
+	var handler http.Handler = server
+	if settings.ProxyClient != nil {
+		handler = eth.NewProxyHandler(eth.NewProxy(settings.ProxyClient, settings.ProxyMethods), server)
+	}
+	listener, err := net.Listen("tcp", settings.HTTPEndpoint)
+	if err != nil {
+		return err
+	}
+	go rpc.NewHTTPServer(nil, nil, rpc.HTTPTimeouts{}, handler).Serve(listener)
+	return nil
+}
+
+// startGraphQLServer serves the GraphQL endpoint using the watcher's eth api
+func startGraphQLServer(apis []rpc.API, watcher w.Watcher, settings *w.Config) error {
+	var api *eth.PublicEthAPI
+	for _, a := range apis {
+		if pea, ok := a.Service.(*eth.PublicEthAPI); ok {
+			api = pea
+		}
+	}
+	if api == nil {
+		return fmt.Errorf("graphql server requires the %s api", eth.APIName)
+	}
+	var proxy *eth.Proxy
+	if settings.ProxyClient != nil {
+		proxy = eth.NewProxy(settings.ProxyClient, settings.ProxyMethods)
+	}
+	server, err := graphql.New(api, watcher, proxy, settings.GraphQLEndpoint, nil, nil, rpc.HTTPTimeouts{})
+	if err != nil {
+		return err
+	}
+	return server.Start()
+}
+
+// startEsploraServer serves the Esplora REST endpoint using the backend of the watcher's btc api
+func startEsploraServer(apis []rpc.API, settings *w.Config) error {
+	var api *btc.PublicBtcAPI
+	for _, a := range apis {
+		if pba, ok := a.Service.(*btc.PublicBtcAPI); ok {
+			api = pba
+		}
+	}
+	if api == nil {
+		return fmt.Errorf("esplora server requires the %s api", btc.APIName)
+	}
+	server, err := esplora.New(api.B, settings.EsploraEndpoint, nil, nil, rpc.HTTPTimeouts{})
+	if err != nil {
+		return err
+	}
+	return server.Start()
+}
+
+// startElectrumServer serves the Electrum endpoint using the watcher's btc api, updating its subscriptions from the
+// payloads served by the watcher
+func startElectrumServer(apis []rpc.API, watcher w.Watcher, settings *w.Config) error {
+	var api *btc.PublicBtcAPI
+	for _, a := range apis {
+		if pba, ok := a.Service.(*btc.PublicBtcAPI); ok {
+			api = pba
+		}
+	}
+	if api == nil {
+		return fmt.Errorf("electrum server requires the %s api", btc.APIName)
+	}
+	server, err := electrum.New(api, watcher, settings.ElectrumEndpoint)
+	if err != nil {
+		return err
+	}
+	return server.Start()
 }
 
 func init() {
@@ -145,6 +264,12 @@ func init() {
 	watchCmd.PersistentFlags().String("watcher-ws-path", "", "vdb server ws path")
 	watchCmd.PersistentFlags().String("watcher-http-path", "", "vdb server http path")
 	watchCmd.PersistentFlags().String("watcher-ipc-path", "", "vdb server ipc path")
+	watchCmd.PersistentFlags().Bool("watcher-graphql", false, "turn the graphql server on or off")
+	watchCmd.PersistentFlags().String("watcher-graphql-path", "", "graphql server http path")
+	watchCmd.PersistentFlags().Bool("watcher-esplora", false, "turn the esplora server on or off")
+	watchCmd.PersistentFlags().String("watcher-esplora-path", "", "esplora server http path")
+	watchCmd.PersistentFlags().Bool("watcher-electrum", false, "turn the electrum server on or off")
+	watchCmd.PersistentFlags().String("watcher-electrum-path", "", "electrum server tcp path")
 	watchCmd.PersistentFlags().Bool("watcher-sync", false, "turn vdb sync on or off")
 	watchCmd.PersistentFlags().Int("watcher-workers", 0, "how many worker goroutines to publish and index data")
 	watchCmd.PersistentFlags().Bool("watcher-back-fill", false, "turn vdb backfill on or off")
@@ -156,12 +281,14 @@ func init() {
 
 	watchCmd.PersistentFlags().String("btc-ws-path", "", "ws url for bitcoin node")
 	watchCmd.PersistentFlags().String("btc-http-path", "", "http url for bitcoin node")
+	watchCmd.PersistentFlags().String("btc-p2p-path", "", "p2p address for bitcoin node, used instead of rpc to retrieve blocks if set")
 	watchCmd.PersistentFlags().String("btc-password", "", "password for btc node")
 	watchCmd.PersistentFlags().String("btc-username", "", "username for btc node")
 	watchCmd.PersistentFlags().String("btc-node-id", "", "btc node id")
 	watchCmd.PersistentFlags().String("btc-client-name", "", "btc client name")
 	watchCmd.PersistentFlags().String("btc-genesis-block", "", "btc genesis block hash")
 	watchCmd.PersistentFlags().String("btc-network-id", "", "btc network id")
+	watchCmd.PersistentFlags().String("btc-network", "", "btc network params to use: mainnet, testnet, regtest, simnet or signet")
 
 	watchCmd.PersistentFlags().String("eth-ws-path", "", "ws url for ethereum node")
 	watchCmd.PersistentFlags().String("eth-http-path", "", "http url for ethereum node")
@@ -169,6 +296,10 @@ func init() {
 	watchCmd.PersistentFlags().String("eth-client-name", "", "eth client name")
 	watchCmd.PersistentFlags().String("eth-genesis-block", "", "eth genesis block hash")
 	watchCmd.PersistentFlags().String("eth-network-id", "", "eth network id")
+	watchCmd.PersistentFlags().String("eth-network", "", "eth chain config to use: mainnet, ropsten, rinkeby, goerli or auto")
+	watchCmd.PersistentFlags().String("eth-genesis-file", "", "path to a geth genesis json file to load the eth chain config from")
+	watchCmd.PersistentFlags().Bool("eth-proxy", false, "forward unsupported eth requests to the eth node's http path")
+	watchCmd.PersistentFlags().StringSlice("eth-proxy-methods", nil, "eth methods that can be forwarded to the eth node")
 
 	// and their bindings
 	viper.BindPFlag("ipfs.path", watchCmd.PersistentFlags().Lookup("ipfs-path"))
@@ -179,6 +310,12 @@ func init() {
 	viper.BindPFlag("watcher.wsPath", watchCmd.PersistentFlags().Lookup("watcher-ws-path"))
 	viper.BindPFlag("watcher.httpPath", watchCmd.PersistentFlags().Lookup("watcher-http-path"))
 	viper.BindPFlag("watcher.ipcPath", watchCmd.PersistentFlags().Lookup("watcher-ipc-path"))
+	viper.BindPFlag("watcher.graphql", watchCmd.PersistentFlags().Lookup("watcher-graphql"))
+	viper.BindPFlag("watcher.graphqlPath", watchCmd.PersistentFlags().Lookup("watcher-graphql-path"))
+	viper.BindPFlag("watcher.esplora", watchCmd.PersistentFlags().Lookup("watcher-esplora"))
+	viper.BindPFlag("watcher.esploraPath", watchCmd.PersistentFlags().Lookup("watcher-esplora-path"))
+	viper.BindPFlag("watcher.electrum", watchCmd.PersistentFlags().Lookup("watcher-electrum"))
+	viper.BindPFlag("watcher.electrumPath", watchCmd.PersistentFlags().Lookup("watcher-electrum-path"))
 	viper.BindPFlag("watcher.sync", watchCmd.PersistentFlags().Lookup("watcher-sync"))
 	viper.BindPFlag("watcher.workers", watchCmd.PersistentFlags().Lookup("watcher-workers"))
 	viper.BindPFlag("watcher.backFill", watchCmd.PersistentFlags().Lookup("watcher-back-fill"))
@@ -190,12 +327,14 @@ func init() {
 
 	viper.BindPFlag("bitcoin.wsPath", watchCmd.PersistentFlags().Lookup("btc-ws-path"))
 	viper.BindPFlag("bitcoin.httpPath", watchCmd.PersistentFlags().Lookup("btc-http-path"))
+	viper.BindPFlag("bitcoin.p2pPath", watchCmd.PersistentFlags().Lookup("btc-p2p-path"))
 	viper.BindPFlag("bitcoin.pass", watchCmd.PersistentFlags().Lookup("btc-password"))
 	viper.BindPFlag("bitcoin.user", watchCmd.PersistentFlags().Lookup("btc-username"))
 	viper.BindPFlag("bitcoin.nodeID", watchCmd.PersistentFlags().Lookup("btc-node-id"))
 	viper.BindPFlag("bitcoin.clientName", watchCmd.PersistentFlags().Lookup("btc-client-name"))
 	viper.BindPFlag("bitcoin.genesisBlock", watchCmd.PersistentFlags().Lookup("btc-genesis-block"))
 	viper.BindPFlag("bitcoin.networkID", watchCmd.PersistentFlags().Lookup("btc-network-id"))
+	viper.BindPFlag("bitcoin.network", watchCmd.PersistentFlags().Lookup("btc-network"))
 
 	viper.BindPFlag("ethereum.wsPath", watchCmd.PersistentFlags().Lookup("eth-ws-path"))
 	viper.BindPFlag("ethereum.httpPath", watchCmd.PersistentFlags().Lookup("eth-http-path"))
@@ -203,4 +342,8 @@ func init() {
 	viper.BindPFlag("ethereum.clientName", watchCmd.PersistentFlags().Lookup("eth-client-name"))
 	viper.BindPFlag("ethereum.genesisBlock", watchCmd.PersistentFlags().Lookup("eth-genesis-block"))
 	viper.BindPFlag("ethereum.networkID", watchCmd.PersistentFlags().Lookup("eth-network-id"))
+	viper.BindPFlag("ethereum.network", watchCmd.PersistentFlags().Lookup("eth-network"))
+	viper.BindPFlag("ethereum.genesisFile", watchCmd.PersistentFlags().Lookup("eth-genesis-file"))
+	viper.BindPFlag("ethereum.proxy", watchCmd.PersistentFlags().Lookup("eth-proxy"))
+	viper.BindPFlag("ethereum.proxyMethods", watchCmd.PersistentFlags().Lookup("eth-proxy-methods"))
 }
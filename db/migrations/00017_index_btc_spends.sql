@@ -0,0 +1,19 @@
+-- +goose Up
+-- index a transaction once for each block it is included in, so that competing blocks keep their own inputs and outputs
+ALTER TABLE btc.transaction_cids
+DROP CONSTRAINT transaction_cids_tx_hash_key,
+ADD CONSTRAINT transaction_cids_header_id_tx_hash_key UNIQUE (header_id, tx_hash);
+
+CREATE INDEX transaction_cids_tx_hash_index ON btc.transaction_cids USING btree (tx_hash);
+
+-- the inputs spending an output are found by its outpoint
+CREATE INDEX tx_inputs_outpoint_index ON btc.tx_inputs USING btree (outpoint_tx_hash, outpoint_index);
+
+-- +goose Down
+DROP INDEX btc.tx_inputs_outpoint_index;
+
+DROP INDEX btc.transaction_cids_tx_hash_index;
+
+ALTER TABLE btc.transaction_cids
+DROP CONSTRAINT transaction_cids_header_id_tx_hash_key,
+ADD CONSTRAINT transaction_cids_tx_hash_key UNIQUE (tx_hash);
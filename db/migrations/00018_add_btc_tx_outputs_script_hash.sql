@@ -0,0 +1,13 @@
+-- +goose Up
+-- script_hash is the electrum script hash of pk_script: the sha256 of the script, hex encoded in reverse byte order
+-- the outputs that were indexed before the column existed are hashed by the electrum server when it starts up
+ALTER TABLE btc.tx_outputs
+ADD COLUMN script_hash VARCHAR(66);
+
+CREATE INDEX tx_outputs_script_hash_index ON btc.tx_outputs USING btree (script_hash);
+
+-- +goose Down
+DROP INDEX btc.tx_outputs_script_hash_index;
+
+ALTER TABLE btc.tx_outputs
+DROP COLUMN script_hash;
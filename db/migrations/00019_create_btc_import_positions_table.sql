@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE btc.import_positions (
+	node_id         INTEGER PRIMARY KEY REFERENCES nodes (id) ON DELETE CASCADE,
+	block_number    BIGINT NOT NULL,
+	block_hash      VARCHAR(66) NOT NULL
+);
+
+COMMENT ON TABLE btc.import_positions IS E'@name BtcImportPositions';
+COMMENT ON COLUMN btc.import_positions.node_id IS E'@name BtcImportNodeID';
+
+-- +goose Down
+DROP TABLE btc.import_positions;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE eth.header_cids
+ADD COLUMN canonical BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX header_cids_canonical_index ON eth.header_cids USING btree (block_number) WHERE canonical;
+
+-- at heights holding several headers, mark the headers which are not the parent of an indexed child non-canonical
+-- when one of the other headers at that height is
+UPDATE eth.header_cids
+SET canonical = false
+WHERE NOT EXISTS (SELECT 1 FROM eth.header_cids AS children
+                  WHERE children.block_number = header_cids.block_number + 1
+                  AND children.parent_hash = header_cids.block_hash)
+AND EXISTS (SELECT 1 FROM eth.header_cids AS siblings, eth.header_cids AS children
+            WHERE siblings.block_number = header_cids.block_number
+            AND siblings.id <> header_cids.id
+            AND children.block_number = siblings.block_number + 1
+            AND children.parent_hash = siblings.block_hash);
+
+-- +goose Down
+DROP INDEX eth.header_cids_canonical_index;
+
+ALTER TABLE eth.header_cids
+DROP COLUMN canonical;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE eth.contract_codes (
+  code_hash             BYTEA PRIMARY KEY,
+  cid                   TEXT NOT NULL,
+  mh_key                TEXT NOT NULL REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED
+);
+
+-- +goose Down
+DROP TABLE eth.contract_codes;
@@ -86,6 +86,31 @@ CREATE SEQUENCE btc.header_cids_id_seq
 ALTER SEQUENCE btc.header_cids_id_seq OWNED BY btc.header_cids.id;
 
 
+--
+-- Name: import_positions; Type: TABLE; Schema: btc; Owner: -
+--
+
+CREATE TABLE btc.import_positions (
+    node_id integer NOT NULL,
+    block_number bigint NOT NULL,
+    block_hash character varying(66) NOT NULL
+);
+
+
+--
+-- Name: TABLE import_positions; Type: COMMENT; Schema: btc; Owner: -
+--
+
+COMMENT ON TABLE btc.import_positions IS '@name BtcImportPositions';
+
+
+--
+-- Name: COLUMN import_positions.node_id; Type: COMMENT; Schema: btc; Owner: -
+--
+
+COMMENT ON COLUMN btc.import_positions.node_id IS '@name BtcImportNodeID';
+
+
 --
 -- Name: transaction_cids; Type: TABLE; Schema: btc; Owner: -
 --
@@ -176,7 +201,8 @@ CREATE TABLE btc.tx_outputs (
     pk_script bytea NOT NULL,
     script_class integer NOT NULL,
     addresses character varying(66)[],
-    required_sigs integer NOT NULL
+    required_sigs integer NOT NULL,
+    script_hash character varying(66)
 );
 
 
@@ -200,6 +226,17 @@ CREATE SEQUENCE btc.tx_outputs_id_seq
 ALTER SEQUENCE btc.tx_outputs_id_seq OWNED BY btc.tx_outputs.id;
 
 
+--
+-- Name: contract_codes; Type: TABLE; Schema: eth; Owner: -
+--
+
+CREATE TABLE eth.contract_codes (
+    code_hash bytea NOT NULL,
+    cid text NOT NULL,
+    mh_key text NOT NULL
+);
+
+
 --
 -- Name: header_cids; Type: TABLE; Schema: eth; Owner: -
 --
@@ -220,7 +257,8 @@ CREATE TABLE eth.header_cids (
     uncle_root character varying(66) NOT NULL,
     bloom bytea NOT NULL,
     "timestamp" numeric NOT NULL,
-    times_validated integer DEFAULT 1 NOT NULL
+    times_validated integer DEFAULT 1 NOT NULL,
+    canonical boolean DEFAULT true NOT NULL
 );
 
 
@@ -678,19 +716,27 @@ ALTER TABLE ONLY btc.header_cids
 
 
 --
--- Name: transaction_cids transaction_cids_pkey; Type: CONSTRAINT; Schema: btc; Owner: -
+-- Name: import_positions import_positions_pkey; Type: CONSTRAINT; Schema: btc; Owner: -
+--
+
+ALTER TABLE ONLY btc.import_positions
+    ADD CONSTRAINT import_positions_pkey PRIMARY KEY (node_id);
+
+
+--
+-- Name: transaction_cids transaction_cids_header_id_tx_hash_key; Type: CONSTRAINT; Schema: btc; Owner: -
 --
 
 ALTER TABLE ONLY btc.transaction_cids
-    ADD CONSTRAINT transaction_cids_pkey PRIMARY KEY (id);
+    ADD CONSTRAINT transaction_cids_header_id_tx_hash_key UNIQUE (header_id, tx_hash);
 
 
 --
--- Name: transaction_cids transaction_cids_tx_hash_key; Type: CONSTRAINT; Schema: btc; Owner: -
+-- Name: transaction_cids transaction_cids_pkey; Type: CONSTRAINT; Schema: btc; Owner: -
 --
 
 ALTER TABLE ONLY btc.transaction_cids
-    ADD CONSTRAINT transaction_cids_tx_hash_key UNIQUE (tx_hash);
+    ADD CONSTRAINT transaction_cids_pkey PRIMARY KEY (id);
 
 
 --
@@ -725,6 +771,14 @@ ALTER TABLE ONLY btc.tx_outputs
     ADD CONSTRAINT tx_outputs_tx_id_index_key UNIQUE (tx_id, index);
 
 
+--
+-- Name: contract_codes contract_codes_pkey; Type: CONSTRAINT; Schema: eth; Owner: -
+--
+
+ALTER TABLE ONLY eth.contract_codes
+    ADD CONSTRAINT contract_codes_pkey PRIMARY KEY (code_hash);
+
+
 --
 -- Name: header_cids header_cids_block_number_block_hash_key; Type: CONSTRAINT; Schema: eth; Owner: -
 --
@@ -869,6 +923,34 @@ ALTER TABLE ONLY public.nodes
     ADD CONSTRAINT nodes_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: transaction_cids_tx_hash_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX transaction_cids_tx_hash_index ON btc.transaction_cids USING btree (tx_hash);
+
+
+--
+-- Name: tx_inputs_outpoint_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX tx_inputs_outpoint_index ON btc.tx_inputs USING btree (outpoint_tx_hash, outpoint_index);
+
+
+--
+-- Name: tx_outputs_script_hash_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX tx_outputs_script_hash_index ON btc.tx_outputs USING btree (script_hash);
+
+
+--
+-- Name: header_cids_canonical_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX header_cids_canonical_index ON eth.header_cids USING btree (block_number) WHERE canonical;
+
+
 --
 -- Name: header_cids header_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: btc; Owner: -
 --
@@ -885,6 +967,14 @@ ALTER TABLE ONLY btc.header_cids
     ADD CONSTRAINT header_cids_node_id_fkey FOREIGN KEY (node_id) REFERENCES public.nodes(id) ON DELETE CASCADE;
 
 
+--
+-- Name: import_positions import_positions_node_id_fkey; Type: FK CONSTRAINT; Schema: btc; Owner: -
+--
+
+ALTER TABLE ONLY btc.import_positions
+    ADD CONSTRAINT import_positions_node_id_fkey FOREIGN KEY (node_id) REFERENCES public.nodes(id) ON DELETE CASCADE;
+
+
 --
 -- Name: transaction_cids transaction_cids_header_id_fkey; Type: FK CONSTRAINT; Schema: btc; Owner: -
 --
@@ -917,6 +1007,14 @@ ALTER TABLE ONLY btc.tx_outputs
     ADD CONSTRAINT tx_outputs_tx_id_fkey FOREIGN KEY (tx_id) REFERENCES btc.transaction_cids(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
 
 
+--
+-- Name: contract_codes contract_codes_mh_key_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
+--
+
+ALTER TABLE ONLY eth.contract_codes
+    ADD CONSTRAINT contract_codes_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+
+
 --
 -- Name: header_cids header_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
 --
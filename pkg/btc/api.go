@@ -0,0 +1,419 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// APIName is the namespace for the watcher's btc api
+const APIName = "btc"
+
+// APIVersion is the version of the watcher's btc api
+const APIVersion = "0.0.1"
+
+// PublicBtcAPI serves a subset of bitcoind's read-only json-rpc calls from the indexed btc data
+type PublicBtcAPI struct {
+	B *Backend
+}
+
+// NewPublicBtcAPI creates a new PublicBtcAPI with the provided underlying Backend
+func NewPublicBtcAPI(b *Backend) *PublicBtcAPI {
+	return &PublicBtcAPI{
+		B: b,
+	}
+}
+
+// Verbosity is a verbosity argument, which bitcoind accepts as either a boolean or a number
+type Verbosity int
+
+// UnmarshalJSON parses a verbosity argument given as a boolean or a number
+func (v *Verbosity) UnmarshalJSON(input []byte) error {
+	switch string(input) {
+	case "true":
+		*v = 1
+	case "false":
+		*v = 0
+	default:
+		var n int
+		if err := json.Unmarshal(input, &n); err != nil {
+			return fmt.Errorf("verbosity must be a boolean or a number: %s", err.Error())
+		}
+		*v = Verbosity(n)
+	}
+	return nil
+}
+
+// GetBlockVerboseTxResult models the data returned by getblock at verbosity 2, where the transactions of the block
+// are decoded instead of listed by id
+type GetBlockVerboseTxResult struct {
+	btcjson.GetBlockVerboseResult
+	Tx []btcjson.TxRawResult `json:"tx"`
+}
+
+// rpcError is an error carrying one of bitcoind's json-rpc error codes
+type rpcError struct {
+	code    btcjson.RPCErrorCode
+	message string
+}
+
+func (e *rpcError) Error() string {
+	return e.message
+}
+
+// ErrorCode satisfies the rpc.Error interface so that the code is reported to the client
+func (e *rpcError) ErrorCode() int {
+	return int(e.code)
+}
+
+var (
+	errBlockNotFound = &rpcError{code: btcjson.ErrRPCBlockNotFound, message: "Block not found"}
+	errTxNotFound    = &rpcError{code: btcjson.ErrRPCNoTxInfo, message: "No such mempool or blockchain transaction"}
+	errHeightRange   = &rpcError{code: btcjson.ErrRPCInvalidParameter, message: "Block height out of range"}
+)
+
+// GetBlockCount returns the height of the most recent block
+func (pba *PublicBtcAPI) GetBlockCount() (int64, error) {
+	return pba.B.LastBlockNumber()
+}
+
+// GetBestBlockHash returns the hash of the most recent block
+func (pba *PublicBtcAPI) GetBestBlockHash() (string, error) {
+	height, err := pba.B.LastBlockNumber()
+	if err != nil {
+		return "", err
+	}
+	header, err := pba.B.HeaderCIDByNumber(height)
+	if err != nil {
+		return "", err
+	}
+	return header.BlockHash, nil
+}
+
+// GetBlockHash returns the hash of the block at the provided height
+func (pba *PublicBtcAPI) GetBlockHash(height int64) (string, error) {
+	header, err := pba.B.HeaderCIDByNumber(height)
+	if err == sql.ErrNoRows {
+		return "", errHeightRange
+	}
+	if err != nil {
+		return "", err
+	}
+	return header.BlockHash, nil
+}
+
+// GetBlockHeader returns the header of the block with the provided hash
+// If verbose is false the serialized header is returned as a hex string, otherwise (the default) it is returned as
+// a json object
+func (pba *PublicBtcAPI) GetBlockHeader(blockHash string, verbose *bool) (interface{}, error) {
+	hash, err := decodeHash("blockhash", blockHash)
+	if err != nil {
+		return nil, err
+	}
+	header, height, err := pba.B.HeaderByHash(*hash)
+	if err == sql.ErrNoRows {
+		return nil, errBlockNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if verbose != nil && !*verbose {
+		buf := bytes.NewBuffer(make([]byte, 0, wire.MaxBlockHeaderPayload))
+		if err := header.Serialize(buf); err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(buf.Bytes()), nil
+	}
+	confirmations, nextHash, err := pba.chainContext(*hash, height)
+	if err != nil {
+		return nil, err
+	}
+	return btcjson.GetBlockHeaderVerboseResult{
+		Hash:          hash.String(),
+		Confirmations: confirmations,
+		Height:        int32(height),
+		Version:       header.Version,
+		VersionHex:    fmt.Sprintf("%08x", header.Version),
+		MerkleRoot:    header.MerkleRoot.String(),
+		NextHash:      nextHash,
+		PreviousHash:  header.PrevBlock.String(),
+		Nonce:         uint64(header.Nonce),
+		Time:          header.Timestamp.Unix(),
+		Bits:          strconv.FormatInt(int64(header.Bits), 16),
+		Difficulty:    DifficultyRatio(header.Bits, pba.B.Params),
+	}, nil
+}
+
+// GetBlock returns the block with the provided hash
+// At verbosity 0 the serialized block is returned as a hex string, at verbosity 1 (the default) it is returned as a
+// json object listing the ids of its transactions, and at verbosity 2 the transactions are decoded as well
+func (pba *PublicBtcAPI) GetBlock(blockHash string, verbosity *Verbosity) (interface{}, error) {
+	hash, err := decodeHash("blockhash", blockHash)
+	if err != nil {
+		return nil, err
+	}
+	block, height, err := pba.B.BlockByHash(*hash)
+	if err == sql.ErrNoRows {
+		return nil, errBlockNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	level := Verbosity(1)
+	if verbosity != nil {
+		level = *verbosity
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, block.SerializeSize()))
+	if err := block.Serialize(buf); err != nil {
+		return nil, err
+	}
+	if level <= 0 {
+		return hex.EncodeToString(buf.Bytes()), nil
+	}
+
+	confirmations, nextHash, err := pba.chainContext(*hash, height)
+	if err != nil {
+		return nil, err
+	}
+	header := block.Header
+	result := btcjson.GetBlockVerboseResult{
+		Hash:          hash.String(),
+		Confirmations: confirmations,
+		StrippedSize:  int32(block.SerializeSizeStripped()),
+		Size:          int32(buf.Len()),
+		Weight:        int32(blockchain.GetBlockWeight(btcutil.NewBlock(block))),
+		Height:        height,
+		Version:       header.Version,
+		VersionHex:    fmt.Sprintf("%08x", header.Version),
+		MerkleRoot:    header.MerkleRoot.String(),
+		Time:          header.Timestamp.Unix(),
+		Nonce:         header.Nonce,
+		Bits:          strconv.FormatInt(int64(header.Bits), 16),
+		Difficulty:    DifficultyRatio(header.Bits, pba.B.Params),
+		PreviousHash:  header.PrevBlock.String(),
+		NextHash:      nextHash,
+	}
+	if level == 1 {
+		result.Tx = make([]string, len(block.Transactions))
+		for i, tx := range block.Transactions {
+			result.Tx[i] = tx.TxHash().String()
+		}
+		return result, nil
+	}
+	txs := make([]btcjson.TxRawResult, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		rawTx, err := pba.txRawResult(tx)
+		if err != nil {
+			return nil, err
+		}
+		txs[i] = *rawTx
+	}
+	return GetBlockVerboseTxResult{
+		GetBlockVerboseResult: result,
+		Tx:                    txs,
+	}, nil
+}
+
+// GetRawTransaction returns the transaction with the provided id
+// If verbose is false (the default) the serialized transaction is returned as a hex string, otherwise it is returned
+// as a json object
+// If a block hash is provided the transaction is only looked for in that block
+func (pba *PublicBtcAPI) GetRawTransaction(txid string, verbose *Verbosity, blockHash *string) (interface{}, error) {
+	txHash, err := decodeHash("txid", txid)
+	if err != nil {
+		return nil, err
+	}
+	var inBlock *chainhash.Hash
+	if blockHash != nil {
+		if inBlock, err = decodeHash("blockhash", *blockHash); err != nil {
+			return nil, err
+		}
+	}
+	tx, header, height, err := pba.B.GetTransaction(*txHash, inBlock)
+	if err == sql.ErrNoRows {
+		return nil, errTxNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if verbose == nil || *verbose <= 0 {
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(buf.Bytes()), nil
+	}
+	result, err := pba.txRawResult(tx)
+	if err != nil {
+		return nil, err
+	}
+	blkHash := header.BlockHash()
+	confirmations, _, err := pba.chainContext(blkHash, height)
+	if err != nil {
+		return nil, err
+	}
+	result.BlockHash = blkHash.String()
+	result.Confirmations = uint64(confirmations)
+	result.Time = header.Timestamp.Unix()
+	result.Blocktime = header.Timestamp.Unix()
+	return result, nil
+}
+
+// chainContext returns the number of confirmations of the block with the provided hash and height, and the hash of
+// the block that builds on it if there is one
+func (pba *PublicBtcAPI) chainContext(hash chainhash.Hash, height int64) (int64, string, error) {
+	last, err := pba.B.LastBlockNumber()
+	if err != nil {
+		return 0, "", err
+	}
+	nextHash, err := pba.B.NextBlockHash(hash)
+	if err != nil {
+		return 0, "", err
+	}
+	return 1 + last - height, nextHash, nil
+}
+
+// DifficultyRatio returns the proof-of-work difficulty of the provided compact target as a multiple of the minimum
+// difficulty of the provided network
+func DifficultyRatio(bits uint32, params *chaincfg.Params) float64 {
+	max := blockchain.CompactToBig(params.PowLimitBits)
+	target := blockchain.CompactToBig(bits)
+	difficulty := new(big.Rat).SetFrac(max, target)
+	diff, _ := strconv.ParseFloat(difficulty.FloatString(8), 64)
+	return diff
+}
+
+// txRawResult decodes the provided transaction into the json object returned by getrawtransaction
+// The fields describing the block the transaction is included in are left empty
+func (pba *PublicBtcAPI) txRawResult(tx *wire.MsgTx) (*btcjson.TxRawResult, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+	if err := tx.Serialize(buf); err != nil {
+		return nil, err
+	}
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return &btcjson.TxRawResult{
+		Hex:      hex.EncodeToString(buf.Bytes()),
+		Txid:     tx.TxHash().String(),
+		Hash:     tx.WitnessHash().String(),
+		Size:     int32(tx.SerializeSize()),
+		Vsize:    int32((weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor),
+		Weight:   int32(weight),
+		Version:  tx.Version,
+		LockTime: tx.LockTime,
+		Vin:      vinList(tx),
+		Vout:     pba.voutList(tx),
+	}, nil
+}
+
+// vinList returns the json objects describing the inputs of the provided transaction
+func vinList(tx *wire.MsgTx) []btcjson.Vin {
+	vins := make([]btcjson.Vin, len(tx.TxIn))
+	// Coinbase transactions only have a single input
+	if blockchain.IsCoinBaseTx(tx) {
+		txIn := tx.TxIn[0]
+		vins[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		vins[0].Sequence = txIn.Sequence
+		vins[0].Witness = witnessToHex(txIn.Witness)
+		return vins
+	}
+	for i, txIn := range tx.TxIn {
+		// The disassembly contains [error] inline if the script does not parse, so the error is ignored
+		asm, _ := txscript.DisasmString(txIn.SignatureScript)
+		vins[i].Txid = txIn.PreviousOutPoint.Hash.String()
+		vins[i].Vout = txIn.PreviousOutPoint.Index
+		vins[i].Sequence = txIn.Sequence
+		vins[i].ScriptSig = &btcjson.ScriptSig{
+			Asm: asm,
+			Hex: hex.EncodeToString(txIn.SignatureScript),
+		}
+		if tx.HasWitness() {
+			vins[i].Witness = witnessToHex(txIn.Witness)
+		}
+	}
+	return vins
+}
+
+// voutList returns the json objects describing the outputs of the provided transaction
+func (pba *PublicBtcAPI) voutList(tx *wire.MsgTx) []btcjson.Vout {
+	vouts := make([]btcjson.Vout, len(tx.TxOut))
+	for i, txOut := range tx.TxOut {
+		// The disassembly contains [error] inline if the script does not parse, so the error is ignored
+		asm, _ := txscript.DisasmString(txOut.PkScript)
+		// An error means the script could not be parsed, in which case there are no addresses to report
+		class, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript, pba.B.Params)
+		addresses := make([]string, len(addrs))
+		for j, addr := range addrs {
+			addresses[j] = addr.EncodeAddress()
+		}
+		vouts[i] = btcjson.Vout{
+			Value: btcutil.Amount(txOut.Value).ToBTC(),
+			N:     uint32(i),
+			ScriptPubKey: btcjson.ScriptPubKeyResult{
+				Asm:       asm,
+				Hex:       hex.EncodeToString(txOut.PkScript),
+				ReqSigs:   int32(reqSigs),
+				Type:      class.String(),
+				Addresses: addresses,
+			},
+		}
+	}
+	return vouts
+}
+
+// witnessToHex hex encodes the items of the provided witness
+func witnessToHex(witness wire.TxWitness) []string {
+	if len(witness) == 0 {
+		return nil
+	}
+	items := make([]string, len(witness))
+	for i, item := range witness {
+		items[i] = hex.EncodeToString(item)
+	}
+	return items
+}
+
+// decodeHash parses the named hash argument, reporting an invalid parameter error if it is malformed
+func decodeHash(name, str string) (*chainhash.Hash, error) {
+	if len(str) != 2*chainhash.HashSize {
+		return nil, &rpcError{
+			code:    btcjson.ErrRPCInvalidParameter,
+			message: fmt.Sprintf("%s must be of length %d (not %d)", name, 2*chainhash.HashSize, len(str)),
+		}
+	}
+	hash, err := chainhash.NewHashFromStr(str)
+	if err != nil {
+		return nil, &rpcError{
+			code:    btcjson.ErrRPCInvalidParameter,
+			message: fmt.Sprintf("%s must be hexadecimal string (not '%s')", name, str),
+		}
+	}
+	return hash, nil
+}
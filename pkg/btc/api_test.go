@@ -0,0 +1,190 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var (
+	blockHash = mocks.MockBlock.Header.BlockHash().String()
+	txID      = mocks.MockBlock.Transactions[1].TxHash().String()
+)
+
+var _ = Describe("API", func() {
+	var (
+		db  *postgres.DB
+		api *btc.PublicBtcAPI
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+		backend, err := btc.NewBtcBackend(db, &chaincfg.MainNetParams)
+		Expect(err).ToNot(HaveOccurred())
+		api = btc.NewPublicBtcAPI(backend)
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	Describe("GetBlockCount", func() {
+		It("Returns the height of the most recent block", func() {
+			count, err := api.GetBlockCount()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(mocks.MockBlockHeight))
+		})
+	})
+
+	Describe("GetBestBlockHash", func() {
+		It("Returns the hash of the most recent block", func() {
+			hash, err := api.GetBestBlockHash()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hash).To(Equal(blockHash))
+		})
+	})
+
+	Describe("GetBlockHash", func() {
+		It("Returns the hash of the block at the provided height", func() {
+			hash, err := api.GetBlockHash(mocks.MockBlockHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hash).To(Equal(blockHash))
+		})
+		It("Returns an error if there is no block at the provided height", func() {
+			_, err := api.GetBlockHash(mocks.MockBlockHeight + 1)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Block height out of range"))
+		})
+	})
+
+	Describe("GetBlockHeader", func() {
+		It("Returns the serialized header if verbose is false", func() {
+			verbose := false
+			header, err := api.GetBlockHeader(blockHash, &verbose)
+			Expect(err).ToNot(HaveOccurred())
+			buf := new(bytes.Buffer)
+			err = mocks.MockBlock.Header.Serialize(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header).To(Equal(hex.EncodeToString(buf.Bytes())))
+		})
+		It("Returns the decoded header by default", func() {
+			header, err := api.GetBlockHeader(blockHash, nil)
+			Expect(err).ToNot(HaveOccurred())
+			result, ok := header.(btcjson.GetBlockHeaderVerboseResult)
+			Expect(ok).To(BeTrue())
+			Expect(result.Hash).To(Equal(blockHash))
+			Expect(result.Height).To(Equal(int32(mocks.MockBlockHeight)))
+			Expect(result.Confirmations).To(Equal(int64(1)))
+			Expect(result.PreviousHash).To(Equal(mocks.MockBlock.Header.PrevBlock.String()))
+			Expect(result.MerkleRoot).To(Equal(mocks.MockBlock.Header.MerkleRoot.String()))
+			Expect(result.NextHash).To(BeEmpty())
+			Expect(result.Nonce).To(Equal(uint64(mocks.MockBlock.Header.Nonce)))
+			Expect(result.Time).To(Equal(mocks.MockBlock.Header.Timestamp.Unix()))
+			Expect(result.Bits).To(Equal("1b04864c"))
+			Expect(result.Difficulty).To(BeNumerically("~", 14484.1623, 0.0001))
+		})
+		It("Returns an error if the block is not found", func() {
+			_, err := api.GetBlockHeader(mocks.MockBlock.Header.PrevBlock.String(), nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Block not found"))
+		})
+	})
+
+	Describe("GetBlock", func() {
+		It("Returns the serialized block at verbosity 0", func() {
+			verbosity := btc.Verbosity(0)
+			block, err := api.GetBlock(blockHash, &verbosity)
+			Expect(err).ToNot(HaveOccurred())
+			buf := new(bytes.Buffer)
+			err = mocks.MockBlock.Serialize(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(block).To(Equal(hex.EncodeToString(buf.Bytes())))
+		})
+		It("Returns the block with its transaction ids by default", func() {
+			block, err := api.GetBlock(blockHash, nil)
+			Expect(err).ToNot(HaveOccurred())
+			result, ok := block.(btcjson.GetBlockVerboseResult)
+			Expect(ok).To(BeTrue())
+			Expect(result.Hash).To(Equal(blockHash))
+			Expect(result.Height).To(Equal(mocks.MockBlockHeight))
+			Expect(result.Size).To(Equal(int32(mocks.MockBlock.SerializeSize())))
+			Expect(result.Tx).To(Equal([]string{
+				mocks.MockBlock.Transactions[0].TxHash().String(),
+				mocks.MockBlock.Transactions[1].TxHash().String(),
+				mocks.MockBlock.Transactions[2].TxHash().String(),
+			}))
+		})
+		It("Returns the block with its decoded transactions at verbosity 2", func() {
+			verbosity := btc.Verbosity(2)
+			block, err := api.GetBlock(blockHash, &verbosity)
+			Expect(err).ToNot(HaveOccurred())
+			result, ok := block.(btc.GetBlockVerboseTxResult)
+			Expect(ok).To(BeTrue())
+			Expect(result.Hash).To(Equal(blockHash))
+			Expect(len(result.Tx)).To(Equal(3))
+			Expect(result.Tx[0].Vin[0].IsCoinBase()).To(BeTrue())
+			Expect(result.Tx[1].Txid).To(Equal(txID))
+			Expect(result.Tx[1].Vin[0].Txid).To(Equal(mocks.MockBlock.Transactions[1].TxIn[0].PreviousOutPoint.Hash.String()))
+			Expect(len(result.Tx[1].Vout)).To(Equal(len(mocks.MockBlock.Transactions[1].TxOut)))
+		})
+	})
+
+	Describe("GetRawTransaction", func() {
+		It("Returns the serialized transaction by default", func() {
+			tx, err := api.GetRawTransaction(txID, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			buf := new(bytes.Buffer)
+			err = mocks.MockBlock.Transactions[1].Serialize(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx).To(Equal(hex.EncodeToString(buf.Bytes())))
+		})
+		It("Returns the decoded transaction if verbose", func() {
+			verbose := btc.Verbosity(1)
+			tx, err := api.GetRawTransaction(txID, &verbose, nil)
+			Expect(err).ToNot(HaveOccurred())
+			result, ok := tx.(*btcjson.TxRawResult)
+			Expect(ok).To(BeTrue())
+			Expect(result.Txid).To(Equal(txID))
+			Expect(result.BlockHash).To(Equal(blockHash))
+			Expect(result.Confirmations).To(Equal(uint64(1)))
+			Expect(result.Blocktime).To(Equal(mocks.MockBlock.Header.Timestamp.Unix()))
+			for i, out := range mocks.MockBlock.Transactions[1].TxOut {
+				Expect(result.Vout[i].Value).To(Equal(btcutil.Amount(out.Value).ToBTC()))
+				Expect(result.Vout[i].ScriptPubKey.Hex).To(Equal(hex.EncodeToString(out.PkScript)))
+			}
+		})
+		It("Returns an error if the transaction is not found", func() {
+			_, err := api.GetRawTransaction(blockHash, nil, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("No such mempool or blockchain transaction"))
+		})
+	})
+})
@@ -0,0 +1,380 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lib/pq"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// medianTimeBlocks is the number of blocks whose timestamps are used to calculate the median time of a block
+const medianTimeBlocks = 11
+
+// Backend serves the btc api from the header and transaction cids and the IPLDs they reference
+type Backend struct {
+	Retriever *CIDRetriever
+	Fetcher   *IPLDPGFetcher
+	DB        *postgres.DB
+	Params    *chaincfg.Params
+}
+
+// NewBtcBackend creates a new Backend over the provided database for the network with the provided params
+func NewBtcBackend(db *postgres.DB, params *chaincfg.Params) (*Backend, error) {
+	return &Backend{
+		Retriever: NewCIDRetriever(db),
+		Fetcher:   NewIPLDPGFetcher(db),
+		DB:        db,
+		Params:    params,
+	}, nil
+}
+
+// LastBlockNumber returns the height of the highest block in the database
+func (b *Backend) LastBlockNumber() (int64, error) {
+	return b.Retriever.RetrieveLastBlockNumber()
+}
+
+// HeaderCIDByNumber returns the header cid at the provided height
+func (b *Backend) HeaderCIDByNumber(height int64) (HeaderModel, error) {
+	pgStr := `SELECT * FROM btc.header_cids
+			WHERE block_number = $1
+			ORDER BY times_validated DESC, id ASC
+			LIMIT 1`
+	var header HeaderModel
+	return header, b.DB.Get(&header, pgStr, height)
+}
+
+// HeaderCIDByHash returns the header cid for the provided block hash
+func (b *Backend) HeaderCIDByHash(hash chainhash.Hash) (HeaderModel, error) {
+	pgStr := `SELECT * FROM btc.header_cids
+			WHERE block_hash = $1`
+	var header HeaderModel
+	return header, b.DB.Get(&header, pgStr, hash.String())
+}
+
+// NextBlockHash returns the hash of the block that builds on the provided block
+// An empty string is returned if there is no such block in the database
+func (b *Backend) NextBlockHash(hash chainhash.Hash) (string, error) {
+	pgStr := `SELECT block_hash FROM btc.header_cids
+			WHERE parent_hash = $1
+			ORDER BY times_validated DESC, id ASC
+			LIMIT 1`
+	var next string
+	err := b.DB.Get(&next, pgStr, hash.String())
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return next, err
+}
+
+// HeaderByHash returns the decoded header for the provided block hash, along with its height
+func (b *Backend) HeaderByHash(hash chainhash.Hash) (*wire.BlockHeader, int64, error) {
+	headerCID, err := b.HeaderCIDByHash(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	height, err := strconv.ParseInt(headerCID.BlockNumber, 10, 64)
+	if err != nil {
+		return nil, 0, err
+	}
+	headerBytes, err := b.fetchIPLD(headerCID.MhKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := new(wire.BlockHeader)
+	if err := header.Deserialize(bytes.NewReader(headerBytes)); err != nil {
+		return nil, 0, err
+	}
+	return header, height, nil
+}
+
+// BlockByHash returns the decoded block for the provided block hash, along with its height
+func (b *Backend) BlockByHash(hash chainhash.Hash) (*wire.MsgBlock, int64, error) {
+	// Retrieve all the CIDs for the block
+	headerCID, txCIDs, err := b.Retriever.RetrieveBlockByHash(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	height, err := strconv.ParseInt(headerCID.BlockNumber, 10, 64)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Fetch and decode the header IPLD
+	headerIPLD, err := b.Fetcher.FetchHeader(tx, headerCID)
+	if err != nil {
+		return nil, 0, err
+	}
+	block := new(wire.MsgBlock)
+	if err := block.Header.Deserialize(bytes.NewReader(headerIPLD.Data)); err != nil {
+		return nil, 0, err
+	}
+	// Fetch and decode the transaction IPLDs
+	txIPLDs, err := b.Fetcher.FetchTrxs(tx, txCIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	block.Transactions = make([]*wire.MsgTx, len(txIPLDs))
+	for i, txIPLD := range txIPLDs {
+		block.Transactions[i] = new(wire.MsgTx)
+		if err := block.Transactions[i].Deserialize(bytes.NewReader(txIPLD.Data)); err != nil {
+			return nil, 0, err
+		}
+	}
+	return block, height, err
+}
+
+// GetTransaction returns the decoded transaction for the provided transaction hash, along with the header of the block
+// it was included in and that block's height
+// If a block hash is provided the transaction is only looked for in that block
+func (b *Backend) GetTransaction(txHash chainhash.Hash, blockHash *chainhash.Hash) (*wire.MsgTx, *wire.BlockHeader, int64, error) {
+	pgStr := `SELECT transaction_cids.mh_key, header_cids.block_hash
+			FROM btc.transaction_cids, btc.header_cids
+			WHERE transaction_cids.header_id = header_cids.id
+			AND transaction_cids.tx_hash = $1`
+	args := []interface{}{txHash.String()}
+	if blockHash != nil {
+		pgStr += ` AND header_cids.block_hash = $2`
+		args = append(args, blockHash.String())
+	}
+	pgStr += ` ORDER BY header_cids.times_validated DESC, header_cids.id ASC LIMIT 1`
+	var txCIDWithHeaderInfo struct {
+		MhKey     string `db:"mh_key"`
+		BlockHash string `db:"block_hash"`
+	}
+	if err := b.DB.Get(&txCIDWithHeaderInfo, pgStr, args...); err != nil {
+		return nil, nil, 0, err
+	}
+	txBytes, err := b.fetchIPLD(txCIDWithHeaderInfo.MhKey)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	msgTx := new(wire.MsgTx)
+	if err := msgTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, nil, 0, err
+	}
+	hash, err := chainhash.NewHashFromStr(txCIDWithHeaderInfo.BlockHash)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("invalid block hash %s: %s", txCIDWithHeaderInfo.BlockHash, err.Error())
+	}
+	header, height, err := b.HeaderByHash(*hash)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return msgTx, header, height, nil
+}
+
+// PrevOutputs returns the indexed outputs spent by the inputs of the provided transaction, keyed by outpoint
+// Outputs that have not been indexed are missing from the returned map
+func (b *Backend) PrevOutputs(msgTx *wire.MsgTx) (map[wire.OutPoint]TxOutput, error) {
+	hashes := make([]string, 0, len(msgTx.TxIn))
+	indexes := make([]int64, 0, len(msgTx.TxIn))
+	for _, txIn := range msgTx.TxIn {
+		hashes = append(hashes, txIn.PreviousOutPoint.Hash.String())
+		indexes = append(indexes, int64(txIn.PreviousOutPoint.Index))
+	}
+	pgStr := `SELECT transaction_cids.tx_hash, tx_outputs.*
+			FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids ON (tx_outputs.tx_id = transaction_cids.id)
+			INNER JOIN unnest($1::VARCHAR(66)[], $2::INTEGER[]) AS outpoints (tx_hash, index)
+			ON (transaction_cids.tx_hash = outpoints.tx_hash AND tx_outputs.index = outpoints.index)`
+	var outputs []struct {
+		TxHash string `db:"tx_hash"`
+		TxOutput
+	}
+	if err := b.DB.Select(&outputs, pgStr, pq.Array(hashes), pq.Array(indexes)); err != nil {
+		return nil, err
+	}
+	prevOuts := make(map[wire.OutPoint]TxOutput, len(outputs))
+	for _, output := range outputs {
+		hash, err := chainhash.NewHashFromStr(output.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		prevOuts[*wire.NewOutPoint(hash, uint32(output.Index))] = output.TxOutput
+	}
+	return prevOuts, nil
+}
+
+// addressTxIDsPgStr selects the ids of the transactions that fund or spend the outputs paying to the addresses in $1
+const addressTxIDsPgStr = `SELECT tx_outputs.tx_id FROM btc.tx_outputs
+			WHERE tx_outputs.addresses && $1::VARCHAR(66)[]
+			UNION
+			SELECT tx_inputs.tx_id FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids funding_txs ON (tx_outputs.tx_id = funding_txs.id)
+			INNER JOIN btc.tx_inputs ON (tx_inputs.outpoint_tx_hash = funding_txs.tx_hash AND tx_inputs.outpoint_index = tx_outputs.index)
+			WHERE tx_outputs.addresses && $1::VARCHAR(66)[]`
+
+// AddressStats returns the number and value of the outputs paying to the provided address, the number and value of
+// those which have been spent, and the number of transactions funding or spending them
+// Only the best header at each height is considered
+func (b *Backend) AddressStats(address string) (AddressStatsModel, error) {
+	spentPgStr := `EXISTS (` + bestSpendsPgStr + `)`
+	pgStr := `SELECT COUNT(*) AS funded_txo_count, COALESCE(SUM(tx_outputs.value), 0) AS funded_txo_sum,
+			COUNT(*) FILTER (WHERE ` + spentPgStr + `) AS spent_txo_count,
+			COALESCE(SUM(tx_outputs.value) FILTER (WHERE ` + spentPgStr + `), 0) AS spent_txo_sum,
+			(SELECT COUNT(*) FROM btc.transaction_cids address_txs
+			INNER JOIN btc.header_cids address_headers ON (address_txs.header_id = address_headers.id)
+			WHERE address_txs.id IN (` + addressTxIDsPgStr + `)
+			AND ` + bestHeaderPgStr("address_headers") + `) AS tx_count
+			FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids ON (tx_outputs.tx_id = transaction_cids.id)
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE tx_outputs.addresses && $1::VARCHAR(66)[]
+			AND ` + bestHeaderPgStr("header_cids")
+	var stats AddressStatsModel
+	return stats, b.DB.Get(&stats, pgStr, pq.Array([]string{address}))
+}
+
+// AddressTxs returns the hashes of the transactions funding or spending the outputs paying to the provided address,
+// newest first
+// If a last seen transaction hash is provided only the transactions that come before it in the chain are returned
+// Only the best header at each height is considered
+func (b *Backend) AddressTxs(address string, lastSeen *chainhash.Hash, limit int) ([]string, error) {
+	pgStr := `SELECT transaction_cids.tx_hash
+			FROM btc.transaction_cids
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE transaction_cids.id IN (` + addressTxIDsPgStr + `)
+			AND ` + bestHeaderPgStr("header_cids")
+	args := []interface{}{pq.Array([]string{address}), limit}
+	if lastSeen != nil {
+		// a transaction included in several competing blocks is positioned by the one validated the most times
+		pgStr += ` AND (header_cids.block_number, transaction_cids.index) < (SELECT last_seen_headers.block_number, last_seen_txs.index
+										FROM btc.transaction_cids last_seen_txs
+										INNER JOIN btc.header_cids last_seen_headers ON (last_seen_txs.header_id = last_seen_headers.id)
+										WHERE last_seen_txs.tx_hash = $3
+										ORDER BY last_seen_headers.times_validated DESC, last_seen_headers.id ASC
+										LIMIT 1)`
+		args = append(args, lastSeen.String())
+	}
+	pgStr += ` ORDER BY header_cids.block_number DESC, transaction_cids.index DESC LIMIT $2`
+	txHashes := make([]string, 0, limit)
+	return txHashes, b.DB.Select(&txHashes, pgStr, args...)
+}
+
+// scriptHashTxIDsPgStr selects the ids of the transactions that fund or spend the outputs with the script hash in $1
+const scriptHashTxIDsPgStr = `SELECT tx_outputs.tx_id FROM btc.tx_outputs
+			WHERE tx_outputs.script_hash = $1
+			UNION
+			SELECT tx_inputs.tx_id FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids funding_txs ON (tx_outputs.tx_id = funding_txs.id)
+			INNER JOIN btc.tx_inputs ON (tx_inputs.outpoint_tx_hash = funding_txs.tx_hash AND tx_inputs.outpoint_index = tx_outputs.index)
+			WHERE tx_outputs.script_hash = $1`
+
+// ScriptHashHistory returns the transactions funding or spending the outputs with the provided electrum script hash,
+// in the order they appear in the chain
+// Only the best header at each height is considered
+func (b *Backend) ScriptHashHistory(scriptHash string) ([]HistoryModel, error) {
+	pgStr := `SELECT transaction_cids.tx_hash, header_cids.block_number
+			FROM btc.transaction_cids
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE transaction_cids.id IN (` + scriptHashTxIDsPgStr + `)
+			AND ` + bestHeaderPgStr("header_cids") + `
+			ORDER BY header_cids.block_number, transaction_cids.index`
+	history := make([]HistoryModel, 0)
+	return history, b.DB.Select(&history, pgStr, scriptHash)
+}
+
+// ScriptHashBalance returns the total value of the unspent outputs with the provided electrum script hash
+// Only the best header at each height is considered
+func (b *Backend) ScriptHashBalance(scriptHash string) (int64, error) {
+	pgStr := `SELECT COALESCE(SUM(tx_outputs.value), 0) FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids ON (tx_outputs.tx_id = transaction_cids.id)
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE tx_outputs.script_hash = $1
+			AND ` + bestHeaderPgStr("header_cids") + `
+			AND NOT EXISTS (` + bestSpendsPgStr + `)`
+	var balance int64
+	return balance, b.DB.Get(&balance, pgStr, scriptHash)
+}
+
+// ScriptHashUTXOs returns the unspent outputs with the provided electrum script hash, in the order they appear in the
+// chain
+// Only the best header at each height is considered
+func (b *Backend) ScriptHashUTXOs(scriptHash string) ([]UTXOModel, error) {
+	pgStr := `SELECT transaction_cids.tx_hash, tx_outputs.index, tx_outputs.value, tx_outputs.pk_script, tx_outputs.script_class,
+			tx_outputs.required_sigs, tx_outputs.addresses, header_cids.block_number, header_cids.block_hash, header_cids.timestamp
+			FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids ON (tx_outputs.tx_id = transaction_cids.id)
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE tx_outputs.script_hash = $1
+			AND ` + bestHeaderPgStr("header_cids") + `
+			AND NOT EXISTS (` + bestSpendsPgStr + `)
+			ORDER BY header_cids.block_number, transaction_cids.index, tx_outputs.index`
+	utxos := make([]UTXOModel, 0)
+	return utxos, b.DB.Select(&utxos, pgStr, scriptHash)
+}
+
+// RawHeaderByNumber returns the serialized header of the block at the provided height
+func (b *Backend) RawHeaderByNumber(height int64) ([]byte, error) {
+	headerCID, err := b.HeaderCIDByNumber(height)
+	if err != nil {
+		return nil, err
+	}
+	return b.fetchIPLD(headerCID.MhKey)
+}
+
+// MedianTime returns the median timestamp, in seconds, of the block at the provided height and the ten blocks before it
+func (b *Backend) MedianTime(height int64) (int64, error) {
+	pgStr := `SELECT DISTINCT ON (block_number) timestamp
+			FROM btc.header_cids
+			WHERE block_number BETWEEN $1 AND $2
+			ORDER BY block_number, times_validated DESC, id ASC`
+	var timestamps []int64
+	if err := b.DB.Select(&timestamps, pgStr, height-medianTimeBlocks+1, height); err != nil {
+		return 0, err
+	}
+	if len(timestamps) == 0 {
+		return 0, sql.ErrNoRows
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return time.Unix(0, timestamps[len(timestamps)/2]).Unix(), nil
+}
+
+// fetchIPLD returns the raw data of the IPLD stored under the provided multihash key
+func (b *Backend) fetchIPLD(mhKey string) ([]byte, error) {
+	pgStr := `SELECT data FROM public.blocks WHERE key = $1`
+	var data []byte
+	return data, b.DB.Get(&data, pgStr, mhKey)
+}
@@ -0,0 +1,355 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+const (
+	// BlockFilePattern matches the names of the block files in a Bitcoin Core blocks directory
+	BlockFilePattern = "blk*.dat"
+	// BlockFileXORKeyName is the name of the file holding the key Bitcoin Core obfuscates its block files with
+	BlockFileXORKeyName = "xor.dat"
+
+	blockFrameSize  = 8  // the network magic and the size of the block, each a little-endian uint32
+	blockHeaderSize = 80 // the size of a serialized block header
+
+	// maxOpenBlockFiles is the number of block files kept open between reads; files being read are never closed, so
+	// concurrent reads can briefly hold more open
+	maxOpenBlockFiles = 8
+)
+
+// blockFileEntry locates a block within the block files
+type blockFileEntry struct {
+	file   int
+	offset int64 // offset of the serialized block, past its frame
+	size   uint32
+	prev   chainhash.Hash
+	bits   uint32
+}
+
+// blockFileHandle is an open block file along with the number of reads using it and when it was last used
+type blockFileHandle struct {
+	file     *os.File
+	reads    int
+	lastUsed uint64
+}
+
+// BlockFiles reads blocks directly from the blk*.dat files of a Bitcoin Core blocks directory
+// Bitcoin Core writes blocks as they are received, so they are not in height order and include stale blocks; the
+// files are indexed when opened and the chain with the most work is found by following the blocks' PrevBlock links
+// from the genesis block
+// Blocks are read by opening their file on demand, keeping the most recently used files open
+// Satisfies the shared.PayloadFetcher interface
+type BlockFiles struct {
+	params *chaincfg.Params
+	paths  []string
+	key    []byte // the obfuscation key, nil if the files are not obfuscated
+	index  map[chainhash.Hash]blockFileEntry
+	chain  []chainhash.Hash // the hashes of the best chain, by height
+
+	handlesLock sync.Mutex
+	handles     map[int]*blockFileHandle // the open block files, by index in paths
+	uses        uint64                   // the number of times a block file has been opened or reused
+}
+
+// OpenBlockFiles indexes the block files in the provided directory
+func OpenBlockFiles(dir string, params *chaincfg.Params) (*BlockFiles, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, BlockFilePattern))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no btc block files found in %s", dir)
+	}
+	sort.Strings(paths)
+	bf := &BlockFiles{
+		params:  params,
+		paths:   paths,
+		index:   make(map[chainhash.Hash]blockFileEntry),
+		handles: make(map[int]*blockFileHandle),
+	}
+	if bf.key, err = readXORKey(filepath.Join(dir, BlockFileXORKeyName)); err != nil {
+		return nil, err
+	}
+	for i := range paths {
+		if err := bf.scan(i); err != nil {
+			return nil, err
+		}
+	}
+	if err := bf.order(); err != nil {
+		return nil, err
+	}
+	logrus.Infof("indexed %d btc blocks in %d block files, best chain height is %d", len(bf.index), len(bf.paths), bf.Height())
+	return bf, nil
+}
+
+// Height returns the height of the tip of the best chain in the block files
+func (bf *BlockFiles) Height() int64 {
+	return int64(len(bf.chain) - 1)
+}
+
+// BlockHash returns the hash of the block at the provided height of the best chain in the block files
+func (bf *BlockFiles) BlockHash(height int64) (chainhash.Hash, error) {
+	if height < 0 || height > bf.Height() {
+		return chainhash.Hash{}, fmt.Errorf("btc block height %d is outside of the block files' chain of height %d", height, bf.Height())
+	}
+	return bf.chain[height], nil
+}
+
+// FetchAt reads the block payloads at the given block heights
+// It is safe for concurrent use
+func (bf *BlockFiles) FetchAt(blockHeights []uint64) ([]shared.RawChainData, error) {
+	blockPayloads := make([]shared.RawChainData, len(blockHeights))
+	for i, height := range blockHeights {
+		hash, err := bf.BlockHash(int64(height))
+		if err != nil {
+			return nil, err
+		}
+		block, err := bf.readBlock(bf.index[hash])
+		if err != nil {
+			return nil, fmt.Errorf("bitcoin BlockFiles read err at blockheight %d: %s", height, err.Error())
+		}
+		blockPayloads[i] = BlockPayload{
+			BlockHeight: int64(height),
+			Header:      &block.Header,
+			Txs:         msgTxsToUtilTxs(block.Transactions),
+		}
+	}
+	return blockPayloads, nil
+}
+
+// Close closes the block files that are kept open
+func (bf *BlockFiles) Close() error {
+	bf.handlesLock.Lock()
+	defer bf.handlesLock.Unlock()
+	var err error
+	for i, handle := range bf.handles {
+		if closeErr := handle.file.Close(); closeErr != nil {
+			err = closeErr
+		}
+		delete(bf.handles, i)
+	}
+	return err
+}
+
+// acquire returns the i-th block file, opening it if it is not open already
+// The file stays open until it is released
+func (bf *BlockFiles) acquire(i int) (*os.File, error) {
+	bf.handlesLock.Lock()
+	defer bf.handlesLock.Unlock()
+	bf.uses++
+	if handle, ok := bf.handles[i]; ok {
+		handle.reads++
+		handle.lastUsed = bf.uses
+		return handle.file, nil
+	}
+	file, err := os.Open(bf.paths[i])
+	if err != nil {
+		return nil, err
+	}
+	bf.handles[i] = &blockFileHandle{
+		file:     file,
+		reads:    1,
+		lastUsed: bf.uses,
+	}
+	return file, nil
+}
+
+// release marks a read of the i-th block file as done, closing the least recently used files which are not being read
+// while more than maxOpenBlockFiles are open
+func (bf *BlockFiles) release(i int) {
+	bf.handlesLock.Lock()
+	defer bf.handlesLock.Unlock()
+	bf.handles[i].reads--
+	for len(bf.handles) > maxOpenBlockFiles {
+		lru := -1
+		for j, handle := range bf.handles {
+			if handle.reads == 0 && (lru == -1 || handle.lastUsed < bf.handles[lru].lastUsed) {
+				lru = j
+			}
+		}
+		if lru == -1 {
+			return
+		}
+		if err := bf.handles[lru].file.Close(); err != nil {
+			logrus.Warnf("unable to close btc block file %s: %v", bf.paths[lru], err)
+		}
+		delete(bf.handles, lru)
+	}
+}
+
+// scan indexes the blocks in the i-th block file, stopping at the zeroed space Bitcoin Core preallocates at the end of
+// its files and at a block that has not been completely written yet
+func (bf *BlockFiles) scan(i int) error {
+	file, err := os.Open(bf.paths[i])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, blockFrameSize)
+	headerBytes := make([]byte, blockHeaderSize)
+	for offset := int64(0); offset+blockFrameSize <= info.Size(); {
+		if err := bf.readAt(file, frame, offset); err != nil {
+			return err
+		}
+		magic := binary.LittleEndian.Uint32(frame[:4])
+		if magic == 0 {
+			break
+		}
+		if wire.BitcoinNet(magic) != bf.params.Net {
+			return fmt.Errorf("btc block file %s has network magic %#x at offset %d, expected %#x", file.Name(), magic, offset, uint32(bf.params.Net))
+		}
+		size := binary.LittleEndian.Uint32(frame[4:])
+		if size < blockHeaderSize {
+			return fmt.Errorf("btc block file %s has a block of %d bytes at offset %d", file.Name(), size, offset)
+		}
+		if offset+blockFrameSize+int64(size) > info.Size() {
+			logrus.Warnf("btc block file %s ends with an incomplete block at offset %d", file.Name(), offset)
+			break
+		}
+		if err := bf.readAt(file, headerBytes, offset+blockFrameSize); err != nil {
+			return err
+		}
+		var header wire.BlockHeader
+		if err := header.Deserialize(bytes.NewReader(headerBytes)); err != nil {
+			return err
+		}
+		hash := header.BlockHash()
+		if _, ok := bf.index[hash]; !ok {
+			bf.index[hash] = blockFileEntry{
+				file:   i,
+				offset: offset + blockFrameSize,
+				size:   size,
+				prev:   header.PrevBlock,
+				bits:   header.Bits,
+			}
+		}
+		offset += blockFrameSize + int64(size)
+	}
+	return nil
+}
+
+// order finds the chain with the most work among the blocks that connect to the genesis block
+// When branches have equal work the one found first is kept
+func (bf *BlockFiles) order() error {
+	genesis := *bf.params.GenesisHash
+	if _, ok := bf.index[genesis]; !ok {
+		return fmt.Errorf("btc genesis block %s is not in the block files", genesis.String())
+	}
+	children := make(map[chainhash.Hash][]chainhash.Hash, len(bf.index))
+	for hash, entry := range bf.index {
+		if hash != genesis {
+			children[entry.prev] = append(children[entry.prev], hash)
+		}
+	}
+	type branch struct {
+		hash   chainhash.Hash
+		height int64
+		work   *big.Int
+	}
+	best := branch{hash: genesis, work: blockchain.CalcWork(bf.index[genesis].bits)}
+	stack := []branch{best}
+	for len(stack) > 0 {
+		tip := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if tip.work.Cmp(best.work) > 0 {
+			best = tip
+		}
+		for _, child := range children[tip.hash] {
+			work := new(big.Int).Add(tip.work, blockchain.CalcWork(bf.index[child].bits))
+			stack = append(stack, branch{hash: child, height: tip.height + 1, work: work})
+		}
+	}
+	bf.chain = make([]chainhash.Hash, best.height+1)
+	for hash, height := best.hash, best.height; height >= 0; height-- {
+		bf.chain[height] = hash
+		hash = bf.index[hash].prev
+	}
+	return nil
+}
+
+// readBlock reads and deserializes the block at the provided location
+func (bf *BlockFiles) readBlock(entry blockFileEntry) (*wire.MsgBlock, error) {
+	file, err := bf.acquire(entry.file)
+	if err != nil {
+		return nil, err
+	}
+	defer bf.release(entry.file)
+	blockBytes := make([]byte, entry.size)
+	if err := bf.readAt(file, blockBytes, entry.offset); err != nil {
+		return nil, err
+	}
+	block := new(wire.MsgBlock)
+	if err := block.Deserialize(bytes.NewReader(blockBytes)); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// readAt fills the buffer from the file at the provided offset, removing the obfuscation
+func (bf *BlockFiles) readAt(file *os.File, buf []byte, offset int64) error {
+	if _, err := file.ReadAt(buf, offset); err != nil {
+		return err
+	}
+	if bf.key != nil {
+		keyLen := int64(len(bf.key))
+		for i := range buf {
+			buf[i] ^= bf.key[(offset+int64(i))%keyLen]
+		}
+	}
+	return nil
+}
+
+// readXORKey reads the key Bitcoin Core obfuscates its block files with
+// It returns nil if the key file does not exist or the key is all zeros, in which case the files are not obfuscated
+func readXORKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range key {
+		if b != 0 {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
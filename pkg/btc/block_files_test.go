@@ -0,0 +1,154 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+)
+
+// blockFilesParams returns network parameters whose genesis block is the first block of the chain
+func blockFilesParams(chain []*wire.MsgBlock) *chaincfg.Params {
+	genesisHash := chain[0].BlockHash()
+	params := chaincfg.RegressionNetParams
+	params.GenesisHash = &genesisHash
+	return &params
+}
+
+var _ = Describe("BlockFiles", func() {
+	var (
+		dir    string
+		chain  []*wire.MsgBlock
+		params *chaincfg.Params
+	)
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "btc-block-files")
+		Expect(err).ToNot(HaveOccurred())
+		chain = mocks.ExtendWorkChain(nil, 6, 0)
+		params = blockFilesParams(chain)
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Orders out of order blocks by following their PrevBlock links", func() {
+		files := [][]*wire.MsgBlock{
+			{chain[0], chain[2], chain[1]},
+			{chain[5], chain[3], chain[4]},
+		}
+		Expect(mocks.WriteBlockFiles(dir, params.Net, files, nil)).To(Succeed())
+		blockFiles, err := btc.OpenBlockFiles(dir, params)
+		Expect(err).ToNot(HaveOccurred())
+		defer blockFiles.Close()
+		Expect(blockFiles.Height()).To(Equal(int64(5)))
+		for height, block := range chain {
+			hash, err := blockFiles.BlockHash(int64(height))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hash).To(Equal(block.BlockHash()))
+		}
+	})
+
+	It("Follows the branch with the most work and ignores blocks that do not connect", func() {
+		stale := mocks.ExtendWorkChain(chain[:3], 2, 100)
+		orphan := mocks.ExtendWorkChain([]*wire.MsgBlock{wire.NewMsgBlock(wire.NewBlockHeader(1, &chainhash.Hash{1}, &chainhash.Hash{}, mocks.RegtestBits, 0))}, 4, 200)
+		files := [][]*wire.MsgBlock{
+			append([]*wire.MsgBlock{chain[0], chain[1], chain[2], stale[3], stale[4]}, orphan...),
+			{chain[3], chain[4], chain[5]},
+		}
+		Expect(mocks.WriteBlockFiles(dir, params.Net, files, nil)).To(Succeed())
+		blockFiles, err := btc.OpenBlockFiles(dir, params)
+		Expect(err).ToNot(HaveOccurred())
+		defer blockFiles.Close()
+		Expect(blockFiles.Height()).To(Equal(int64(5)))
+		hash, err := blockFiles.BlockHash(3)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hash).To(Equal(chain[3].BlockHash()))
+	})
+
+	It("Reads the block payloads at the requested heights", func() {
+		Expect(mocks.WriteBlockFiles(dir, params.Net, [][]*wire.MsgBlock{chain}, nil)).To(Succeed())
+		blockFiles, err := btc.OpenBlockFiles(dir, params)
+		Expect(err).ToNot(HaveOccurred())
+		defer blockFiles.Close()
+		payloads, err := blockFiles.FetchAt([]uint64{2, 4})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(payloads)).To(Equal(2))
+		payload, ok := payloads[1].(btc.BlockPayload)
+		Expect(ok).To(BeTrue())
+		Expect(payload.BlockHeight).To(Equal(int64(4)))
+		Expect(payload.Header.BlockHash()).To(Equal(chain[4].BlockHash()))
+		Expect(len(payload.Txs)).To(Equal(len(mocks.MockTransactions)))
+		for i, tx := range payload.Txs {
+			Expect(tx.Hash()).To(Equal(mocks.MockTransactions[i].Hash()))
+			Expect(tx.Index()).To(Equal(i))
+		}
+		_, err = blockFiles.FetchAt([]uint64{6})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Reads blocks from more block files than it keeps open", func() {
+		chain = mocks.ExtendWorkChain(nil, 12, 0)
+		params = blockFilesParams(chain)
+		files := make([][]*wire.MsgBlock, len(chain))
+		for i, block := range chain {
+			files[i] = []*wire.MsgBlock{block}
+		}
+		Expect(mocks.WriteBlockFiles(dir, params.Net, files, nil)).To(Succeed())
+		blockFiles, err := btc.OpenBlockFiles(dir, params)
+		Expect(err).ToNot(HaveOccurred())
+		defer blockFiles.Close()
+		heights := []uint64{11, 0, 5, 10, 1, 2, 3, 4, 6, 7, 8, 9, 11, 0}
+		payloads, err := blockFiles.FetchAt(heights)
+		Expect(err).ToNot(HaveOccurred())
+		for i, height := range heights {
+			payload, ok := payloads[i].(btc.BlockPayload)
+			Expect(ok).To(BeTrue())
+			Expect(payload.Header.BlockHash()).To(Equal(chain[height].BlockHash()))
+		}
+	})
+
+	It("Reads obfuscated block files", func() {
+		key := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+		Expect(mocks.WriteBlockFiles(dir, params.Net, [][]*wire.MsgBlock{chain}, key)).To(Succeed())
+		blockFiles, err := btc.OpenBlockFiles(dir, params)
+		Expect(err).ToNot(HaveOccurred())
+		defer blockFiles.Close()
+		Expect(blockFiles.Height()).To(Equal(int64(5)))
+		payloads, err := blockFiles.FetchAt([]uint64{5})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(payloads[0].(btc.BlockPayload).Header.BlockHash()).To(Equal(chain[5].BlockHash()))
+	})
+
+	It("Fails for block files of another network or without the genesis block", func() {
+		Expect(mocks.WriteBlockFiles(dir, wire.MainNet, [][]*wire.MsgBlock{chain}, nil)).To(Succeed())
+		_, err := btc.OpenBlockFiles(dir, params)
+		Expect(err).To(HaveOccurred())
+		Expect(mocks.WriteBlockFiles(dir, params.Net, [][]*wire.MsgBlock{chain[1:]}, nil)).To(Succeed())
+		_, err = btc.OpenBlockFiles(dir, params)
+		Expect(err).To(HaveOccurred())
+	})
+})
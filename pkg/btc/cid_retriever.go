@@ -21,7 +21,8 @@ import (
 	"fmt"
 	"math/big"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
@@ -217,7 +218,7 @@ func (bcr *CIDRetriever) RetrieveGapsInData(validationLevel int) ([]shared.Gap,
 }
 
 // RetrieveBlockByHash returns all of the CIDs needed to compose an entire block, for a given block hash
-func (bcr *CIDRetriever) RetrieveBlockByHash(blockHash common.Hash) (HeaderModel, []TxModel, error) {
+func (bcr *CIDRetriever) RetrieveBlockByHash(blockHash chainhash.Hash) (HeaderModel, []TxModel, error) {
 	log.Debug("retrieving block cids for block hash ", blockHash.String())
 
 	// Begin new db tx
@@ -284,7 +285,7 @@ func (bcr *CIDRetriever) RetrieveBlockByNumber(blockNumber int64) (HeaderModel,
 }
 
 // RetrieveHeaderCIDByHash returns the header for the given block hash
-func (bcr *CIDRetriever) RetrieveHeaderCIDByHash(tx *sqlx.Tx, blockHash common.Hash) (HeaderModel, error) {
+func (bcr *CIDRetriever) RetrieveHeaderCIDByHash(tx *sqlx.Tx, blockHash chainhash.Hash) (HeaderModel, error) {
 	log.Debug("retrieving header cids for block hash ", blockHash.String())
 	pgStr := `SELECT * FROM btc.header_cids
 			WHERE block_hash = $1`
@@ -296,7 +297,80 @@ func (bcr *CIDRetriever) RetrieveHeaderCIDByHash(tx *sqlx.Tx, blockHash common.H
 func (bcr *CIDRetriever) RetrieveTxCIDsByHeaderID(tx *sqlx.Tx, headerID int64) ([]TxModel, error) {
 	log.Debug("retrieving tx cids for block id ", headerID)
 	pgStr := `SELECT * FROM btc.transaction_cids
-			WHERE header_id = $1`
+			WHERE header_id = $1
+			ORDER BY index`
 	var txCIDs []TxModel
 	return txCIDs, tx.Select(&txCIDs, pgStr, headerID)
 }
+
+// bestHeaderPgStr returns a condition that holds when the header with the provided alias is the best header at its
+// height, which like in HeaderCIDByNumber is the one validated the most times, so that blocks orphaned by a reorg are left out
+func bestHeaderPgStr(alias string) string {
+	return `NOT EXISTS (SELECT 1 FROM btc.header_cids better_headers
+			WHERE better_headers.block_number = ` + alias + `.block_number
+			AND (better_headers.times_validated > ` + alias + `.times_validated
+			OR (better_headers.times_validated = ` + alias + `.times_validated AND better_headers.id < ` + alias + `.id)))`
+}
+
+// bestSpendsPgStr selects the inputs spending the output in btc.tx_outputs of the transaction in btc.transaction_cids
+// which are included in the best header at their height, along with their transaction and block
+// Spends are found by outpoint when queried, so they do not depend on the order blocks were indexed in
+var bestSpendsPgStr = `SELECT spending_txs.tx_hash, tx_inputs.index, spending_headers.block_number, spending_headers.block_hash,
+			spending_headers.timestamp
+			FROM btc.tx_inputs
+			INNER JOIN btc.transaction_cids spending_txs ON (tx_inputs.tx_id = spending_txs.id)
+			INNER JOIN btc.header_cids spending_headers ON (spending_txs.header_id = spending_headers.id)
+			WHERE tx_inputs.outpoint_tx_hash = transaction_cids.tx_hash
+			AND tx_inputs.outpoint_index = tx_outputs.index
+			AND ` + bestHeaderPgStr("spending_headers")
+
+// RetrieveUTXOs returns the outputs that were unspent as of the provided block height
+// Outputs that cannot be spent (null data outputs) are not included, and only the best header at each height is considered
+// If addresses are provided only the outputs paying to at least one of them are returned
+func (bcr *CIDRetriever) RetrieveUTXOs(blockNumber int64, addresses []string) ([]UTXOModel, error) {
+	log.Debug("retrieving utxos at block ", blockNumber)
+	pgStr := `SELECT transaction_cids.tx_hash, tx_outputs.index, tx_outputs.value, tx_outputs.pk_script, tx_outputs.script_class,
+			tx_outputs.required_sigs, tx_outputs.addresses, header_cids.block_number, header_cids.block_hash, header_cids.timestamp
+			FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids ON (tx_outputs.tx_id = transaction_cids.id)
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE header_cids.block_number <= $1
+			AND tx_outputs.script_class != $2
+			AND ` + bestHeaderPgStr("header_cids") + `
+			AND NOT EXISTS (` + bestSpendsPgStr + ` AND spending_headers.block_number <= $1)`
+	args := []interface{}{blockNumber, txscript.NullDataTy}
+	if len(addresses) > 0 {
+		pgStr += ` AND tx_outputs.addresses && $3::VARCHAR(66)[]`
+		args = append(args, pq.Array(addresses))
+	}
+	pgStr += ` ORDER BY header_cids.block_number, transaction_cids.index, tx_outputs.index`
+	utxos := make([]UTXOModel, 0)
+	return utxos, bcr.db.Select(&utxos, pgStr, args...)
+}
+
+// outspendPgStr selects the outputs of a transaction along with the inputs spending them
+// Only the best header at each height is considered, for both the transaction and the ones spending its outputs
+var outspendPgStr = `SELECT tx_outputs.index, spends.tx_hash AS spending_tx_hash, spends.index AS spending_index,
+			spends.block_number, spends.block_hash, spends.timestamp
+			FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids ON (tx_outputs.tx_id = transaction_cids.id)
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			LEFT JOIN LATERAL (` + bestSpendsPgStr + ` LIMIT 1) AS spends ON TRUE
+			WHERE transaction_cids.tx_hash = $1
+			AND ` + bestHeaderPgStr("header_cids")
+
+// RetrieveOutspends returns the outputs of the provided transaction along with the inputs spending them, ordered by index
+func (bcr *CIDRetriever) RetrieveOutspends(txHash string) ([]OutspendModel, error) {
+	log.Debug("retrieving outspends for transaction ", txHash)
+	pgStr := outspendPgStr + ` ORDER BY tx_outputs.index`
+	outspends := make([]OutspendModel, 0)
+	return outspends, bcr.db.Select(&outspends, pgStr, txHash)
+}
+
+// RetrieveOutspend returns the output of the provided transaction at the provided index along with the input spending it
+func (bcr *CIDRetriever) RetrieveOutspend(txHash string, index int64) (OutspendModel, error) {
+	log.Debugf("retrieving outspend for output %d of transaction %s", index, txHash)
+	pgStr := outspendPgStr + ` AND tx_outputs.index = $2`
+	var outspend OutspendModel
+	return outspend, bcr.db.Get(&outspend, pgStr, txHash, index)
+}
@@ -0,0 +1,256 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"github.com/ethereum/go-ethereum/crypto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var (
+	// Block 1, spending the first output of tx1 in block 0
+	spendingTxModels = []btc.TxModelWithInsAndOuts{
+		{
+			Index:  0,
+			CID:    tx3CID.String(),
+			MhKey:  tx3MhKey,
+			TxHash: tx3Hash.String(),
+			SegWit: true,
+			TxInputs: []btc.TxInput{
+				{
+					Index:                 0,
+					TxWitness:             []string{"mockWitness"},
+					SignatureScript:       []byte{03},
+					PreviousOutPointIndex: 0,
+					PreviousOutPointHash:  tx1Hash.String(),
+				},
+			},
+			TxOutputs: []btc.TxOutput{
+				{
+					Index:        0,
+					Value:        49000000,
+					PkScript:     []byte{04},
+					ScriptClass:  0,
+					RequiredSigs: 1,
+				},
+			},
+		},
+	}
+	mockSpendingCIDPayload = &btc.CIDPayload{
+		HeaderCID:       headerModel2,
+		TransactionCIDs: spendingTxModels,
+	}
+	// A competing block at height 1
+	forkBlockHash = crypto.Keccak256Hash([]byte{00, 04})
+)
+
+var _ = Describe("Retriever", func() {
+	var (
+		db        *postgres.DB
+		repo      *btc.CIDIndexer
+		retriever *btc.CIDRetriever
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		repo = btc.NewCIDIndexer(db)
+		retriever = btc.NewCIDRetriever(db)
+		for _, key := range mhKeys {
+			_, err := db.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2)`, key, mockData)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	Describe("RetrieveOutspend", func() {
+		It("Links an output to the input spending it when the output is indexed first", func() {
+			err := repo.Index(mockCIDPayload1)
+			Expect(err).ToNot(HaveOccurred())
+			err = repo.Index(mockSpendingCIDPayload)
+			Expect(err).ToNot(HaveOccurred())
+
+			outspend, err := retriever.RetrieveOutspend(tx1Hash.String(), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outspend.SpendingTxHash.String).To(Equal(tx3Hash.String()))
+			Expect(outspend.SpendingIndex.Int64).To(Equal(int64(0)))
+			Expect(outspend.BlockNumber.Int64).To(Equal(int64(1)))
+			Expect(outspend.BlockHash.String).To(Equal(blockHash2.String()))
+		})
+		It("Links an output to the input spending it when the input is indexed first", func() {
+			err := repo.Index(mockSpendingCIDPayload)
+			Expect(err).ToNot(HaveOccurred())
+			err = repo.Index(mockCIDPayload1)
+			Expect(err).ToNot(HaveOccurred())
+
+			outspend, err := retriever.RetrieveOutspend(tx1Hash.String(), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outspend.SpendingTxHash.String).To(Equal(tx3Hash.String()))
+			Expect(outspend.BlockNumber.Int64).To(Equal(int64(1)))
+		})
+		It("Reports unspent outputs", func() {
+			err := repo.Index(mockCIDPayload1)
+			Expect(err).ToNot(HaveOccurred())
+
+			outspends, err := retriever.RetrieveOutspends(tx1Hash.String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(outspends)).To(Equal(1))
+			Expect(outspends[0].Index).To(Equal(int64(0)))
+			Expect(outspends[0].SpendingTxHash.Valid).To(BeFalse())
+		})
+		It("Unlinks the output when the spending block is cleaned", func() {
+			err := repo.Index(mockCIDPayload1)
+			Expect(err).ToNot(HaveOccurred())
+			err = repo.Index(mockSpendingCIDPayload)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = btc.NewCleaner(db).Clean([][2]uint64{{1, 1}}, shared.Full)
+			Expect(err).ToNot(HaveOccurred())
+			outspend, err := retriever.RetrieveOutspend(tx1Hash.String(), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outspend.SpendingTxHash.Valid).To(BeFalse())
+
+			// re-indexing the block restores the link
+			_, err = db.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2), ($3, $4)`, headerMhKey2, mockData, tx3MhKey, mockData)
+			Expect(err).ToNot(HaveOccurred())
+			err = repo.Index(mockSpendingCIDPayload)
+			Expect(err).ToNot(HaveOccurred())
+			outspend, err = retriever.RetrieveOutspend(tx1Hash.String(), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outspend.SpendingTxHash.String).To(Equal(tx3Hash.String()))
+		})
+		It("Reports each output once, with its spend in the best block, when transactions are included in competing blocks", func() {
+			err := repo.Index(mockCIDPayload1)
+			Expect(err).ToNot(HaveOccurred())
+			err = repo.Index(mockSpendingCIDPayload)
+			Expect(err).ToNot(HaveOccurred())
+			forkHeader := headerModel2
+			forkHeader.BlockHash = forkBlockHash.String()
+			err = repo.Index(&btc.CIDPayload{HeaderCID: forkHeader, TransactionCIDs: spendingTxModels})
+			Expect(err).ToNot(HaveOccurred())
+
+			outspends, err := retriever.RetrieveOutspends(tx1Hash.String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(outspends)).To(Equal(1))
+			Expect(outspends[0].SpendingTxHash.String).To(Equal(tx3Hash.String()))
+			Expect(outspends[0].BlockHash.String).To(Equal(blockHash2.String()))
+			outspends, err = retriever.RetrieveOutspends(tx3Hash.String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(outspends)).To(Equal(1))
+
+			_, err = db.Exec(`UPDATE btc.header_cids SET times_validated = times_validated + 1 WHERE block_hash = $1`, forkBlockHash.String())
+			Expect(err).ToNot(HaveOccurred())
+			outspends, err = retriever.RetrieveOutspends(tx1Hash.String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(outspends)).To(Equal(1))
+			Expect(outspends[0].BlockHash.String).To(Equal(forkBlockHash.String()))
+		})
+		It("Reports outputs spent only in a block orphaned by a fork as unspent", func() {
+			err := repo.Index(mockCIDPayload1)
+			Expect(err).ToNot(HaveOccurred())
+			err = repo.Index(&btc.CIDPayload{HeaderCID: headerModel2})
+			Expect(err).ToNot(HaveOccurred())
+			forkHeader := headerModel2
+			forkHeader.BlockHash = forkBlockHash.String()
+			err = repo.Index(&btc.CIDPayload{HeaderCID: forkHeader, TransactionCIDs: spendingTxModels})
+			Expect(err).ToNot(HaveOccurred())
+
+			outspend, err := retriever.RetrieveOutspend(tx1Hash.String(), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(outspend.SpendingTxHash.Valid).To(BeFalse())
+		})
+	})
+
+	Describe("RetrieveUTXOs", func() {
+		BeforeEach(func() {
+			err := repo.Index(mockCIDPayload1)
+			Expect(err).ToNot(HaveOccurred())
+			err = repo.Index(mockSpendingCIDPayload)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("Returns the outputs unspent as of the provided height", func() {
+			utxos, err := retriever.RetrieveUTXOs(0, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(utxos)).To(Equal(1))
+			Expect(utxos[0].TxHash).To(Equal(tx1Hash.String()))
+			Expect(utxos[0].Value).To(Equal(int64(50000000)))
+			Expect(utxos[0].BlockNumber).To(Equal(int64(0)))
+
+			utxos, err = retriever.RetrieveUTXOs(1, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(utxos)).To(Equal(1))
+			Expect(utxos[0].TxHash).To(Equal(tx3Hash.String()))
+			Expect(utxos[0].Value).To(Equal(int64(49000000)))
+			Expect(utxos[0].BlockHash).To(Equal(blockHash2.String()))
+		})
+		It("Ignores the outputs and spends in blocks orphaned by a fork", func() {
+			forkHeader := headerModel2
+			forkHeader.BlockHash = forkBlockHash.String()
+			err := repo.Index(&btc.CIDPayload{HeaderCID: forkHeader})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = db.Exec(`UPDATE btc.header_cids SET times_validated = times_validated + 1 WHERE block_hash = $1`, forkBlockHash.String())
+			Expect(err).ToNot(HaveOccurred())
+
+			utxos, err := retriever.RetrieveUTXOs(1, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(utxos)).To(Equal(1))
+			Expect(utxos[0].TxHash).To(Equal(tx1Hash.String()))
+		})
+		It("Counts the outputs and spends of a transaction included in competing blocks in the best block only", func() {
+			forkHeader := headerModel2
+			forkHeader.BlockHash = forkBlockHash.String()
+			err := repo.Index(&btc.CIDPayload{HeaderCID: forkHeader, TransactionCIDs: spendingTxModels})
+			Expect(err).ToNot(HaveOccurred())
+
+			utxos, err := retriever.RetrieveUTXOs(1, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(utxos)).To(Equal(1))
+			Expect(utxos[0].TxHash).To(Equal(tx3Hash.String()))
+			Expect(utxos[0].BlockHash).To(Equal(blockHash2.String()))
+
+			_, err = db.Exec(`UPDATE btc.header_cids SET times_validated = times_validated + 1 WHERE block_hash = $1`, forkBlockHash.String())
+			Expect(err).ToNot(HaveOccurred())
+			utxos, err = retriever.RetrieveUTXOs(1, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(utxos)).To(Equal(1))
+			Expect(utxos[0].TxHash).To(Equal(tx3Hash.String()))
+			Expect(utxos[0].BlockHash).To(Equal(forkBlockHash.String()))
+		})
+		It("Ignores spends that are only included in a block orphaned by a fork", func() {
+			forkHeader := headerModel2
+			forkHeader.BlockHash = forkBlockHash.String()
+			err := repo.Index(&btc.CIDPayload{HeaderCID: forkHeader, TransactionCIDs: spendingTxModels})
+			Expect(err).ToNot(HaveOccurred())
+			// the best block at height 1 no longer includes the spending transaction
+			_, err = db.Exec(`DELETE FROM btc.transaction_cids USING btc.header_cids
+							WHERE transaction_cids.header_id = header_cids.id AND header_cids.block_hash = $1`, blockHash2.String())
+			Expect(err).ToNot(HaveOccurred())
+
+			utxos, err := retriever.RetrieveUTXOs(1, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(utxos)).To(Equal(1))
+			Expect(utxos[0].TxHash).To(Equal(tx1Hash.String()))
+		})
+	})
+})
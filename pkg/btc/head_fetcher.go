@@ -0,0 +1,42 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"github.com/btcsuite/btcd/rpcclient"
+)
+
+// HeadFetcher satisfies the HeadFetcher interface for bitcoin
+type HeadFetcher struct {
+	client *rpcclient.Client
+}
+
+// NewHeadFetcher returns a HeadFetcher
+func NewHeadFetcher(c *rpcclient.ConnConfig) (*HeadFetcher, error) {
+	client, err := rpcclient.New(c, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &HeadFetcher{
+		client: client,
+	}, nil
+}
+
+// FetchHead fetches the height of the upstream node's best chain
+func (fetcher *HeadFetcher) FetchHead() (int64, error) {
+	return fetcher.client.GetBlockCount()
+}
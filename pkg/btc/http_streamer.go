@@ -17,85 +17,226 @@
 package btc
 
 import (
-	"bytes"
+	"database/sql"
+	"errors"
+	"sync"
 	"time"
 
-	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 )
 
+const (
+	// DefaultPollInterval is how often the HTTPPayloadStreamer polls bitcoind for new blocks
+	DefaultPollInterval = 5 * time.Second
+	// maxReorgDepth is the number of recently streamed block hashes the HTTPPayloadStreamer keeps to find fork points
+	maxReorgDepth = 100
+)
+
+// errStreamerStopped is returned when the HTTPPayloadStreamer is unsubscribed from while streaming blocks
+var errStreamerStopped = errors.New("btc http streamer stopped")
+
+// BlockClient is the subset of the bitcoind rpc client used by the HTTPPayloadStreamer
+type BlockClient interface {
+	GetBlockCount() (int64, error)
+	GetBlockHash(blockHeight int64) (*chainhash.Hash, error)
+	GetBlockHeader(blockHash *chainhash.Hash) (*wire.BlockHeader, error)
+	GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error)
+	Shutdown()
+}
+
+// IndexedChain reports the blocks which have been indexed, from which the HTTPPayloadStreamer resumes streaming
+type IndexedChain interface {
+	// IndexedHead returns the height and hash of the best block at the highest indexed height, or sql.ErrNoRows if none
+	IndexedHead() (int64, string, error)
+	// IndexedHash returns the hash of the best block indexed at the provided height, or sql.ErrNoRows if there is none
+	IndexedHash(height int64) (string, error)
+}
+
 // HTTPPayloadStreamer satisfies the PayloadStreamer interface for bitcoin over http endpoints (since bitcoin core doesn't support websockets)
+// It polls bitcoind and streams every block from the last one it streamed up to the tip; when a new block does not
+// build on the last one streamed, it walks the new chain back by parent hash to the fork point and streams the
+// replacement blocks from there
 type HTTPPayloadStreamer struct {
-	Config   *rpcclient.ConnConfig
-	lastHash []byte
+	client       BlockClient
+	indexed      IndexedChain
+	PollInterval time.Duration
+
+	height int64            // the height of the last block streamed
+	hash   string           // the hash of the last block streamed, empty if unknown
+	recent map[int64]string // the hashes of the recently streamed blocks, by height
 }
 
 // NewHTTPPayloadStreamer creates a pointer to a new PayloadStreamer which satisfies the PayloadStreamer interface for bitcoin
-func NewHTTPPayloadStreamer(clientConfig *rpcclient.ConnConfig) *HTTPPayloadStreamer {
+func NewHTTPPayloadStreamer(client BlockClient, indexed IndexedChain) *HTTPPayloadStreamer {
 	return &HTTPPayloadStreamer{
-		Config: clientConfig,
+		client:       client,
+		indexed:      indexed,
+		PollInterval: DefaultPollInterval,
+		recent:       make(map[int64]string),
 	}
 }
 
 // Stream is the main loop for subscribing to data from the btc block notifications
 // Satisfies the shared.PayloadStreamer interface
+// Streaming resumes after the indexed head, so that blocks which were streamed but not yet indexed when the watcher
+// stopped are streamed again; if nothing has been indexed, it starts at the current tip
 func (ps *HTTPPayloadStreamer) Stream(payloadChan chan shared.RawChainData) (shared.ClientSubscription, error) {
 	logrus.Debug("streaming block payloads from btc")
-	client, err := rpcclient.New(ps.Config, nil)
-	if err != nil {
+	height, hash, err := ps.indexed.IndexedHead()
+	switch err {
+	case nil:
+		ps.height, ps.hash = height, hash
+		ps.recent[height] = hash
+		logrus.Infof("resuming btc block stream after the indexed block %s at height %d", hash, height)
+	case sql.ErrNoRows:
+		tip, err := ps.client.GetBlockCount()
+		if err != nil {
+			return nil, err
+		}
+		ps.height = tip - 1
+	default:
 		return nil, err
 	}
-	ticker := time.NewTicker(time.Second * 5)
-	errChan := make(chan error)
-	go func() {
-		for {
-			// start at
+	sub := &HTTPClientSubscription{
+		client:  ps.client,
+		errChan: make(chan error),
+		quit:    make(chan struct{}),
+	}
+	go ps.poll(payloadChan, sub)
+	return sub, nil
+}
+
+// poll streams the new blocks each poll interval until the subscription is closed
+func (ps *HTTPPayloadStreamer) poll(payloadChan chan shared.RawChainData, sub *HTTPClientSubscription) {
+	ticker := time.NewTicker(ps.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := ps.streamNewBlocks(payloadChan, sub.quit); err != nil {
+			if err == errStreamerStopped {
+				return
+			}
 			select {
-			case <-ticker.C:
-				height, err := client.GetBlockCount()
-				if err != nil {
-					errChan <- err
-					continue
-				}
-				blockHash, err := client.GetBlockHash(height)
-				if err != nil {
-					errChan <- err
-					continue
-				}
-				blockHashBytes := blockHash.CloneBytes()
-				if bytes.Equal(blockHashBytes, ps.lastHash) {
-					continue
-				}
-				block, err := client.GetBlock(blockHash)
-				if err != nil {
-					errChan <- err
-					continue
-				}
-				ps.lastHash = blockHashBytes
-				payloadChan <- BlockPayload{
-					Header:      &block.Header,
-					BlockHeight: height,
-					Txs:         msgTxsToUtilTxs(block.Transactions),
-				}
-			default:
+			case sub.errChan <- err:
+			case <-sub.quit:
+				return
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-sub.quit:
+			return
+		}
+	}
+}
+
+// streamNewBlocks streams the blocks after the last one streamed up to the current tip, rewinding to the fork point
+// whenever a block does not build on the last one streamed
+func (ps *HTTPPayloadStreamer) streamNewBlocks(payloadChan chan shared.RawChainData, quit <-chan struct{}) error {
+	tip, err := ps.client.GetBlockCount()
+	if err != nil {
+		return err
+	}
+	for ps.height < tip {
+		height := ps.height + 1
+		blockHash, err := ps.client.GetBlockHash(height)
+		if err != nil {
+			return err
+		}
+		block, err := ps.client.GetBlock(blockHash)
+		if err != nil {
+			return err
+		}
+		if ps.hash != "" && block.Header.PrevBlock.String() != ps.hash {
+			forkHeight, err := ps.findForkPoint(height-1, block.Header.PrevBlock)
+			if err != nil {
+				return err
+			}
+			logrus.Infof("btc reorg detected at height %d, streaming replacement blocks from height %d", height, forkHeight+1)
+			ps.rewind(forkHeight)
+			continue
+		}
+		select {
+		case payloadChan <- BlockPayload{
+			Header:      &block.Header,
+			BlockHeight: height,
+			Txs:         msgTxsToUtilTxs(block.Transactions),
+		}:
+		case <-quit:
+			return errStreamerStopped
+		}
+		ps.advance(height, blockHash.String())
+	}
+	return nil
+}
+
+// findForkPoint walks the new chain back by parent hash, starting from the block with the provided hash at the
+// provided height, to the last block it has in common with the streamed blocks, and returns that block's height
+// Heights the streamer does not remember, such as those streamed before a restart, are compared against the indexed
+// blocks; if the walk goes past those too, the height below the first block it cannot compare is returned so that
+// block is streamed again
+func (ps *HTTPPayloadStreamer) findForkPoint(height int64, hash chainhash.Hash) (int64, error) {
+	for {
+		streamed, ok := ps.recent[height]
+		if !ok {
+			indexed, err := ps.indexed.IndexedHash(height)
+			if err == sql.ErrNoRows {
+				logrus.Warnf("btc reorg fork point is older than the blocks the streamer remembers or has indexed")
+				return height - 1, nil
 			}
+			if err != nil {
+				return 0, err
+			}
+			streamed = indexed
+		}
+		if streamed == hash.String() {
+			// remember the fork point so that the replacement blocks are checked against it
+			ps.recent[height] = streamed
+			return height, nil
+		}
+		header, err := ps.client.GetBlockHeader(&hash)
+		if err != nil {
+			return 0, err
+		}
+		height, hash = height-1, header.PrevBlock
+	}
+}
+
+// advance records the block as the last one streamed
+func (ps *HTTPPayloadStreamer) advance(height int64, hash string) {
+	ps.height, ps.hash = height, hash
+	ps.recent[height] = hash
+	delete(ps.recent, height-maxReorgDepth)
+}
+
+// rewind makes the block at the provided height the last one streamed, forgetting the ones above it
+func (ps *HTTPPayloadStreamer) rewind(height int64) {
+	for h := range ps.recent {
+		if h > height {
+			delete(ps.recent, h)
 		}
-	}()
-	return &HTTPClientSubscription{client: client, errChan: errChan}, nil
+	}
+	ps.height, ps.hash = height, ps.recent[height]
 }
 
 // HTTPClientSubscription is a wrapper around the underlying bitcoind rpc client
 // to fit the shared.ClientSubscription interface
 type HTTPClientSubscription struct {
-	client  *rpcclient.Client
+	client  BlockClient
 	errChan chan error
+	quit    chan struct{}
+	once    sync.Once
 }
 
 // Unsubscribe satisfies the rpc.Subscription interface
 func (bcs *HTTPClientSubscription) Unsubscribe() {
-	bcs.client.Shutdown()
+	bcs.once.Do(func() {
+		close(bcs.quit)
+		bcs.client.Shutdown()
+	})
 }
 
 // Err() satisfies the rpc.Subscription interface
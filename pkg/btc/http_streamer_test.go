@@ -0,0 +1,166 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// extendChain returns the chain with n blocks appended, whose nonces distinguish them from blocks of other branches
+func extendChain(chain []*wire.MsgBlock, n int, nonce uint32) []*wire.MsgBlock {
+	extended := append([]*wire.MsgBlock{}, chain...)
+	for i := 0; i < n; i++ {
+		var prevHash chainhash.Hash
+		if len(extended) > 0 {
+			prevHash = extended[len(extended)-1].BlockHash()
+		}
+		header := wire.NewBlockHeader(1, &prevHash, &chainhash.Hash{}, 0, nonce)
+		extended = append(extended, wire.NewMsgBlock(header))
+	}
+	return extended
+}
+
+// receiveBlocks receives the next n payloads from the channel and returns their heights and hashes
+func receiveBlocks(payloadChan chan shared.RawChainData, n int) ([]int64, []chainhash.Hash) {
+	heights := make([]int64, 0, n)
+	hashes := make([]chainhash.Hash, 0, n)
+	for i := 0; i < n; i++ {
+		var data shared.RawChainData
+		Eventually(payloadChan, time.Second).Should(Receive(&data))
+		payload, ok := data.(btc.BlockPayload)
+		Expect(ok).To(BeTrue())
+		heights = append(heights, payload.BlockHeight)
+		hashes = append(hashes, payload.Header.BlockHash())
+	}
+	return heights, hashes
+}
+
+var _ = Describe("HTTPPayloadStreamer", func() {
+	var (
+		chain       []*wire.MsgBlock
+		client      *mocks.BlockClient
+		indexed     *mocks.IndexedChain
+		streamer    *btc.HTTPPayloadStreamer
+		payloadChan chan shared.RawChainData
+		sub         shared.ClientSubscription
+	)
+	BeforeEach(func() {
+		chain = extendChain(nil, 6, 0)
+		client = mocks.NewBlockClient(chain)
+		indexed = &mocks.IndexedChain{Indexed: make(map[int64]string)}
+		streamer = btc.NewHTTPPayloadStreamer(client, indexed)
+		streamer.PollInterval = 10 * time.Millisecond
+		payloadChan = make(chan shared.RawChainData, btc.PayloadChanBufferSize)
+	})
+	AfterEach(func() {
+		if sub != nil {
+			sub.Unsubscribe()
+		}
+	})
+
+	It("Starts at the tip and streams every block that arrives after it", func() {
+		var err error
+		sub, err = streamer.Stream(payloadChan)
+		Expect(err).ToNot(HaveOccurred())
+		heights, hashes := receiveBlocks(payloadChan, 1)
+		Expect(heights).To(Equal([]int64{5}))
+		Expect(hashes[0]).To(Equal(chain[5].BlockHash()))
+
+		chain = extendChain(chain, 3, 0)
+		client.SetChain(chain)
+		heights, hashes = receiveBlocks(payloadChan, 3)
+		Expect(heights).To(Equal([]int64{6, 7, 8}))
+		Expect(hashes).To(Equal([]chainhash.Hash{chain[6].BlockHash(), chain[7].BlockHash(), chain[8].BlockHash()}))
+		Consistently(payloadChan, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("Resumes after the indexed head, streaming the blocks which were not indexed again", func() {
+		for i, block := range chain[:3] {
+			indexed.Indexed[int64(i)] = block.BlockHash().String()
+		}
+		var err error
+		sub, err = streamer.Stream(payloadChan)
+		Expect(err).ToNot(HaveOccurred())
+		heights, _ := receiveBlocks(payloadChan, 3)
+		Expect(heights).To(Equal([]int64{3, 4, 5}))
+	})
+
+	It("Finds the fork point of a reorg and streams the replacement blocks", func() {
+		var err error
+		sub, err = streamer.Stream(payloadChan)
+		Expect(err).ToNot(HaveOccurred())
+		receiveBlocks(payloadChan, 1)
+		chain = extendChain(chain, 2, 0)
+		client.SetChain(chain)
+		receiveBlocks(payloadChan, 2)
+
+		// replace the blocks above height 5 with a longer branch
+		fork := extendChain(chain[:6], 4, 1)
+		client.SetChain(fork)
+		heights, hashes := receiveBlocks(payloadChan, 4)
+		Expect(heights).To(Equal([]int64{6, 7, 8, 9}))
+		Expect(hashes).To(Equal([]chainhash.Hash{fork[6].BlockHash(), fork[7].BlockHash(), fork[8].BlockHash(), fork[9].BlockHash()}))
+		Consistently(payloadChan, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("Streams the blocks it cannot compare again when the fork point is older than the blocks it remembers", func() {
+		indexed.Indexed[5] = chain[5].BlockHash().String()
+		fork := extendChain(chain[:3], 4, 1)
+		client.SetChain(fork)
+		var err error
+		sub, err = streamer.Stream(payloadChan)
+		Expect(err).ToNot(HaveOccurred())
+		heights, hashes := receiveBlocks(payloadChan, 3)
+		Expect(heights).To(Equal([]int64{4, 5, 6}))
+		Expect(hashes).To(Equal([]chainhash.Hash{fork[4].BlockHash(), fork[5].BlockHash(), fork[6].BlockHash()}))
+	})
+
+	It("Finds the fork point among the indexed blocks when it is older than the blocks it remembers", func() {
+		for i, block := range chain[:6] {
+			indexed.Indexed[int64(i)] = block.BlockHash().String()
+		}
+		fork := extendChain(chain[:2], 5, 1)
+		client.SetChain(fork)
+		var err error
+		sub, err = streamer.Stream(payloadChan)
+		Expect(err).ToNot(HaveOccurred())
+		heights, hashes := receiveBlocks(payloadChan, 5)
+		Expect(heights).To(Equal([]int64{2, 3, 4, 5, 6}))
+		Expect(hashes[0]).To(Equal(fork[2].BlockHash()))
+		Expect(hashes[4]).To(Equal(fork[6].BlockHash()))
+		Consistently(payloadChan, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("Stops streaming when unsubscribed", func() {
+		var err error
+		sub, err = streamer.Stream(payloadChan)
+		Expect(err).ToNot(HaveOccurred())
+		receiveBlocks(payloadChan, 1)
+		sub.Unsubscribe()
+		client.SetChain(extendChain(chain, 1, 0))
+		Consistently(payloadChan, 50*time.Millisecond).ShouldNot(Receive())
+	})
+})
@@ -17,6 +17,8 @@
 package btc
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 
 	"github.com/sirupsen/logrus"
@@ -109,7 +111,7 @@ func (in *CIDIndexer) indexTransactionCID(tx *sqlx.Tx, transaction TxModelWithIn
 	var txID int64
 	err := tx.QueryRowx(`INSERT INTO btc.transaction_cids (header_id, tx_hash, index, cid, segwit, witness_hash, mh_key)
 							VALUES ($1, $2, $3, $4, $5, $6, $7)
-							ON CONFLICT (tx_hash) DO UPDATE SET (header_id, index, cid, segwit, witness_hash, mh_key) = ($1, $3, $4, $5, $6, $7)
+							ON CONFLICT (header_id, tx_hash) DO UPDATE SET (index, cid, segwit, witness_hash, mh_key) = ($3, $4, $5, $6, $7)
 							RETURNING id`,
 		headerID, transaction.TxHash, transaction.Index, transaction.CID, transaction.SegWit, transaction.WitnessHash, transaction.MhKey).Scan(&txID)
 	return txID, err
@@ -124,9 +126,63 @@ func (in *CIDIndexer) indexTxInput(tx *sqlx.Tx, txInput TxInput, txID int64) err
 }
 
 func (in *CIDIndexer) indexTxOutput(tx *sqlx.Tx, txOuput TxOutput, txID int64) error {
-	_, err := tx.Exec(`INSERT INTO btc.tx_outputs (tx_id, index, value, pk_script, script_class, addresses, required_sigs)
-							VALUES ($1, $2, $3, $4, $5, $6, $7)
-							ON CONFLICT (tx_id, index) DO UPDATE SET (value, pk_script, script_class, addresses, required_sigs) = ($3, $4, $5, $6, $7)`,
-		txID, txOuput.Index, txOuput.Value, txOuput.PkScript, txOuput.ScriptClass, txOuput.Addresses, txOuput.RequiredSigs)
+	_, err := tx.Exec(`INSERT INTO btc.tx_outputs (tx_id, index, value, pk_script, script_class, addresses, required_sigs, script_hash)
+							VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+							ON CONFLICT (tx_id, index) DO UPDATE SET (value, pk_script, script_class, addresses, required_sigs, script_hash) = ($3, $4, $5, $6, $7, $8)`,
+		txID, txOuput.Index, txOuput.Value, txOuput.PkScript, txOuput.ScriptClass, txOuput.Addresses, txOuput.RequiredSigs, ScriptHash(txOuput.PkScript))
 	return err
 }
+
+// IndexScriptHashes sets the script hash of the outputs that were indexed before the script_hash column existed,
+// working through them in batches of the provided size
+// It returns the number of outputs that were updated
+func (in *CIDIndexer) IndexScriptHashes(batchSize int) (int64, error) {
+	var updated, lastID int64
+	for {
+		var outputs []struct {
+			ID       int64  `db:"id"`
+			PkScript []byte `db:"pk_script"`
+		}
+		pgStr := `SELECT id, pk_script FROM btc.tx_outputs
+				WHERE script_hash IS NULL
+				AND id > $1
+				ORDER BY id
+				LIMIT $2`
+		if err := in.db.Select(&outputs, pgStr, lastID, batchSize); err != nil {
+			return updated, err
+		}
+		if len(outputs) == 0 {
+			return updated, nil
+		}
+		ids := make([]int64, len(outputs))
+		scriptHashes := make([]string, len(outputs))
+		for i, output := range outputs {
+			ids[i] = output.ID
+			scriptHashes[i] = ScriptHash(output.PkScript)
+		}
+		res, err := in.db.Exec(`UPDATE btc.tx_outputs
+								SET script_hash = hashes.script_hash
+								FROM unnest($1::INTEGER[], $2::VARCHAR(66)[]) AS hashes (id, script_hash)
+								WHERE tx_outputs.id = hashes.id`,
+			pq.Array(ids), pq.Array(scriptHashes))
+		if err != nil {
+			return updated, err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return updated, err
+		}
+		updated += rows
+		lastID = ids[len(ids)-1]
+	}
+}
+
+// ScriptHash returns the electrum script hash of the provided output script
+// This is the sha256 hash of the script, hex encoded in reverse byte order
+func ScriptHash(pkScript []byte) string {
+	hash := sha256.Sum256(pkScript)
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:])
+}
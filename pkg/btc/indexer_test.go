@@ -17,6 +17,8 @@
 package btc_test
 
 import (
+	"encoding/hex"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -90,5 +92,49 @@ var _ = Describe("Indexer", func() {
 				}
 			}
 		})
+		It("Indexes the electrum script hash of each output", func() {
+			err = repo.Index(&mocks.MockCIDPayload)
+			Expect(err).ToNot(HaveOccurred())
+			outputs := make([]btc.TxOutput, 0)
+			err = db.Select(&outputs, `SELECT * FROM btc.tx_outputs`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(outputs)).ToNot(BeZero())
+			for _, output := range outputs {
+				Expect(output.ScriptHash.Valid).To(BeTrue())
+				Expect(output.ScriptHash.String).To(Equal(btc.ScriptHash(output.PkScript)))
+			}
+		})
+	})
+
+	Describe("IndexScriptHashes", func() {
+		It("Indexes the script hashes of the outputs that do not have them", func() {
+			err = repo.Index(&mocks.MockCIDPayload)
+			Expect(err).ToNot(HaveOccurred())
+			res, err := db.Exec(`UPDATE btc.tx_outputs SET script_hash = NULL`)
+			Expect(err).ToNot(HaveOccurred())
+			count, err := res.RowsAffected()
+			Expect(err).ToNot(HaveOccurred())
+			updated, err := repo.IndexScriptHashes(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updated).To(Equal(count))
+			outputs := make([]btc.TxOutput, 0)
+			err = db.Select(&outputs, `SELECT * FROM btc.tx_outputs`)
+			Expect(err).ToNot(HaveOccurred())
+			for _, output := range outputs {
+				Expect(output.ScriptHash.String).To(Equal(btc.ScriptHash(output.PkScript)))
+			}
+			updated, err = repo.IndexScriptHashes(2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updated).To(BeZero())
+		})
+	})
+
+	Describe("ScriptHash", func() {
+		It("Hashes the script with sha256 and hex encodes it in reverse byte order", func() {
+			// the p2pkh script of 1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa, the genesis block's coinbase address
+			pkScript, err := hex.DecodeString("76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(btc.ScriptHash(pkScript)).To(Equal("8b01df4e368ea28f8dc0423bcf7a4923e3a12d307c875e47a0cfbf90b5c39161"))
+		})
 	})
 })
@@ -0,0 +1,146 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BlockClient is a mock btc.BlockClient serving a chain of blocks which can be replaced to simulate reorgs
+type BlockClient struct {
+	lock   sync.Mutex
+	chain  []*wire.MsgBlock
+	blocks map[chainhash.Hash]*wire.MsgBlock
+}
+
+// NewBlockClient creates a new BlockClient serving the provided chain, indexed by height
+func NewBlockClient(chain []*wire.MsgBlock) *BlockClient {
+	c := &BlockClient{blocks: make(map[chainhash.Hash]*wire.MsgBlock)}
+	c.SetChain(chain)
+	return c
+}
+
+// SetChain replaces the chain served by the client; blocks of the previous chains can still be retrieved by hash
+func (c *BlockClient) SetChain(chain []*wire.MsgBlock) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.chain = chain
+	for _, block := range chain {
+		c.blocks[block.BlockHash()] = block
+	}
+}
+
+// GetBlockCount mock method
+func (c *BlockClient) GetBlockCount() (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return int64(len(c.chain) - 1), nil
+}
+
+// GetBlockHash mock method
+func (c *BlockClient) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if blockHeight < 0 || blockHeight >= int64(len(c.chain)) {
+		return nil, fmt.Errorf("block height %d out of range", blockHeight)
+	}
+	hash := c.chain[blockHeight].BlockHash()
+	return &hash, nil
+}
+
+// GetBlockHeader mock method
+func (c *BlockClient) GetBlockHeader(blockHash *chainhash.Hash) (*wire.BlockHeader, error) {
+	block, err := c.GetBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return &block.Header, nil
+}
+
+// GetBlock mock method
+func (c *BlockClient) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	block, ok := c.blocks[*blockHash]
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", blockHash.String())
+	}
+	return block, nil
+}
+
+// Shutdown mock method
+func (c *BlockClient) Shutdown() {}
+
+// PositionStore is an in-memory btc.PositionStore
+type PositionStore struct {
+	lock   sync.Mutex
+	set    bool
+	height int64
+	hash   string
+}
+
+// Position mock method
+func (s *PositionStore) Position() (int64, string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.set {
+		return 0, "", sql.ErrNoRows
+	}
+	return s.height, s.hash, nil
+}
+
+// SetPosition mock method
+func (s *PositionStore) SetPosition(height int64, hash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.set, s.height, s.hash = true, height, hash
+	return nil
+}
+
+// IndexedChain is an in-memory btc.IndexedChain
+type IndexedChain struct {
+	// The hashes of the blocks reported as indexed, by height
+	Indexed map[int64]string
+}
+
+// IndexedHead mock method
+func (c *IndexedChain) IndexedHead() (int64, string, error) {
+	if len(c.Indexed) == 0 {
+		return 0, "", sql.ErrNoRows
+	}
+	var head int64 = -1
+	for height := range c.Indexed {
+		if height > head {
+			head = height
+		}
+	}
+	return head, c.Indexed[head], nil
+}
+
+// IndexedHash mock method
+func (c *IndexedChain) IndexedHash(height int64) (string, error) {
+	hash, ok := c.Indexed[height]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return hash, nil
+}
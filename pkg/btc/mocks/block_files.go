@@ -0,0 +1,82 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// RegtestBits is the regtest proof of work limit, so that every block adds work to its chain
+const RegtestBits = 0x207fffff
+
+// blockFilePadding is the zeroed space left at the end of the written block files, as Bitcoin Core preallocates it
+const blockFilePadding = 64
+
+// WriteBlockFiles writes fixture Bitcoin Core block files to the directory, one blk?????.dat file for each set of blocks
+// If the key is not nil the files are obfuscated with it and it is written to xor.dat
+func WriteBlockFiles(dir string, net wire.BitcoinNet, files [][]*wire.MsgBlock, key []byte) error {
+	for i, blocks := range files {
+		buf := new(bytes.Buffer)
+		for _, block := range blocks {
+			frame := make([]byte, 8)
+			binary.LittleEndian.PutUint32(frame[:4], uint32(net))
+			binary.LittleEndian.PutUint32(frame[4:], uint32(block.SerializeSize()))
+			buf.Write(frame)
+			if err := block.Serialize(buf); err != nil {
+				return err
+			}
+		}
+		buf.Write(make([]byte, blockFilePadding))
+		data := buf.Bytes()
+		for j := range data {
+			if key != nil {
+				data[j] ^= key[j%len(key)]
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("blk%05d.dat", i)), data, 0644); err != nil {
+			return err
+		}
+	}
+	if key != nil {
+		return ioutil.WriteFile(filepath.Join(dir, "xor.dat"), key, 0644)
+	}
+	return nil
+}
+
+// ExtendWorkChain returns the chain with n blocks appended which carry work and the mock block's transactions
+// The nonces of the blocks start at the provided nonce, distinguishing them from the blocks of other branches
+func ExtendWorkChain(chain []*wire.MsgBlock, n int, nonce uint32) []*wire.MsgBlock {
+	extended := append([]*wire.MsgBlock{}, chain...)
+	for i := 0; i < n; i++ {
+		var prevHash chainhash.Hash
+		if len(extended) > 0 {
+			prevHash = extended[len(extended)-1].BlockHash()
+		}
+		header := wire.NewBlockHeader(1, &prevHash, &MockBlock.Header.MerkleRoot, RegtestBits, nonce+uint32(i))
+		block := wire.NewMsgBlock(header)
+		block.Transactions = MockBlock.Transactions
+		extended = append(extended, block)
+	}
+	return extended
+}
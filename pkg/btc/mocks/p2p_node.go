@@ -0,0 +1,232 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// P2PNode is an in-process stand-in for the p2p port of a bitcoin node
+// It serves headers and blocks of a chain which can be replaced to simulate new blocks and reorgs
+// The first block of the chain must be the genesis block of the provided network parameters
+// It speaks the wire protocol directly since btcd's peer package refuses connections between peers in the same process
+type P2PNode struct {
+	params   *chaincfg.Params
+	listener net.Listener
+
+	lock   sync.Mutex
+	chain  []*wire.MsgBlock
+	blocks map[chainhash.Hash]*wire.MsgBlock
+	conns  map[*p2pConn]struct{}
+}
+
+// p2pConn is a connection to the P2PNode; writes are serialized so that announcements don't interleave with replies
+type p2pConn struct {
+	net.Conn
+	params *chaincfg.Params
+	lock   sync.Mutex
+}
+
+func (c *p2pConn) write(msg wire.Message, encoding wire.MessageEncoding) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := wire.WriteMessageWithEncodingN(c, msg, wire.ProtocolVersion, c.params.Net, encoding)
+	return err
+}
+
+// NewP2PNode creates a new P2PNode serving the provided chain, indexed by height, on a local port
+func NewP2PNode(params *chaincfg.Params, chain []*wire.MsgBlock) (*P2PNode, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	n := &P2PNode{
+		params:   params,
+		listener: listener,
+		blocks:   make(map[chainhash.Hash]*wire.MsgBlock),
+		conns:    make(map[*p2pConn]struct{}),
+	}
+	n.setChain(chain)
+	go n.accept()
+	return n, nil
+}
+
+// Addr returns the host:port address the node is listening on
+func (n *P2PNode) Addr() string {
+	return n.listener.Addr().String()
+}
+
+// SetChain replaces the chain served by the node and announces its tip to the connected peers; blocks of the previous
+// chains can still be retrieved by hash
+func (n *P2PNode) SetChain(chain []*wire.MsgBlock) {
+	n.lock.Lock()
+	n.setChain(chain)
+	tip := chain[len(chain)-1].BlockHash()
+	conns := make([]*p2pConn, 0, len(n.conns))
+	for conn := range n.conns {
+		conns = append(conns, conn)
+	}
+	n.lock.Unlock()
+	inv := wire.NewMsgInv()
+	inv.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &tip))
+	for _, conn := range conns {
+		conn.write(inv, wire.BaseEncoding)
+	}
+}
+
+// Stop stops listening and closes the open connections
+func (n *P2PNode) Stop() {
+	n.listener.Close()
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for conn := range n.conns {
+		conn.Close()
+	}
+}
+
+func (n *P2PNode) setChain(chain []*wire.MsgBlock) {
+	n.chain = chain
+	for _, block := range chain {
+		n.blocks[block.BlockHash()] = block
+	}
+}
+
+func (n *P2PNode) accept() {
+	for {
+		netConn, err := n.listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := &p2pConn{Conn: netConn, params: n.params}
+		n.lock.Lock()
+		n.conns[conn] = struct{}{}
+		n.lock.Unlock()
+		go func() {
+			n.serve(conn)
+			conn.Close()
+			n.lock.Lock()
+			delete(n.conns, conn)
+			n.lock.Unlock()
+		}()
+	}
+}
+
+// serve answers the version handshake, then the getheaders and getdata messages, until the connection is closed
+func (n *P2PNode) serve(conn *p2pConn) {
+	for {
+		_, msg, _, err := wire.ReadMessageWithEncodingN(conn, wire.ProtocolVersion, n.params.Net, wire.LatestEncoding)
+		if err != nil {
+			if _, ok := err.(*wire.MessageError); ok {
+				continue
+			}
+			return
+		}
+		switch msg := msg.(type) {
+		case *wire.MsgVersion:
+			err = n.onVersion(conn, msg)
+		case *wire.MsgGetHeaders:
+			err = n.onGetHeaders(conn, msg)
+		case *wire.MsgGetData:
+			err = n.onGetData(conn, msg)
+		case *wire.MsgPing:
+			err = conn.write(wire.NewMsgPong(msg.Nonce), wire.BaseEncoding)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// onVersion sends our version, advertising witness support, and acknowledges the peer's
+func (n *P2PNode) onVersion(conn *p2pConn, msg *wire.MsgVersion) error {
+	n.lock.Lock()
+	height := int32(len(n.chain) - 1)
+	n.lock.Unlock()
+	services := wire.SFNodeNetwork | wire.SFNodeWitness
+	me := wire.NewNetAddressTimestamp(time.Now(), services, net.IPv4(127, 0, 0, 1), 0)
+	version := wire.NewMsgVersion(me, &msg.AddrMe, 0, height)
+	version.Services = services
+	if err := conn.write(version, wire.BaseEncoding); err != nil {
+		return err
+	}
+	return conn.write(wire.NewMsgVerAck(), wire.BaseEncoding)
+}
+
+// onGetHeaders sends the headers following the first locator hash in the chain
+func (n *P2PNode) onGetHeaders(conn *p2pConn, msg *wire.MsgGetHeaders) error {
+	n.lock.Lock()
+	start := 0
+	for _, hash := range msg.BlockLocatorHashes {
+		if height, ok := n.height(*hash); ok {
+			start = height + 1
+			break
+		}
+	}
+	headers := wire.NewMsgHeaders()
+	for i := start; i < len(n.chain) && len(headers.Headers) < wire.MaxBlockHeadersPerMsg; i++ {
+		header := n.chain[i].Header
+		headers.AddBlockHeader(&header)
+	}
+	n.lock.Unlock()
+	return conn.write(headers, wire.BaseEncoding)
+}
+
+// onGetData sends the requested blocks, or a notfound message listing those that are not known
+func (n *P2PNode) onGetData(conn *p2pConn, msg *wire.MsgGetData) error {
+	notFound := wire.NewMsgNotFound()
+	for _, iv := range msg.InvList {
+		if iv.Type != wire.InvTypeBlock && iv.Type != wire.InvTypeWitnessBlock {
+			notFound.AddInvVect(iv)
+			continue
+		}
+		n.lock.Lock()
+		block, ok := n.blocks[iv.Hash]
+		n.lock.Unlock()
+		if !ok {
+			notFound.AddInvVect(iv)
+			continue
+		}
+		encoding := wire.BaseEncoding
+		if iv.Type == wire.InvTypeWitnessBlock {
+			encoding = wire.WitnessEncoding
+		}
+		if err := conn.write(block, encoding); err != nil {
+			return err
+		}
+	}
+	if len(notFound.InvList) > 0 {
+		return conn.write(notFound, wire.BaseEncoding)
+	}
+	return nil
+}
+
+// height returns the height of the block with the provided hash in the current chain
+// It must be called with the lock held
+func (n *P2PNode) height(hash chainhash.Hash) (int, bool) {
+	for i, block := range n.chain {
+		if block.BlockHash() == hash {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -16,7 +16,11 @@
 
 package btc
 
-import "github.com/lib/pq"
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
 
 // HeaderModel is the db model for btc.header_cids table
 type HeaderModel struct {
@@ -65,8 +69,8 @@ type TxInput struct {
 	Index                 int64    `db:"index"`
 	TxWitness             []string `db:"witness"`
 	SignatureScript       []byte   `db:"sig_script"`
-	PreviousOutPointIndex uint32   `db:"outpoint_tx_hash"`
-	PreviousOutPointHash  string   `db:"outpoint_index"`
+	PreviousOutPointIndex uint32   `db:"outpoint_index"`
+	PreviousOutPointHash  string   `db:"outpoint_tx_hash"`
 }
 
 // TxOutput is the db model for btc.tx_outputs table
@@ -79,4 +83,45 @@ type TxOutput struct {
 	ScriptClass  uint8          `db:"script_class"`
 	RequiredSigs int64          `db:"required_sigs"`
 	Addresses    pq.StringArray `db:"addresses"`
+	ScriptHash   sql.NullString `db:"script_hash"`
+}
+
+// UTXOModel is an unspent output along with the transaction and block that created it
+type UTXOModel struct {
+	TxHash       string         `db:"tx_hash"`
+	Index        int64          `db:"index"`
+	Value        int64          `db:"value"`
+	PkScript     []byte         `db:"pk_script"`
+	ScriptClass  uint8          `db:"script_class"`
+	RequiredSigs int64          `db:"required_sigs"`
+	Addresses    pq.StringArray `db:"addresses"`
+	BlockNumber  int64          `db:"block_number"`
+	BlockHash    string         `db:"block_hash"`
+	Timestamp    int64          `db:"timestamp"`
+}
+
+// OutspendModel is an output of a transaction along with the input spending it and the block that input was included in
+// The spending fields are null if the output has not been spent
+type OutspendModel struct {
+	Index          int64          `db:"index"`
+	SpendingTxHash sql.NullString `db:"spending_tx_hash"`
+	SpendingIndex  sql.NullInt64  `db:"spending_index"`
+	BlockNumber    sql.NullInt64  `db:"block_number"`
+	BlockHash      sql.NullString `db:"block_hash"`
+	Timestamp      sql.NullInt64  `db:"timestamp"`
+}
+
+// HistoryModel is a transaction funding or spending an output along with the height of the block it was included in
+type HistoryModel struct {
+	TxHash      string `db:"tx_hash"`
+	BlockNumber int64  `db:"block_number"`
+}
+
+// AddressStatsModel summarizes the outputs paying to an address and the transactions funding or spending them
+type AddressStatsModel struct {
+	FundedTxoCount int64 `db:"funded_txo_count"`
+	FundedTxoSum   int64 `db:"funded_txo_sum"`
+	SpentTxoCount  int64 `db:"spent_txo_count"`
+	SpentTxoSum    int64 `db:"spent_txo_sum"`
+	TxCount        int64 `db:"tx_count"`
 }
@@ -0,0 +1,539 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+const (
+	p2pDialTimeout      = 30 * time.Second // how long to wait for the tcp connection to the node
+	p2pHandshakeTimeout = 30 * time.Second // how long to wait for the node to acknowledge our version
+	p2pRetryInterval    = 5 * time.Second  // how long to wait before reconnecting to the node
+	p2pSyncTimeout      = 10 * time.Minute // how long to wait for the initial header sync
+	p2pUserAgent        = "ipfs-blockchain-watcher"
+)
+
+var (
+	// errP2PClientStopped is returned when the P2PClient is stopped while waiting on the node
+	errP2PClientStopped = errors.New("btc p2p client stopped")
+	// zeroHash is used as the stop hash of getheaders messages to ask for as many headers as the node will send
+	zeroHash chainhash.Hash
+)
+
+// P2PClient is a connection to the p2p port of a bitcoin node
+// It keeps a copy of the node's best header chain, synced with getheaders, so that blocks can be requested by height
+// and the heights of the blocks the node announces are known. Blocks are requested with getdata; the new blocks the
+// node announces after the initial header sync, including those replacing blocks in a reorg, are sent to the
+// subscribers
+// The client reconnects to the node whenever the connection is lost
+type P2PClient struct {
+	addr   string
+	params *chaincfg.Params
+
+	startOnce  sync.Once
+	stopOnce   sync.Once
+	quit       chan struct{}
+	synced     chan struct{} // closed when the initial header sync has completed
+	syncedOnce sync.Once
+
+	lock    sync.Mutex
+	peer    *peer.Peer                               // the current connection, nil while disconnected
+	hashes  []chainhash.Hash                         // the hashes of the node's best header chain, by height
+	heights map[chainhash.Hash]int64                 // the heights of the headers in the chain
+	pending map[chainhash.Hash][]chan *wire.MsgBlock // the requested blocks and the channels waiting for them
+	live    map[chainhash.Hash]bool                  // the new blocks requested for the subscribers
+	subs    map[*P2PClientSubscription]struct{}      // the subscribers to new blocks
+}
+
+// NewP2PClient creates a new P2PClient for the node at the provided host:port address on the provided network
+// The client does not connect until it is first used
+func NewP2PClient(addr string, params *chaincfg.Params) *P2PClient {
+	return &P2PClient{
+		addr:    addr,
+		params:  params,
+		quit:    make(chan struct{}),
+		synced:  make(chan struct{}),
+		hashes:  []chainhash.Hash{*params.GenesisHash},
+		heights: map[chainhash.Hash]int64{*params.GenesisHash: 0},
+		pending: make(map[chainhash.Hash][]chan *wire.MsgBlock),
+		live:    make(map[chainhash.Hash]bool),
+		subs:    make(map[*P2PClientSubscription]struct{}),
+	}
+}
+
+// Start connects to the node in a background goroutine, reconnecting whenever the connection is lost
+// It is safe to call more than once
+func (c *P2PClient) Start() {
+	c.startOnce.Do(func() {
+		go c.run()
+	})
+}
+
+// Stop disconnects from the node
+func (c *P2PClient) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.quit)
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if c.peer != nil {
+			c.peer.Disconnect()
+		}
+	})
+}
+
+// FetchHead returns the height of the node's best chain
+// Satisfies the shared.HeadFetcher interface
+func (c *P2PClient) FetchHead() (int64, error) {
+	if err := c.waitForSync(); err != nil {
+		return 0, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return int64(len(c.hashes) - 1), nil
+}
+
+// FetchBlocks requests the blocks at the provided heights of the node's best chain, waiting up to the provided timeout
+// for them to be delivered
+func (c *P2PClient) FetchBlocks(heights []uint64, timeout time.Duration) ([]BlockPayload, error) {
+	if err := c.waitForSync(); err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	hashes := make([]chainhash.Hash, len(heights))
+	waiters := make([]chan *wire.MsgBlock, len(heights))
+	for i, height := range heights {
+		if height >= uint64(len(c.hashes)) {
+			c.lock.Unlock()
+			c.cancel(hashes[:i], waiters[:i])
+			return nil, fmt.Errorf("btc p2p block height %d is above the node's tip at height %d", height, len(c.hashes)-1)
+		}
+		hashes[i] = c.hashes[height]
+		waiters[i] = make(chan *wire.MsgBlock, 1)
+		c.pending[hashes[i]] = append(c.pending[hashes[i]], waiters[i])
+	}
+	p := c.peer
+	c.lock.Unlock()
+	// If we are disconnected the requests are sent when we reconnect
+	if p != nil {
+		pushGetData(p, hashes)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	payloads := make([]BlockPayload, len(heights))
+	for i, waiter := range waiters {
+		select {
+		case block := <-waiter:
+			payloads[i] = BlockPayload{
+				BlockHeight: int64(heights[i]),
+				Header:      &block.Header,
+				Txs:         msgTxsToUtilTxs(block.Transactions),
+			}
+		case <-timer.C:
+			c.cancel(hashes, waiters)
+			return nil, fmt.Errorf("btc p2p timed out waiting for block %s at height %d", hashes[i].String(), heights[i])
+		case <-c.quit:
+			return nil, errP2PClientStopped
+		}
+	}
+	return payloads, nil
+}
+
+// Subscribe sends the new blocks announced by the node after the initial header sync to the provided channel
+// The blocks are delivered in the order they arrive by a goroutine of the subscription's own, so that a subscriber
+// which is slow to receive does not hold up the handling of the node's messages
+func (c *P2PClient) Subscribe(payloadChan chan<- shared.RawChainData) *P2PClientSubscription {
+	sub := &P2PClientSubscription{
+		client:      c,
+		payloadChan: payloadChan,
+		errChan:     make(chan error, 1),
+		queued:      make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+	}
+	c.lock.Lock()
+	c.subs[sub] = struct{}{}
+	c.lock.Unlock()
+	go sub.deliver()
+	return sub
+}
+
+// run connects to the node and waits for the connection to be lost, until the client is stopped
+func (c *P2PClient) run() {
+	for {
+		p, err := c.connect()
+		if err == nil {
+			p.WaitForDisconnect()
+			c.lock.Lock()
+			c.peer = nil
+			c.lock.Unlock()
+			err = fmt.Errorf("btc p2p peer %s disconnected", c.addr)
+		}
+		select {
+		case <-c.quit:
+			return
+		default:
+		}
+		logrus.Error(err)
+		c.notifyErr(err)
+		select {
+		case <-time.After(p2pRetryInterval):
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// connect connects to the node, waits for the version handshake, then starts syncing headers and re-sends the
+// requests for any blocks that have not been delivered
+func (c *P2PClient) connect() (*peer.Peer, error) {
+	verAck := make(chan struct{}, 1)
+	cfg := &peer.Config{
+		UserAgentName:    p2pUserAgent,
+		UserAgentVersion: version.VersionWithMeta,
+		ChainParams:      c.params,
+		DisableRelayTx:   true,
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verAck <- struct{}{}
+			},
+			OnHeaders: c.onHeaders,
+			OnInv:     c.onInv,
+			OnBlock:   c.onBlock,
+		},
+	}
+	p, err := peer.NewOutboundPeer(cfg, c.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("tcp", c.addr, p2pDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	p.AssociateConnection(conn)
+	select {
+	case <-verAck:
+	case <-time.After(p2pHandshakeTimeout):
+		p.Disconnect()
+		return nil, fmt.Errorf("btc p2p handshake with %s timed out", c.addr)
+	case <-c.quit:
+		p.Disconnect()
+		return nil, errP2PClientStopped
+	}
+	logrus.Infof("btc p2p connected to %s (%s)", c.addr, p.UserAgent())
+
+	c.lock.Lock()
+	c.peer = p
+	locator := c.locator()
+	requested := make([]chainhash.Hash, 0, len(c.pending)+len(c.live))
+	for hash := range c.pending {
+		requested = append(requested, hash)
+	}
+	for hash := range c.live {
+		if _, ok := c.pending[hash]; !ok {
+			requested = append(requested, hash)
+		}
+	}
+	c.lock.Unlock()
+	if err := p.PushGetHeadersMsg(locator, &zeroHash); err != nil {
+		p.Disconnect()
+		return nil, err
+	}
+	if len(requested) > 0 {
+		pushGetData(p, requested)
+	}
+	return p, nil
+}
+
+// onHeaders adds the headers to the header chain, replacing the headers above the parent of the first one that
+// differs, and either asks for more headers or, once synced, requests the new blocks for the subscribers
+func (c *P2PClient) onHeaders(p *peer.Peer, msg *wire.MsgHeaders) {
+	c.lock.Lock()
+	added := make([]chainhash.Hash, 0, len(msg.Headers))
+	for _, header := range msg.Headers {
+		hash := header.BlockHash()
+		parentHeight, ok := c.heights[header.PrevBlock]
+		if !ok {
+			logrus.Debugf("btc p2p header %s does not connect to the header chain", hash.String())
+			continue
+		}
+		height := parentHeight + 1
+		if height < int64(len(c.hashes)) {
+			if c.hashes[height] == hash {
+				continue
+			}
+			logrus.Infof("btc p2p reorg detected at height %d", height)
+			for _, removed := range c.hashes[height:] {
+				delete(c.heights, removed)
+			}
+			c.hashes = c.hashes[:height]
+		}
+		c.hashes = append(c.hashes, hash)
+		c.heights[hash] = height
+		added = append(added, hash)
+	}
+	more := len(msg.Headers) == wire.MaxBlockHeadersPerMsg
+	var requested []chainhash.Hash
+	if c.isSynced() && len(c.subs) > 0 {
+		for _, hash := range added {
+			c.live[hash] = true
+		}
+		requested = added
+	}
+	locator := c.locator()
+	tip := len(c.hashes) - 1
+	c.lock.Unlock()
+
+	if more {
+		if err := p.PushGetHeadersMsg(locator, &zeroHash); err != nil {
+			logrus.Errorf("btc p2p getheaders error: %v", err)
+		}
+		return
+	}
+	c.syncedOnce.Do(func() {
+		logrus.Infof("btc p2p synced headers to height %d", tip)
+		close(c.synced)
+	})
+	if len(requested) > 0 {
+		pushGetData(p, requested)
+	}
+}
+
+// onInv asks for the headers of any blocks the node announces that are not in the header chain
+func (c *P2PClient) onInv(p *peer.Peer, msg *wire.MsgInv) {
+	c.lock.Lock()
+	unknown := false
+	for _, iv := range msg.InvList {
+		if iv.Type != wire.InvTypeBlock && iv.Type != wire.InvTypeWitnessBlock {
+			continue
+		}
+		if _, ok := c.heights[iv.Hash]; !ok {
+			unknown = true
+		}
+	}
+	locator := c.locator()
+	c.lock.Unlock()
+	if unknown {
+		if err := p.PushGetHeadersMsg(locator, &zeroHash); err != nil {
+			logrus.Errorf("btc p2p getheaders error: %v", err)
+		}
+	}
+}
+
+// onBlock delivers the block to the requests waiting for it and, if it is a new block in the header chain, to the
+// subscribers
+func (c *P2PClient) onBlock(p *peer.Peer, msg *wire.MsgBlock, buf []byte) {
+	hash := msg.BlockHash()
+	c.lock.Lock()
+	waiters := c.pending[hash]
+	delete(c.pending, hash)
+	live := c.live[hash]
+	delete(c.live, hash)
+	height, inChain := c.heights[hash]
+	subs := make([]*P2PClientSubscription, 0, len(c.subs))
+	for sub := range c.subs {
+		subs = append(subs, sub)
+	}
+	c.lock.Unlock()
+
+	for _, waiter := range waiters {
+		waiter <- msg
+	}
+	if !live || !inChain {
+		return
+	}
+	payload := BlockPayload{
+		BlockHeight: height,
+		Header:      &msg.Header,
+		Txs:         msgTxsToUtilTxs(msg.Transactions),
+	}
+	for _, sub := range subs {
+		sub.enqueue(payload)
+	}
+}
+
+// cancel removes the provided channels from the requests waiting for the blocks
+func (c *P2PClient) cancel(hashes []chainhash.Hash, waiters []chan *wire.MsgBlock) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, hash := range hashes {
+		remaining := c.pending[hash][:0]
+		for _, waiter := range c.pending[hash] {
+			if waiter != waiters[i] {
+				remaining = append(remaining, waiter)
+			}
+		}
+		if len(remaining) == 0 {
+			delete(c.pending, hash)
+		} else {
+			c.pending[hash] = remaining
+		}
+	}
+}
+
+// notifyErr sends the error to the subscribers that do not already have one pending
+func (c *P2PClient) notifyErr(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for sub := range c.subs {
+		select {
+		case sub.errChan <- err:
+		default:
+		}
+	}
+}
+
+// waitForSync starts the client if it has not been started and waits for the initial header sync to complete
+func (c *P2PClient) waitForSync() error {
+	c.Start()
+	select {
+	case <-c.synced:
+		return nil
+	case <-time.After(p2pSyncTimeout):
+		return fmt.Errorf("btc p2p timed out syncing headers from %s", c.addr)
+	case <-c.quit:
+		return errP2PClientStopped
+	}
+}
+
+// isSynced returns whether the initial header sync has completed
+func (c *P2PClient) isSynced() bool {
+	select {
+	case <-c.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+// locator returns a block locator for the tip of the header chain: the ten most recent hashes, then hashes
+// exponentially further apart, ending with the genesis hash
+// It must be called with the lock held
+func (c *P2PClient) locator() blockchain.BlockLocator {
+	locator := make(blockchain.BlockLocator, 0, wire.MaxBlockLocatorsPerMsg)
+	step := int64(1)
+	for height := int64(len(c.hashes) - 1); height > 0; height -= step {
+		hash := c.hashes[height]
+		locator = append(locator, &hash)
+		if len(locator) >= 10 {
+			step *= 2
+		}
+	}
+	genesis := c.hashes[0]
+	return append(locator, &genesis)
+}
+
+// pushGetData requests the blocks with the provided hashes from the peer, including their witness data if the peer
+// supports it
+func pushGetData(p *peer.Peer, hashes []chainhash.Hash) {
+	invType := wire.InvTypeBlock
+	if p.IsWitnessEnabled() {
+		invType = wire.InvTypeWitnessBlock
+	}
+	msg := wire.NewMsgGetData()
+	for i := range hashes {
+		if len(msg.InvList) == wire.MaxInvPerMsg {
+			p.QueueMessage(msg, nil)
+			msg = wire.NewMsgGetData()
+		}
+		msg.AddInvVect(wire.NewInvVect(invType, &hashes[i]))
+	}
+	p.QueueMessage(msg, nil)
+}
+
+// P2PClientSubscription is a subscription to the new blocks of a P2PClient
+// Satisfies the shared.ClientSubscription interface
+type P2PClientSubscription struct {
+	client      *P2PClient
+	payloadChan chan<- shared.RawChainData
+	errChan     chan error
+
+	queueLock sync.Mutex
+	queue     []BlockPayload // the blocks waiting to be delivered, in the order they arrived
+	queued    chan struct{}  // signalled when blocks are added to the queue
+	quit      chan struct{}
+	quitOnce  sync.Once
+}
+
+// Unsubscribe stops sending new blocks to the subscription
+func (sub *P2PClientSubscription) Unsubscribe() {
+	sub.client.lock.Lock()
+	delete(sub.client.subs, sub)
+	sub.client.lock.Unlock()
+	sub.quitOnce.Do(func() {
+		close(sub.quit)
+	})
+}
+
+// enqueue adds the block to the blocks waiting to be delivered, without blocking
+func (sub *P2PClientSubscription) enqueue(payload BlockPayload) {
+	sub.queueLock.Lock()
+	sub.queue = append(sub.queue, payload)
+	sub.queueLock.Unlock()
+	select {
+	case sub.queued <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends the queued blocks to the subscriber until it unsubscribes or the client is stopped
+func (sub *P2PClientSubscription) deliver() {
+	for {
+		select {
+		case <-sub.queued:
+		case <-sub.quit:
+			return
+		case <-sub.client.quit:
+			return
+		}
+		for {
+			sub.queueLock.Lock()
+			if len(sub.queue) == 0 {
+				sub.queueLock.Unlock()
+				break
+			}
+			payload := sub.queue[0]
+			sub.queue = sub.queue[1:]
+			sub.queueLock.Unlock()
+			select {
+			case sub.payloadChan <- payload:
+			case <-sub.quit:
+				return
+			case <-sub.client.quit:
+				return
+			}
+		}
+	}
+}
+
+// Err returns a channel on which connection errors are reported
+func (sub *P2PClientSubscription) Err() <-chan error {
+	return sub.errChan
+}
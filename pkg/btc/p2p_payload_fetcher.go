@@ -0,0 +1,55 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// DefaultP2PFetchTimeout is how long the P2PPayloadFetcher waits for the node to deliver the requested blocks
+const DefaultP2PFetchTimeout = 5 * time.Minute
+
+// P2PPayloadFetcher satisfies the PayloadFetcher interface for bitcoin
+// It requests blocks over a P2PClient's connection to a bitcoin node's p2p port
+type P2PPayloadFetcher struct {
+	client  *P2PClient
+	timeout time.Duration
+}
+
+// NewP2PPayloadFetcher returns a P2PPayloadFetcher which waits up to the provided timeout for each batch of blocks
+func NewP2PPayloadFetcher(client *P2PClient, timeout time.Duration) *P2PPayloadFetcher {
+	return &P2PPayloadFetcher{
+		client:  client,
+		timeout: timeout,
+	}
+}
+
+// FetchAt fetches the block payloads at the given block heights
+func (fetcher *P2PPayloadFetcher) FetchAt(blockHeights []uint64) ([]shared.RawChainData, error) {
+	blocks, err := fetcher.client.FetchBlocks(blockHeights, fetcher.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("bitcoin P2PPayloadFetcher err: %s", err.Error())
+	}
+	blockPayloads := make([]shared.RawChainData, len(blocks))
+	for i, block := range blocks {
+		blockPayloads[i] = block
+	}
+	return blockPayloads, nil
+}
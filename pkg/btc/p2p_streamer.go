@@ -0,0 +1,42 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// P2PPayloadStreamer satisfies the PayloadStreamer interface for bitcoin
+// It streams the new blocks announced on a P2PClient's connection to a bitcoin node's p2p port
+type P2PPayloadStreamer struct {
+	client *P2PClient
+}
+
+// NewP2PPayloadStreamer creates a pointer to a new P2PPayloadStreamer which satisfies the PayloadStreamer interface
+func NewP2PPayloadStreamer(client *P2PClient) *P2PPayloadStreamer {
+	return &P2PPayloadStreamer{
+		client: client,
+	}
+}
+
+// Stream is the main loop for subscribing to data from the btc node's p2p port
+// Satisfies the shared.PayloadStreamer interface
+func (ps *P2PPayloadStreamer) Stream(payloadChan chan shared.RawChainData) (shared.ClientSubscription, error) {
+	sub := ps.client.Subscribe(payloadChan)
+	ps.client.Start()
+	return sub, nil
+}
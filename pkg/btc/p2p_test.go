@@ -0,0 +1,136 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("P2P", func() {
+	var (
+		chain  []*wire.MsgBlock
+		params chaincfg.Params
+		node   *mocks.P2PNode
+		client *btc.P2PClient
+	)
+	BeforeEach(func() {
+		chain = extendChain(nil, 6, 0)
+		genesisHash := chain[0].BlockHash()
+		params = chaincfg.RegressionNetParams
+		params.GenesisHash = &genesisHash
+		var err error
+		node, err = mocks.NewP2PNode(&params, chain)
+		Expect(err).ToNot(HaveOccurred())
+		client = btc.NewP2PClient(node.Addr(), &params)
+	})
+	AfterEach(func() {
+		client.Stop()
+		node.Stop()
+	})
+
+	Describe("FetchHead", func() {
+		It("Syncs the node's headers and returns the height of its tip", func() {
+			head, err := client.FetchHead()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(head).To(Equal(int64(5)))
+		})
+	})
+
+	Describe("P2PPayloadFetcher", func() {
+		It("Fetches the blocks at the provided heights with getdata", func() {
+			fetcher := btc.NewP2PPayloadFetcher(client, time.Second)
+			payloads, err := fetcher.FetchAt([]uint64{1, 3, 5})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(payloads)).To(Equal(3))
+			for i, height := range []int64{1, 3, 5} {
+				payload, ok := payloads[i].(btc.BlockPayload)
+				Expect(ok).To(BeTrue())
+				Expect(payload.BlockHeight).To(Equal(height))
+				Expect(payload.Header.BlockHash()).To(Equal(chain[height].BlockHash()))
+			}
+		})
+
+		It("Returns an error for heights above the node's tip", func() {
+			fetcher := btc.NewP2PPayloadFetcher(client, time.Second)
+			_, err := fetcher.FetchAt([]uint64{6})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("P2PPayloadStreamer", func() {
+		var (
+			payloadChan chan shared.RawChainData
+			sub         shared.ClientSubscription
+		)
+		BeforeEach(func() {
+			payloadChan = make(chan shared.RawChainData, btc.PayloadChanBufferSize)
+			var err error
+			sub, err = btc.NewP2PPayloadStreamer(client).Stream(payloadChan)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = client.FetchHead()
+			Expect(err).ToNot(HaveOccurred())
+		})
+		AfterEach(func() {
+			sub.Unsubscribe()
+		})
+
+		It("Streams the new blocks the node announces", func() {
+			chain = extendChain(chain, 2, 0)
+			node.SetChain(chain)
+			heights, hashes := receiveBlocks(payloadChan, 2)
+			Expect(heights).To(Equal([]int64{6, 7}))
+			Expect(hashes).To(Equal([]chainhash.Hash{chain[6].BlockHash(), chain[7].BlockHash()}))
+			Consistently(payloadChan, 100*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("Streams the blocks replacing the old ones in a reorg", func() {
+			fork := extendChain(chain[:4], 3, 1)
+			node.SetChain(fork)
+			heights, hashes := receiveBlocks(payloadChan, 3)
+			Expect(heights).To(Equal([]int64{4, 5, 6}))
+			Expect(hashes).To(Equal([]chainhash.Hash{fork[4].BlockHash(), fork[5].BlockHash(), fork[6].BlockHash()}))
+			head, err := client.FetchHead()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(head).To(Equal(int64(6)))
+		})
+
+		It("Keeps handling the node's messages while a subscriber is not receiving", func() {
+			stalled := make(chan shared.RawChainData)
+			stalledSub := client.Subscribe(stalled)
+			defer stalledSub.Unsubscribe()
+			chain = extendChain(chain, 2, 0)
+			node.SetChain(chain)
+			receiveBlocks(payloadChan, 2)
+			payloads, err := client.FetchBlocks([]uint64{1, 2}, time.Second)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(payloads)).To(Equal(2))
+
+			heights, _ := receiveBlocks(stalled, 2)
+			Expect(heights).To(Equal([]int64{6, 7}))
+		})
+	})
+})
@@ -0,0 +1,229 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// DefaultNetwork is the network used when bitcoin.network is not configured
+const DefaultNetwork = "mainnet"
+
+// SigNetParams are the parameters for the default signet, which btcd does not define
+var SigNetParams = newSigNetParams()
+
+// networks maps the names accepted by bitcoin.network to the parameters of that network
+var networks = map[string]*chaincfg.Params{
+	"mainnet":  &chaincfg.MainNetParams,
+	"testnet":  &chaincfg.TestNet3Params,
+	"testnet3": &chaincfg.TestNet3Params,
+	"regtest":  &chaincfg.RegressionNetParams,
+	"simnet":   &chaincfg.SimNetParams,
+	"signet":   &SigNetParams,
+}
+
+func newSigNetParams() chaincfg.Params {
+	genesis := *chaincfg.MainNetParams.GenesisBlock
+	genesis.Header.Timestamp = time.Unix(1598918400, 0)
+	genesis.Header.Bits = 0x1e0377ae
+	genesis.Header.Nonce = 52613770
+	genesisHash := genesis.BlockHash()
+
+	params := chaincfg.TestNet3Params
+	params.Name = "signet"
+	params.Net = wire.BitcoinNet(0x40cf030a)
+	params.DefaultPort = "38333"
+	params.DNSSeeds = nil
+	params.GenesisBlock = &genesis
+	params.GenesisHash = &genesisHash
+	params.PowLimit = blockchain.CompactToBig(genesis.Header.Bits)
+	params.PowLimitBits = genesis.Header.Bits
+	params.ReduceMinDifficulty = false
+	params.MinDiffReductionTime = 0
+	params.Checkpoints = nil
+	return params
+}
+
+// NetworkParams returns a copy of the parameters for the named network
+func NetworkParams(network string) (*chaincfg.Params, error) {
+	if network == "" {
+		network = DefaultNetwork
+	}
+	params, ok := networks[strings.ToLower(network)]
+	if !ok {
+		names := make([]string, 0, len(networks))
+		for name := range networks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unrecognized bitcoin network %s, expected one of %s", network, strings.Join(names, ", "))
+	}
+	paramsCopy := *params
+	return &paramsCopy, nil
+}
+
+// ParamsFromConfig returns the parameters of the network configured at bitcoin.network with any bitcoin.params
+// overrides applied, for chains derived from bitcoin that use different address prefixes
+// The parameters are validated against the genesis block and network id of the provided node info
+func ParamsFromConfig(nodeInfo node.Node) (*chaincfg.Params, error) {
+	viper.BindEnv("bitcoin.network", shared.BTC_NETWORK)
+	params, err := NetworkParams(viper.GetString("bitcoin.network"))
+	if err != nil {
+		return nil, err
+	}
+	custom := viper.Sub("bitcoin.params")
+	if custom != nil {
+		if err := overrideParams(params, custom); err != nil {
+			return nil, err
+		}
+		// Registering makes the custom address prefixes known when decoding addresses
+		// A network that reuses the magic of an existing network cannot be registered again, but its
+		// parameters are still used when encoding addresses
+		if err := chaincfg.Register(params); err != nil && err != chaincfg.ErrDuplicateNet {
+			return nil, err
+		}
+	}
+	return params, ValidateParams(params, nodeInfo)
+}
+
+// ValidateParams checks that the parameters belong to the same network as the node
+// Empty node info fields are not checked
+func ValidateParams(params *chaincfg.Params, nodeInfo node.Node) error {
+	if nodeInfo.GenesisBlock != "" && params.GenesisHash != nil && nodeInfo.GenesisBlock != params.GenesisHash.String() {
+		return fmt.Errorf("bitcoin network %s has genesis block %s but the node's genesis block is %s",
+			params.Name, params.GenesisHash.String(), nodeInfo.GenesisBlock)
+	}
+	if nodeInfo.NetworkID != "" {
+		networkID, err := strconv.ParseUint(trimHexPrefix(nodeInfo.NetworkID), 16, 32)
+		if err != nil {
+			return fmt.Errorf("unable to parse bitcoin network id %s: %v", nodeInfo.NetworkID, err)
+		}
+		if wire.BitcoinNet(networkID) != params.Net {
+			return fmt.Errorf("bitcoin network %s has network id %#x but the node's network id is %s",
+				params.Name, uint32(params.Net), nodeInfo.NetworkID)
+		}
+	}
+	return nil
+}
+
+// overrideParams applies the custom parameters found in the config to the params
+func overrideParams(params *chaincfg.Params, custom *viper.Viper) error {
+	if custom.IsSet("name") {
+		params.Name = custom.GetString("name")
+	}
+	if custom.IsSet("net") {
+		net, err := parseHexUint(custom.Get("net"), 32)
+		if err != nil {
+			return fmt.Errorf("unable to parse bitcoin.params.net: %v", err)
+		}
+		params.Net = wire.BitcoinNet(net)
+	}
+	if custom.IsSet("genesisHash") {
+		genesisHash, err := chainhash.NewHashFromStr(custom.GetString("genesisHash"))
+		if err != nil {
+			return fmt.Errorf("unable to parse bitcoin.params.genesisHash: %v", err)
+		}
+		params.GenesisHash = genesisHash
+		params.GenesisBlock = nil
+		params.Checkpoints = nil
+	}
+	if custom.IsSet("defaultPort") {
+		params.DefaultPort = custom.GetString("defaultPort")
+	}
+	if custom.IsSet("bech32HRPSegwit") {
+		params.Bech32HRPSegwit = custom.GetString("bech32HRPSegwit")
+	}
+	ids := []struct {
+		key string
+		id  *byte
+	}{
+		{"pubKeyHashAddrID", &params.PubKeyHashAddrID},
+		{"scriptHashAddrID", &params.ScriptHashAddrID},
+		{"privateKeyID", &params.PrivateKeyID},
+		{"witnessPubKeyHashAddrID", &params.WitnessPubKeyHashAddrID},
+		{"witnessScriptHashAddrID", &params.WitnessScriptHashAddrID},
+	}
+	for _, id := range ids {
+		if !custom.IsSet(id.key) {
+			continue
+		}
+		value, err := parseHexUint(custom.Get(id.key), 8)
+		if err != nil {
+			return fmt.Errorf("unable to parse bitcoin.params.%s: %v", id.key, err)
+		}
+		*id.id = byte(value)
+	}
+	hdIDs := []struct {
+		key string
+		id  *[4]byte
+	}{
+		{"hdPrivateKeyID", &params.HDPrivateKeyID},
+		{"hdPublicKeyID", &params.HDPublicKeyID},
+	}
+	for _, id := range hdIDs {
+		if !custom.IsSet(id.key) {
+			continue
+		}
+		value, err := hex.DecodeString(trimHexPrefix(custom.GetString(id.key)))
+		if err != nil || len(value) != 4 {
+			return fmt.Errorf("bitcoin.params.%s needs to be 4 hex encoded bytes", id.key)
+		}
+		copy(id.id[:], value)
+	}
+	if custom.IsSet("hdCoinType") {
+		params.HDCoinType = custom.GetUint32("hdCoinType")
+	}
+	return nil
+}
+
+// parseHexUint parses toml integers as they are and strings as hex, with or without a 0x prefix
+func parseHexUint(value interface{}, bitSize int) (uint64, error) {
+	if str, ok := value.(string); ok {
+		return strconv.ParseUint(trimHexPrefix(str), 16, bitSize)
+	}
+	var i uint64
+	switch v := value.(type) {
+	case int64:
+		i = uint64(v)
+	case int:
+		i = uint64(v)
+	default:
+		return 0, fmt.Errorf("expected an integer or a hex string, got %T", value)
+	}
+	if i >= 1<<uint(bitSize) {
+		return 0, fmt.Errorf("value %d out of range", i)
+	}
+	return i, nil
+}
+
+func trimHexPrefix(s string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+}
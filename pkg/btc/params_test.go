@@ -0,0 +1,135 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+)
+
+var litecoinConfig = []byte(`
+[bitcoin]
+network = "mainnet"
+[bitcoin.params]
+name = "litecoin"
+net = "0xdbb6c0fb"
+genesisHash = "12a765e31ffd4059bada1e25190f6e98c99d9714d334efa41a195a7e7e04bfe2"
+pubKeyHashAddrID = 0x30
+scriptHashAddrID = "0x32"
+privateKeyID = 0xb0
+bech32HRPSegwit = "ltc"
+hdPrivateKeyID = "0x0488ade4"
+hdPublicKeyID = "0x0488b21e"
+hdCoinType = 2
+defaultPort = "9333"
+`)
+
+var _ = Describe("Params", func() {
+	AfterEach(func() {
+		viper.Reset()
+	})
+	Describe("NetworkParams", func() {
+		It("Returns a copy of the params of the named network", func() {
+			params, err := btc.NetworkParams("TestNet3")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params.Net).To(Equal(chaincfg.TestNet3Params.Net))
+			params.PubKeyHashAddrID = 0xff
+			Expect(chaincfg.TestNet3Params.PubKeyHashAddrID).ToNot(Equal(byte(0xff)))
+
+			params, err = btc.NetworkParams("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params.Name).To(Equal(chaincfg.MainNetParams.Name))
+		})
+
+		It("Defines the default signet", func() {
+			params, err := btc.NetworkParams("signet")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params.GenesisHash.String()).To(Equal("00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6"))
+			Expect(params.GenesisBlock.BlockHash()).To(Equal(*params.GenesisHash))
+			Expect(uint32(params.Net)).To(Equal(uint32(0x40cf030a)))
+			Expect(params.Bech32HRPSegwit).To(Equal("tb"))
+		})
+
+		It("Throws an error for unknown networks", func() {
+			_, err := btc.NetworkParams("moonnet")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("ParamsFromConfig", func() {
+		It("Validates the configured network against the node info", func() {
+			viper.Set("bitcoin.network", "regtest")
+			params, err := btc.ParamsFromConfig(node.Node{
+				GenesisBlock: chaincfg.RegressionNetParams.GenesisHash.String(),
+				NetworkID:    "0xdab5bffa",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params.Bech32HRPSegwit).To(Equal("bcrt"))
+
+			_, err = btc.ParamsFromConfig(node.Node{
+				GenesisBlock: chaincfg.MainNetParams.GenesisHash.String(),
+			})
+			Expect(err).To(HaveOccurred())
+			_, err = btc.ParamsFromConfig(node.Node{
+				NetworkID: "0xD9B4BEF9",
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Applies custom params for chains derived from bitcoin", func() {
+			viper.SetConfigType("toml")
+			err := viper.ReadConfig(bytes.NewBuffer(litecoinConfig))
+			Expect(err).ToNot(HaveOccurred())
+			params, err := btc.ParamsFromConfig(node.Node{
+				GenesisBlock: "12a765e31ffd4059bada1e25190f6e98c99d9714d334efa41a195a7e7e04bfe2",
+				NetworkID:    "0xdbb6c0fb",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params.Name).To(Equal("litecoin"))
+			Expect(params.PubKeyHashAddrID).To(Equal(byte(0x30)))
+			Expect(params.ScriptHashAddrID).To(Equal(byte(0x32)))
+			Expect(params.PrivateKeyID).To(Equal(byte(0xb0)))
+			Expect(params.HDCoinType).To(Equal(uint32(2)))
+			Expect(params.DefaultPort).To(Equal("9333"))
+			Expect(chaincfg.MainNetParams.PubKeyHashAddrID).To(Equal(byte(0x00)))
+
+			addr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), params)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(addr.EncodeAddress()).To(HavePrefix("L"))
+			decoded, err := btcutil.DecodeAddress(addr.EncodeAddress(), params)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.IsForNet(params)).To(BeTrue())
+			segwit, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), params)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segwit.EncodeAddress()).To(HavePrefix("ltc1"))
+		})
+
+		It("Throws an error for malformed custom params", func() {
+			viper.Set("bitcoin.params.pubKeyHashAddrID", 300)
+			_, err := btc.ParamsFromConfig(node.Node{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
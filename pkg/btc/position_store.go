@@ -0,0 +1,99 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+)
+
+// PositionStore persists the height and hash of the last block imported by the block file importer
+type PositionStore interface {
+	// Position returns the last persisted position, or sql.ErrNoRows if there is none
+	Position() (int64, string, error)
+	SetPosition(height int64, hash string) error
+}
+
+// PGPositionStore satisfies the PositionStore interface, persisting the position of the node's importer in Postgres
+type PGPositionStore struct {
+	db *postgres.DB
+}
+
+// NewPGImportPositionStore creates a pointer to a new PGPositionStore which persists the position of the block file
+// importer
+func NewPGImportPositionStore(db *postgres.DB) *PGPositionStore {
+	return &PGPositionStore{
+		db: db,
+	}
+}
+
+// Position returns the height and hash of the last block processed, or sql.ErrNoRows if there is none
+func (s *PGPositionStore) Position() (int64, string, error) {
+	pgStr := `SELECT block_number, block_hash FROM btc.import_positions
+			WHERE node_id = $1`
+	var position struct {
+		BlockNumber int64  `db:"block_number"`
+		BlockHash   string `db:"block_hash"`
+	}
+	err := s.db.Get(&position, pgStr, s.db.NodeID)
+	return position.BlockNumber, position.BlockHash, err
+}
+
+// SetPosition persists the height and hash of the last block processed
+func (s *PGPositionStore) SetPosition(height int64, hash string) error {
+	pgStr := `INSERT INTO btc.import_positions (node_id, block_number, block_hash) VALUES ($1, $2, $3)
+							ON CONFLICT (node_id) DO UPDATE SET (block_number, block_hash) = ($2, $3)`
+	_, err := s.db.Exec(pgStr, s.db.NodeID, height, hash)
+	return err
+}
+
+// PGIndexedChain satisfies the IndexedChain interface using the btc headers indexed in Postgres
+type PGIndexedChain struct {
+	db *postgres.DB
+}
+
+// NewPGIndexedChain creates a pointer to a new PGIndexedChain
+func NewPGIndexedChain(db *postgres.DB) *PGIndexedChain {
+	return &PGIndexedChain{
+		db: db,
+	}
+}
+
+// IndexedHead returns the height and hash of the best block at the highest indexed height, or sql.ErrNoRows if no
+// block has been indexed
+// Like HeaderCIDByNumber, the best block is the one validated the most times
+func (c *PGIndexedChain) IndexedHead() (int64, string, error) {
+	pgStr := `SELECT block_number, block_hash FROM btc.header_cids
+			ORDER BY block_number DESC, times_validated DESC, id ASC
+			LIMIT 1`
+	var head struct {
+		BlockNumber int64  `db:"block_number"`
+		BlockHash   string `db:"block_hash"`
+	}
+	err := c.db.Get(&head, pgStr)
+	return head.BlockNumber, head.BlockHash, err
+}
+
+// IndexedHash returns the hash of the best block indexed at the provided height, or sql.ErrNoRows if there is none
+// Like HeaderCIDByNumber, the best block is the one validated the most times
+func (c *PGIndexedChain) IndexedHash(height int64) (string, error) {
+	pgStr := `SELECT block_hash FROM btc.header_cids
+			WHERE block_number = $1
+			ORDER BY times_validated DESC, id ASC
+			LIMIT 1`
+	var hash string
+	return hash, c.db.Get(&hash, pgStr, height)
+}
@@ -0,0 +1,87 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"database/sql"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("PGPositionStore", func() {
+	var (
+		db    *postgres.DB
+		store *btc.PGPositionStore
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		store = btc.NewPGImportPositionStore(db)
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	It("Persists the position of the importer", func() {
+		_, _, err := store.Position()
+		Expect(err).To(Equal(sql.ErrNoRows))
+		hash := mocks.MockBlock.Header.BlockHash().String()
+		Expect(store.SetPosition(mocks.MockBlockHeight, hash)).To(Succeed())
+		Expect(store.SetPosition(mocks.MockBlockHeight+1, hash)).To(Succeed())
+		height, storedHash, err := store.Position()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(height).To(Equal(mocks.MockBlockHeight + 1))
+		Expect(storedHash).To(Equal(hash))
+	})
+})
+
+var _ = Describe("PGIndexedChain", func() {
+	var (
+		db      *postgres.DB
+		indexed *btc.PGIndexedChain
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		indexed = btc.NewPGIndexedChain(db)
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	It("Reports the best block at the highest indexed height", func() {
+		_, _, err := indexed.IndexedHead()
+		Expect(err).To(Equal(sql.ErrNoRows))
+		err = btc.NewCIDIndexer(db).Index(&mocks.MockCIDPayload)
+		Expect(err).ToNot(HaveOccurred())
+		height, hash, err := indexed.IndexedHead()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(height).To(Equal(mocks.MockBlockHeight))
+		Expect(hash).To(Equal(mocks.MockCIDPayload.HeaderCID.BlockHash))
+		indexedHash, err := indexed.IndexedHash(mocks.MockBlockHeight)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(indexedHash).To(Equal(hash))
+	})
+})
@@ -33,6 +33,9 @@ type SubscriptionSettings struct {
 	End          *big.Int // set to 0 or a negative value to have no ending block
 	HeaderFilter HeaderFilter
 	TxFilter     TxFilter
+	// Confirmations is the number of blocks built on top of a block before it is sent, none sends blocks at the head
+	// It is an rlp tail of at most one value, so that settings encoded before it was added still decode
+	Confirmations []uint64 `rlp:"tail"`
 }
 
 // HeaderFilter contains filter settings for headers
@@ -61,6 +64,10 @@ func NewBtcSubscriptionConfig() (*SubscriptionSettings, error) {
 	// 0 start means we start at the beginning and 0 end means we continue indefinitely
 	sc.Start = big.NewInt(viper.GetInt64("watcher.btcSubscription.startingBlock"))
 	sc.End = big.NewInt(viper.GetInt64("watcher.btcSubscription.endingBlock"))
+	// Below defaults to 0, which means blocks are sent as soon as they are synced
+	if confirmations := viper.GetUint64("watcher.btcSubscription.confirmations"); confirmations > 0 {
+		sc.Confirmations = []uint64{confirmations}
+	}
 	// Below default to false, which means we get all headers by default
 	sc.HeaderFilter = HeaderFilter{
 		Off: viper.GetBool("watcher.btcSubscription.headerFilter.off"),
@@ -109,6 +116,14 @@ func (sc *SubscriptionSettings) HistoricalDataOnly() bool {
 	return sc.BackFillOnly
 }
 
+// ConfirmationDepth satisfies the SubscriptionSettings() interface
+func (sc *SubscriptionSettings) ConfirmationDepth() int64 {
+	if len(sc.Confirmations) == 0 {
+		return 0
+	}
+	return int64(sc.Confirmations[0])
+}
+
 // ChainType satisfies the SubscriptionSettings() interface
 func (sc *SubscriptionSettings) ChainType() shared.ChainType {
 	return shared.Bitcoin
@@ -45,6 +45,16 @@ func (cp ConvertedPayload) Height() int64 {
 	return cp.BlockPayload.BlockHeight
 }
 
+// Hash satisfies the StreamedIPLDs interface
+func (cp ConvertedPayload) Hash() string {
+	return cp.BlockPayload.Header.BlockHash().String()
+}
+
+// ParentHash satisfies the StreamedIPLDs interface
+func (cp ConvertedPayload) ParentHash() string {
+	return cp.BlockPayload.Header.PrevBlock.String()
+}
+
 // CIDPayload is a struct to hold all the CIDs and their associated meta data for indexing in Postgres
 // Returned by IPLDPublisher
 // Passed to CIDIndexer
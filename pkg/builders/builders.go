@@ -51,7 +51,8 @@ func NewCIDIndexer(chain shared.ChainType, db *postgres.DB, ipfsMode shared.IPFS
 		case shared.LocalInterface, shared.RemoteClient:
 			return eth.NewCIDIndexer(db), nil
 		case shared.DirectPostgres:
-			return eth.NewIPLDPublisherAndIndexer(db), nil
+			// The IPLDPublisherAndIndexer indexes when publishing, only its no-op Index method is used here
+			return eth.NewIPLDPublisherAndIndexer(db, nil, nil), nil
 		default:
 			return nil, fmt.Errorf("ethereum CIDIndexer unexpected ipfs mode %s", ipfsMode.String())
 		}
@@ -60,7 +61,7 @@ func NewCIDIndexer(chain shared.ChainType, db *postgres.DB, ipfsMode shared.IPFS
 		case shared.LocalInterface, shared.RemoteClient:
 			return btc.NewCIDIndexer(db), nil
 		case shared.DirectPostgres:
-			return eth.NewIPLDPublisherAndIndexer(db), nil
+			return eth.NewIPLDPublisherAndIndexer(db, nil, nil), nil
 		default:
 			return nil, fmt.Errorf("bitcoin CIDIndexer unexpected ipfs mode %s", ipfsMode.String())
 		}
@@ -82,7 +83,8 @@ func NewCIDRetriever(chain shared.ChainType, db *postgres.DB) (shared.CIDRetriev
 }
 
 // NewPayloadStreamer constructs a PayloadStreamer for the provided chain type
-func NewPayloadStreamer(chain shared.ChainType, clientOrConfig interface{}) (shared.PayloadStreamer, chan shared.RawChainData, error) {
+// The bitcoin streamer persists its position in the provided database
+func NewPayloadStreamer(chain shared.ChainType, clientOrConfig interface{}, db *postgres.DB) (shared.PayloadStreamer, chan shared.RawChainData, error) {
 	switch chain {
 	case shared.Ethereum:
 		ethClient, ok := clientOrConfig.(*rpc.Client)
@@ -92,12 +94,19 @@ func NewPayloadStreamer(chain shared.ChainType, clientOrConfig interface{}) (sha
 		streamChan := make(chan shared.RawChainData, eth.PayloadChanBufferSize)
 		return eth.NewPayloadStreamer(ethClient), streamChan, nil
 	case shared.Bitcoin:
-		btcClientConn, ok := clientOrConfig.(*rpcclient.ConnConfig)
-		if !ok {
-			return nil, nil, fmt.Errorf("bitcoin payload streamer constructor expected client config type %T got %T", rpcclient.ConnConfig{}, clientOrConfig)
-		}
 		streamChan := make(chan shared.RawChainData, btc.PayloadChanBufferSize)
-		return btc.NewHTTPPayloadStreamer(btcClientConn), streamChan, nil
+		switch btcClient := clientOrConfig.(type) {
+		case *btc.P2PClient:
+			return btc.NewP2PPayloadStreamer(btcClient), streamChan, nil
+		case *rpcclient.ConnConfig:
+			rpcClient, err := rpcclient.New(btcClient, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			return btc.NewHTTPPayloadStreamer(rpcClient, btc.NewPGIndexedChain(db)), streamChan, nil
+		default:
+			return nil, nil, fmt.Errorf("bitcoin payload streamer constructor expected client config type %T or %T got %T", &rpcclient.ConnConfig{}, &btc.P2PClient{}, clientOrConfig)
+		}
 	default:
 		return nil, nil, fmt.Errorf("invalid chain %s for streamer constructor", chain.String())
 	}
@@ -113,23 +122,57 @@ func NewPaylaodFetcher(chain shared.ChainType, client interface{}, timeout time.
 		}
 		return eth.NewPayloadFetcher(batchClient, timeout), nil
 	case shared.Bitcoin:
-		connConfig, ok := client.(*rpcclient.ConnConfig)
-		if !ok {
-			return nil, fmt.Errorf("bitcoin payload fetcher constructor expected client type %T got %T", &rpcclient.Client{}, client)
+		switch btcClient := client.(type) {
+		case *btc.P2PClient:
+			return btc.NewP2PPayloadFetcher(btcClient, timeout), nil
+		case *rpcclient.ConnConfig:
+			return btc.NewPayloadFetcher(btcClient)
+		default:
+			return nil, fmt.Errorf("bitcoin payload fetcher constructor expected client config type %T or %T got %T", &rpcclient.ConnConfig{}, &btc.P2PClient{}, client)
 		}
-		return btc.NewPayloadFetcher(connConfig)
 	default:
 		return nil, fmt.Errorf("invalid chain %s for payload fetcher constructor", chain.String())
 	}
 }
 
+// NewHeadFetcher constructs a HeadFetcher for the provided chain type
+func NewHeadFetcher(chain shared.ChainType, client interface{}) (shared.HeadFetcher, error) {
+	switch chain {
+	case shared.Ethereum:
+		ethClient, ok := client.(*rpc.Client)
+		if !ok {
+			return nil, fmt.Errorf("ethereum head fetcher constructor expected client type %T got %T", &rpc.Client{}, client)
+		}
+		return eth.NewHeadFetcher(ethClient), nil
+	case shared.Bitcoin:
+		switch btcClient := client.(type) {
+		case *btc.P2PClient:
+			return btcClient, nil
+		case *rpcclient.ConnConfig:
+			return btc.NewHeadFetcher(btcClient)
+		default:
+			return nil, fmt.Errorf("bitcoin head fetcher constructor expected client config type %T or %T got %T", &rpcclient.ConnConfig{}, &btc.P2PClient{}, client)
+		}
+	default:
+		return nil, fmt.Errorf("invalid chain %s for head fetcher constructor", chain.String())
+	}
+}
+
 // NewPayloadConverter constructs a PayloadConverter for the provided chain type
-func NewPayloadConverter(chain shared.ChainType) (shared.PayloadConverter, error) {
+func NewPayloadConverter(chain shared.ChainType, chainConfig interface{}) (shared.PayloadConverter, error) {
 	switch chain {
 	case shared.Ethereum:
-		return eth.NewPayloadConverter(params.MainnetChainConfig), nil
+		ethConfig, ok := chainConfig.(*params.ChainConfig)
+		if !ok {
+			return nil, fmt.Errorf("ethereum converter constructor expected chain config type %T got %T", &params.ChainConfig{}, chainConfig)
+		}
+		return eth.NewPayloadConverter(ethConfig), nil
 	case shared.Bitcoin:
-		return btc.NewPayloadConverter(&chaincfg.MainNetParams), nil
+		btcParams, ok := chainConfig.(*chaincfg.Params)
+		if !ok {
+			return nil, fmt.Errorf("bitcoin converter constructor expected chain config type %T got %T", &chaincfg.Params{}, chainConfig)
+		}
+		return btc.NewPayloadConverter(btcParams), nil
 	default:
 		return nil, fmt.Errorf("invalid chain %s for converter constructor", chain.String())
 	}
@@ -162,14 +205,19 @@ func NewIPLDFetcher(chain shared.ChainType, ipfsPath string, db *postgres.DB, ip
 }
 
 // NewIPLDPublisher constructs an IPLDPublisher for the provided chain type
-func NewIPLDPublisher(chain shared.ChainType, ipfsPath string, db *postgres.DB, ipfsMode shared.IPFSMode) (shared.IPLDPublisher, error) {
+// For ethereum in direct postgres mode, the provided client is used to fetch the contract code missing from the state diffs
+func NewIPLDPublisher(chain shared.ChainType, chainConfig interface{}, ipfsPath string, db *postgres.DB, ipfsMode shared.IPFSMode, client interface{}) (shared.IPLDPublisher, error) {
 	switch chain {
 	case shared.Ethereum:
+		ethConfig, ok := chainConfig.(*params.ChainConfig)
+		if !ok {
+			return nil, fmt.Errorf("ethereum IPLDPublisher constructor expected chain config type %T got %T", &params.ChainConfig{}, chainConfig)
+		}
 		switch ipfsMode {
 		case shared.LocalInterface, shared.RemoteClient:
-			return eth.NewIPLDPublisher(ipfsPath)
+			return eth.NewIPLDPublisher(ipfsPath, ethConfig)
 		case shared.DirectPostgres:
-			return eth.NewIPLDPublisherAndIndexer(db), nil
+			return eth.NewIPLDPublisherAndIndexer(db, ethConfig, newCodeFetcher(client)), nil
 		default:
 			return nil, fmt.Errorf("ethereum IPLDPublisher unexpected ipfs mode %s", ipfsMode.String())
 		}
@@ -187,22 +235,78 @@ func NewIPLDPublisher(chain shared.ChainType, ipfsPath string, db *postgres.DB,
 	}
 }
 
-// NewPublicAPI constructs a PublicAPI for the provided chain type
-func NewPublicAPI(chain shared.ChainType, db *postgres.DB, ipfsPath string) (rpc.API, error) {
+// NewCodeBackFiller constructs a CodeBackFiller for the provided chain type
+// A nil CodeBackFiller is returned when the chain has no contract code to backfill or code is not published in the ipfs mode
+func NewCodeBackFiller(chain shared.ChainType, db *postgres.DB, ipfsMode shared.IPFSMode, client interface{}) *eth.CodeBackFiller {
+	if chain != shared.Ethereum || ipfsMode != shared.DirectPostgres {
+		return nil
+	}
+	fetcher := newCodeFetcher(client)
+	if fetcher == nil {
+		return nil
+	}
+	return eth.NewCodeBackFiller(db, fetcher)
+}
+
+// newCodeFetcher returns a CodeFetcher for the provided client, or nil if it is not an ethereum rpc client
+func newCodeFetcher(client interface{}) *eth.CodeFetcher {
+	ethClient, ok := client.(*rpc.Client)
+	if !ok || ethClient == nil {
+		return nil
+	}
+	return eth.NewCodeFetcher(ethClient)
+}
+
+// NewPublicAPI constructs the PublicAPIs for the provided chain type
+// If an upstream proxy client is provided, the pending block queries of the eth methods in the proxy allow-list are forwarded to it
+// The eth api settings are ignored for other chains
+func NewPublicAPI(chain shared.ChainType, chainConfig interface{}, db *postgres.DB, ipfsPath string, payloads shared.PayloadSubscriber, status shared.SyncStatusReporter, proxyClient *rpc.Client, proxyMethods []string, ethAPIConfig eth.APIConfig) ([]rpc.API, error) {
 	switch chain {
 	case shared.Ethereum:
-		backend, err := eth.NewEthBackend(db)
-		if err != nil {
-			return rpc.API{}, err
+		ethConfig, ok := chainConfig.(*params.ChainConfig)
+		if !ok {
+			return nil, fmt.Errorf("ethereum public api constructor expected chain config type %T got %T", &params.ChainConfig{}, chainConfig)
+		}
+		backend := eth.NewEthBackend(db, ethConfig, ethAPIConfig)
+		apis := make([]rpc.API, 0, 2)
+		var proxy *eth.Proxy
+		if proxyClient != nil {
+			proxy = eth.NewProxy(proxyClient, proxyMethods)
 		}
-		return rpc.API{
+		apis = append(apis, rpc.API{
 			Namespace: eth.APIName,
 			Version:   eth.APIVersion,
-			Service:   eth.NewPublicEthAPI(backend),
+			Service:   eth.NewPublicEthAPI(backend, payloads, status, proxy),
 			Public:    true,
+		})
+		if ethAPIConfig.DebugAPI {
+			apis = append(apis, rpc.API{
+				Namespace: eth.DebugAPIName,
+				Version:   eth.APIVersion,
+				Service:   eth.NewPublicDebugAPI(backend, ethAPIConfig.MaxTraceTimeout),
+				Public:    true,
+			})
+		}
+		return apis, nil
+	case shared.Bitcoin:
+		btcParams, ok := chainConfig.(*chaincfg.Params)
+		if !ok {
+			return nil, fmt.Errorf("bitcoin public api constructor expected chain config type %T got %T", &chaincfg.Params{}, chainConfig)
+		}
+		backend, err := btc.NewBtcBackend(db, btcParams)
+		if err != nil {
+			return nil, err
+		}
+		return []rpc.API{
+			{
+				Namespace: btc.APIName,
+				Version:   btc.APIVersion,
+				Service:   btc.NewPublicBtcAPI(backend),
+				Public:    true,
+			},
 		}, nil
 	default:
-		return rpc.API{}, fmt.Errorf("invalid chain %s for public api constructor", chain.String())
+		return nil, fmt.Errorf("invalid chain %s for public api constructor", chain.String())
 	}
 }
 
@@ -0,0 +1,235 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package electrum_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/electrum"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	mocks2 "github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared/mocks"
+)
+
+var (
+	mockTx     = mocks.MockBlock.Transactions[1]
+	txID       = mockTx.TxHash().String()
+	scriptHash = btc.ScriptHash(mockTx.TxOut[0].PkScript)
+	status     = func() string {
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:", txID, mocks.MockBlockHeight)))
+		return hex.EncodeToString(hash[:])
+	}()
+)
+
+// message is a response or notification read from the server
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// client is an electrum client connected to the service over an in-memory pipe
+type client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	id     int
+}
+
+func newClient(service *electrum.Service) *client {
+	clientConn, serverConn := net.Pipe()
+	go service.ServeConn(serverConn)
+	return &client{conn: clientConn, reader: bufio.NewReader(clientConn)}
+}
+
+func (c *client) read() message {
+	Expect(c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+	line, err := c.reader.ReadBytes('\n')
+	Expect(err).ToNot(HaveOccurred())
+	var msg message
+	Expect(json.Unmarshal(line, &msg)).To(Succeed())
+	return msg
+}
+
+func (c *client) call(method string, params ...interface{}) message {
+	c.id++
+	if params == nil {
+		params = []interface{}{}
+	}
+	req, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+	Expect(err).ToNot(HaveOccurred())
+	_, err = c.conn.Write(append(req, '\n'))
+	Expect(err).ToNot(HaveOccurred())
+	msg := c.read()
+	Expect(msg.ID).ToNot(BeNil())
+	Expect(*msg.ID).To(Equal(c.id))
+	return msg
+}
+
+func (c *client) result(res interface{}, method string, params ...interface{}) {
+	msg := c.call(method, params...)
+	Expect(msg.Error).To(BeNil())
+	Expect(json.Unmarshal(msg.Result, res)).To(Succeed())
+}
+
+var _ = Describe("Electrum", func() {
+	var (
+		db       *postgres.DB
+		payloads *mocks2.PayloadSubscriber
+		service  *electrum.Service
+		c        *client
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		backend, err := btc.NewBtcBackend(db, &chaincfg.MainNetParams)
+		Expect(err).ToNot(HaveOccurred())
+		payloads = new(mocks2.PayloadSubscriber)
+		service, err = electrum.New(btc.NewPublicBtcAPI(backend), payloads, "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		c = newClient(service)
+	})
+	AfterEach(func() {
+		c.conn.Close()
+		Expect(service.Stop()).To(Succeed())
+		btc.TearDownDB(db)
+	})
+
+	Describe("requests", func() {
+		BeforeEach(func() {
+			_, err := btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Negotiates the protocol version", func() {
+			var version []string
+			c.result(&version, "server.version", "test", []string{"1.2", "1.4.2"})
+			Expect(len(version)).To(Equal(2))
+			Expect(version[1]).To(Equal(electrum.ProtocolVersion))
+			msg := c.call("server.version", "test", "1.2")
+			Expect(msg.Error).ToNot(BeNil())
+			Expect(msg.Error.Code).To(Equal(1))
+		})
+		It("Returns the header of the block at the provided height", func() {
+			var header string
+			c.result(&header, "blockchain.block.header", mocks.MockBlockHeight)
+			buf := new(bytes.Buffer)
+			Expect(mocks.MockBlock.Header.Serialize(buf)).To(Succeed())
+			Expect(header).To(Equal(hex.EncodeToString(buf.Bytes())))
+			msg := c.call("blockchain.block.header", mocks.MockBlockHeight+1)
+			Expect(msg.Error).ToNot(BeNil())
+		})
+		It("Returns the balance of a script hash", func() {
+			var balance electrum.Balance
+			c.result(&balance, "blockchain.scripthash.get_balance", scriptHash)
+			Expect(balance.Confirmed).To(Equal(mockTx.TxOut[0].Value))
+			Expect(balance.Unconfirmed).To(BeZero())
+		})
+		It("Returns the history of a script hash", func() {
+			var history []electrum.HistoryItem
+			c.result(&history, "blockchain.scripthash.get_history", scriptHash)
+			Expect(history).To(Equal([]electrum.HistoryItem{{TxHash: txID, Height: mocks.MockBlockHeight}}))
+		})
+		It("Returns the unspent outputs of a script hash", func() {
+			var utxos []electrum.UTXO
+			c.result(&utxos, "blockchain.scripthash.listunspent", scriptHash)
+			Expect(utxos).To(Equal([]electrum.UTXO{{TxHash: txID, TxPos: 0, Height: mocks.MockBlockHeight, Value: mockTx.TxOut[0].Value}}))
+		})
+		It("Returns the status of a subscribed script hash", func() {
+			var res *string
+			c.result(&res, "blockchain.scripthash.subscribe", scriptHash)
+			Expect(res).ToNot(BeNil())
+			Expect(*res).To(Equal(status))
+			var unsubscribed bool
+			c.result(&unsubscribed, "blockchain.scripthash.unsubscribe", scriptHash)
+			Expect(unsubscribed).To(BeTrue())
+		})
+		It("Returns the serialized transaction", func() {
+			var tx string
+			c.result(&tx, "blockchain.transaction.get", txID)
+			buf := new(bytes.Buffer)
+			Expect(mockTx.Serialize(buf)).To(Succeed())
+			Expect(tx).To(Equal(hex.EncodeToString(buf.Bytes())))
+		})
+		It("Returns errors for unknown methods and invalid params", func() {
+			msg := c.call("blockchain.unknown")
+			Expect(msg.Error).ToNot(BeNil())
+			Expect(msg.Error.Code).To(Equal(-32601))
+			msg = c.call("blockchain.scripthash.get_balance", "notascripthash")
+			Expect(msg.Error).ToNot(BeNil())
+			Expect(msg.Error.Code).To(Equal(1))
+			msg = c.call("blockchain.scripthash.get_balance")
+			Expect(msg.Error).ToNot(BeNil())
+			Expect(msg.Error.Code).To(Equal(-32602))
+		})
+	})
+
+	Describe("start up", func() {
+		It("Indexes missing script hashes in the background and answers the scripthash methods once they are indexed", func() {
+			_, err := btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = db.Exec(`UPDATE btc.tx_outputs SET script_hash = NULL`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(service.Start()).To(Succeed())
+			msg := c.call("blockchain.scripthash.get_balance", scriptHash)
+			if msg.Error != nil {
+				Expect(msg.Error.Message).To(ContainSubstring("being indexed"))
+			}
+			Eventually(func() bool {
+				return c.call("blockchain.scripthash.get_balance", scriptHash).Error == nil
+			}).Should(BeTrue())
+			var balance electrum.Balance
+			c.result(&balance, "blockchain.scripthash.get_balance", scriptHash)
+			Expect(balance.Confirmed).To(Equal(mockTx.TxOut[0].Value))
+		})
+	})
+
+	Describe("subscriptions", func() {
+		It("Notifies subscribed clients when a served payload has been indexed", func() {
+			var res *string
+			c.result(&res, "blockchain.scripthash.subscribe", scriptHash)
+			Expect(res).To(BeNil())
+			Expect(service.Start()).To(Succeed())
+			Eventually(func() int { return payloads.Send(mocks.MockConvertedPayload) }).Should(Equal(1))
+			_, err := btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			msg := c.read()
+			Expect(msg.Method).To(Equal("blockchain.scripthash.subscribe"))
+			var params []string
+			Expect(json.Unmarshal(msg.Params, &params)).To(Succeed())
+			Expect(params).To(Equal([]string{scriptHash, status}))
+		})
+	})
+})
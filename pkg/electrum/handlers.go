@@ -0,0 +1,320 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package electrum
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// handlerFunc answers a request of a session with the provided positional params
+type handlerFunc func(sess *session, params []json.RawMessage) (interface{}, error)
+
+// serverVersion is the server software version reported to clients
+var serverVersion = "ipfs-blockchain-watcher " + version.VersionWithMeta
+
+// handlers returns the handlers of the supported methods
+func (s *Service) handlers() map[string]handlerFunc {
+	return map[string]handlerFunc{
+		"server.version":                    s.negotiateVersion,
+		"server.ping":                       s.ping,
+		"server.banner":                     s.banner,
+		"server.features":                   s.features,
+		"blockchain.block.header":           s.blockHeader,
+		"blockchain.headers.subscribe":      s.headersSubscribe,
+		"blockchain.scripthash.get_balance": s.getBalance,
+		"blockchain.scripthash.get_history": s.getHistory,
+		"blockchain.scripthash.listunspent": s.listUnspent,
+		"blockchain.scripthash.subscribe":   s.scriptHashSubscribe,
+		"blockchain.scripthash.unsubscribe": s.scriptHashUnsubscribe,
+		"blockchain.transaction.get":        s.transactionGet,
+	}
+}
+
+// call calls the handler of the method for the session
+func (s *Service) call(sess *session, method string, params []json.RawMessage) (interface{}, error) {
+	handler, ok := s.methods[method]
+	if !ok {
+		return nil, &rpcError{code: errCodeMethodNotFound, message: fmt.Sprintf("unknown method %q", method)}
+	}
+	return handler(sess, params)
+}
+
+// negotiateVersion negotiates the protocol version with the client
+func (s *Service) negotiateVersion(sess *session, params []json.RawMessage) (interface{}, error) {
+	var clientName string
+	var protocolVersion json.RawMessage
+	if err := parseParams(params, 0, &clientName, &protocolVersion); err != nil {
+		return nil, err
+	}
+	if protocolVersion != nil && !supportsProtocol(protocolVersion) {
+		return nil, &rpcError{code: errCodeBadRequest, message: fmt.Sprintf("unsupported protocol version: %s", string(protocolVersion))}
+	}
+	return []string{serverVersion, ProtocolVersion}, nil
+}
+
+// ping does nothing, it is used by clients to keep the connection alive
+func (s *Service) ping(sess *session, params []json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+// banner returns the server banner
+func (s *Service) banner(sess *session, params []json.RawMessage) (interface{}, error) {
+	return fmt.Sprintf("Welcome to %s, serving electrum protocol %s from an ipfs-backed btc index", serverVersion, ProtocolVersion), nil
+}
+
+// features describes the server
+func (s *Service) features(sess *session, params []json.RawMessage) (interface{}, error) {
+	return Features{
+		GenesisHash:   s.api.B.Params.GenesisHash.String(),
+		Hosts:         map[string]interface{}{},
+		ProtocolMax:   ProtocolVersion,
+		ProtocolMin:   ProtocolVersion,
+		ServerVersion: serverVersion,
+		HashFunction:  "sha256",
+	}, nil
+}
+
+// blockHeader returns the hex encoded header of the block at the provided height
+// Checkpoint proofs are not supported
+func (s *Service) blockHeader(sess *session, params []json.RawMessage) (interface{}, error) {
+	var height, cpHeight int64
+	if err := parseParams(params, 1, &height, &cpHeight); err != nil {
+		return nil, err
+	}
+	if cpHeight != 0 {
+		return nil, &rpcError{code: errCodeBadRequest, message: "checkpoint proofs are not supported"}
+	}
+	return s.rawHeader(height)
+}
+
+// headersSubscribe subscribes the client to new headers and returns the header of the most recent block
+func (s *Service) headersSubscribe(sess *session, params []json.RawMessage) (interface{}, error) {
+	if err := parseParams(params, 0); err != nil {
+		return nil, err
+	}
+	height, err := s.api.B.LastBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	header, err := s.rawHeader(height)
+	if err != nil {
+		return nil, err
+	}
+	sess.subscribeHeaders()
+	return Header{Height: height, Hex: header}, nil
+}
+
+// getBalance returns the balance of the provided script hash
+func (s *Service) getBalance(sess *session, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := s.scriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.api.B.ScriptHashBalance(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	return Balance{Confirmed: balance}, nil
+}
+
+// getHistory returns the transactions funding or spending the outputs of the provided script hash
+func (s *Service) getHistory(sess *session, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := s.scriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.api.B.ScriptHashHistory(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]HistoryItem, len(history))
+	for i, tx := range history {
+		items[i] = HistoryItem{TxHash: tx.TxHash, Height: tx.BlockNumber}
+	}
+	return items, nil
+}
+
+// listUnspent returns the unspent outputs of the provided script hash
+func (s *Service) listUnspent(sess *session, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := s.scriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	utxos, err := s.api.B.ScriptHashUTXOs(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]UTXO, len(utxos))
+	for i, utxo := range utxos {
+		res[i] = UTXO{TxHash: utxo.TxHash, TxPos: utxo.Index, Height: utxo.BlockNumber, Value: utxo.Value}
+	}
+	return res, nil
+}
+
+// scriptHashSubscribe subscribes the client to the provided script hash and returns its status
+func (s *Service) scriptHashSubscribe(sess *session, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := s.scriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	status, err := s.scriptHashStatus(scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	sess.subscribeScriptHash(scriptHash, status)
+	return statusResult(status), nil
+}
+
+// scriptHashUnsubscribe unsubscribes the client from the provided script hash, returning whether it was subscribed
+func (s *Service) scriptHashUnsubscribe(sess *session, params []json.RawMessage) (interface{}, error) {
+	scriptHash, err := s.scriptHashParam(params)
+	if err != nil {
+		return nil, err
+	}
+	return sess.unsubscribeScriptHash(scriptHash), nil
+}
+
+// transactionGet returns the transaction with the provided id, hex encoded or, if verbose, decoded as by bitcoind
+func (s *Service) transactionGet(sess *session, params []json.RawMessage) (interface{}, error) {
+	var txid string
+	var verbose bool
+	if err := parseParams(params, 1, &txid, &verbose); err != nil {
+		return nil, err
+	}
+	var verbosity btc.Verbosity
+	if verbose {
+		verbosity = 1
+	}
+	return s.api.GetRawTransaction(txid, &verbosity, nil)
+}
+
+// rawHeader returns the hex encoded header of the block at the provided height
+func (s *Service) rawHeader(height int64) (string, error) {
+	header, err := s.api.B.RawHeaderByNumber(height)
+	if err == sql.ErrNoRows {
+		return "", &rpcError{code: errCodeBadRequest, message: fmt.Sprintf("height %d out of range", height)}
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(header), nil
+}
+
+// scriptHashStatus returns the status of the provided script hash, or an empty string if it has no history
+// The status is the hex encoded sha256 hash of the concatenated "tx_hash:height:" of each transaction in its history
+func (s *Service) scriptHashStatus(scriptHash string) (string, error) {
+	history, err := s.api.B.ScriptHashHistory(scriptHash)
+	if err != nil || len(history) == 0 {
+		return "", err
+	}
+	var status strings.Builder
+	for _, tx := range history {
+		fmt.Fprintf(&status, "%s:%d:", tx.TxHash, tx.BlockNumber)
+	}
+	hash := sha256.Sum256([]byte(status.String()))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// statusResult returns the status as it is sent to clients, which is null if the script hash has no history
+func statusResult(status string) interface{} {
+	if status == "" {
+		return nil
+	}
+	return status
+}
+
+// parseParams decodes the positional params into the provided args
+// The first required number of params must be present, the rest are optional
+func parseParams(params []json.RawMessage, required int, args ...interface{}) error {
+	if len(params) < required || len(params) > len(args) {
+		return &rpcError{code: errCodeInvalidParams, message: fmt.Sprintf("expected %d to %d params, got %d", required, len(args), len(params))}
+	}
+	for i, param := range params {
+		if err := json.Unmarshal(param, args[i]); err != nil {
+			return &rpcError{code: errCodeInvalidParams, message: fmt.Sprintf("invalid param %d: %s", i, err.Error())}
+		}
+	}
+	return nil
+}
+
+// errIndexing is returned by the blockchain.scripthash methods while the script hashes of previously indexed outputs
+// are being indexed
+var errIndexing = &rpcError{code: errCodeDaemon, message: "script hashes are being indexed, try again later"}
+
+// scriptHashParam decodes and validates the script hash param of the blockchain.scripthash methods
+// It returns errIndexing until the script hashes of all indexed outputs are known
+func (s *Service) scriptHashParam(params []json.RawMessage) (string, error) {
+	if atomic.LoadInt32(&s.indexing) == 1 {
+		return "", errIndexing
+	}
+	var scriptHash string
+	if err := parseParams(params, 1, &scriptHash); err != nil {
+		return "", err
+	}
+	scriptHash = strings.ToLower(scriptHash)
+	if decoded, err := hex.DecodeString(scriptHash); err != nil || len(decoded) != sha256.Size {
+		return "", &rpcError{code: errCodeBadRequest, message: fmt.Sprintf("invalid script hash %s", scriptHash)}
+	}
+	return scriptHash, nil
+}
+
+// supportsProtocol returns whether the protocol version requested by a client, either a single version or a
+// [min, max] range, includes the protocol version of the server
+func supportsProtocol(requested json.RawMessage) bool {
+	var min, max string
+	var versionRange []string
+	if err := json.Unmarshal(requested, &min); err == nil {
+		max = min
+	} else if err := json.Unmarshal(requested, &versionRange); err == nil && len(versionRange) == 2 {
+		min, max = versionRange[0], versionRange[1]
+	} else {
+		return false
+	}
+	return compareVersions(min, ProtocolVersion) <= 0 && compareVersions(ProtocolVersion, max) <= 0
+}
+
+// compareVersions compares two dotted version strings numerically, returning -1, 0 or 1
+// Missing or malformed components count as zero
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
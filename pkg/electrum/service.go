@@ -0,0 +1,292 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package electrum
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+const (
+	scriptHashBatchSize   = 10000                  // the number of outputs whose script hash is indexed at a time on start up
+	scriptHashRetryDelay  = 10 * time.Second       // how long to wait before retrying to index script hashes after an error
+	payloadChanBufferSize = 100                    // the buffer size of the channel the served payloads are received on
+	indexPollInterval     = 200 * time.Millisecond // how often to check whether the block of a served payload has been indexed
+	indexTimeout          = time.Minute            // how long to wait for the block of a served payload to be indexed
+)
+
+// Service encapsulates an Electrum json-rpc over tcp service.
+type Service struct {
+	endpoint string                   // The host:port endpoint for this service.
+	api      *btc.PublicBtcAPI        // The btc api the requests are answered with.
+	payloads shared.PayloadSubscriber // The source of the payloads the subscriptions are updated from.
+	methods  map[string]handlerFunc   // The handlers of the supported methods.
+	listener net.Listener             // The listening socket.
+	quit     chan struct{}            // Closed when the service is stopped.
+	lock     sync.Mutex               // Guards the sessions.
+	sessions map[*session]struct{}    // The open client connections.
+	indexing int32                    // Set while the script hashes of previously indexed outputs are being indexed.
+}
+
+// New constructs a new Electrum service instance which answers requests using the provided btc api
+// Subscriptions are updated from the payloads of the provided subscriber, if it is nil they are never notified
+func New(api *btc.PublicBtcAPI, payloads shared.PayloadSubscriber, endpoint string) (*Service, error) {
+	s := &Service{
+		endpoint: endpoint,
+		api:      api,
+		payloads: payloads,
+		quit:     make(chan struct{}),
+		sessions: make(map[*session]struct{}),
+	}
+	s.methods = s.handlers()
+	return s, nil
+}
+
+// Start opens the Electrum endpoint and serves requests and subscriptions in background goroutines
+// The script hashes of any outputs that do not have them yet are indexed in the background; until that has caught up,
+// the blockchain.scripthash methods answer with an error
+func (s *Service) Start() error {
+	var err error
+	if s.listener, err = net.Listen("tcp", s.endpoint); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&s.indexing, 1)
+	go s.indexScriptHashes()
+	go s.accept(s.listener)
+	if s.payloads != nil {
+		go s.servePayloads()
+	}
+	log.Infof("Electrum endpoint opened at tcp://%s", s.endpoint)
+	return nil
+}
+
+// Stop closes the Electrum endpoint and all client connections
+func (s *Service) Stop() error {
+	select {
+	case <-s.quit:
+		return nil
+	default:
+		close(s.quit)
+	}
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+		log.Infof("Electrum endpoint closed at tcp://%s", s.endpoint)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for sess := range s.sessions {
+		sess.conn.Close()
+	}
+	return nil
+}
+
+// ServeConn serves the requests of a client on the provided connection until it is closed
+func (s *Service) ServeConn(conn net.Conn) {
+	sess := newSession(s, conn)
+	s.lock.Lock()
+	s.sessions[sess] = struct{}{}
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.sessions, sess)
+		s.lock.Unlock()
+	}()
+	sess.serve()
+}
+
+// accept serves each connection made to the listener in its own goroutine
+func (s *Service) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+			default:
+				log.Errorf("electrum server accept error: %v", err)
+			}
+			return
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// indexScriptHashes indexes the script hashes of the outputs that do not have them yet, retrying after errors until it
+// succeeds or the service is stopped
+func (s *Service) indexScriptHashes() {
+	defer atomic.StoreInt32(&s.indexing, 0)
+	indexer := btc.NewCIDIndexer(s.api.B.DB)
+	for {
+		updated, err := indexer.IndexScriptHashes(scriptHashBatchSize)
+		if err == nil {
+			if updated > 0 {
+				log.Infof("indexed script hashes of %d btc outputs", updated)
+			}
+			return
+		}
+		log.Errorf("electrum server error indexing btc output script hashes: %v", err)
+		select {
+		case <-time.After(scriptHashRetryDelay):
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// servePayloads updates the subscriptions of the open sessions with each btc payload served by the watcher
+func (s *Service) servePayloads() {
+	payloadChan := make(chan shared.ConvertedData, payloadChanBufferSize)
+	sub := s.payloads.SubscribePayloads(payloadChan)
+	defer sub.Unsubscribe()
+	var highest int64
+	for {
+		select {
+		case data := <-payloadChan:
+			payload, ok := data.(btc.ConvertedPayload)
+			if !ok {
+				log.Errorf("electrum server expected payload type %T got %T", btc.ConvertedPayload{}, data)
+				continue
+			}
+			if err := s.waitForIndex(payload); err != nil {
+				log.Errorf("electrum server error waiting for block at height %d to be indexed: %v", payload.Height(), err)
+				continue
+			}
+			if payload.Height() >= highest {
+				highest = payload.Height()
+				s.notifyHeader(payload)
+			}
+			s.notifyScriptHashes(payload)
+		case err := <-sub.Err():
+			if err != nil {
+				log.Errorf("electrum server payload feed error: %v", err)
+			}
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// waitForIndex blocks until the block of the payload has been indexed
+// Payloads are served concurrently with their publishing and indexing, while the subscriptions are updated from the
+// indexed data
+func (s *Service) waitForIndex(payload btc.ConvertedPayload) error {
+	hash := payload.Header.BlockHash()
+	ticker := time.NewTicker(indexPollInterval)
+	defer ticker.Stop()
+	timeout := time.After(indexTimeout)
+	for {
+		_, err := s.api.B.HeaderCIDByHash(hash)
+		if err != sql.ErrNoRows {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			return fmt.Errorf("block %s was not indexed within %s", hash.String(), indexTimeout.String())
+		case <-s.quit:
+			return errors.New("electrum server stopped")
+		}
+	}
+}
+
+// notifyHeader sends the header of the payload to the sessions subscribed to headers
+func (s *Service) notifyHeader(payload btc.ConvertedPayload) {
+	buf := bytes.NewBuffer(make([]byte, 0, wire.MaxBlockHeaderPayload))
+	if err := payload.Header.Serialize(buf); err != nil {
+		log.Errorf("electrum server header serialization error: %v", err)
+		return
+	}
+	header := Header{Height: payload.Height(), Hex: hex.EncodeToString(buf.Bytes())}
+	for _, sess := range s.openSessions() {
+		if sess.headersSubscribed() {
+			sess.notify("blockchain.headers.subscribe", header)
+		}
+	}
+}
+
+// notifyScriptHashes sends the new status of each script hash funded or spent by the payload to the sessions
+// subscribed to it, if the status has changed since it was last sent
+func (s *Service) notifyScriptHashes(payload btc.ConvertedPayload) {
+	sessions := s.openSessions()
+	if len(sessions) == 0 {
+		return
+	}
+	touched := make(map[string]bool)
+	for _, tx := range payload.TxMetaData {
+		for _, output := range tx.TxOutputs {
+			touched[btc.ScriptHash(output.PkScript)] = true
+		}
+	}
+	// Look up the outputs spent by all of the inputs in the block at once
+	spending := wire.NewMsgTx(wire.TxVersion)
+	for _, tx := range payload.Txs {
+		for _, txIn := range tx.MsgTx().TxIn {
+			spending.AddTxIn(txIn)
+		}
+	}
+	prevOuts, err := s.api.B.PrevOutputs(spending)
+	if err != nil {
+		log.Errorf("electrum server error retrieving outputs spent at height %d: %v", payload.Height(), err)
+		return
+	}
+	for _, output := range prevOuts {
+		if output.ScriptHash.Valid {
+			touched[output.ScriptHash.String] = true
+		}
+	}
+	statuses := make(map[string]string)
+	for _, sess := range sessions {
+		for _, scriptHash := range sess.subscribedScriptHashes(touched) {
+			status, ok := statuses[scriptHash]
+			if !ok {
+				if status, err = s.scriptHashStatus(scriptHash); err != nil {
+					log.Errorf("electrum server error computing status of script hash %s: %v", scriptHash, err)
+					continue
+				}
+				statuses[scriptHash] = status
+			}
+			if sess.updateStatus(scriptHash, status) {
+				sess.notify("blockchain.scripthash.subscribe", scriptHash, statusResult(status))
+			}
+		}
+	}
+}
+
+// openSessions returns the sessions of the open client connections
+func (s *Service) openSessions() []*session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
@@ -0,0 +1,216 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package electrum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxRequestSize = 1024 * 1024      // the maximum size of a request line
+	writeTimeout   = 30 * time.Second // how long a write to a client may take
+)
+
+// session is the connection of a client along with its subscriptions
+type session struct {
+	srv       *Service
+	conn      net.Conn
+	writeLock sync.Mutex
+
+	subLock      sync.Mutex
+	headers      bool              // whether the client is subscribed to headers
+	scriptHashes map[string]string // the script hashes the client is subscribed to and the last status sent for each
+}
+
+func newSession(srv *Service, conn net.Conn) *session {
+	return &session{
+		srv:          srv,
+		conn:         conn,
+		scriptHashes: make(map[string]string),
+	}
+}
+
+// serve reads newline delimited requests from the connection and writes the responses until the connection is closed
+func (s *session) serve() {
+	defer s.conn.Close()
+	scanner := bufio.NewScanner(s.conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxRequestSize)
+	for scanner.Scan() {
+		msg := bytes.TrimSpace(scanner.Bytes())
+		if len(msg) == 0 {
+			continue
+		}
+		res := s.handleMessage(msg)
+		if res == nil {
+			continue
+		}
+		if err := s.write(res); err != nil {
+			log.Debugf("electrum server error writing to %s: %v", s.conn.RemoteAddr(), err)
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Debugf("electrum server error reading from %s: %v", s.conn.RemoteAddr(), err)
+	}
+}
+
+// handleMessage handles a single request or a batch of requests, returning the response(s) to write
+// Nil is returned if there is nothing to write back, as is the case for notifications sent by the client
+func (s *session) handleMessage(msg []byte) interface{} {
+	if msg[0] != '[' {
+		if res := s.handleRequest(msg); res != nil {
+			return res
+		}
+		return nil
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil {
+		return errorResponse(nil, &rpcError{code: errCodeParse, message: err.Error()})
+	}
+	if len(batch) == 0 {
+		return errorResponse(nil, &rpcError{code: errCodeInvalidRequest, message: "empty batch"})
+	}
+	responses := make([]*response, 0, len(batch))
+	for _, req := range batch {
+		if res := s.handleRequest(req); res != nil {
+			responses = append(responses, res)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+// handleRequest calls the handler of the requested method and returns the response
+func (s *session) handleRequest(msg []byte) *response {
+	if !json.Valid(msg) {
+		return errorResponse(nil, &rpcError{code: errCodeParse, message: "invalid json"})
+	}
+	var req request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return errorResponse(nil, &rpcError{code: errCodeInvalidRequest, message: err.Error()})
+	}
+	result, err := s.srv.call(s, req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	res, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, err)
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: res}
+}
+
+// errorResponse returns the error response to the request with the provided id
+func errorResponse(id json.RawMessage, err error) *response {
+	jsonErr := &jsonError{Code: errCodeDaemon, Message: err.Error()}
+	switch e := err.(type) {
+	case *rpcError:
+		jsonErr.Code = e.code
+	case interface{ ErrorCode() int }:
+		jsonErr.Code = e.ErrorCode()
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: jsonErr}
+}
+
+// notify sends a notification to the client, logging any error
+func (s *session) notify(method string, params ...interface{}) {
+	if err := s.write(notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		log.Debugf("electrum server error notifying %s: %v", s.conn.RemoteAddr(), err)
+	}
+}
+
+// write writes the json encoding of the message to the connection, followed by a newline
+func (s *session) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if err := s.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	_, err = s.conn.Write(append(data, '\n'))
+	return err
+}
+
+// subscribeHeaders subscribes the client to new headers
+func (s *session) subscribeHeaders() {
+	s.subLock.Lock()
+	defer s.subLock.Unlock()
+	s.headers = true
+}
+
+// headersSubscribed returns whether the client is subscribed to new headers
+func (s *session) headersSubscribed() bool {
+	s.subLock.Lock()
+	defer s.subLock.Unlock()
+	return s.headers
+}
+
+// subscribeScriptHash subscribes the client to the script hash, recording the status it was sent
+func (s *session) subscribeScriptHash(scriptHash, status string) {
+	s.subLock.Lock()
+	defer s.subLock.Unlock()
+	s.scriptHashes[scriptHash] = status
+}
+
+// unsubscribeScriptHash unsubscribes the client from the script hash, returning whether it was subscribed
+func (s *session) unsubscribeScriptHash(scriptHash string) bool {
+	s.subLock.Lock()
+	defer s.subLock.Unlock()
+	_, ok := s.scriptHashes[scriptHash]
+	delete(s.scriptHashes, scriptHash)
+	return ok
+}
+
+// subscribedScriptHashes returns those of the provided script hashes the client is subscribed to
+func (s *session) subscribedScriptHashes(scriptHashes map[string]bool) []string {
+	s.subLock.Lock()
+	defer s.subLock.Unlock()
+	subscribed := make([]string, 0)
+	for scriptHash := range s.scriptHashes {
+		if scriptHashes[scriptHash] {
+			subscribed = append(subscribed, scriptHash)
+		}
+	}
+	return subscribed
+}
+
+// updateStatus records the status of a script hash the client is subscribed to, returning whether it has changed
+func (s *session) updateStatus(scriptHash, status string) bool {
+	s.subLock.Lock()
+	defer s.subLock.Unlock()
+	last, ok := s.scriptHashes[scriptHash]
+	if !ok || last == status {
+		return false
+	}
+	s.scriptHashes[scriptHash] = status
+	return true
+}
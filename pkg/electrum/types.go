@@ -0,0 +1,110 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package electrum
+
+import (
+	"encoding/json"
+)
+
+// ProtocolVersion is the version of the electrum protocol spoken by the server
+const ProtocolVersion = "1.4"
+
+// Error codes reported to clients, the json-rpc 2.0 codes followed by those used by ElectrumX
+const (
+	errCodeParse          = -32700
+	errCodeInvalidRequest = -32600
+	errCodeMethodNotFound = -32601
+	errCodeInvalidParams  = -32602
+	errCodeBadRequest     = 1
+	errCodeDaemon         = 2
+)
+
+// request is a json-rpc request sent by a client; params are positional
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// response is the json-rpc response to a request
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonError      `json:"error,omitempty"`
+}
+
+// notification is a json-rpc notification sent to a client for one of its subscriptions
+type notification struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// jsonError is the error of a json-rpc response
+type jsonError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcError is an error carrying the code it is reported to the client with
+type rpcError struct {
+	code    int
+	message string
+}
+
+func (e *rpcError) Error() string {
+	return e.message
+}
+
+// Header is the header of a block, as returned by blockchain.headers.subscribe and sent in its notifications
+type Header struct {
+	Height int64  `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+// Balance is the balance of a script hash, as returned by blockchain.scripthash.get_balance
+// The watcher does not track the mempool so the unconfirmed balance is always zero
+type Balance struct {
+	Confirmed   int64 `json:"confirmed"`
+	Unconfirmed int64 `json:"unconfirmed"`
+}
+
+// HistoryItem is a transaction involving a script hash, as returned by blockchain.scripthash.get_history
+type HistoryItem struct {
+	TxHash string `json:"tx_hash"`
+	Height int64  `json:"height"`
+}
+
+// UTXO is an unspent output of a script hash, as returned by blockchain.scripthash.listunspent
+type UTXO struct {
+	TxHash string `json:"tx_hash"`
+	TxPos  int64  `json:"tx_pos"`
+	Height int64  `json:"height"`
+	Value  int64  `json:"value"`
+}
+
+// Features describes the server, as returned by server.features
+type Features struct {
+	GenesisHash   string                 `json:"genesis_hash"`
+	Hosts         map[string]interface{} `json:"hosts"`
+	ProtocolMax   string                 `json:"protocol_max"`
+	ProtocolMin   string                 `json:"protocol_min"`
+	Pruning       *int64                 `json:"pruning"`
+	ServerVersion string                 `json:"server_version"`
+	HashFunction  string                 `json:"hash_function"`
+}
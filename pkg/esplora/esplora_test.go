@@ -0,0 +1,190 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package esplora_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/esplora"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var (
+	blockHash = mocks.MockBlock.Header.BlockHash().String()
+	mockTx    = mocks.MockBlock.Transactions[1]
+	txID      = mockTx.TxHash().String()
+)
+
+func get(handler http.Handler, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func getJSON(handler http.Handler, path string, res interface{}) {
+	rec := get(handler, path)
+	Expect(rec.Code).To(Equal(http.StatusOK))
+	Expect(json.Unmarshal(rec.Body.Bytes(), res)).To(Succeed())
+}
+
+var _ = Describe("Esplora", func() {
+	var (
+		db      *postgres.DB
+		handler http.Handler
+		address string
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+		backend, err := btc.NewBtcBackend(db, &chaincfg.MainNetParams)
+		Expect(err).ToNot(HaveOccurred())
+		handler = esplora.NewHandler(backend)
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(mockTx.TxOut[0].PkScript, &chaincfg.MainNetParams)
+		Expect(err).ToNot(HaveOccurred())
+		address = addrs[0].EncodeAddress()
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	Describe("/block", func() {
+		It("Returns the block with the provided hash", func() {
+			var block esplora.Block
+			getJSON(handler, "/block/"+blockHash, &block)
+			Expect(block.ID).To(Equal(blockHash))
+			Expect(block.Height).To(Equal(mocks.MockBlockHeight))
+			Expect(block.TxCount).To(Equal(3))
+			Expect(block.Size).To(Equal(mocks.MockBlock.SerializeSize()))
+			Expect(block.MerkleRoot).To(Equal(mocks.MockBlock.Header.MerkleRoot.String()))
+			Expect(block.PreviousBlockHash).To(Equal(mocks.MockBlock.Header.PrevBlock.String()))
+			Expect(block.Timestamp).To(Equal(mocks.MockBlock.Header.Timestamp.Unix()))
+			Expect(block.MedianTime).To(Equal(mocks.MockBlock.Header.Timestamp.Unix()))
+			Expect(block.Bits).To(Equal(mocks.MockBlock.Header.Bits))
+		})
+		It("Returns the transactions of the block", func() {
+			var txs []esplora.Tx
+			getJSON(handler, "/block/"+blockHash+"/txs", &txs)
+			Expect(len(txs)).To(Equal(3))
+			Expect(txs[0].Vin[0].IsCoinbase).To(BeTrue())
+			Expect(txs[1].TxID).To(Equal(txID))
+			Expect(txs[1].Status.BlockHash).To(Equal(blockHash))
+		})
+		It("Returns 404 for an unknown block", func() {
+			rec := get(handler, "/block/"+mocks.MockBlock.Header.PrevBlock.String())
+			Expect(rec.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("/block-height", func() {
+		It("Returns the hash of the block at the provided height", func() {
+			rec := get(handler, "/block-height/"+strconv.Itoa(int(mocks.MockBlockHeight)))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(Equal(blockHash))
+		})
+	})
+
+	Describe("/tx", func() {
+		It("Returns the transaction with the provided id", func() {
+			var tx esplora.Tx
+			getJSON(handler, "/tx/"+txID, &tx)
+			Expect(tx.TxID).To(Equal(txID))
+			Expect(tx.Size).To(Equal(mockTx.SerializeSize()))
+			Expect(tx.Status.Confirmed).To(BeTrue())
+			Expect(tx.Status.BlockHeight).To(Equal(mocks.MockBlockHeight))
+			Expect(tx.Status.BlockTime).To(Equal(mocks.MockBlock.Header.Timestamp.Unix()))
+			Expect(tx.Vin[0].TxID).To(Equal(mockTx.TxIn[0].PreviousOutPoint.Hash.String()))
+			// the spent output is not indexed
+			Expect(tx.Vin[0].Prevout).To(BeNil())
+			Expect(tx.Fee).To(Equal(int64(0)))
+			Expect(len(tx.Vout)).To(Equal(len(mockTx.TxOut)))
+			Expect(tx.Vout[0].Value).To(Equal(mockTx.TxOut[0].Value))
+			Expect(tx.Vout[0].ScriptPubKeyType).To(Equal("p2pkh"))
+			Expect(tx.Vout[0].ScriptPubKeyAddress).To(Equal(address))
+		})
+		It("Returns the serialized transaction", func() {
+			rec := get(handler, "/tx/"+txID+"/hex")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			buf := new(bytes.Buffer)
+			Expect(mockTx.Serialize(buf)).To(Succeed())
+			Expect(rec.Body.String()).To(Equal(hex.EncodeToString(buf.Bytes())))
+		})
+		It("Returns the spending status of the outputs of the transaction", func() {
+			var outspends []esplora.Outspend
+			getJSON(handler, "/tx/"+txID+"/outspends", &outspends)
+			Expect(len(outspends)).To(Equal(len(mockTx.TxOut)))
+			for _, outspend := range outspends {
+				Expect(outspend.Spent).To(BeFalse())
+			}
+		})
+		It("Returns 404 for an unknown transaction", func() {
+			rec := get(handler, "/tx/"+blockHash)
+			Expect(rec.Code).To(Equal(http.StatusNotFound))
+			rec = get(handler, "/tx/"+blockHash+"/outspends")
+			Expect(rec.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("/address", func() {
+		It("Returns the stats of the address", func() {
+			var addr esplora.Address
+			getJSON(handler, "/address/"+address, &addr)
+			Expect(addr.Address).To(Equal(address))
+			Expect(addr.ChainStats.FundedTxoCount).To(Equal(int64(1)))
+			Expect(addr.ChainStats.FundedTxoSum).To(Equal(mockTx.TxOut[0].Value))
+			Expect(addr.ChainStats.SpentTxoCount).To(Equal(int64(0)))
+			Expect(addr.ChainStats.TxCount).To(Equal(int64(1)))
+		})
+		It("Returns the transactions of the address", func() {
+			var txs []esplora.Tx
+			getJSON(handler, "/address/"+address+"/txs", &txs)
+			Expect(len(txs)).To(Equal(1))
+			Expect(txs[0].TxID).To(Equal(txID))
+			getJSON(handler, "/address/"+address+"/txs/chain/"+txID, &txs)
+			Expect(len(txs)).To(Equal(0))
+		})
+		It("Returns the unspent outputs of the address", func() {
+			var utxos []esplora.UTXO
+			getJSON(handler, "/address/"+address+"/utxo", &utxos)
+			Expect(len(utxos)).To(Equal(1))
+			Expect(utxos[0].TxID).To(Equal(txID))
+			Expect(utxos[0].Vout).To(Equal(uint32(0)))
+			Expect(utxos[0].Value).To(Equal(mockTx.TxOut[0].Value))
+			Expect(utxos[0].Status.BlockHash).To(Equal(blockHash))
+		})
+		It("Returns 400 for an invalid address", func() {
+			rec := get(handler, "/address/notanaddress")
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
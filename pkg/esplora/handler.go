@@ -0,0 +1,499 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package esplora
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+)
+
+const (
+	blockTxsPageSize   = 25 // the number of transactions returned by a /block/:hash/txs request
+	addressTxsPageSize = 25 // the number of transactions returned by a /address/:addr/txs request
+)
+
+var (
+	errBlockNotFound = &httpError{status: http.StatusNotFound, message: "Block not found"}
+	errTxNotFound    = &httpError{status: http.StatusNotFound, message: "Transaction not found"}
+)
+
+// httpError is an error answered with the provided status code
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func badRequest(message string) *httpError {
+	return &httpError{status: http.StatusBadRequest, message: message}
+}
+
+// text is a response that is written as plain text rather than json
+type text string
+
+// Handler answers Esplora REST requests from the indexed btc data
+type Handler struct {
+	b *btc.Backend
+}
+
+// NewHandler creates a new Handler over the provided btc Backend
+func NewHandler(b *btc.Backend) *Handler {
+	return &Handler{
+		b: b,
+	}
+}
+
+// ServeHTTP routes the request to the endpoint matching its path
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var res interface{}
+	var err error
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "block":
+		res, err = h.block(parts[1])
+	case len(parts) == 3 && parts[0] == "block" && parts[2] == "txs":
+		res, err = h.blockTxs(parts[1], "0")
+	case len(parts) == 4 && parts[0] == "block" && parts[2] == "txs":
+		res, err = h.blockTxs(parts[1], parts[3])
+	case len(parts) == 2 && parts[0] == "block-height":
+		res, err = h.blockHeight(parts[1])
+	case len(parts) == 2 && parts[0] == "tx":
+		res, err = h.tx(parts[1])
+	case len(parts) == 3 && parts[0] == "tx" && parts[2] == "hex":
+		res, err = h.txHex(parts[1])
+	case len(parts) == 3 && parts[0] == "tx" && parts[2] == "outspends":
+		res, err = h.txOutspends(parts[1])
+	case len(parts) == 2 && parts[0] == "address":
+		res, err = h.address(parts[1])
+	case len(parts) == 3 && parts[0] == "address" && parts[2] == "txs":
+		res, err = h.addressTxs(parts[1], "")
+	case len(parts) == 4 && parts[0] == "address" && parts[2] == "txs" && parts[3] == "chain":
+		res, err = h.addressTxs(parts[1], "")
+	case len(parts) == 5 && parts[0] == "address" && parts[2] == "txs" && parts[3] == "chain":
+		res, err = h.addressTxs(parts[1], parts[4])
+	case len(parts) == 3 && parts[0] == "address" && parts[2] == "utxo":
+		res, err = h.addressUTXOs(parts[1])
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		if httpErr, ok := err.(*httpError); ok {
+			http.Error(w, httpErr.message, httpErr.status)
+			return
+		}
+		log.Errorf("esplora request %s failed: %s", r.URL.Path, err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if t, ok := res.(text); ok {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(t))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Errorf("esplora response encoding for %s failed: %s", r.URL.Path, err.Error())
+	}
+}
+
+// block answers /block/:hash
+func (h *Handler) block(hashStr string) (interface{}, error) {
+	hash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil {
+		return nil, badRequest("Invalid hex string")
+	}
+	block, height, err := h.b.BlockByHash(*hash)
+	if err == sql.ErrNoRows {
+		return nil, errBlockNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	medianTime, err := h.b.MedianTime(height)
+	if err != nil {
+		return nil, err
+	}
+	return Block{
+		ID:                hash.String(),
+		Height:            height,
+		Version:           block.Header.Version,
+		Timestamp:         block.Header.Timestamp.Unix(),
+		TxCount:           len(block.Transactions),
+		Size:              block.SerializeSize(),
+		Weight:            blockchain.GetBlockWeight(btcutil.NewBlock(block)),
+		MerkleRoot:        block.Header.MerkleRoot.String(),
+		PreviousBlockHash: block.Header.PrevBlock.String(),
+		MedianTime:        medianTime,
+		Nonce:             block.Header.Nonce,
+		Bits:              block.Header.Bits,
+		Difficulty:        btc.DifficultyRatio(block.Header.Bits, h.b.Params),
+	}, nil
+}
+
+// blockTxs answers /block/:hash/txs[/:start_index]
+func (h *Handler) blockTxs(hashStr, startStr string) (interface{}, error) {
+	hash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil {
+		return nil, badRequest("Invalid hex string")
+	}
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 0 || start%blockTxsPageSize != 0 {
+		return nil, badRequest("start index must be a multiple of " + strconv.Itoa(blockTxsPageSize))
+	}
+	block, height, err := h.b.BlockByHash(*hash)
+	if err == sql.ErrNoRows {
+		return nil, errBlockNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if start >= len(block.Transactions) {
+		return nil, badRequest("start index out of range")
+	}
+	end := start + blockTxsPageSize
+	if end > len(block.Transactions) {
+		end = len(block.Transactions)
+	}
+	txs := make([]Tx, 0, end-start)
+	for _, msgTx := range block.Transactions[start:end] {
+		tx, err := h.newTx(msgTx, &block.Header, height)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// blockHeight answers /block-height/:height
+func (h *Handler) blockHeight(heightStr string) (interface{}, error) {
+	height, err := strconv.ParseInt(heightStr, 10, 64)
+	if err != nil {
+		return nil, badRequest("Invalid height")
+	}
+	header, err := h.b.HeaderCIDByNumber(height)
+	if err == sql.ErrNoRows {
+		return nil, errBlockNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return text(header.BlockHash), nil
+}
+
+// tx answers /tx/:txid
+func (h *Handler) tx(txid string) (interface{}, error) {
+	msgTx, header, height, err := h.getTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+	return h.newTx(msgTx, header, height)
+}
+
+// txHex answers /tx/:txid/hex
+func (h *Handler) txHex(txid string) (interface{}, error) {
+	msgTx, _, _, err := h.getTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, msgTx.SerializeSize()))
+	if err := msgTx.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return text(hex.EncodeToString(buf.Bytes())), nil
+}
+
+// txOutspends answers /tx/:txid/outspends
+func (h *Handler) txOutspends(txid string) (interface{}, error) {
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, badRequest("Invalid hex string")
+	}
+	outspendModels, err := h.b.Retriever.RetrieveOutspends(hash.String())
+	if err != nil {
+		return nil, err
+	}
+	// every transaction has at least one output, so no outputs means the transaction is not indexed
+	if len(outspendModels) == 0 {
+		return nil, errTxNotFound
+	}
+	// the outspends are positioned by output index, which the last output has the highest of
+	outspends := make([]Outspend, outspendModels[len(outspendModels)-1].Index+1)
+	for _, model := range outspendModels {
+		if !model.SpendingTxHash.Valid {
+			continue
+		}
+		vin := model.SpendingIndex.Int64
+		outspends[model.Index] = Outspend{
+			Spent: true,
+			TxID:  model.SpendingTxHash.String,
+			Vin:   &vin,
+			Status: &TxStatus{
+				Confirmed:   true,
+				BlockHeight: model.BlockNumber.Int64,
+				BlockHash:   model.BlockHash.String,
+				BlockTime:   time.Unix(0, model.Timestamp.Int64).Unix(),
+			},
+		}
+	}
+	return outspends, nil
+}
+
+// address answers /address/:addr
+func (h *Handler) address(addr string) (interface{}, error) {
+	if err := h.validateAddress(addr); err != nil {
+		return nil, err
+	}
+	stats, err := h.b.AddressStats(addr)
+	if err != nil {
+		return nil, err
+	}
+	return Address{
+		Address: addr,
+		ChainStats: AddressStats{
+			FundedTxoCount: stats.FundedTxoCount,
+			FundedTxoSum:   stats.FundedTxoSum,
+			SpentTxoCount:  stats.SpentTxoCount,
+			SpentTxoSum:    stats.SpentTxoSum,
+			TxCount:        stats.TxCount,
+		},
+	}, nil
+}
+
+// addressTxs answers /address/:addr/txs and /address/:addr/txs/chain[/:last_seen_txid]
+func (h *Handler) addressTxs(addr, lastSeenStr string) (interface{}, error) {
+	if err := h.validateAddress(addr); err != nil {
+		return nil, err
+	}
+	var lastSeen *chainhash.Hash
+	if lastSeenStr != "" {
+		var err error
+		if lastSeen, err = chainhash.NewHashFromStr(lastSeenStr); err != nil {
+			return nil, badRequest("Invalid hex string")
+		}
+	}
+	txHashes, err := h.b.AddressTxs(addr, lastSeen, addressTxsPageSize)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]Tx, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		msgTx, header, height, err := h.getTransaction(txHash)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := h.newTx(msgTx, header, height)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// addressUTXOs answers /address/:addr/utxo
+func (h *Handler) addressUTXOs(addr string) (interface{}, error) {
+	if err := h.validateAddress(addr); err != nil {
+		return nil, err
+	}
+	height, err := h.b.LastBlockNumber()
+	if err == sql.ErrNoRows {
+		return []UTXO{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	utxoModels, err := h.b.Retriever.RetrieveUTXOs(height, []string{addr})
+	if err != nil {
+		return nil, err
+	}
+	utxos := make([]UTXO, len(utxoModels))
+	for i, model := range utxoModels {
+		utxos[i] = UTXO{
+			TxID: model.TxHash,
+			Vout: uint32(model.Index),
+			Status: TxStatus{
+				Confirmed:   true,
+				BlockHeight: model.BlockNumber,
+				BlockHash:   model.BlockHash,
+				BlockTime:   time.Unix(0, model.Timestamp).Unix(),
+			},
+			Value: model.Value,
+		}
+	}
+	return utxos, nil
+}
+
+// getTransaction looks up the transaction with the provided id, along with the header and height of its block
+func (h *Handler) getTransaction(txid string) (*wire.MsgTx, *wire.BlockHeader, int64, error) {
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, nil, 0, badRequest("Invalid hex string")
+	}
+	msgTx, header, height, err := h.b.GetTransaction(*hash, nil)
+	if err == sql.ErrNoRows {
+		return nil, nil, 0, errTxNotFound
+	}
+	return msgTx, header, height, err
+}
+
+// validateAddress checks that the provided address is a valid address on the backend's network
+func (h *Handler) validateAddress(addr string) error {
+	address, err := btcutil.DecodeAddress(addr, h.b.Params)
+	if err != nil || !address.IsForNet(h.b.Params) {
+		return badRequest("Invalid Bitcoin address")
+	}
+	return nil
+}
+
+// newTx converts the provided transaction into its json representation, looking up the outputs it spends
+func (h *Handler) newTx(msgTx *wire.MsgTx, header *wire.BlockHeader, height int64) (Tx, error) {
+	coinbase := blockchain.IsCoinBaseTx(msgTx)
+	var prevOuts map[wire.OutPoint]btc.TxOutput
+	if !coinbase {
+		var err error
+		if prevOuts, err = h.b.PrevOutputs(msgTx); err != nil {
+			return Tx{}, err
+		}
+	}
+	vins := make([]Vin, len(msgTx.TxIn))
+	var inValue int64
+	for i, txIn := range msgTx.TxIn {
+		// The disassembly contains [error] inline if the script does not parse, so the error is ignored
+		asm, _ := txscript.DisasmString(txIn.SignatureScript)
+		vins[i] = Vin{
+			TxID:         txIn.PreviousOutPoint.Hash.String(),
+			Vout:         txIn.PreviousOutPoint.Index,
+			ScriptSig:    hex.EncodeToString(txIn.SignatureScript),
+			ScriptSigAsm: asm,
+			Witness:      witnessToHex(txIn.Witness),
+			IsCoinbase:   coinbase,
+			Sequence:     txIn.Sequence,
+		}
+		if prevOut, ok := prevOuts[txIn.PreviousOutPoint]; ok {
+			vout := h.newVout(prevOut.PkScript, prevOut.Value)
+			vins[i].Prevout = &vout
+			inValue += prevOut.Value
+		}
+	}
+	vouts := make([]Vout, len(msgTx.TxOut))
+	var outValue int64
+	for i, txOut := range msgTx.TxOut {
+		vouts[i] = h.newVout(txOut.PkScript, txOut.Value)
+		outValue += txOut.Value
+	}
+	// The fee can only be calculated if all of the spent outputs are indexed
+	var fee int64
+	if !coinbase && len(prevOuts) == len(msgTx.TxIn) {
+		fee = inValue - outValue
+	}
+	return Tx{
+		TxID:     msgTx.TxHash().String(),
+		Version:  msgTx.Version,
+		LockTime: msgTx.LockTime,
+		Vin:      vins,
+		Vout:     vouts,
+		Size:     msgTx.SerializeSize(),
+		Weight:   blockchain.GetTransactionWeight(btcutil.NewTx(msgTx)),
+		Fee:      fee,
+		Status: TxStatus{
+			Confirmed:   true,
+			BlockHeight: height,
+			BlockHash:   header.BlockHash().String(),
+			BlockTime:   header.Timestamp.Unix(),
+		},
+	}, nil
+}
+
+// newVout converts the provided output script and value into the json representation of an output
+func (h *Handler) newVout(pkScript []byte, value int64) Vout {
+	// The disassembly contains [error] inline if the script does not parse, so the error is ignored
+	asm, _ := txscript.DisasmString(pkScript)
+	// An error means the script could not be parsed, in which case there is no address to report
+	class, addrs, _, _ := txscript.ExtractPkScriptAddrs(pkScript, h.b.Params)
+	vout := Vout{
+		ScriptPubKey:     hex.EncodeToString(pkScript),
+		ScriptPubKeyAsm:  asm,
+		ScriptPubKeyType: scriptType(class, pkScript),
+		Value:            value,
+	}
+	if len(addrs) == 1 {
+		vout.ScriptPubKeyAddress = addrs[0].EncodeAddress()
+	}
+	return vout
+}
+
+// scriptType returns the name Esplora uses for the provided script class
+func scriptType(class txscript.ScriptClass, pkScript []byte) string {
+	switch class {
+	case txscript.PubKeyTy:
+		return "p2pk"
+	case txscript.PubKeyHashTy:
+		return "p2pkh"
+	case txscript.ScriptHashTy:
+		return "p2sh"
+	case txscript.WitnessV0PubKeyHashTy:
+		return "v0_p2wpkh"
+	case txscript.WitnessV0ScriptHashTy:
+		return "v0_p2wsh"
+	case txscript.MultiSigTy:
+		return "multisig"
+	case txscript.NullDataTy:
+		return "op_return"
+	default:
+		if len(pkScript) == 0 {
+			return "empty"
+		}
+		return "unknown"
+	}
+}
+
+// witnessToHex hex encodes the items of the provided witness
+func witnessToHex(witness wire.TxWitness) []string {
+	if len(witness) == 0 {
+		return nil
+	}
+	items := make([]string, len(witness))
+	for i, item := range witness {
+		items[i] = hex.EncodeToString(item)
+	}
+	return items
+}
@@ -0,0 +1,109 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package esplora
+
+// Block is the json representation of a block returned by the /block endpoints
+type Block struct {
+	ID                string  `json:"id"`
+	Height            int64   `json:"height"`
+	Version           int32   `json:"version"`
+	Timestamp         int64   `json:"timestamp"`
+	TxCount           int     `json:"tx_count"`
+	Size              int     `json:"size"`
+	Weight            int64   `json:"weight"`
+	MerkleRoot        string  `json:"merkle_root"`
+	PreviousBlockHash string  `json:"previousblockhash"`
+	MedianTime        int64   `json:"mediantime"`
+	Nonce             uint32  `json:"nonce"`
+	Bits              uint32  `json:"bits"`
+	Difficulty        float64 `json:"difficulty"`
+}
+
+// Tx is the json representation of a transaction returned by the /tx, /block/:hash/txs and /address/:addr/txs endpoints
+type Tx struct {
+	TxID     string   `json:"txid"`
+	Version  int32    `json:"version"`
+	LockTime uint32   `json:"locktime"`
+	Vin      []Vin    `json:"vin"`
+	Vout     []Vout   `json:"vout"`
+	Size     int      `json:"size"`
+	Weight   int64    `json:"weight"`
+	Fee      int64    `json:"fee"`
+	Status   TxStatus `json:"status"`
+}
+
+// Vin is the json representation of a transaction input
+// Prevout is null if the output being spent has not been indexed
+type Vin struct {
+	TxID         string   `json:"txid"`
+	Vout         uint32   `json:"vout"`
+	Prevout      *Vout    `json:"prevout"`
+	ScriptSig    string   `json:"scriptsig"`
+	ScriptSigAsm string   `json:"scriptsig_asm"`
+	Witness      []string `json:"witness,omitempty"`
+	IsCoinbase   bool     `json:"is_coinbase"`
+	Sequence     uint32   `json:"sequence"`
+}
+
+// Vout is the json representation of a transaction output
+type Vout struct {
+	ScriptPubKey        string `json:"scriptpubkey"`
+	ScriptPubKeyAsm     string `json:"scriptpubkey_asm"`
+	ScriptPubKeyType    string `json:"scriptpubkey_type"`
+	ScriptPubKeyAddress string `json:"scriptpubkey_address,omitempty"`
+	Value               int64  `json:"value"`
+}
+
+// TxStatus is the json representation of the confirmation status of a transaction
+type TxStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height,omitempty"`
+	BlockHash   string `json:"block_hash,omitempty"`
+	BlockTime   int64  `json:"block_time,omitempty"`
+}
+
+// Outspend is the json representation of the spending status of a transaction output
+type Outspend struct {
+	Spent  bool      `json:"spent"`
+	TxID   string    `json:"txid,omitempty"`
+	Vin    *int64    `json:"vin,omitempty"`
+	Status *TxStatus `json:"status,omitempty"`
+}
+
+// Address is the json representation of an address returned by the /address/:addr endpoint
+type Address struct {
+	Address      string       `json:"address"`
+	ChainStats   AddressStats `json:"chain_stats"`
+	MempoolStats AddressStats `json:"mempool_stats"`
+}
+
+// AddressStats is the json representation of the funding and spending activity of an address
+type AddressStats struct {
+	FundedTxoCount int64 `json:"funded_txo_count"`
+	FundedTxoSum   int64 `json:"funded_txo_sum"`
+	SpentTxoCount  int64 `json:"spent_txo_count"`
+	SpentTxoSum    int64 `json:"spent_txo_sum"`
+	TxCount        int64 `json:"tx_count"`
+}
+
+// UTXO is the json representation of an unspent output returned by the /address/:addr/utxo endpoint
+type UTXO struct {
+	TxID   string   `json:"txid"`
+	Vout   uint32   `json:"vout"`
+	Status TxStatus `json:"status"`
+	Value  int64    `json:"value"`
+}
@@ -18,6 +18,9 @@ package eth
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
@@ -25,8 +28,13 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/jmoiron/sqlx"
 )
 
 // APIName is the namespace for the watcher's eth api
@@ -35,14 +43,35 @@ const APIName = "eth"
 // APIVersion is the version of the watcher's eth api
 const APIVersion = "0.0.1"
 
+const (
+	defaultGasPrice = params.GWei
+)
+
+var errInvalidBlockRange = errors.New("invalid block range params")
+
 type PublicEthAPI struct {
 	B *Backend
+	// Source of the payloads served by the watcher, used to feed eth_subscribe subscriptions
+	payloads shared.PayloadSubscriber
+	// Source of the watcher's sync status, used to answer eth_syncing
+	status shared.SyncStatusReporter
+	// Polling filters installed with eth_newFilter and eth_newBlockFilter
+	filters *filterManager
+	// Upstream node that pending block queries are forwarded to, if any
+	proxy *Proxy
 }
 
 // NewPublicEthAPI creates a new PublicEthAPI with the provided underlying Backend
-func NewPublicEthAPI(b *Backend) *PublicEthAPI {
+// The provided PayloadSubscriber feeds the eth_subscribe subscriptions; if it is nil subscriptions are not supported
+// The provided SyncStatusReporter answers eth_syncing; if it is nil the watcher is reported as not syncing
+// The provided Proxy is used to forward pending block queries upstream; if it is nil they are not supported
+func NewPublicEthAPI(b *Backend, payloads shared.PayloadSubscriber, status shared.SyncStatusReporter, proxy *Proxy) *PublicEthAPI {
 	return &PublicEthAPI{
-		B: b,
+		B:        b,
+		payloads: payloads,
+		status:   status,
+		filters:  newFilterManager(filterTimeout),
+		proxy:    proxy,
 	}
 }
 
@@ -52,104 +81,196 @@ func (pea *PublicEthAPI) BlockNumber() hexutil.Uint64 {
 	return hexutil.Uint64(number)
 }
 
+// Syncing returns false when the watcher's index is contiguous up to the head of the node it syncs from, otherwise
+// it returns the sync progress in the same format as geth: the first block streamed by the watcher, the highest block
+// below which there are no gaps in the index, and the head of the node
+func (pea *PublicEthAPI) Syncing() (interface{}, error) {
+	progress, err := SyncProgress(pea.status)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		return false, nil
+	}
+	return map[string]interface{}{
+		"startingBlock": hexutil.Uint64(progress.StartingBlock),
+		"currentBlock":  hexutil.Uint64(progress.CurrentBlock),
+		"highestBlock":  hexutil.Uint64(progress.HighestBlock),
+		"pulledStates":  hexutil.Uint64(progress.PulledStates),
+		"knownStates":   hexutil.Uint64(progress.KnownStates),
+	}, nil
+}
+
+// SyncProgress converts the sync status of the watcher into geth's sync progress
+// It returns nil if the index is contiguous up to the head of the node, or if no SyncStatusReporter is provided
+func SyncProgress(status shared.SyncStatusReporter) (*ethereum.SyncProgress, error) {
+	if status == nil {
+		return nil, nil
+	}
+	syncStatus, err := status.SyncStatus()
+	if err != nil {
+		return nil, err
+	}
+	if syncStatus.HeadBlock < 0 || syncStatus.ContiguousBlock >= syncStatus.HeadBlock {
+		return nil, nil
+	}
+	startingBlock := syncStatus.StartingBlock
+	if startingBlock == 0 || startingBlock > syncStatus.ContiguousBlock {
+		startingBlock = syncStatus.ContiguousBlock
+	}
+	return &ethereum.SyncProgress{
+		StartingBlock: uint64(startingBlock),
+		CurrentBlock:  uint64(syncStatus.ContiguousBlock),
+		HighestBlock:  uint64(syncStatus.HeadBlock),
+	}, nil
+}
+
+// ChainId returns the chain id of the chain config used to serve the eth api, as needed for EIP-155 replay protection
+func (pea *PublicEthAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(pea.B.ChainConfig.ChainID)
+}
+
 // GetLogs returns logs matching the given argument that are stored within the state.
+// Missing fromBlock and toBlock values default to the latest indexed block, as in geth.
+// Block ranges are served through the same path as the log filters: a single query over the blocks whose header bloom
+// can match the criteria, after which only the receipts of the blocks with matching receipts are fetched. Ranges and
+// results larger than the configured maximums are rejected.
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getlogs
 func (pea *PublicEthAPI) GetLogs(ctx context.Context, crit ethereum.FilterQuery) ([]*types.Log, error) {
-	// Convert FilterQuery into ReceiptFilter
-	addrStrs := make([]string, len(crit.Addresses))
-	for i, addr := range crit.Addresses {
-		addrStrs[i] = addr.String()
+	// If we have a blockhash to filter on, fire off single retrieval query
+	if crit.BlockHash != nil {
+		return pea.logsByBlockHash(ctx, *crit.BlockHash, crit)
 	}
-	topicStrSets := make([][]string, 4)
-	for i, topicSet := range crit.Topics {
-		if i > 3 {
-			// don't allow more than 4 topics
-			break
-		}
-		for _, topic := range topicSet {
-			topicStrSets[i] = append(topicStrSets[i], topic.String())
-		}
+	// Otherwise, create block range from criteria
+	// nil values are filled in; to request a single block have both ToBlock and FromBlock equal that number
+	start, err := pea.resolveFilterBlock(crit.FromBlock, pea.B.Retriever.RetrieveLastBlockNumber)
+	if err != nil {
+		return nil, err
 	}
-	filter := ReceiptFilter{
-		LogAddresses: addrStrs,
-		Topics:       topicStrSets,
+	end, err := pea.resolveFilterBlock(crit.ToBlock, pea.B.Retriever.RetrieveLastBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if start > end {
+		return nil, errInvalidBlockRange
+	}
+	return pea.logsInRange(ctx, filters.FilterCriteria(crit), start, end)
+}
+
+// checkLogRange returns an error if the provided block range spans more blocks than the configured maximum
+func (pea *PublicEthAPI) checkLogRange(start, end int64) error {
+	if pea.B.MaxLogRange > 0 && end-start+1 > pea.B.MaxLogRange {
+		return fmt.Errorf("exceed maximum block range: %d", pea.B.MaxLogRange)
+	}
+	return nil
+}
+
+// checkLogResults returns an error if the provided number of logs exceeds the configured maximum
+func (pea *PublicEthAPI) checkLogResults(n int) error {
+	if pea.B.MaxLogResults > 0 && n > pea.B.MaxLogResults {
+		return fmt.Errorf("query returned more than %d results", pea.B.MaxLogResults)
 	}
+	return nil
+}
 
-	// Begin tx
-	tx, err := pea.B.DB.Beginx()
+// logsByBlockHash returns the logs, with their derived fields, in the block with the provided hash that match the
+// criteria
+// A block which has not been indexed has no logs
+func (pea *PublicEthAPI) logsByBlockHash(ctx context.Context, hash common.Hash, crit ethereum.FilterQuery) ([]*types.Log, error) {
+	logs := make([]*types.Log, 0)
+	receipts, err := pea.B.GetReceipts(ctx, hash)
+	if err == sql.ErrNoRows {
+		return logs, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if p := recover(); p != nil {
-			shared.Rollback(tx)
-			panic(p)
-		} else if err != nil {
-			shared.Rollback(tx)
-		} else {
-			err = tx.Commit()
-		}
-	}()
+	for _, receipt := range receipts {
+		logs = append(logs, filterLogs(receipt.Logs, crit.Addresses, crit.Topics)...)
+	}
+	if err := pea.checkLogResults(len(logs)); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
 
-	// If we have a blockhash to filter on, fire off single retrieval query
-	if crit.BlockHash != nil {
-		rctCIDs, err := pea.B.Retriever.RetrieveRctCIDs(tx, filter, 0, crit.BlockHash, nil)
-		if err != nil {
-			return nil, err
+// headersMatchingBloom returns the canonical headers within the provided block range whose bloom can match the criteria
+// Headers indexed without a bloom cannot be skipped
+func (pea *PublicEthAPI) headersMatchingBloom(tx *sqlx.Tx, crit ethereum.FilterQuery, start, end int64) ([]HeaderModel, error) {
+	headers, err := pea.B.Retriever.RetrieveHeaderBloomsInRange(tx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	matching := make([]HeaderModel, 0, len(headers))
+	for _, header := range headers {
+		if len(header.Bloom) != types.BloomByteLength || BloomFilter(types.BytesToBloom(header.Bloom), crit.Addresses, crit.Topics) {
+			matching = append(matching, header)
 		}
-		rctIPLDs, err := pea.B.Fetcher.FetchRcts(tx, rctCIDs)
-		if err != nil {
-			return nil, err
+	}
+	return matching, nil
+}
+
+// BloomFilter returns false if the bloom cannot contain a log that matches the provided addresses and topics
+func BloomFilter(bloom types.Bloom, addresses []common.Address, topics [][]common.Hash) bool {
+	if len(addresses) > 0 {
+		var included bool
+		for _, addr := range addresses {
+			if types.BloomLookup(bloom, addr) {
+				included = true
+				break
+			}
 		}
-		if err := tx.Commit(); err != nil {
-			return nil, err
+		if !included {
+			return false
 		}
-		return extractLogsOfInterest(rctIPLDs, filter.Topics)
 	}
-	// Otherwise, create block range from criteria
-	// nil values are filled in; to request a single block have both ToBlock and FromBlock equal that number
-	startingBlock := crit.FromBlock
-	endingBlock := crit.ToBlock
-	if startingBlock == nil {
-		startingBlockInt, err := pea.B.Retriever.RetrieveFirstBlockNumber()
-		if err != nil {
-			return nil, err
+	for _, sub := range topics {
+		included := len(sub) == 0 // empty rule set == wildcard
+		for _, topic := range sub {
+			if types.BloomLookup(bloom, topic) {
+				included = true
+				break
+			}
 		}
-		startingBlock = big.NewInt(startingBlockInt)
-	}
-	if endingBlock == nil {
-		endingBlockInt, err := pea.B.Retriever.RetrieveLastBlockNumber()
-		if err != nil {
-			return nil, err
+		if !included {
+			return false
 		}
-		endingBlock = big.NewInt(endingBlockInt)
 	}
-	start := startingBlock.Int64()
-	end := endingBlock.Int64()
-	allRctCIDs := make([]ReceiptModel, 0)
-	for i := start; i <= end; i++ {
-		rctCIDs, err := pea.B.Retriever.RetrieveRctCIDs(tx, filter, i, nil, nil)
-		if err != nil {
-			return nil, err
-		}
-		allRctCIDs = append(allRctCIDs, rctCIDs...)
+	return true
+}
+
+// newReceiptFilter converts log filter criteria into a ReceiptFilter
+func newReceiptFilter(addresses []common.Address, topics [][]common.Hash) ReceiptFilter {
+	addrStrs := make([]string, len(addresses))
+	for i, addr := range addresses {
+		addrStrs[i] = addr.String()
 	}
-	rctIPLDs, err := pea.B.Fetcher.FetchRcts(tx, allRctCIDs)
-	if err != nil {
-		return nil, err
+	topicStrSets := make([][]string, 4)
+	for i, topicSet := range topics {
+		if i > 3 {
+			// don't allow more than 4 topics
+			break
+		}
+		for _, topic := range topicSet {
+			topicStrSets[i] = append(topicStrSets[i], topic.String())
+		}
 	}
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	return ReceiptFilter{
+		LogAddresses: addrStrs,
+		Topics:       topicStrSets,
 	}
-	logs, err := extractLogsOfInterest(rctIPLDs, filter.Topics)
-	return logs, err // need to return err variable so that we return the err = tx.Commit() assignment in the defer
 }
 
 // GetHeaderByNumber returns the requested canonical block header.
 // * When blockNr is -1 the chain head is returned.
 // * We cannot support pending block calls since we do not have an active miner
 func (pea *PublicEthAPI) GetHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (map[string]interface{}, error) {
+	if number == rpc.PendingBlockNumber && pea.proxy.Forwards("eth_getHeaderByNumber") {
+		var res map[string]interface{}
+		err := pea.proxy.Forward(ctx, &res, "eth_getHeaderByNumber", pendingBlock)
+		return res, err
+	}
 	header, err := pea.B.HeaderByNumber(ctx, number)
 	if header != nil && err == nil {
 		return pea.rpcMarshalHeader(header)
@@ -163,6 +284,11 @@ func (pea *PublicEthAPI) GetHeaderByNumber(ctx context.Context, number rpc.Block
 // * When fullTx is true all transactions in the block are returned, otherwise
 //   only the transaction hash is returned.
 func (pea *PublicEthAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	if number == rpc.PendingBlockNumber && pea.proxy.Forwards("eth_getBlockByNumber") {
+		var res map[string]interface{}
+		err := pea.proxy.Forward(ctx, &res, "eth_getBlockByNumber", pendingBlock, fullTx)
+		return res, err
+	}
 	block, err := pea.B.BlockByNumber(ctx, number)
 	if block != nil && err == nil {
 		return pea.rpcMarshalBlock(block, true, fullTx)
@@ -180,6 +306,91 @@ func (pea *PublicEthAPI) GetBlockByHash(ctx context.Context, hash common.Hash, f
 	return nil, err
 }
 
+// GetBlockTransactionCountByNumber returns the number of transactions in the block with the given block number
+func (pea *PublicEthAPI) GetBlockTransactionCountByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*hexutil.Uint, error) {
+	count, err := pea.B.GetBlockTransactionCount(ctx, rpc.BlockNumberOrHashWithNumber(blockNr))
+	if err != nil {
+		return nil, err
+	}
+	n := hexutil.Uint(count)
+	return &n, nil
+}
+
+// GetBlockTransactionCountByHash returns the number of transactions in the block with the given hash
+func (pea *PublicEthAPI) GetBlockTransactionCountByHash(ctx context.Context, blockHash common.Hash) (*hexutil.Uint, error) {
+	count, err := pea.B.GetBlockTransactionCount(ctx, rpc.BlockNumberOrHashWithHash(blockHash, false))
+	if err != nil {
+		return nil, err
+	}
+	n := hexutil.Uint(count)
+	return &n, nil
+}
+
+// GetTransactionByBlockNumberAndIndex returns the transaction for the given block number and index
+func (pea *PublicEthAPI) GetTransactionByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (*RPCTransaction, error) {
+	return pea.transactionByBlockAndIndex(ctx, rpc.BlockNumberOrHashWithNumber(blockNr), uint64(index))
+}
+
+// GetTransactionByBlockHashAndIndex returns the transaction for the given block hash and index
+func (pea *PublicEthAPI) GetTransactionByBlockHashAndIndex(ctx context.Context, blockHash common.Hash, index hexutil.Uint) (*RPCTransaction, error) {
+	return pea.transactionByBlockAndIndex(ctx, rpc.BlockNumberOrHashWithHash(blockHash, false), uint64(index))
+}
+
+func (pea *PublicEthAPI) transactionByBlockAndIndex(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, index uint64) (*RPCTransaction, error) {
+	tx, blockHash, blockNumber, err := pea.B.GetTransactionByBlockAndIndex(ctx, blockNrOrHash, index)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		return NewRPCTransaction(tx, blockHash, blockNumber, index), nil
+	}
+	// Index out of range, return as such
+	return nil, nil
+}
+
+// GetUncleByBlockNumberAndIndex returns the uncle block for the given block number and index
+func (pea *PublicEthAPI) GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error) {
+	return pea.uncleByBlockAndIndex(ctx, rpc.BlockNumberOrHashWithNumber(blockNr), uint64(index))
+}
+
+// GetUncleByBlockHashAndIndex returns the uncle block for the given block hash and index
+func (pea *PublicEthAPI) GetUncleByBlockHashAndIndex(ctx context.Context, blockHash common.Hash, index hexutil.Uint) (map[string]interface{}, error) {
+	return pea.uncleByBlockAndIndex(ctx, rpc.BlockNumberOrHashWithHash(blockHash, false), uint64(index))
+}
+
+func (pea *PublicEthAPI) uncleByBlockAndIndex(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, index uint64) (map[string]interface{}, error) {
+	uncle, err := pea.B.GetUncleByBlockAndIndex(ctx, blockNrOrHash, index)
+	if err != nil {
+		return nil, err
+	}
+	if uncle == nil {
+		// Index out of range, return as such
+		return nil, nil
+	}
+	// Uncles are not indexed with a total difficulty, so the generalized output filler is used directly
+	return RPCMarshalBlock(types.NewBlockWithHeader(uncle), false, false)
+}
+
+// GetUncleCountByBlockNumber returns the number of uncles in the block with the given block number
+func (pea *PublicEthAPI) GetUncleCountByBlockNumber(ctx context.Context, blockNr rpc.BlockNumber) (*hexutil.Uint, error) {
+	count, err := pea.B.GetUncleCount(ctx, rpc.BlockNumberOrHashWithNumber(blockNr))
+	if err != nil {
+		return nil, err
+	}
+	n := hexutil.Uint(count)
+	return &n, nil
+}
+
+// GetUncleCountByBlockHash returns the number of uncles in the block with the given hash
+func (pea *PublicEthAPI) GetUncleCountByBlockHash(ctx context.Context, blockHash common.Hash) (*hexutil.Uint, error) {
+	count, err := pea.B.GetUncleCount(ctx, rpc.BlockNumberOrHashWithHash(blockHash, false))
+	if err != nil {
+		return nil, err
+	}
+	n := hexutil.Uint(count)
+	return &n, nil
+}
+
 // GetTransactionByHash returns the transaction for the given hash
 // eth ipfs-blockchain-watcher cannot currently handle pending/tx_pool txs
 func (pea *PublicEthAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
@@ -194,3 +405,312 @@ func (pea *PublicEthAPI) GetTransactionByHash(ctx context.Context, hash common.H
 	// Transaction unknown, return as such
 	return nil, nil
 }
+
+// GetTransactionReceipt returns the transaction receipt for the given transaction hash.
+func (pea *PublicEthAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	tx, blockHash, blockNumber, index, err := pea.B.GetTransaction(ctx, hash)
+	if err == sql.ErrNoRows {
+		// Transaction unknown, return as such
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := pea.B.GetReceipts(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) <= int(index) {
+		return nil, nil
+	}
+	receipt := receipts[index]
+
+	var signer types.Signer = types.FrontierSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	from, _ := types.Sender(signer, tx)
+
+	fields := map[string]interface{}{
+		"blockHash":         blockHash,
+		"blockNumber":       hexutil.Uint64(blockNumber),
+		"transactionHash":   hash,
+		"transactionIndex":  hexutil.Uint64(index),
+		"from":              from,
+		"to":                tx.To(),
+		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
+		"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
+		"contractAddress":   nil,
+		"logs":              receipt.Logs,
+		"logsBloom":         receipt.Bloom,
+	}
+
+	// Assign receipt status or post state.
+	if len(receipt.PostState) > 0 {
+		fields["root"] = hexutil.Bytes(receipt.PostState)
+	} else {
+		fields["status"] = hexutil.Uint(receipt.Status)
+	}
+	if receipt.Logs == nil {
+		fields["logs"] = []*types.Log{}
+	}
+	// If the ContractAddress is 20 0x0 bytes, assume it is not a contract creation
+	if receipt.ContractAddress != (common.Address{}) {
+		fields["contractAddress"] = receipt.ContractAddress
+	}
+	return fields, nil
+}
+
+// GetBalance returns the amount of wei for the given address in the state of the
+// given block number or hash. The rpc.LatestBlockNumber meta block number is also allowed.
+func (pea *PublicEthAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	if isPending(blockNrOrHash) && pea.proxy.Forwards("eth_getBalance") {
+		var res *hexutil.Big
+		err := pea.proxy.Forward(ctx, &res, "eth_getBalance", address, pendingBlock)
+		return res, err
+	}
+	account, err := pea.B.GetAccountByNumberOrHash(ctx, address, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return (*hexutil.Big)(new(big.Int)), nil
+	}
+	return (*hexutil.Big)(account.Balance), nil
+}
+
+// GetTransactionCount returns the number of transactions the given address has sent for the given block number or hash
+func (pea *PublicEthAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
+	if isPending(blockNrOrHash) && pea.proxy.Forwards("eth_getTransactionCount") {
+		var res *hexutil.Uint64
+		err := pea.proxy.Forward(ctx, &res, "eth_getTransactionCount", address, pendingBlock)
+		return res, err
+	}
+	account, err := pea.B.GetAccountByNumberOrHash(ctx, address, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	var nonce uint64
+	if account != nil {
+		nonce = account.Nonce
+	}
+	return (*hexutil.Uint64)(&nonce), nil
+}
+
+// GetCode returns the code stored at the given address in the state for the given block number or hash
+func (pea *PublicEthAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	if isPending(blockNrOrHash) && pea.proxy.Forwards("eth_getCode") {
+		var res hexutil.Bytes
+		err := pea.proxy.Forward(ctx, &res, "eth_getCode", address, pendingBlock)
+		return res, err
+	}
+	account, err := pea.B.GetAccountByNumberOrHash(ctx, address, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return hexutil.Bytes{}, nil
+	}
+	code, err := pea.B.GetCodeByHash(ctx, common.BytesToHash(account.CodeHash))
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// GetStorageAt returns the storage from the state at the given address, key and
+// block number or hash. The rpc.LatestBlockNumber meta block number is also allowed.
+func (pea *PublicEthAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	if isPending(blockNrOrHash) && pea.proxy.Forwards("eth_getStorageAt") {
+		var res hexutil.Bytes
+		err := pea.proxy.Forward(ctx, &res, "eth_getStorageAt", address, key, pendingBlock)
+		return res, err
+	}
+	value, err := pea.B.GetStorageByNumberOrHash(ctx, address, common.HexToHash(key), blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// CallArgs represents the arguments for a call.
+type CallArgs struct {
+	From     *common.Address `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     *hexutil.Bytes  `json:"data"`
+}
+
+// OverrideAccount indicates the overriding fields of account during the execution of
+// a message call.
+// Note, state and stateDiff can't be specified at the same time. If state is
+// set, message execution will only use the data in the given state. Otherwise
+// if statDiff is set, all diff will be applied first and then execute the call
+// message.
+type OverrideAccount struct {
+	Nonce     *hexutil.Uint64              `json:"nonce"`
+	Code      *hexutil.Bytes               `json:"code"`
+	Balance   **hexutil.Big                `json:"balance"`
+	State     *map[common.Hash]common.Hash `json:"state"`
+	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
+}
+
+// Call executes the given transaction on the state for the given block number or hash.
+//
+// Additionally, the caller can specify a batch of contract for fields overriding.
+//
+// Note, this function doesn't make and changes in the state/blockchain and is
+// useful to execute and retrieve values.
+func (pea *PublicEthAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *map[common.Address]OverrideAccount) (hexutil.Bytes, error) {
+	if isPending(blockNrOrHash) && pea.proxy.Forwards("eth_call") {
+		var res hexutil.Bytes
+		var err error
+		if overrides != nil {
+			err = pea.proxy.Forward(ctx, &res, "eth_call", args, pendingBlock, overrides)
+		} else {
+			err = pea.proxy.Forward(ctx, &res, "eth_call", args, pendingBlock)
+		}
+		return res, err
+	}
+	var accounts map[common.Address]OverrideAccount
+	if overrides != nil {
+		accounts = *overrides
+	}
+	result, _, _, err := pea.B.DoCall(ctx, args, blockNrOrHash, accounts, DefaultEVMTimeout)
+	return (hexutil.Bytes)(result), err
+}
+
+// EstimateGas returns an estimate of the amount of gas needed to execute the given transaction against the state
+// at the given block number or hash; if no block is provided the latest block is used
+func (pea *PublicEthAPI) EstimateGas(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	if isPending(bNrOrHash) && pea.proxy.Forwards("eth_estimateGas") {
+		var res hexutil.Uint64
+		// The upstream node estimates against the pending block by default
+		err := pea.proxy.Forward(ctx, &res, "eth_estimateGas", args)
+		return res, err
+	}
+	// Binary search the gas requirement, as it may be higher than the amount used
+	var (
+		lo  uint64 = params.TxGas - 1
+		hi  uint64
+		cap uint64
+	)
+	if args.Gas != nil && uint64(*args.Gas) >= params.TxGas {
+		hi = uint64(*args.Gas)
+	} else {
+		// Retrieve the header to act as the gas ceiling
+		header, err := pea.B.HeaderByNumberOrHash(ctx, bNrOrHash)
+		if err != nil {
+			return 0, err
+		}
+		hi = header.GasLimit
+	}
+	cap = hi
+
+	// Use zero-address if no sender is specified
+	if args.From == nil {
+		args.From = &common.Address{}
+	}
+	// Create a helper to check if a gas allowance results in an executable transaction
+	executable := func(gas uint64) (bool, error) {
+		args.Gas = (*hexutil.Uint64)(&gas)
+
+		_, _, failed, err := pea.B.DoCall(ctx, args, bNrOrHash, nil, 0)
+		if err != nil {
+			if err == core.ErrIntrinsicGas {
+				return false, nil
+			}
+			return false, err
+		}
+		return !failed, nil
+	}
+	// Execute the binary search and hone in on an executable gas limit
+	for lo+1 < hi {
+		mid := (hi + lo) / 2
+		ok, err := executable(mid)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	// Reject the transaction as invalid if it still fails at the highest allowance
+	if hi == cap {
+		ok, err := executable(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, fmt.Errorf("gas required exceeds allowance (%d) or always failing transaction", cap)
+		}
+	}
+	return hexutil.Uint64(hi), nil
+}
+
+// AccountResult is the result struct for GetProof
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the result struct for the storage proofs of GetProof
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// GetProof returns the Merkle-proof for a given account and optionally some storage keys (EIP-1186).
+// The proofs are assembled from the state and storage trie nodes indexed in public.blocks, so they require that
+// intermediate trie nodes were indexed for the requested block and all blocks before it
+func (pea *PublicEthAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
+	header, err := pea.B.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	account, accountProof, err := pea.B.GetAccountProof(ctx, address, header.Root)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountResult{
+		Address:      address,
+		AccountProof: common.ToHexArray(accountProof),
+		Balance:      (*hexutil.Big)(new(big.Int)),
+		CodeHash:     crypto.Keccak256Hash(nil),
+		StorageHash:  types.EmptyRootHash,
+		StorageProof: make([]StorageResult, len(storageKeys)),
+	}
+	// no account means that the account does not exist, so neither does any of its storage
+	if account == nil {
+		for i, key := range storageKeys {
+			result.StorageProof[i] = StorageResult{key, &hexutil.Big{}, []string{}}
+		}
+		return result, nil
+	}
+	result.Balance = (*hexutil.Big)(account.Balance)
+	result.CodeHash = common.BytesToHash(account.CodeHash)
+	result.Nonce = hexutil.Uint64(account.Nonce)
+	result.StorageHash = account.Root
+	for i, key := range storageKeys {
+		value, storageProof, err := pea.B.GetStorageProof(ctx, address, account, common.HexToHash(key))
+		if err != nil {
+			return nil, err
+		}
+		result.StorageProof[i] = StorageResult{key, (*hexutil.Big)(value.Big()), common.ToHexArray(storageProof)}
+	}
+	return result, nil
+}
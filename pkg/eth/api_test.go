@@ -18,14 +18,27 @@ package eth_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -34,6 +47,7 @@ import (
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	mocks2 "github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared/mocks"
 )
 
 var (
@@ -95,13 +109,15 @@ var _ = Describe("API", func() {
 		Expect(err).ToNot(HaveOccurred())
 		retriever = eth.NewCIDRetriever(db)
 		fetcher = eth.NewIPLDPGFetcher(db)
-		indexAndPublisher = eth.NewIPLDPublisherAndIndexer(db)
+		indexAndPublisher = eth.NewIPLDPublisherAndIndexer(db, params.MainnetChainConfig, nil)
 		backend = &eth.Backend{
-			Retriever: retriever,
-			Fetcher:   fetcher,
-			DB:        db,
+			Retriever:     retriever,
+			Fetcher:       fetcher,
+			DB:            db,
+			ChainConfig:   params.MainnetChainConfig,
+			StateDatabase: state.NewDatabase(rawdb.NewDatabase(eth.NewIPLDDatabase(db))),
 		}
-		api = eth.NewPublicEthAPI(backend)
+		api = eth.NewPublicEthAPI(backend, nil, nil, nil)
 		_, err = indexAndPublisher.Publish(mocks.MockConvertedPayload)
 		Expect(err).ToNot(HaveOccurred())
 		uncles := mocks.MockBlock.Uncles()
@@ -123,6 +139,38 @@ var _ = Describe("API", func() {
 		})
 	})
 
+	Describe("Syncing", func() {
+		It("Returns false when the index is contiguous up to the head", func() {
+			syncingAPI := eth.NewPublicEthAPI(backend, nil, &mocks2.SyncStatusReporter{
+				StatusToReturn: shared.SyncStatus{StartingBlock: 5, ContiguousBlock: 10, HeadBlock: 10},
+			}, nil)
+			syncing, err := syncingAPI.Syncing()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(syncing).To(Equal(false))
+		})
+
+		It("Returns the sync progress when the index is behind the head", func() {
+			syncingAPI := eth.NewPublicEthAPI(backend, nil, &mocks2.SyncStatusReporter{
+				StatusToReturn: shared.SyncStatus{StartingBlock: 5, ContiguousBlock: 8, HeadBlock: 10},
+			}, nil)
+			syncing, err := syncingAPI.Syncing()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(syncing).To(Equal(map[string]interface{}{
+				"startingBlock": hexutil.Uint64(5),
+				"currentBlock":  hexutil.Uint64(8),
+				"highestBlock":  hexutil.Uint64(10),
+				"pulledStates":  hexutil.Uint64(0),
+				"knownStates":   hexutil.Uint64(0),
+			}))
+		})
+	})
+
+	Describe("ChainId", func() {
+		It("Returns the chain id of the chain config", func() {
+			Expect(api.ChainId().ToInt()).To(Equal(params.MainnetChainConfig.ChainID))
+		})
+	})
+
 	Describe("GetTransactionByHash", func() {
 		It("Retrieves a transaction by hash", func() {
 			hash := mocks.MockTransactions[0].Hash()
@@ -132,6 +180,521 @@ var _ = Describe("API", func() {
 		})
 	})
 
+	Describe("Block transaction navigation", func() {
+		It("Retrieves the number of transactions in a block by number or hash", func() {
+			count, err := api.GetBlockTransactionCountByNumber(context.Background(), rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uint64(*count)).To(Equal(uint64(len(mocks.MockTransactions))))
+			count, err = api.GetBlockTransactionCountByHash(context.Background(), mocks.MockBlock.Hash())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uint64(*count)).To(Equal(uint64(len(mocks.MockTransactions))))
+		})
+		It("Retrieves a transaction by block number or hash and index", func() {
+			tx, err := api.GetTransactionByBlockNumberAndIndex(context.Background(), rpc.BlockNumber(mocks.BlockNumber.Int64()), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx).To(Equal(expectedTransaction))
+			tx, err = api.GetTransactionByBlockHashAndIndex(context.Background(), mocks.MockBlock.Hash(), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx).To(Equal(expectedTransaction))
+		})
+		It("Returns nil if the index is out of range", func() {
+			tx, err := api.GetTransactionByBlockHashAndIndex(context.Background(), mocks.MockBlock.Hash(), hexutil.Uint(len(mocks.MockTransactions)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tx).To(BeNil())
+		})
+		It("Throws an error if the block cannot be found", func() {
+			_, err := api.GetBlockTransactionCountByHash(context.Background(), common.HexToHash("0x01"))
+			Expect(err).To(HaveOccurred())
+			_, err = api.GetTransactionByBlockNumberAndIndex(context.Background(), rpc.BlockNumber(mocks.BlockNumber.Int64()+10), 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Block uncle navigation", func() {
+		var (
+			uncle = &types.Header{
+				Number:     big.NewInt(2),
+				ParentHash: mocks.MockBlock.Hash(),
+				Difficulty: big.NewInt(100),
+				Extra:      []byte{},
+			}
+			block = types.NewBlock(&types.Header{
+				Number:     big.NewInt(3),
+				Difficulty: big.NewInt(200),
+				Extra:      []byte{},
+			}, nil, []*types.Header{uncle}, nil)
+		)
+		BeforeEach(func() {
+			_, err := indexAndPublisher.Publish(eth.ConvertedPayload{
+				TotalDifficulty: block.Difficulty(),
+				Block:           block,
+				Receipts:        types.Receipts{},
+				TxMetaData:      []eth.TxModel{},
+				ReceiptMetaData: []eth.ReceiptModel{},
+				StorageNodes:    map[string][]eth.TrieNode{},
+				StateNodes:      []eth.TrieNode{},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("Retrieves the number of uncles in a block by number or hash", func() {
+			count, err := api.GetUncleCountByBlockNumber(context.Background(), rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uint64(*count)).To(Equal(uint64(0)))
+			count, err = api.GetUncleCountByBlockHash(context.Background(), block.Hash())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uint64(*count)).To(Equal(uint64(1)))
+		})
+		It("Retrieves an uncle by block number or hash and index", func() {
+			expectedUncle, err := eth.RPCMarshalBlock(types.NewBlockWithHeader(uncle), false, false)
+			Expect(err).ToNot(HaveOccurred())
+			res, err := api.GetUncleByBlockNumberAndIndex(context.Background(), 3, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(expectedUncle))
+			res, err = api.GetUncleByBlockHashAndIndex(context.Background(), block.Hash(), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(expectedUncle))
+		})
+		It("Returns nil if the index is out of range", func() {
+			res, err := api.GetUncleByBlockHashAndIndex(context.Background(), block.Hash(), 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(BeNil())
+		})
+	})
+
+	Describe("GetTransactionReceipt", func() {
+		It("Retrieves a receipt with its derived fields by transaction hash", func() {
+			hash := mocks.MockTransactions[1].Hash()
+			rct, err := api.GetTransactionReceipt(context.Background(), hash)
+			Expect(err).ToNot(HaveOccurred())
+			expectedLog := *mocks.MockLog2
+			expectedLog.BlockNumber = mocks.MockBlock.NumberU64()
+			expectedLog.BlockHash = mocks.MockBlock.Hash()
+			expectedLog.TxHash = hash
+			expectedLog.TxIndex = 1
+			expectedLog.Index = 1
+			Expect(rct["blockHash"]).To(Equal(mocks.MockBlock.Hash()))
+			Expect(rct["blockNumber"]).To(Equal(hexutil.Uint64(mocks.MockBlock.NumberU64())))
+			Expect(rct["transactionHash"]).To(Equal(hash))
+			Expect(rct["transactionIndex"]).To(Equal(hexutil.Uint64(1)))
+			Expect(rct["from"]).To(Equal(mocks.SenderAddr))
+			Expect(rct["to"]).To(Equal(&mocks.AnotherAddress))
+			Expect(rct["gasUsed"]).To(Equal(hexutil.Uint64(50)))
+			Expect(rct["cumulativeGasUsed"]).To(Equal(hexutil.Uint64(100)))
+			Expect(rct["contractAddress"]).To(BeNil())
+			Expect(rct["logs"]).To(Equal([]*types.Log{&expectedLog}))
+			Expect(rct["logsBloom"]).To(Equal(mocks.MockReceipts[1].Bloom))
+			Expect(rct["root"]).To(Equal(hexutil.Bytes(mocks.MockReceipts[1].PostState)))
+		})
+
+		It("Sets the contract address for contract creation transactions", func() {
+			rct, err := api.GetTransactionReceipt(context.Background(), mocks.MockTransactions[2].Hash())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rct["contractAddress"]).To(Equal(mocks.ContractAddress))
+			Expect(rct["transactionIndex"]).To(Equal(hexutil.Uint64(2)))
+			Expect(rct["logs"]).To(Equal([]*types.Log{}))
+		})
+
+		It("Returns nil for an unknown transaction", func() {
+			rct, err := api.GetTransactionReceipt(context.Background(), common.HexToHash("0x01"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rct).To(BeNil())
+		})
+	})
+
+	Describe("GetBalance", func() {
+		It("Retrieves the balance for an account at the provided block number or hash", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			bal, err := api.GetBalance(context.Background(), mocks.AccountAddresss, number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bal).To(Equal((*hexutil.Big)(big.NewInt(1000))))
+
+			bal, err = api.GetBalance(context.Background(), mocks.ContractAddress, number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bal).To(Equal((*hexutil.Big)(big.NewInt(0))))
+
+			hash := rpc.BlockNumberOrHashWithHash(mocks.MockBlock.Hash(), false)
+			bal, err = api.GetBalance(context.Background(), mocks.AccountAddresss, hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bal).To(Equal((*hexutil.Big)(big.NewInt(1000))))
+
+			latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+			bal, err = api.GetBalance(context.Background(), mocks.AccountAddresss, latest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bal).To(Equal((*hexutil.Big)(big.NewInt(1000))))
+		})
+
+		It("Retrieves the balance at an orphaned block from that block's branch when given its hash", func() {
+			forkHeader := *mocks.MockBlock.Header()
+			forkHeader.Extra = []byte{0x01}
+			forkPayload := mocks.MockConvertedPayload
+			forkPayload.Block = types.NewBlock(&forkHeader, mocks.MockTransactions, nil, mocks.MockReceipts)
+			forkPayload.StateNodes = nil
+			forkPayload.StorageNodes = nil
+			_, err := indexAndPublisher.Publish(forkPayload)
+			Expect(err).ToNot(HaveOccurred())
+
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			bal, err := api.GetBalance(context.Background(), mocks.AccountAddresss, number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bal).To(Equal((*hexutil.Big)(big.NewInt(0))))
+
+			hash := rpc.BlockNumberOrHashWithHash(mocks.MockBlock.Hash(), false)
+			bal, err = api.GetBalance(context.Background(), mocks.AccountAddresss, hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bal).To(Equal((*hexutil.Big)(big.NewInt(1000))))
+		})
+
+		It("Returns a zero balance for accounts that do not exist at the provided block", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			bal, err := api.GetBalance(context.Background(), mocks.Address, number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bal).To(Equal((*hexutil.Big)(big.NewInt(0))))
+		})
+
+		It("Throws an error if the block is not available", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64() + 1))
+			_, err := api.GetBalance(context.Background(), mocks.AccountAddresss, number)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GetTransactionCount", func() {
+		It("Retrieves the nonce for an account at the provided block", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			count, err := api.GetTransactionCount(context.Background(), mocks.AccountAddresss, number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*count).To(Equal(hexutil.Uint64(0)))
+
+			count, err = api.GetTransactionCount(context.Background(), mocks.ContractAddress, number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*count).To(Equal(hexutil.Uint64(1)))
+		})
+	})
+
+	Describe("GetCode", func() {
+		It("Returns empty code for accounts without code", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			code, err := api.GetCode(context.Background(), mocks.AccountAddresss, number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(code).To(Equal(hexutil.Bytes{}))
+		})
+
+		It("Throws an error if the code for a contract is not available", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			_, err := api.GetCode(context.Background(), mocks.ContractAddress, number)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("code for hash %s is not available", mocks.ContractCodeHash.Hex()))
+		})
+	})
+
+	Describe("GetStorageAt", func() {
+		It("Retrieves the storage value at the provided slot and block as a 32-byte word", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			val, err := api.GetStorageAt(context.Background(), mocks.ContractAddress, "0x0", number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(val).To(Equal(hexutil.Bytes(common.BytesToHash(mocks.StorageValue).Bytes())))
+		})
+
+		It("Retrieves the storage value at an orphaned block from that block's branch when given its hash", func() {
+			forkHeader := *mocks.MockBlock.Header()
+			forkHeader.Extra = []byte{0x01}
+			forkPayload := mocks.MockConvertedPayload
+			forkPayload.Block = types.NewBlock(&forkHeader, mocks.MockTransactions, nil, mocks.MockReceipts)
+			forkPayload.StateNodes = nil
+			forkPayload.StorageNodes = nil
+			_, err := indexAndPublisher.Publish(forkPayload)
+			Expect(err).ToNot(HaveOccurred())
+
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			val, err := api.GetStorageAt(context.Background(), mocks.ContractAddress, "0x0", number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(val).To(Equal(hexutil.Bytes(common.Hash{}.Bytes())))
+
+			hash := rpc.BlockNumberOrHashWithHash(mocks.MockBlock.Hash(), false)
+			val, err = api.GetStorageAt(context.Background(), mocks.ContractAddress, "0x0", hash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(val).To(Equal(hexutil.Bytes(common.BytesToHash(mocks.StorageValue).Bytes())))
+		})
+
+		It("Returns a zero word for slots that have no value", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.BlockNumber.Int64()))
+			val, err := api.GetStorageAt(context.Background(), mocks.ContractAddress, "0x1", number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(val).To(Equal(hexutil.Bytes(common.Hash{}.Bytes())))
+
+			val, err = api.GetStorageAt(context.Background(), mocks.Address, "0x0", number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(val).To(Equal(hexutil.Bytes(common.Hash{}.Bytes())))
+		})
+	})
+
+	Describe("Call and EstimateGas", func() {
+		BeforeEach(func() {
+			_, err := indexAndPublisher.Publish(mocks.MockCallConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			for _, node := range mocks.CallStateTrieNodes {
+				mhKey, err := shared.MultihashKeyFromKeccak256(crypto.Keccak256Hash(node))
+				Expect(err).ToNot(HaveOccurred())
+				err = shared.PublishMockIPLD(db, mhKey, node)
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("Executes a call against the state at the provided block", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.CallBlockNumber.Int64()))
+			res, err := api.Call(context.Background(), eth.CallArgs{To: &mocks.CallContractAddress}, number, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(hexutil.Bytes(mocks.CallStorageValue.Bytes())))
+
+			hash := rpc.BlockNumberOrHashWithHash(mocks.CallBlock.Hash(), false)
+			res, err = api.Call(context.Background(), eth.CallArgs{To: &mocks.CallContractAddress}, hash, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(hexutil.Bytes(mocks.CallStorageValue.Bytes())))
+		})
+
+		It("Throws an error if the provided block is not available", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.CallBlockNumber.Int64() + 1))
+			_, err := api.Call(context.Background(), eth.CallArgs{To: &mocks.CallContractAddress}, number, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Estimates the gas needed to execute a call", func() {
+			number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.CallBlockNumber.Int64()))
+			gas, err := api.EstimateGas(context.Background(), eth.CallArgs{To: &mocks.CallContractAddress}, &number)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(uint64(gas)).To(BeNumerically(">", params.TxGas))
+			Expect(uint64(gas)).To(BeNumerically("<", mocks.CallHeader.GasLimit))
+		})
+	})
+
+	Describe("Tracing", func() {
+		var debugAPI *eth.PublicDebugAPI
+		BeforeEach(func() {
+			debugAPI = eth.NewPublicDebugAPI(backend, eth.DefaultMaxTraceTimeout)
+			_, err := indexAndPublisher.Publish(mocks.MockCallConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			for _, node := range mocks.CallStateTrieNodes {
+				mhKey, err := shared.MultihashKeyFromKeccak256(crypto.Keccak256Hash(node))
+				Expect(err).ToNot(HaveOccurred())
+				err = shared.PublishMockIPLD(db, mhKey, node)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			_, err = indexAndPublisher.Publish(mocks.MockCallTraceConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Traces a transaction with the struct logger", func() {
+			res, err := debugAPI.TraceTransaction(context.Background(), mocks.CallTraceTransaction.Hash(), nil)
+			Expect(err).ToNot(HaveOccurred())
+			result, ok := res.(*eth.ExecutionResult)
+			Expect(ok).To(BeTrue())
+			Expect(result.Failed).To(BeFalse())
+			Expect(result.Gas).To(BeNumerically(">", params.TxGas))
+			Expect(result.ReturnValue).To(Equal(common.Bytes2Hex(mocks.CallStorageValue.Bytes())))
+			Expect(len(result.StructLogs)).To(Equal(7))
+			Expect(result.StructLogs[1].Op).To(Equal("SLOAD"))
+			Expect(result.StructLogs[6].Op).To(Equal("RETURN"))
+		})
+
+		It("Traces a transaction with the call tracer", func() {
+			tracer := "callTracer"
+			res, err := debugAPI.TraceTransaction(context.Background(), mocks.CallTraceTransaction.Hash(), &eth.TraceConfig{Tracer: &tracer})
+			Expect(err).ToNot(HaveOccurred())
+			raw, ok := res.(json.RawMessage)
+			Expect(ok).To(BeTrue())
+			call := make(map[string]interface{})
+			err = json.Unmarshal(raw, &call)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(call["type"]).To(Equal("CALL"))
+			Expect(call["to"]).To(Equal(strings.ToLower(mocks.CallContractAddress.Hex())))
+			Expect(call["output"]).To(Equal(hexutil.Encode(mocks.CallStorageValue.Bytes())))
+		})
+
+		It("Traces every transaction in a block by number or hash", func() {
+			results, err := debugAPI.TraceBlockByNumber(context.Background(), rpc.BlockNumber(mocks.CallTraceBlock.Number().Int64()), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(results)).To(Equal(1))
+			Expect(results[0].Error).To(BeEmpty())
+			result, ok := results[0].Result.(*eth.ExecutionResult)
+			Expect(ok).To(BeTrue())
+			Expect(result.ReturnValue).To(Equal(common.Bytes2Hex(mocks.CallStorageValue.Bytes())))
+
+			results, err = debugAPI.TraceBlockByHash(context.Background(), mocks.CallTraceBlock.Hash(), nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(results)).To(Equal(1))
+			Expect(results[0].Result).To(Equal(result))
+		})
+
+		It("Throws a not found error for an unknown transaction", func() {
+			hash := common.HexToHash("0x02")
+			_, err := debugAPI.TraceTransaction(context.Background(), hash, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(fmt.Sprintf("transaction %#x not found", hash)))
+		})
+
+		It("Aborts a trace which runs for longer than the maximum trace timeout", func() {
+			tracer := "callTracer"
+			timeout := "1m"
+			capped := eth.NewPublicDebugAPI(backend, time.Nanosecond)
+			_, err := capped.TraceTransaction(context.Background(), mocks.CallTraceTransaction.Hash(), &eth.TraceConfig{Tracer: &tracer, Timeout: &timeout})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Throws an error if the parent state is not available", func() {
+			orphan := types.NewBlock(&types.Header{
+				Number:     big.NewInt(3),
+				ParentHash: common.HexToHash("0x01"),
+				Difficulty: big.NewInt(100),
+				Extra:      []byte{},
+			}, types.Transactions{mocks.CallTraceTransaction}, nil, nil)
+			blob, err := rlp.EncodeToBytes(orphan)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = debugAPI.TraceBlock(context.Background(), blob, nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GetProof", func() {
+		BeforeEach(func() {
+			_, err := indexAndPublisher.Publish(mocks.MockCallConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when the trie nodes are indexed", func() {
+			BeforeEach(func() {
+				for _, node := range mocks.CallStateTrieNodes {
+					mhKey, err := shared.MultihashKeyFromKeccak256(crypto.Keccak256Hash(node))
+					Expect(err).ToNot(HaveOccurred())
+					err = shared.PublishMockIPLD(db, mhKey, node)
+					Expect(err).ToNot(HaveOccurred())
+				}
+			})
+
+			It("Returns verifiable account and storage proofs", func() {
+				number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.CallBlockNumber.Int64()))
+				res, err := api.GetProof(context.Background(), mocks.CallContractAddress, []string{"0x0"}, number)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.Address).To(Equal(mocks.CallContractAddress))
+				Expect(res.CodeHash).To(Equal(crypto.Keccak256Hash(mocks.CallContractCode)))
+				Expect(len(res.AccountProof)).To(BeNumerically(">", 0))
+				Expect(len(res.StorageProof)).To(Equal(1))
+				Expect(res.StorageProof[0].Value.ToInt()).To(Equal(mocks.CallStorageValue.Big()))
+
+				accountProofDB := memorydb.New()
+				for _, node := range res.AccountProof {
+					nodeBytes := common.FromHex(node)
+					Expect(accountProofDB.Put(crypto.Keccak256(nodeBytes), nodeBytes)).To(Succeed())
+				}
+				_, _, err = trie.VerifyProof(mocks.CallStateRoot, crypto.Keccak256(mocks.CallContractAddress.Bytes()), accountProofDB)
+				Expect(err).ToNot(HaveOccurred())
+
+				storageProofDB := memorydb.New()
+				for _, node := range res.StorageProof[0].Proof {
+					nodeBytes := common.FromHex(node)
+					Expect(storageProofDB.Put(crypto.Keccak256(nodeBytes), nodeBytes)).To(Succeed())
+				}
+				_, _, err = trie.VerifyProof(res.StorageHash, crypto.Keccak256(common.Hash{}.Bytes()), storageProofDB)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("Returns an empty account result for accounts that do not exist", func() {
+				number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.CallBlockNumber.Int64()))
+				res, err := api.GetProof(context.Background(), mocks.Address, []string{"0x0"}, number)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.Balance.ToInt().Int64()).To(Equal(int64(0)))
+				Expect(res.StorageHash).To(Equal(types.EmptyRootHash))
+				Expect(res.StorageProof[0].Proof).To(BeEmpty())
+			})
+		})
+
+		Context("when the trie nodes are not indexed", func() {
+			It("Throws an error identifying the missing node", func() {
+				number := rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(mocks.CallBlockNumber.Int64()))
+				_, err := api.GetProof(context.Background(), mocks.CallContractAddress, []string{"0x0"}, number)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("trie node %s", mocks.CallStateRoot.Hex()))
+				Expect(err.Error()).To(ContainSubstring("is not available in the index"))
+			})
+		})
+	})
+
+	Describe("Filters", func() {
+		It("Returns the hashes of the blocks indexed since a block filter was last polled", func() {
+			id, err := api.NewBlockFilter()
+			Expect(err).ToNot(HaveOccurred())
+			changes, err := api.GetFilterChanges(context.Background(), id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(BeEmpty())
+
+			_, err = indexAndPublisher.Publish(mocks.MockCallConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			changes, err = api.GetFilterChanges(context.Background(), id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(Equal([]common.Hash{mocks.CallBlock.Hash()}))
+
+			changes, err = api.GetFilterChanges(context.Background(), id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(BeEmpty())
+		})
+
+		It("Returns the matching logs for a log filter", func() {
+			id, err := api.NewFilter(filters.FilterCriteria{
+				Topics: [][]common.Hash{{common.HexToHash("0x04")}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			changes, err := api.GetFilterChanges(context.Background(), id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(BeEmpty())
+
+			logs, err := api.GetFilterLogs(context.Background(), id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(logs)).To(Equal(1))
+			Expect(logs[0].Address).To(Equal(mocks.MockLog1.Address))
+			Expect(logs[0].Topics).To(Equal(mocks.MockLog1.Topics))
+			Expect(logs[0].BlockHash).To(Equal(mocks.MockBlock.Hash()))
+			Expect(logs[0].TxHash).To(Equal(mocks.MockTransactions[0].Hash()))
+		})
+
+		It("Applies the eth_getLogs range and result limits to log filters", func() {
+			id, err := api.NewFilter(filters.FilterCriteria{
+				FromBlock: big.NewInt(0),
+				ToBlock:   big.NewInt(2),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			backend.MaxLogRange = 2
+			_, err = api.GetFilterLogs(context.Background(), id)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exceed maximum block range: 2"))
+
+			backend.MaxLogRange = 3
+			backend.MaxLogResults = 1
+			_, err = api.GetFilterLogs(context.Background(), id)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("query returned more than 1 results"))
+
+			backend.MaxLogResults = 2
+			logs, err := api.GetFilterLogs(context.Background(), id)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(logs)).To(Equal(2))
+		})
+
+		It("Does not return logs for block filters", func() {
+			id, err := api.NewBlockFilter()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = api.GetFilterLogs(context.Background(), id)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Uninstalls filters", func() {
+			id, err := api.NewBlockFilter()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(api.UninstallFilter(id)).To(BeTrue())
+			Expect(api.UninstallFilter(id)).To(BeFalse())
+			_, err = api.GetFilterChanges(context.Background(), id)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("GetBlockByNumber", func() {
 		It("Retrieves a block by number", func() {
 			// without full txs
@@ -210,6 +773,13 @@ var _ = Describe("API", func() {
 	})
 
 	Describe("GetLogs", func() {
+		// the logs are returned with the fields derived from the block and transactions that include them
+		var expectedLog1, expectedLog2 *types.Log
+		BeforeEach(func() {
+			expectedLog1 = derivedLog(mocks.MockLog1, 0, 0)
+			expectedLog2 = derivedLog(mocks.MockLog2, 1, 1)
+		})
+
 		It("Retrieves receipt logs that match the provided topcis within the provided range", func() {
 			crit := ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -223,7 +793,7 @@ var _ = Describe("API", func() {
 			logs, err := api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1}))
 
 			crit = ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -238,7 +808,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -253,7 +823,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1}))
 
 			crit = ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -286,7 +856,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1}))
 
 			crit = ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -303,7 +873,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -321,7 +891,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -340,7 +910,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -355,7 +925,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				Topics: [][]common.Hash{
@@ -370,7 +940,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1}))
 
 			crit = ethereum.FilterQuery{
 				Topics:    [][]common.Hash{},
@@ -380,7 +950,57 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
+		})
+
+		It("Returns no logs for blocks whose bloom cannot match the criteria", func() {
+			crit := ethereum.FilterQuery{
+				Addresses: []common.Address{common.HexToAddress("0x99")},
+				FromBlock: mocks.MockBlock.Number(),
+				ToBlock:   mocks.MockBlock.Number(),
+			}
+			logs, err := api.GetLogs(context.Background(), crit)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(logs)).To(Equal(0))
+		})
+
+		It("Throws an error if the block range is invalid", func() {
+			crit := ethereum.FilterQuery{
+				FromBlock: new(big.Int).Add(mocks.MockBlock.Number(), big.NewInt(1)),
+				ToBlock:   mocks.MockBlock.Number(),
+			}
+			_, err := api.GetLogs(context.Background(), crit)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("invalid block range params"))
+		})
+
+		It("Throws an error if the block range exceeds the maximum", func() {
+			backend.MaxLogRange = 1
+			crit := ethereum.FilterQuery{
+				FromBlock: big.NewInt(0),
+				ToBlock:   mocks.MockBlock.Number(),
+			}
+			_, err := api.GetLogs(context.Background(), crit)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("exceed maximum block range: 1"))
+		})
+
+		It("Defaults a missing block range to the latest block", func() {
+			backend.MaxLogRange = 1
+			logs, err := api.GetLogs(context.Background(), ethereum.FilterQuery{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
+		})
+
+		It("Throws an error if the number of results exceeds the maximum", func() {
+			backend.MaxLogResults = 1
+			crit := ethereum.FilterQuery{
+				FromBlock: mocks.MockBlock.Number(),
+				ToBlock:   mocks.MockBlock.Number(),
+			}
+			_, err := api.GetLogs(context.Background(), crit)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("query returned more than 1 results"))
 		})
 
 		It("Uses the provided blockhash if one is provided", func() {
@@ -397,7 +1017,7 @@ var _ = Describe("API", func() {
 			logs, err := api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1}))
 
 			crit = ethereum.FilterQuery{
 				BlockHash: &hash,
@@ -413,7 +1033,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1}))
 
 			crit = ethereum.FilterQuery{
 				BlockHash: &hash,
@@ -427,7 +1047,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				BlockHash: &hash,
@@ -443,7 +1063,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				BlockHash: &hash,
@@ -475,7 +1095,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				BlockHash: &hash,
@@ -489,7 +1109,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				BlockHash: &hash,
@@ -507,7 +1127,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
 
 			crit = ethereum.FilterQuery{
 				BlockHash: &hash,
@@ -516,7 +1136,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
 		})
 
 		It("Filters on contract address if any are provided", func() {
@@ -540,7 +1160,7 @@ var _ = Describe("API", func() {
 			logs, err := api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1}))
 
 			hash = mocks.MockBlock.Hash()
 			crit = ethereum.FilterQuery{
@@ -563,7 +1183,7 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
 
 			hash = mocks.MockBlock.Hash()
 			crit = ethereum.FilterQuery{
@@ -576,7 +1196,18 @@ var _ = Describe("API", func() {
 			logs, err = api.GetLogs(context.Background(), crit)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
-			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+			Expect(logs).To(Equal([]*types.Log{expectedLog1, expectedLog2}))
 		})
 	})
 })
+
+// derivedLog returns a copy of the provided mock log with the fields derived from its position in the mock block
+func derivedLog(log *types.Log, txIndex, index uint) *types.Log {
+	derived := *log
+	derived.BlockNumber = mocks.MockBlock.NumberU64()
+	derived.BlockHash = mocks.MockBlock.Hash()
+	derived.TxHash = mocks.MockTransactions[txIndex].Hash()
+	derived.TxIndex = txIndex
+	derived.Index = index
+	return &derived
+}
@@ -18,37 +18,104 @@ package eth
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
+	"time"
 
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
-	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/ethereum/go-ethereum/statediff"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/sirupsen/logrus"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 )
 
+const (
+	DefaultMaxLogRange   = 10000 // the default maximum number of blocks an eth_getLogs range can span
+	DefaultMaxLogResults = 10000 // the default maximum number of logs an eth_getLogs query can return
+)
+
+// DefaultEVMTimeout is the timeout applied to EVM calls made on behalf of eth_call
+const DefaultEVMTimeout = 5 * time.Second
+
 var (
 	errPendingBlockNumber = errors.New("pending block number not supported")
+	emptyCodeHash         = crypto.Keccak256Hash(nil)
 )
 
+const (
+	// branchHeadersPgStr defines the branch CTE: the header with the hash bound to $1 followed by its ancestors down to,
+	// and including, the first canonical one
+	branchHeadersPgStr = `WITH RECURSIVE branch AS (
+				SELECT id, block_number, parent_hash, canonical FROM eth.header_cids
+				WHERE block_hash = $1
+			UNION
+				SELECT header_cids.id, header_cids.block_number, header_cids.parent_hash, header_cids.canonical
+				FROM eth.header_cids, branch
+				WHERE NOT branch.canonical
+				AND header_cids.block_number = branch.block_number - 1
+				AND header_cids.block_hash = branch.parent_hash
+			)
+			`
+	// onBranchPgStr restricts header_cids to the headers in the branch CTE and the canonical headers below it
+	onBranchPgStr = `(header_cids.id IN (SELECT id FROM branch WHERE NOT branch.canonical)
+				OR (header_cids.canonical AND header_cids.block_number <= (SELECT MAX(block_number) FROM branch WHERE branch.canonical)))`
+)
+
+// leafCIDModel is the mh_key and node type of a state or storage leaf cid
+type leafCIDModel struct {
+	MhKey    string `db:"mh_key"`
+	NodeType int    `db:"node_type"`
+}
+
 type Backend struct {
-	Retriever *CIDRetriever
-	Fetcher   *IPLDPGFetcher
-	DB        *postgres.DB
+	Retriever     *CIDRetriever
+	Fetcher       *IPLDPGFetcher
+	DB            *postgres.DB
+	ChainConfig   *params.ChainConfig
+	StateDatabase state.Database
+	// Limits on eth_getLogs queries; a value of 0 means no limit
+	MaxLogRange   int64
+	MaxLogResults int
+}
+
+// APIConfig holds the settings of the eth apis that are read from the watcher config
+type APIConfig struct {
+	// Limits on eth_getLogs queries; a value of 0 means no limit
+	MaxLogRange   int64
+	MaxLogResults int
+	// Whether the debug api is served, and the longest a single transaction trace can run for; 0 means no limit
+	DebugAPI        bool
+	MaxTraceTimeout time.Duration
 }
 
-func NewEthBackend(db *postgres.DB) (*Backend, error) {
-	r := NewCIDRetriever(db)
+// NewEthBackend creates a new Backend over the provided database for the chain with the provided config
+func NewEthBackend(db *postgres.DB, chainConfig *params.ChainConfig, apiConfig APIConfig) *Backend {
 	return &Backend{
-		Retriever: r,
-		DB:        db,
-	}, nil
+		Retriever:     NewCIDRetriever(db),
+		Fetcher:       NewIPLDPGFetcher(db),
+		DB:            db,
+		ChainConfig:   chainConfig,
+		StateDatabase: state.NewDatabase(rawdb.NewDatabase(NewIPLDDatabase(db))),
+		MaxLogRange:   apiConfig.MaxLogRange,
+		MaxLogResults: apiConfig.MaxLogResults,
+	}
 }
 
 func (b *Backend) HeaderByNumber(ctx context.Context, blockNumber rpc.BlockNumber) (*types.Header, error) {
@@ -102,6 +169,183 @@ func (b *Backend) HeaderByNumber(ctx context.Context, blockNumber rpc.BlockNumbe
 	return &header, err
 }
 
+// HeaderByHash returns the header with the provided block hash
+func (b *Backend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	headerCID, err := b.Retriever.RetrieveHeaderCIDByHash(tx, hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("header for hash %s is not available", hash.Hex())
+		}
+		return nil, err
+	}
+	headerIPLD, err := b.Fetcher.FetchHeader(tx, headerCID)
+	if err != nil {
+		return nil, err
+	}
+	var header types.Header
+	err = rlp.DecodeBytes(headerIPLD.Data, &header)
+	return &header, err
+}
+
+// HeaderByNumberOrHash returns the header for the provided block number or hash
+func (b *Backend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return b.HeaderByHash(ctx, hash)
+	}
+	if number, ok := blockNrOrHash.Number(); ok {
+		return b.HeaderByNumber(ctx, number)
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
+// StateAndHeaderByNumberOrHash returns the header for the provided block number or hash and a state.StateDB
+// at that header's state root; the state is resolved from the trie nodes in public.blocks
+func (b *Backend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	header, err := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	stateDB, err := state.New(header.Root, b.StateDatabase)
+	return stateDB, header, err
+}
+
+// GetEVM constructs and returns a vm.EVM for executing the provided message on top of the provided state and header
+func (b *Backend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) *vm.EVM {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, b, &header.Coinbase)
+	return vm.NewEVM(context, state, b.ChainConfig, vm.Config{})
+}
+
+// DoCall executes the call message on top of the state at the provided block using the core EVM
+// It returns the return data, the gas used, and whether or not the execution failed
+func (b *Backend) DoCall(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides map[common.Address]OverrideAccount, timeout time.Duration) ([]byte, uint64, bool, error) {
+	defer func(start time.Time) { logrus.Debugf("executing EVM call finished in %s", time.Since(start)) }(time.Now())
+
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, 0, false, err
+	}
+	// Set sender address or use the zero address if none specified
+	var addr common.Address
+	if args.From != nil {
+		addr = *args.From
+	}
+	// Override the fields of specified contracts before execution.
+	for addr, account := range overrides {
+		// Override account nonce.
+		if account.Nonce != nil {
+			state.SetNonce(addr, uint64(*account.Nonce))
+		}
+		// Override account(contract) code.
+		if account.Code != nil {
+			state.SetCode(addr, *account.Code)
+		}
+		// Override account balance.
+		if account.Balance != nil {
+			state.SetBalance(addr, (*big.Int)(*account.Balance))
+		}
+		if account.State != nil && account.StateDiff != nil {
+			return nil, 0, false, fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+		}
+		// Replace entire state if caller requires.
+		if account.State != nil {
+			state.SetStorage(addr, *account.State)
+		}
+		// Apply state diff into specified accounts.
+		if account.StateDiff != nil {
+			for key, value := range *account.StateDiff {
+				state.SetState(addr, key, value)
+			}
+		}
+	}
+	// Set default gas & gas price if none were set
+	gas := uint64(math.MaxUint64 / 2)
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	}
+	gasPrice := new(big.Int).SetUint64(defaultGasPrice)
+	if args.GasPrice != nil {
+		gasPrice = args.GasPrice.ToInt()
+	}
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+	var data []byte
+	if args.Data != nil {
+		data = []byte(*args.Data)
+	}
+
+	// Create new call message
+	msg := types.NewMessage(addr, args.To, 0, value, gas, gasPrice, data, false)
+
+	// Setup context so it may be cancelled the call has completed
+	// or, in case of unmetered gas, setup a context with a timeout.
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	// Make sure the context is cancelled when the call has completed
+	// this makes sure resources are cleaned up.
+	defer cancel()
+
+	// Get a new instance of the EVM.
+	evm := b.GetEVM(ctx, msg, state, header)
+	// Wait for the context to be done and cancel the evm. Even if the
+	// EVM has finished, cancelling may be done (repeatedly)
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+
+	// Setup the gas pool (also for unmetered requests)
+	// and apply the message.
+	gp := new(core.GasPool).AddGas(math.MaxUint64)
+	res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
+	if err := state.Error(); err != nil {
+		return nil, 0, false, err
+	}
+	// If the timer caused an abort, return an appropriate error message
+	if evm.Cancelled() {
+		return nil, 0, false, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+	}
+	return res, gas, failed, err
+}
+
+// Engine satisfies the core.ChainContext interface
+// No consensus engine is available; the EVM contexts created by the Backend always carry an explicit author
+func (b *Backend) Engine() consensus.Engine {
+	return nil
+}
+
+// GetHeader satisfies the core.ChainContext interface
+// It returns nil if the header cannot be found
+func (b *Backend) GetHeader(hash common.Hash, height uint64) *types.Header {
+	header, err := b.HeaderByHash(context.Background(), hash)
+	if err != nil {
+		return nil
+	}
+	return header
+}
+
 // GetTd retrieves and returns the total difficulty at the given block hash
 func (b *Backend) GetTd(blockHash common.Hash) (*big.Int, error) {
 	pgStr := `SELECT td FROM eth.header_cids
@@ -329,7 +573,9 @@ func (b *Backend) GetTransaction(ctx context.Context, txHash common.Hash) (*type
 	pgStr := `SELECT transaction_cids.mh_key, transaction_cids.index, header_cids.block_hash, header_cids.block_number
 			FROM eth.transaction_cids, eth.header_cids
 			WHERE transaction_cids.header_id = header_cids.id
-			AND transaction_cids.tx_hash = $1`
+			AND transaction_cids.tx_hash = $1
+			ORDER BY header_cids.canonical DESC
+			LIMIT 1`
 	var txCIDWithHeaderInfo struct {
 		MhKey       string `db:"mh_key"`
 		Index       int64  `db:"index"`
@@ -370,52 +616,539 @@ func (b *Backend) GetTransaction(ctx context.Context, txHash common.Hash) (*type
 	return &transaction, common.HexToHash(txCIDWithHeaderInfo.BlockHash), uint64(txCIDWithHeaderInfo.BlockNumber), uint64(txCIDWithHeaderInfo.Index), err
 }
 
-// extractLogsOfInterest returns logs from the receipt IPLD
-func extractLogsOfInterest(rctIPLDs []ipfs.BlockModel, wantedTopics [][]string) ([]*types.Log, error) {
-	var logs []*types.Log
-	for _, rctIPLD := range rctIPLDs {
-		rctRLP := rctIPLD
-		var rct types.Receipt
-		if err := rlp.DecodeBytes(rctRLP.Data, &rct); err != nil {
+// GetReceipts retrieves the receipts for the block with the provided hash
+// The consensus fields are decoded from the receipt IPLDs and the remaining fields are derived from the indexed
+// transaction and receipt metadata
+func (b *Backend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	headerCID, err := b.Retriever.RetrieveHeaderCIDByHash(tx, hash)
+	if err != nil {
+		return nil, err
+	}
+	txCIDs, err := b.Retriever.RetrieveTxCIDsByHeaderID(tx, headerCID.ID)
+	if err != nil {
+		return nil, err
+	}
+	txIDs := make([]int64, len(txCIDs))
+	for i, txCID := range txCIDs {
+		txIDs[i] = txCID.ID
+	}
+	rctCIDs, err := b.Retriever.RetrieveReceiptCIDsByTxIDs(tx, txIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(rctCIDs) != len(txCIDs) {
+		return nil, fmt.Errorf("block %s has %d transactions but %d receipts", hash.Hex(), len(txCIDs), len(rctCIDs))
+	}
+	rctIPLDs, err := b.Fetcher.FetchRcts(tx, rctCIDs)
+	if err != nil {
+		return nil, err
+	}
+	number, err := strconv.ParseUint(headerCID.BlockNumber, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	receipts := make(types.Receipts, len(rctIPLDs))
+	logIndex := uint(0)
+	for i, rctIPLD := range rctIPLDs {
+		receipt := new(types.Receipt)
+		if err = rlp.DecodeBytes(rctIPLD.Data, receipt); err != nil {
 			return nil, err
 		}
-		for _, log := range rct.Logs {
-			if wanted := wantedLog(wantedTopics, log.Topics); wanted == true {
-				logs = append(logs, log)
-			}
+		// Derive the fields which are not part of the consensus encoding
+		receipt.TxHash = common.HexToHash(txCIDs[i].TxHash)
+		receipt.BlockHash = hash
+		receipt.BlockNumber = new(big.Int).SetUint64(number)
+		receipt.TransactionIndex = uint(i)
+		if rctCIDs[i].Contract != "" {
+			receipt.ContractAddress = common.HexToAddress(rctCIDs[i].Contract)
+		}
+		if i == 0 {
+			receipt.GasUsed = receipt.CumulativeGasUsed
+		} else {
+			receipt.GasUsed = receipt.CumulativeGasUsed - receipts[i-1].CumulativeGasUsed
+		}
+		for _, log := range receipt.Logs {
+			log.BlockNumber = number
+			log.BlockHash = hash
+			log.TxHash = receipt.TxHash
+			log.TxIndex = uint(i)
+			log.Index = logIndex
+			logIndex++
+		}
+		receipts[i] = receipt
+	}
+	return receipts, err
+}
+
+// blockCIDs retrieves the header, uncle and transaction CIDs for the block with the provided number or hash
+func (b *Backend) blockCIDs(blockNrOrHash rpc.BlockNumberOrHash) (HeaderModel, []UncleModel, []TxModel, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		headerCID, uncleCIDs, txCIDs, _, err := b.Retriever.RetrieveBlockByHash(hash)
+		if err == sql.ErrNoRows {
+			return HeaderModel{}, nil, nil, fmt.Errorf("header for hash %s is not available", hash.Hex())
 		}
+		return headerCID, uncleCIDs, txCIDs, err
+	}
+	number, err := b.numberFromNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return HeaderModel{}, nil, nil, err
 	}
-	return logs, nil
+	headerCID, uncleCIDs, txCIDs, _, err := b.Retriever.RetrieveBlockByNumber(number)
+	return headerCID, uncleCIDs, txCIDs, err
+}
+
+// GetBlockTransactionCount returns the number of transactions in the block with the provided number or hash
+func (b *Backend) GetBlockTransactionCount(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (uint64, error) {
+	_, _, txCIDs, err := b.blockCIDs(blockNrOrHash)
+	return uint64(len(txCIDs)), err
 }
 
-// returns true if the log matches on the filter
-func wantedLog(wantedTopics [][]string, actualTopics []common.Hash) bool {
-	// actualTopics will always have length <= 4
-	// wantedTopics will always have length 4
-	matches := 0
-	for i, actualTopic := range actualTopics {
-		// If we have topics in this filter slot, count as a match if the actualTopic matches one of the ones in this filter slot
-		if len(wantedTopics[i]) > 0 {
-			matches += sliceContainsHash(wantedTopics[i], actualTopic)
+// GetUncleCount returns the number of uncles in the block with the provided number or hash
+func (b *Backend) GetUncleCount(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (uint64, error) {
+	_, uncleCIDs, _, err := b.blockCIDs(blockNrOrHash)
+	return uint64(len(uncleCIDs)), err
+}
+
+// GetTransactionByBlockAndIndex returns the transaction at the provided index in the block with the provided number or hash
+// It also returns the hash and number of the block; a nil transaction is returned if the index is out of range
+func (b *Backend) GetTransactionByBlockAndIndex(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, index uint64) (*types.Transaction, common.Hash, uint64, error) {
+	headerCID, _, txCIDs, err := b.blockCIDs(blockNrOrHash)
+	if err != nil {
+		return nil, common.Hash{}, 0, err
+	}
+	if index >= uint64(len(txCIDs)) {
+		return nil, common.Hash{}, 0, nil
+	}
+	number, err := strconv.ParseUint(headerCID.BlockNumber, 10, 64)
+	if err != nil {
+		return nil, common.Hash{}, 0, err
+	}
+
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, common.Hash{}, 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
 		} else {
-			// Filter slot is empty, not matching any topics at this slot => counts as a match
-			matches++
+			err = tx.Commit()
 		}
+	}()
+
+	txIPLDs, err := b.Fetcher.FetchTrxs(tx, txCIDs[index:index+1])
+	if err != nil {
+		return nil, common.Hash{}, 0, err
 	}
-	if matches == len(actualTopics) {
-		return true
+	var transaction types.Transaction
+	if err := rlp.DecodeBytes(txIPLDs[0].Data, &transaction); err != nil {
+		return nil, common.Hash{}, 0, err
 	}
-	return false
+	return &transaction, common.HexToHash(headerCID.BlockHash), number, err
 }
 
-// returns 1 if the slice contains the hash, 0 if it does not
-func sliceContainsHash(slice []string, hash common.Hash) int {
-	for _, str := range slice {
-		if str == hash.String() {
-			return 1
+// GetUncleByBlockAndIndex returns the uncle header at the provided index in the block with the provided number or hash
+// A nil header is returned if the index is out of range
+func (b *Backend) GetUncleByBlockAndIndex(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, index uint64) (*types.Header, error) {
+	_, uncleCIDs, _, err := b.blockCIDs(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if index >= uint64(len(uncleCIDs)) {
+		return nil, nil
+	}
+
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	uncleIPLDs, err := b.Fetcher.FetchUncles(tx, uncleCIDs[index:index+1])
+	if err != nil {
+		return nil, err
+	}
+	var uncle types.Header
+	if err := rlp.DecodeBytes(uncleIPLDs[0].Data, &uncle); err != nil {
+		return nil, err
+	}
+	return &uncle, err
+}
+
+// GetAccountByNumberOrHash returns the account object for the provided address at the block corresponding to the provided number or hash
+// A nil account is returned if the account does not exist at that block
+func (b *Backend) GetAccountByNumberOrHash(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*state.Account, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return b.GetAccountByHash(ctx, address, hash)
+	}
+	number, err := b.numberFromNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetAccountByNumber(ctx, address, number)
+}
+
+// GetAccountByNumber returns the account object for the provided address at the provided block height
+// It does so by finding the most recent canonical state leaf diff for the address' leaf key at or below that height
+func (b *Backend) GetAccountByNumber(ctx context.Context, address common.Address, number int64) (*state.Account, error) {
+	leafKey := crypto.Keccak256Hash(address.Bytes())
+	pgStr := `SELECT state_cids.mh_key, state_cids.node_type
+			FROM eth.state_cids, eth.header_cids
+			WHERE state_cids.header_id = header_cids.id
+			AND state_cids.state_leaf_key = $1
+			AND header_cids.block_number <= $2
+			AND header_cids.canonical
+			ORDER BY header_cids.block_number DESC
+			LIMIT 1`
+	return b.accountByLeafCID(pgStr, leafKey.Hex(), number)
+}
+
+// GetAccountByHash returns the account object for the provided address at the block with the provided hash
+// It does so by finding the most recent state leaf diff for the address' leaf key on the branch ending in that block,
+// which need not be canonical
+func (b *Backend) GetAccountByHash(ctx context.Context, address common.Address, hash common.Hash) (*state.Account, error) {
+	if _, err := b.numberFromNumberOrHash(rpc.BlockNumberOrHashWithHash(hash, false)); err != nil {
+		return nil, err
+	}
+	leafKey := crypto.Keccak256Hash(address.Bytes())
+	pgStr := branchHeadersPgStr + `SELECT state_cids.mh_key, state_cids.node_type
+			FROM eth.state_cids, eth.header_cids
+			WHERE state_cids.header_id = header_cids.id
+			AND state_cids.state_leaf_key = $2
+			AND ` + onBranchPgStr + `
+			ORDER BY header_cids.block_number DESC
+			LIMIT 1`
+	return b.accountByLeafCID(pgStr, hash.String(), leafKey.Hex())
+}
+
+// accountByLeafCID looks up the state leaf cid with the provided query and decodes the account it references
+func (b *Backend) accountByLeafCID(pgStr string, args ...interface{}) (*state.Account, error) {
+	var leafCID leafCIDModel
+	if err := b.DB.Get(&leafCID, pgStr, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if leafCID.NodeType == ResolveFromNodeType(statediff.Removed) {
+		return nil, nil
+	}
+
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	leafNode, err := shared.FetchIPLDByMhKey(tx, leafCID.MhKey)
+	if err != nil {
+		return nil, err
+	}
+	account, err := decodeStateLeafAccount(leafNode)
+	if err != nil {
+		return nil, err
+	}
+	return account, err
+}
+
+// GetStorageByNumberOrHash returns the storage value for the provided contract address and storage slot at the block
+// corresponding to the provided number or hash
+// The value is returned as a 32-byte word; slots without a value, or whose leaf has been removed, read as zero
+func (b *Backend) GetStorageByNumberOrHash(ctx context.Context, address common.Address, key common.Hash, blockNrOrHash rpc.BlockNumberOrHash) ([]byte, error) {
+	stateLeafKey := crypto.Keccak256Hash(address.Bytes())
+	storageLeafKey := crypto.Keccak256Hash(key.Bytes())
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		// If the contract does not exist at this block none of its storage does either
+		account, err := b.GetAccountByHash(ctx, address, hash)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			return common.Hash{}.Bytes(), nil
+		}
+		pgStr := branchHeadersPgStr + `SELECT storage_cids.mh_key, storage_cids.node_type
+			FROM eth.storage_cids, eth.state_cids, eth.header_cids
+			WHERE storage_cids.state_id = state_cids.id
+			AND state_cids.header_id = header_cids.id
+			AND state_cids.state_leaf_key = $2
+			AND storage_cids.storage_leaf_key = $3
+			AND ` + onBranchPgStr + `
+			ORDER BY header_cids.block_number DESC
+			LIMIT 1`
+		return b.storageByLeafCID(pgStr, hash.String(), stateLeafKey.Hex(), storageLeafKey.Hex())
+	}
+	number, err := b.numberFromNumberOrHash(blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	// If the contract does not exist at this height none of its storage does either
+	account, err := b.GetAccountByNumber(ctx, address, number)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return common.Hash{}.Bytes(), nil
+	}
+	pgStr := `SELECT storage_cids.mh_key, storage_cids.node_type
+			FROM eth.storage_cids, eth.state_cids, eth.header_cids
+			WHERE storage_cids.state_id = state_cids.id
+			AND state_cids.header_id = header_cids.id
+			AND state_cids.state_leaf_key = $1
+			AND storage_cids.storage_leaf_key = $2
+			AND header_cids.block_number <= $3
+			AND header_cids.canonical
+			ORDER BY header_cids.block_number DESC
+			LIMIT 1`
+	return b.storageByLeafCID(pgStr, stateLeafKey.Hex(), storageLeafKey.Hex(), number)
+}
+
+// storageByLeafCID looks up the storage leaf cid with the provided query and decodes the value it references
+func (b *Backend) storageByLeafCID(pgStr string, args ...interface{}) ([]byte, error) {
+	var leafCID leafCIDModel
+	if err := b.DB.Get(&leafCID, pgStr, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return common.Hash{}.Bytes(), nil
+		}
+		return nil, err
+	}
+	if leafCID.NodeType == ResolveFromNodeType(statediff.Removed) {
+		return common.Hash{}.Bytes(), nil
+	}
+
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	leafNode, err := shared.FetchIPLDByMhKey(tx, leafCID.MhKey)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeStorageLeafValue(leafNode)
+	if err != nil {
+		return nil, err
+	}
+	return common.BytesToHash(value).Bytes(), err
+}
+
+// GetCodeByHash returns the contract code for the provided code hash
+// Contract code is looked up in public.blocks using the keccak256 multihash key derived from the code hash
+func (b *Backend) GetCodeByHash(ctx context.Context, codeHash common.Hash) ([]byte, error) {
+	if codeHash == emptyCodeHash {
+		return []byte{}, nil
+	}
+	mhKey, err := shared.MultihashKeyFromKeccak256(codeHash)
+	if err != nil {
+		return nil, err
+	}
+	pgStr := `SELECT data FROM public.blocks WHERE key = $1`
+	var code []byte
+	if err := b.DB.Get(&code, pgStr, mhKey); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("code for hash %s is not available", codeHash.Hex())
+		}
+		return nil, err
+	}
+	return code, nil
+}
+
+// GetAccountProof returns the account found at the provided address in the state trie with the provided root, along
+// with the Merkle proof for it; the proof is assembled by walking the trie nodes in public.blocks down from the root
+// A nil account is returned, alongside a proof of its absence, if the account does not exist in the trie
+func (b *Backend) GetAccountProof(ctx context.Context, address common.Address, root common.Hash) (*state.Account, [][]byte, error) {
+	stateTrie, err := b.StateDatabase.OpenTrie(root)
+	if err != nil {
+		return nil, nil, proofError(err)
+	}
+	var proof proofList
+	if err := stateTrie.Prove(crypto.Keccak256(address.Bytes()), 0, &proof); err != nil {
+		return nil, nil, proofError(err)
+	}
+	enc, err := stateTrie.TryGet(address.Bytes())
+	if err != nil {
+		return nil, nil, proofError(err)
+	}
+	if len(enc) == 0 {
+		return nil, proof, nil
+	}
+	account := new(state.Account)
+	if err := rlp.DecodeBytes(enc, account); err != nil {
+		return nil, nil, err
+	}
+	return account, proof, nil
+}
+
+// GetStorageProof returns the value found at the provided storage slot in the storage trie of the provided account,
+// along with the Merkle proof for it; the proof is assembled by walking the trie nodes in public.blocks
+// down from the account's storage root
+func (b *Backend) GetStorageProof(ctx context.Context, address common.Address, account *state.Account, key common.Hash) (common.Hash, [][]byte, error) {
+	storageTrie, err := b.StateDatabase.OpenStorageTrie(crypto.Keccak256Hash(address.Bytes()), account.Root)
+	if err != nil {
+		return common.Hash{}, nil, proofError(err)
+	}
+	var proof proofList
+	if err := storageTrie.Prove(crypto.Keccak256(key.Bytes()), 0, &proof); err != nil {
+		return common.Hash{}, nil, proofError(err)
+	}
+	enc, err := storageTrie.TryGet(key.Bytes())
+	if err != nil {
+		return common.Hash{}, nil, proofError(err)
+	}
+	if len(enc) == 0 {
+		return common.Hash{}, proof, nil
+	}
+	_, content, _, err := rlp.Split(enc)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	return common.BytesToHash(content), proof, nil
+}
+
+// proofList collects the trie nodes of a Merkle proof, in order from the root
+// It satisfies the ethdb.KeyValueWriter interface
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
+
+func (n *proofList) Delete(key []byte) error {
+	panic("not supported")
+}
+
+// proofError converts errors for trie nodes that are missing from public.blocks into a more descriptive error
+func proofError(err error) error {
+	if missingErr, ok := err.(*trie.MissingNodeError); ok {
+		return fmt.Errorf("trie node %s (path %x) required for the proof is not available in the index", missingErr.NodeHash.Hex(), missingErr.Path)
+	}
+	return err
+}
+
+// numberFromNumberOrHash resolves the block height for the provided block number or hash
+func (b *Backend) numberFromNumberOrHash(blockNrOrHash rpc.BlockNumberOrHash) (int64, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		var number int64
+		pgStr := `SELECT block_number FROM eth.header_cids WHERE block_hash = $1`
+		if err := b.DB.Get(&number, pgStr, hash.String()); err != nil {
+			if err == sql.ErrNoRows {
+				return 0, fmt.Errorf("header for hash %s is not available", hash.Hex())
+			}
+			return 0, err
 		}
+		return number, nil
+	}
+	blockNumber, ok := blockNrOrHash.Number()
+	if !ok {
+		return 0, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	switch blockNumber {
+	case rpc.PendingBlockNumber:
+		return 0, errPendingBlockNumber
+	case rpc.LatestBlockNumber:
+		return b.Retriever.RetrieveLastBlockNumber()
+	}
+	number := blockNumber.Int64()
+	var exists bool
+	pgStr := `SELECT EXISTS(SELECT 1 FROM eth.header_cids WHERE block_number = $1)`
+	if err := b.DB.Get(&exists, pgStr, number); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, fmt.Errorf("header at block %d is not available", number)
+	}
+	return number, nil
+}
+
+// decodeStateLeafAccount decodes the account object from the rlp of a state leaf node
+func decodeStateLeafAccount(leafNode []byte) (*state.Account, error) {
+	var i []interface{}
+	if err := rlp.DecodeBytes(leafNode, &i); err != nil {
+		return nil, err
+	}
+	if len(i) != 2 {
+		return nil, fmt.Errorf("eth expected state leaf node rlp to decode into two elements")
+	}
+	accountRLP, ok := i[1].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("eth expected state leaf node value to be a byte slice")
+	}
+	account := new(state.Account)
+	if err := rlp.DecodeBytes(accountRLP, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// decodeStorageLeafValue decodes the storage value from the rlp of a storage leaf node
+func decodeStorageLeafValue(leafNode []byte) ([]byte, error) {
+	var i []interface{}
+	if err := rlp.DecodeBytes(leafNode, &i); err != nil {
+		return nil, err
+	}
+	if len(i) != 2 {
+		return nil, fmt.Errorf("eth expected storage leaf node rlp to decode into two elements")
+	}
+	valueRLP, ok := i[1].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("eth expected storage leaf node value to be a byte slice")
+	}
+	var value []byte
+	if err := rlp.DecodeBytes(valueRLP, &value); err != nil {
+		return nil, err
 	}
-	return 0
+	return value, nil
 }
 
 // rpcMarshalHeader uses the generalized output filler, then adds the total difficulty field, which requires
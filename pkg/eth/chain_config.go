@@ -0,0 +1,160 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+const (
+	// DefaultNetwork is the network used when neither ethereum.network nor ethereum.genesisFile are configured
+	DefaultNetwork = "mainnet"
+	// AutoDetectNetwork is the ethereum.network value used to detect the chain config from the node
+	AutoDetectNetwork = "auto"
+)
+
+type network struct {
+	config      *params.ChainConfig
+	genesisHash common.Hash
+}
+
+// networks maps the names accepted by ethereum.network to the chain config and genesis hash of that network
+var networks = map[string]network{
+	"mainnet": {params.MainnetChainConfig, params.MainnetGenesisHash},
+	"ropsten": {params.TestnetChainConfig, params.TestnetGenesisHash},
+	"testnet": {params.TestnetChainConfig, params.TestnetGenesisHash},
+	"rinkeby": {params.RinkebyChainConfig, params.RinkebyGenesisHash},
+	"goerli":  {params.GoerliChainConfig, params.GoerliGenesisHash},
+}
+
+// NetworkChainConfig returns a copy of the chain config of the named network, along with its genesis hash
+func NetworkChainConfig(name string) (*params.ChainConfig, common.Hash, error) {
+	if name == "" {
+		name = DefaultNetwork
+	}
+	n, ok := networks[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(networks))
+		for name := range networks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, common.Hash{}, fmt.Errorf("unrecognized ethereum network %s, expected one of %s or %s", name, strings.Join(names, ", "), AutoDetectNetwork)
+	}
+	config := *n.config
+	return &config, n.genesisHash, nil
+}
+
+// GenesisChainConfig loads the chain config from a geth genesis JSON file, along with the hash of the genesis block
+func GenesisChainConfig(path string) (*params.ChainConfig, common.Hash, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, common.Hash{}, err
+	}
+	defer file.Close()
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		return nil, common.Hash{}, fmt.Errorf("unable to decode ethereum genesis file %s: %v", path, err)
+	}
+	if genesis.Config == nil {
+		return nil, common.Hash{}, fmt.Errorf("ethereum genesis file %s does not contain a chain config", path)
+	}
+	return genesis.Config, genesis.ToBlock(nil).Hash(), nil
+}
+
+// DetectChainConfig retrieves the chain config from the node
+// The config is read from admin_nodeInfo when the node exposes the admin api, otherwise the node's genesis hash is
+// matched against the known networks
+func DetectChainConfig(client *rpc.Client) (*params.ChainConfig, common.Hash, error) {
+	var info struct {
+		Protocols struct {
+			Eth struct {
+				Genesis common.Hash         `json:"genesis"`
+				Config  *params.ChainConfig `json:"config"`
+			} `json:"eth"`
+		} `json:"protocols"`
+	}
+	if err := client.Call(&info, "admin_nodeInfo"); err == nil && info.Protocols.Eth.Config != nil {
+		return info.Protocols.Eth.Config, info.Protocols.Eth.Genesis, nil
+	}
+	var genesis struct {
+		Hash common.Hash `json:"hash"`
+	}
+	if err := client.Call(&genesis, "eth_getBlockByNumber", "0x0", false); err != nil {
+		return nil, common.Hash{}, fmt.Errorf("unable to retrieve the genesis block from the ethereum node: %v", err)
+	}
+	for _, n := range networks {
+		if n.genesisHash != genesis.Hash {
+			continue
+		}
+		config := *n.config
+		var chainID hexutil.Big
+		if err := client.Call(&chainID, "eth_chainId"); err == nil && chainID.ToInt().Cmp(config.ChainID) != 0 {
+			return nil, common.Hash{}, fmt.Errorf("ethereum node has genesis block %s but chain id %s", genesis.Hash.Hex(), chainID.ToInt().String())
+		}
+		return &config, genesis.Hash, nil
+	}
+	return nil, common.Hash{}, fmt.Errorf("unable to detect the chain config of the ethereum node with genesis block %s, configure ethereum.genesisFile instead", genesis.Hash.Hex())
+}
+
+// ChainConfigFromConfig returns the chain config loaded from ethereum.genesisFile, or else the config of the network
+// selected by ethereum.network; setting the network to "auto" detects the config using the provided client
+// The config is validated against the genesis block of the provided node info
+func ChainConfigFromConfig(nodeInfo node.Node, client *rpc.Client) (*params.ChainConfig, error) {
+	viper.BindEnv("ethereum.genesisFile", shared.ETH_GENESIS_FILE)
+	viper.BindEnv("ethereum.network", shared.ETH_NETWORK)
+	var (
+		config      *params.ChainConfig
+		genesisHash common.Hash
+		err         error
+	)
+	genesisFile := viper.GetString("ethereum.genesisFile")
+	network := viper.GetString("ethereum.network")
+	switch {
+	case genesisFile != "":
+		config, genesisHash, err = GenesisChainConfig(genesisFile)
+	case strings.EqualFold(network, AutoDetectNetwork):
+		if client == nil {
+			return nil, errors.New("ethereum chain config auto-detection requires a connection to the node")
+		}
+		config, genesisHash, err = DetectChainConfig(client)
+	default:
+		config, genesisHash, err = NetworkChainConfig(network)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if nodeInfo.GenesisBlock != "" && common.HexToHash(nodeInfo.GenesisBlock) != genesisHash {
+		return nil, fmt.Errorf("ethereum chain config has genesis block %s but the node's genesis block is %s", genesisHash.Hex(), nodeInfo.GenesisBlock)
+	}
+	return config, nil
+}
@@ -0,0 +1,172 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+)
+
+// genesisAPI is a stand-in for the eth api of a node on the chain with the provided genesis hash and chain id
+type genesisAPI struct {
+	genesisHash common.Hash
+	chainID     *big.Int
+}
+
+func (api genesisAPI) GetBlockByNumber(number rpc.BlockNumber, fullTx bool) map[string]interface{} {
+	return map[string]interface{}{"hash": api.genesisHash}
+}
+
+func (api genesisAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(api.chainID)
+}
+
+// adminAPI is a stand-in for the admin api of a node, which reports its chain config
+type adminAPI struct {
+	config      *params.ChainConfig
+	genesisHash common.Hash
+}
+
+func (api adminAPI) NodeInfo() map[string]interface{} {
+	return map[string]interface{}{
+		"protocols": map[string]interface{}{
+			"eth": map[string]interface{}{
+				"genesis": api.genesisHash,
+				"config":  api.config,
+			},
+		},
+	}
+}
+
+var _ = Describe("Chain config", func() {
+	AfterEach(func() {
+		viper.Reset()
+	})
+
+	Describe("NetworkChainConfig", func() {
+		It("Returns a copy of the chain config of the named network", func() {
+			config, genesisHash, err := eth.NetworkChainConfig("Goerli")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ChainID).To(Equal(params.GoerliChainConfig.ChainID))
+			Expect(genesisHash).To(Equal(params.GoerliGenesisHash))
+			Expect(config).ToNot(BeIdenticalTo(params.GoerliChainConfig))
+
+			config, genesisHash, err = eth.NetworkChainConfig("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ChainID).To(Equal(params.MainnetChainConfig.ChainID))
+			Expect(genesisHash).To(Equal(params.MainnetGenesisHash))
+
+			_, _, err = eth.NetworkChainConfig("moonnet")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GenesisChainConfig", func() {
+		It("Loads the chain config from a genesis file", func() {
+			dir, err := ioutil.TempDir("", "chain_config")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			genesisJSON, err := json.Marshal(core.DefaultGoerliGenesisBlock())
+			Expect(err).ToNot(HaveOccurred())
+			genesisFile := filepath.Join(dir, "genesis.json")
+			err = ioutil.WriteFile(genesisFile, genesisJSON, 0644)
+			Expect(err).ToNot(HaveOccurred())
+
+			config, genesisHash, err := eth.GenesisChainConfig(genesisFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ChainID).To(Equal(params.GoerliChainConfig.ChainID))
+			Expect(config.Clique).ToNot(BeNil())
+			Expect(genesisHash).To(Equal(params.GoerliGenesisHash))
+
+			viper.Set("ethereum.genesisFile", genesisFile)
+			_, err = eth.ChainConfigFromConfig(node.Node{GenesisBlock: params.GoerliGenesisHash.Hex()}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = eth.ChainConfigFromConfig(node.Node{GenesisBlock: params.MainnetGenesisHash.Hex()}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("DetectChainConfig", func() {
+		It("Uses the chain config reported by the admin api", func() {
+			server := rpc.NewServer()
+			defer server.Stop()
+			custom := *params.TestChainConfig
+			custom.ChainID = big.NewInt(1337)
+			err := server.RegisterName("admin", adminAPI{config: &custom, genesisHash: common.HexToHash("0x01")})
+			Expect(err).ToNot(HaveOccurred())
+			client := rpc.DialInProc(server)
+			defer client.Close()
+
+			config, genesisHash, err := eth.DetectChainConfig(client)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ChainID).To(Equal(big.NewInt(1337)))
+			Expect(genesisHash).To(Equal(common.HexToHash("0x01")))
+		})
+
+		It("Matches the node's genesis block against the known networks", func() {
+			server := rpc.NewServer()
+			defer server.Stop()
+			err := server.RegisterName(eth.APIName, genesisAPI{genesisHash: params.RinkebyGenesisHash, chainID: big.NewInt(4)})
+			Expect(err).ToNot(HaveOccurred())
+			client := rpc.DialInProc(server)
+			defer client.Close()
+
+			viper.Set("ethereum.network", eth.AutoDetectNetwork)
+			config, err := eth.ChainConfigFromConfig(node.Node{}, client)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.ChainID).To(Equal(params.RinkebyChainConfig.ChainID))
+		})
+
+		It("Throws an error for unknown chains", func() {
+			server := rpc.NewServer()
+			defer server.Stop()
+			err := server.RegisterName(eth.APIName, genesisAPI{genesisHash: common.HexToHash("0x01"), chainID: big.NewInt(1337)})
+			Expect(err).ToNot(HaveOccurred())
+			client := rpc.DialInProc(server)
+			defer client.Close()
+
+			_, _, err = eth.DetectChainConfig(client)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Rewards", func() {
+		It("Follows the forks of the chain config", func() {
+			header := &types.Header{Number: big.NewInt(2000000)}
+			Expect(eth.CalcEthBlockReward(params.MainnetChainConfig, header, nil, nil, nil).String()).To(Equal("5000000000000000000"))
+			Expect(eth.CalcEthBlockReward(params.TestnetChainConfig, header, nil, nil, nil).String()).To(Equal("3000000000000000000"))
+			Expect(eth.CalcEthBlockReward(params.GoerliChainConfig, header, nil, nil, nil).String()).To(Equal("0"))
+			Expect(eth.CalcUncleMinerReward(params.TestnetChainConfig, 2000000, 1999999).String()).To(Equal("2625000000000000000"))
+		})
+	})
+})
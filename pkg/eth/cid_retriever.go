@@ -51,10 +51,10 @@ func (ecr *CIDRetriever) RetrieveFirstBlockNumber() (int64, error) {
 	return blockNumber, err
 }
 
-// RetrieveLastBlockNumber is used to retrieve the latest block number in the db
+// RetrieveLastBlockNumber is used to retrieve the latest canonical block number in the db
 func (ecr *CIDRetriever) RetrieveLastBlockNumber() (int64, error) {
 	var blockNumber int64
-	err := ecr.db.Get(&blockNumber, "SELECT block_number FROM eth.header_cids ORDER BY block_number DESC LIMIT 1 ")
+	err := ecr.db.Get(&blockNumber, "SELECT block_number FROM eth.header_cids WHERE canonical ORDER BY block_number DESC LIMIT 1 ")
 	return blockNumber, err
 }
 
@@ -82,8 +82,8 @@ func (ecr *CIDRetriever) Retrieve(filter shared.SubscriptionSettings, blockNumbe
 		}
 	}()
 
-	// Retrieve cached header CIDs at this block height
-	headers, err := ecr.RetrieveHeaderCIDs(tx, blockNumber)
+	// Retrieve cached canonical header CIDs at this block height
+	headers, err := ecr.RetrieveCanonicalHeaderCIDs(tx, blockNumber)
 	if err != nil {
 		log.Error("header cid retrieval error")
 		return nil, true, err
@@ -160,12 +160,24 @@ func (ecr *CIDRetriever) Retrieve(filter shared.SubscriptionSettings, blockNumbe
 	return cws, empty, err
 }
 
-// RetrieveHeaderCIDs retrieves and returns all of the header cids at the provided blockheight
+// RetrieveHeaderCIDs retrieves and returns all of the header cids at the provided blockheight, the canonical header first
 func (ecr *CIDRetriever) RetrieveHeaderCIDs(tx *sqlx.Tx, blockNumber int64) ([]HeaderModel, error) {
 	log.Debug("retrieving header cids for block ", blockNumber)
 	headers := make([]HeaderModel, 0)
 	pgStr := `SELECT * FROM eth.header_cids
-				WHERE block_number = $1`
+				WHERE block_number = $1
+				ORDER BY canonical DESC, id`
+	return headers, tx.Select(&headers, pgStr, blockNumber)
+}
+
+// RetrieveCanonicalHeaderCIDs retrieves and returns the canonical header cid at the provided blockheight
+// The slice is empty if the header at this height has not been indexed or was orphaned by a reorg
+func (ecr *CIDRetriever) RetrieveCanonicalHeaderCIDs(tx *sqlx.Tx, blockNumber int64) ([]HeaderModel, error) {
+	log.Debug("retrieving canonical header cids for block ", blockNumber)
+	headers := make([]HeaderModel, 0)
+	pgStr := `SELECT * FROM eth.header_cids
+				WHERE block_number = $1
+				AND canonical`
 	return headers, tx.Select(&headers, pgStr, blockNumber)
 }
 
@@ -174,7 +186,8 @@ func (ecr *CIDRetriever) RetrieveUncleCIDsByHeaderID(tx *sqlx.Tx, headerID int64
 	log.Debug("retrieving uncle cids for block id ", headerID)
 	headers := make([]UncleModel, 0)
 	pgStr := `SELECT * FROM eth.uncle_cids
-				WHERE header_id = $1`
+				WHERE header_id = $1
+				ORDER BY id`
 	return headers, tx.Select(&headers, pgStr, headerID)
 }
 
@@ -301,6 +314,9 @@ func (ecr *CIDRetriever) RetrieveRctCIDs(tx *sqlx.Tx, rctFilter ReceiptFilter, b
 		pgStr += fmt.Sprintf(` AND header_cids.block_number = $%d`, id)
 		args = append(args, blockNumber)
 		id++
+		if blockHash == nil {
+			pgStr += ` AND header_cids.canonical`
+		}
 	}
 	if blockHash != nil {
 		pgStr += fmt.Sprintf(` AND header_cids.block_hash = $%d`, id)
@@ -373,6 +389,57 @@ func (ecr *CIDRetriever) RetrieveRctCIDs(tx *sqlx.Tx, rctFilter ReceiptFilter, b
 	return receiptCids, tx.Select(&receiptCids, pgStr, args...)
 }
 
+// RetrieveHeaderBloomsInRange retrieves the id, block number, hash and bloom of all of the canonical headers within the
+// provided block range, ordered by block number
+func (ecr *CIDRetriever) RetrieveHeaderBloomsInRange(tx *sqlx.Tx, start, end int64) ([]HeaderModel, error) {
+	log.Debugf("retrieving header blooms for blocks %d to %d", start, end)
+	headers := make([]HeaderModel, 0)
+	pgStr := `SELECT id, block_number, block_hash, bloom FROM eth.header_cids
+				WHERE block_number BETWEEN $1 AND $2
+				AND canonical
+				ORDER BY block_number, id`
+	return headers, tx.Select(&headers, pgStr, start, end)
+}
+
+// RetrieveHeaderIDsWithMatchingRcts retrieves and returns the ids of the provided headers which have receipts that
+// conform to the log address and topic parameters of the provided filter, in a single query
+func (ecr *CIDRetriever) RetrieveHeaderIDsWithMatchingRcts(tx *sqlx.Tx, rctFilter ReceiptFilter, headerIDs []int64) ([]int64, error) {
+	log.Debugf("retrieving headers with matching receipts for %d headers", len(headerIDs))
+	ids := make([]int64, 0)
+	if len(headerIDs) == 0 {
+		return ids, nil
+	}
+	pgStr := `SELECT DISTINCT header_cids.id, header_cids.block_number
+ 			FROM eth.receipt_cids, eth.transaction_cids, eth.header_cids`
+	where, args := rctsByHeaderIDsConditions(rctFilter, headerIDs)
+	pgStr = `SELECT id FROM (` + pgStr + where + `) AS matches ORDER BY block_number, id`
+	return ids, tx.Select(&ids, pgStr, args...)
+}
+
+// rctsByHeaderIDsConditions builds the WHERE clause, and its arguments, which restricts the receipts joined to their
+// transactions and headers to those of the provided headers that conform to the provided filter
+func rctsByHeaderIDsConditions(rctFilter ReceiptFilter, headerIDs []int64) (string, []interface{}) {
+	args := []interface{}{pq.Array(headerIDs)}
+	pgStr := `
+			WHERE receipt_cids.tx_id = transaction_cids.id
+			AND transaction_cids.header_id = header_cids.id
+			AND header_cids.id = ANY($1::INTEGER[])`
+	id := 2
+	if len(rctFilter.LogAddresses) > 0 {
+		pgStr += fmt.Sprintf(` AND receipt_cids.log_contracts && $%d::VARCHAR(66)[]`, id)
+		args = append(args, pq.Array(rctFilter.LogAddresses))
+		id++
+	}
+	for i, topicSet := range rctFilter.Topics {
+		if i < 4 && len(topicSet) > 0 {
+			pgStr += fmt.Sprintf(` AND receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
+			args = append(args, pq.Array(topicSet))
+			id++
+		}
+	}
+	return pgStr, args
+}
+
 func hasTopics(topics [][]string) bool {
 	for _, topicSet := range topics {
 		if len(topicSet) > 0 {
@@ -442,6 +509,7 @@ func (ecr *CIDRetriever) RetrieveStorageCIDs(tx *sqlx.Tx, storageFilter StorageF
 
 // RetrieveGapsInData is used to find the the block numbers at which we are missing data in the db
 // it finds the union of heights where no data exists and where the times_validated is lower than the validation level
+// Heights at which every header was orphaned by a reorg are reported as gaps so that the replacement is fetched
 func (ecr *CIDRetriever) RetrieveGapsInData(validationLevel int) ([]shared.Gap, error) {
 	log.Info("searching for gaps in the eth ipfs watcher database")
 	startingBlock, err := ecr.RetrieveFirstBlockNumber()
@@ -481,7 +549,8 @@ func (ecr *CIDRetriever) RetrieveGapsInData(validationLevel int) ([]shared.Gap,
 	// Find sections of blocks where we are below the validation level
 	// There will be no overlap between these "gaps" and the ones above
 	pgStr = `SELECT block_number FROM eth.header_cids
-			WHERE times_validated < $1
+			GROUP BY block_number
+			HAVING NOT bool_or(canonical) OR bool_or(canonical AND times_validated < $1)
 			ORDER BY block_number`
 	var heights []uint64
 	if err := ecr.db.Select(&heights, pgStr, validationLevel); err != nil && err != sql.ErrNoRows {
@@ -22,6 +22,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -218,7 +219,7 @@ var _ = Describe("Retriever", func() {
 		var err error
 		db, err = shared.SetupDB()
 		Expect(err).ToNot(HaveOccurred())
-		repo = eth2.NewIPLDPublisherAndIndexer(db)
+		repo = eth2.NewIPLDPublisherAndIndexer(db, params.MainnetChainConfig, nil)
 		retriever = eth2.NewCIDRetriever(db)
 	})
 	AfterEach(func() {
@@ -488,10 +489,11 @@ var _ = Describe("Retriever", func() {
 			payload0 := mocks.MockConvertedPayload
 			payload0.Block = newMockBlock(0)
 			payload1 := mocks.MockConvertedPayload
+			payload1.Block = mocks.NewMockChildBlock(payload0.Block)
 			payload2 := payload1
-			payload2.Block = newMockBlock(2)
+			payload2.Block = mocks.NewMockChildBlock(payload1.Block)
 			payload3 := payload2
-			payload3.Block = newMockBlock(3)
+			payload3.Block = mocks.NewMockChildBlock(payload2.Block)
 			_, err := repo.Publish(payload0)
 			Expect(err).ToNot(HaveOccurred())
 			_, err = repo.Publish(payload1)
@@ -521,6 +523,7 @@ var _ = Describe("Retriever", func() {
 			payload0 := mocks.MockConvertedPayload
 			payload0.Block = newMockBlock(0)
 			payload1 := mocks.MockConvertedPayload
+			payload1.Block = mocks.NewMockChildBlock(payload0.Block)
 			payload3 := payload1
 			payload3.Block = newMockBlock(3)
 			_, err := repo.Publish(payload0)
@@ -562,17 +565,17 @@ var _ = Describe("Retriever", func() {
 			payload4 := mocks.MockConvertedPayload
 			payload4.Block = newMockBlock(100)
 			payload5 := mocks.MockConvertedPayload
-			payload5.Block = newMockBlock(101)
+			payload5.Block = mocks.NewMockChildBlock(payload4.Block)
 			payload6 := mocks.MockConvertedPayload
-			payload6.Block = newMockBlock(102)
+			payload6.Block = mocks.NewMockChildBlock(payload5.Block)
 			payload7 := mocks.MockConvertedPayload
-			payload7.Block = newMockBlock(103)
+			payload7.Block = mocks.NewMockChildBlock(payload6.Block)
 			payload8 := mocks.MockConvertedPayload
-			payload8.Block = newMockBlock(104)
+			payload8.Block = mocks.NewMockChildBlock(payload7.Block)
 			payload9 := mocks.MockConvertedPayload
-			payload9.Block = newMockBlock(105)
+			payload9.Block = mocks.NewMockChildBlock(payload8.Block)
 			payload10 := mocks.MockConvertedPayload
-			payload10.Block = newMockBlock(106)
+			payload10.Block = mocks.NewMockChildBlock(payload9.Block)
 			payload11 := mocks.MockConvertedPayload
 			payload11.Block = newMockBlock(1000)
 
@@ -620,23 +623,23 @@ var _ = Describe("Retriever", func() {
 			payload4 := mocks.MockConvertedPayload
 			payload4.Block = newMockBlock(100)
 			payload5 := mocks.MockConvertedPayload
-			payload5.Block = newMockBlock(101)
+			payload5.Block = mocks.NewMockChildBlock(payload4.Block)
 			payload6 := mocks.MockConvertedPayload
-			payload6.Block = newMockBlock(102)
+			payload6.Block = mocks.NewMockChildBlock(payload5.Block)
 			payload7 := mocks.MockConvertedPayload
-			payload7.Block = newMockBlock(103)
+			payload7.Block = mocks.NewMockChildBlock(payload6.Block)
 			payload8 := mocks.MockConvertedPayload
-			payload8.Block = newMockBlock(104)
+			payload8.Block = mocks.NewMockChildBlock(payload7.Block)
 			payload9 := mocks.MockConvertedPayload
-			payload9.Block = newMockBlock(105)
+			payload9.Block = mocks.NewMockChildBlock(payload8.Block)
 			payload10 := mocks.MockConvertedPayload
-			payload10.Block = newMockBlock(106)
+			payload10.Block = mocks.NewMockChildBlock(payload9.Block)
 			payload11 := mocks.MockConvertedPayload
-			payload11.Block = newMockBlock(107)
+			payload11.Block = mocks.NewMockChildBlock(payload10.Block)
 			payload12 := mocks.MockConvertedPayload
-			payload12.Block = newMockBlock(108)
+			payload12.Block = mocks.NewMockChildBlock(payload11.Block)
 			payload13 := mocks.MockConvertedPayload
-			payload13.Block = newMockBlock(109)
+			payload13.Block = mocks.NewMockChildBlock(payload12.Block)
 			payload14 := mocks.MockConvertedPayload
 			payload14.Block = newMockBlock(1000)
 
@@ -686,6 +689,101 @@ var _ = Describe("Retriever", func() {
 			Expect(shared.ListContainsGap(gaps, shared.Gap{Start: 1001, Stop: 1010100})).To(BeTrue())
 		})
 	})
+
+	Describe("Canonical chain tracking", func() {
+		var block0, block1a, block1b, block2a, block2b, block3b *types.Block
+		publish := func(blocks ...*types.Block) {
+			for _, block := range blocks {
+				payload := mocks.MockConvertedPayload
+				payload.Block = block
+				_, err := repo.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+		}
+		expectCanonical := func(height int64, block *types.Block) {
+			tx, err := db.Beginx()
+			Expect(err).ToNot(HaveOccurred())
+			headers, err := retriever.RetrieveCanonicalHeaderCIDs(tx, height)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(headers)).To(Equal(1))
+			Expect(headers[0].BlockHash).To(Equal(block.Hash().String()))
+			err = tx.Commit()
+			Expect(err).ToNot(HaveOccurred())
+		}
+		BeforeEach(func() {
+			block0 = newMockBlock(0)
+			block1a = mocks.NewMockChildBlock(block0)
+			block1b = mocks.NewMockForkBlock(block0, 0x01)
+			block2a = mocks.NewMockChildBlock(block1a)
+			block2b = mocks.NewMockChildBlock(block1b)
+			block3b = mocks.NewMockChildBlock(block2b)
+		})
+
+		It("Keeps the canonical header at a height when a competing header is indexed there and returns it first", func() {
+			publish(block0, block1a, block1b)
+			tx, err := db.Beginx()
+			Expect(err).ToNot(HaveOccurred())
+			headers, err := retriever.RetrieveHeaderCIDs(tx, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(headers)).To(Equal(2))
+			Expect(headers[0].BlockHash).To(Equal(block1a.Hash().String()))
+			Expect(headers[0].Canonical).To(BeTrue())
+			Expect(headers[1].BlockHash).To(Equal(block1b.Hash().String()))
+			Expect(headers[1].Canonical).To(BeFalse())
+			err = tx.Commit()
+			Expect(err).ToNot(HaveOccurred())
+			expectCanonical(1, block1a)
+		})
+
+		It("Does not make a header canonical which the canonical child does not link to", func() {
+			publish(block0, block1a, block2a, block1b)
+			num, err := retriever.RetrieveLastBlockNumber()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(num).To(Equal(int64(2)))
+			expectCanonical(1, block1a)
+			expectCanonical(2, block2a)
+			gaps, err := retriever.RetrieveGapsInData(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(gaps)).To(Equal(0))
+		})
+
+		It("Reorganizes to a competing branch once it is indexed above the canonical head", func() {
+			publish(block0, block1a, block2a, block1b, block2b)
+			expectCanonical(1, block1a)
+			expectCanonical(2, block2a)
+
+			publish(block3b)
+			expectCanonical(1, block1b)
+			expectCanonical(2, block2b)
+			expectCanonical(3, block3b)
+			gaps, err := retriever.RetrieveGapsInData(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(gaps)).To(Equal(0))
+			cidWrappers, empty, err := retriever.Retrieve(openFilter, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(empty).ToNot(BeTrue())
+			Expect(len(cidWrappers)).To(Equal(1))
+			cidWrapper, ok := cidWrappers[0].(*eth.CIDWrapper)
+			Expect(ok).To(BeTrue())
+			Expect(cidWrapper.Header.BlockHash).To(Equal(block2b.Hash().String()))
+		})
+
+		It("Reports the height of a missing ancestor as a gap and fills it once the ancestor is indexed", func() {
+			publish(block0, block1a, block2b, block3b)
+			expectCanonical(0, block0)
+			expectCanonical(2, block2b)
+			gaps, err := retriever.RetrieveGapsInData(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(gaps)).To(Equal(1))
+			Expect(shared.ListContainsGap(gaps, shared.Gap{Start: 1, Stop: 1})).To(BeTrue())
+
+			publish(block1b)
+			expectCanonical(1, block1b)
+			gaps, err = retriever.RetrieveGapsInData(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(gaps)).To(Equal(0))
+		})
+	})
 })
 
 func newMockBlock(blockNumber uint64) *types.Block {
@@ -0,0 +1,126 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// codeBackFillBatchSize is the number of missing codes looked up in the db at a time
+const codeBackFillBatchSize = 100
+
+// missingCodesPgStr selects, in code hash order, the contract accounts within an id range whose code is not indexed,
+// along with the state leaf key and latest height they were seen at
+const missingCodesPgStr = `SELECT DISTINCT ON (state_accounts.code_hash) state_accounts.code_hash, state_cids.state_leaf_key,
+			header_cids.block_number
+			FROM eth.state_accounts
+			INNER JOIN eth.state_cids ON (state_accounts.state_id = state_cids.id)
+			INNER JOIN eth.header_cids ON (state_cids.header_id = header_cids.id)
+			WHERE state_accounts.id > $1
+			AND state_accounts.id <= $2
+			AND state_accounts.code_hash > $3
+			AND state_accounts.code_hash <> $4
+			AND NOT EXISTS (SELECT 1 FROM eth.contract_codes WHERE contract_codes.code_hash = state_accounts.code_hash)
+			ORDER BY state_accounts.code_hash, header_cids.block_number DESC
+			LIMIT $5`
+
+type missingCodeModel struct {
+	CodeHash    []byte `db:"code_hash"`
+	LeafKey     string `db:"state_leaf_key"`
+	BlockNumber string `db:"block_number"`
+}
+
+// CodeBackFiller publishes and indexes the code of indexed contract accounts whose code is missing,
+// such as those indexed before code was published or whose code could not be fetched at the time
+type CodeBackFiller struct {
+	indexer *CIDIndexer
+	fetcher *CodeFetcher
+	checked int64 // the id of the last state account checked by a completed pass
+}
+
+// NewCodeBackFiller returns a CodeBackFiller
+func NewCodeBackFiller(db *postgres.DB, fetcher *CodeFetcher) *CodeBackFiller {
+	return &CodeBackFiller{
+		indexer: NewCIDIndexer(db),
+		fetcher: fetcher,
+	}
+}
+
+// BackFill makes a single pass over the contract accounts indexed since the last pass whose code is missing, returning
+// the number of codes filled
+// Codes which cannot be fetched are logged and skipped; they are only tried again when another account with the same
+// code is indexed, or when the watcher restarts
+func (bf *CodeBackFiller) BackFill() (int, error) {
+	var filled int
+	var upTo int64
+	if err := bf.indexer.db.Get(&upTo, `SELECT COALESCE(MAX(id), 0) FROM eth.state_accounts`); err != nil {
+		return filled, err
+	}
+	last := []byte{}
+	for {
+		var missing []missingCodeModel
+		if err := bf.indexer.db.Select(&missing, missingCodesPgStr, bf.checked, upTo, last, emptyCodeHash.Bytes(), codeBackFillBatchSize); err != nil {
+			return filled, err
+		}
+		for _, m := range missing {
+			codeHash := common.BytesToHash(m.CodeHash)
+			blockNumber, ok := new(big.Int).SetString(m.BlockNumber, 10)
+			if !ok {
+				blockNumber = big.NewInt(0)
+			}
+			code, err := bf.fetcher.FetchCode(common.HexToHash(m.LeafKey), codeHash, blockNumber, nil)
+			if err != nil {
+				log.Warnf("eth code backfill unable to fetch code for hash %s: %v", codeHash.Hex(), err)
+				continue
+			}
+			if err := bf.publish(codeHash, code); err != nil {
+				return filled, err
+			}
+			filled++
+		}
+		if len(missing) < codeBackFillBatchSize {
+			bf.checked = upTo
+			return filled, nil
+		}
+		last = missing[len(missing)-1].CodeHash
+	}
+}
+
+func (bf *CodeBackFiller) publish(codeHash common.Hash, code []byte) (err error) {
+	tx, err := bf.indexer.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	err = publishAndIndexContractCode(tx, bf.indexer, codeHash, code)
+	return err
+}
@@ -0,0 +1,114 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// codeFetchTimeout is the amount of time allowed for each call made to the node while fetching a contract's code
+const codeFetchTimeout = 10 * time.Second
+
+// CodeFetcher fetches contract code from an ethereum node
+// The statediff payloads only carry an account's code hash, so the code is retrieved from the node with eth_getCode;
+// the account's address is resolved from the addresses seen in the block, falling back to the node's debug_preimage
+// (which requires the node to run with --cache.preimages)
+type CodeFetcher struct {
+	client CallClient
+}
+
+// NewCodeFetcher returns a CodeFetcher
+func NewCodeFetcher(c CallClient) *CodeFetcher {
+	return &CodeFetcher{
+		client: c,
+	}
+}
+
+// FetchCode fetches the code with the provided hash belonging to the account at the provided state leaf key
+// The candidates map the state leaf keys of addresses known to the caller to those addresses
+func (fetcher *CodeFetcher) FetchCode(leafKey, codeHash common.Hash, blockNumber *big.Int, candidates map[common.Hash]common.Address) ([]byte, error) {
+	address, ok := candidates[leafKey]
+	if !ok {
+		var err error
+		address, err = fetcher.resolveAddress(leafKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the code is requested at the block it was seen at, and at the head in case the node no longer holds that state
+	var err error
+	for _, blockArg := range []string{hexutil.EncodeBig(blockNumber), "latest"} {
+		var code hexutil.Bytes
+		if err = fetcher.call(&code, "eth_getCode", address, blockArg); err != nil {
+			continue
+		}
+		if crypto.Keccak256Hash(code) != codeHash {
+			err = fmt.Errorf("code returned for address %s at block %s does not match code hash %s", address.Hex(), blockArg, codeHash.Hex())
+			continue
+		}
+		return code, nil
+	}
+	return nil, err
+}
+
+// resolveAddress looks up the address for the provided state leaf key using the node's preimage store
+func (fetcher *CodeFetcher) resolveAddress(leafKey common.Hash) (common.Address, error) {
+	var preimage hexutil.Bytes
+	if err := fetcher.call(&preimage, "debug_preimage", leafKey); err != nil {
+		return common.Address{}, fmt.Errorf("unable to resolve address for state leaf key %s: %v", leafKey.Hex(), err)
+	}
+	if len(preimage) != common.AddressLength || crypto.Keccak256Hash(preimage) != leafKey {
+		return common.Address{}, fmt.Errorf("preimage returned for state leaf key %s is not an address", leafKey.Hex())
+	}
+	return common.BytesToAddress(preimage), nil
+}
+
+func (fetcher *CodeFetcher) call(result interface{}, method string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), codeFetchTimeout)
+	defer cancel()
+	return fetcher.client.CallContext(ctx, result, method, args...)
+}
+
+// CandidateAddresses maps the state leaf keys of the addresses seen in the payload's transactions and receipts to those addresses
+func CandidateAddresses(payload ConvertedPayload) map[common.Hash]common.Address {
+	candidates := make(map[common.Hash]common.Address)
+	add := func(addr string) {
+		if !common.IsHexAddress(addr) {
+			return
+		}
+		address := common.HexToAddress(addr)
+		candidates[crypto.Keccak256Hash(address.Bytes())] = address
+	}
+	for _, trx := range payload.TxMetaData {
+		add(trx.Src)
+		add(trx.Dst)
+	}
+	for _, rct := range payload.ReceiptMetaData {
+		add(rct.Contract)
+		for _, logContract := range rct.LogContracts {
+			add(logContract)
+		}
+	}
+	return candidates
+}
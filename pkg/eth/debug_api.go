@@ -0,0 +1,344 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DebugAPIName is the namespace for the watcher's debug api
+const DebugAPIName = "debug"
+
+const (
+	// defaultTraceTimeout is the amount of time a single transaction can execute by default when using a JS tracer
+	defaultTraceTimeout = 5 * time.Second
+	// DefaultMaxTraceTimeout is the default upper bound on the amount of time a single transaction trace can run for
+	DefaultMaxTraceTimeout = 30 * time.Second
+)
+
+// TraceConfig holds extra parameters to trace functions
+type TraceConfig struct {
+	*vm.LogConfig
+	Tracer  *string
+	Timeout *string
+}
+
+// TxTraceResult is the result of a single transaction trace
+type TxTraceResult struct {
+	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
+	Error  string      `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
+// ExecutionResult groups all structured logs emitted by the EVM
+// while replaying a transaction in debug mode as well as transaction
+// execution status, the amount of gas used and the return value
+type ExecutionResult struct {
+	Gas         uint64         `json:"gas"`
+	Failed      bool           `json:"failed"`
+	ReturnValue string         `json:"returnValue"`
+	StructLogs  []StructLogRes `json:"structLogs"`
+}
+
+// StructLogRes stores a structured log emitted by the EVM while replaying a
+// transaction in debug mode
+type StructLogRes struct {
+	Pc      uint64             `json:"pc"`
+	Op      string             `json:"op"`
+	Gas     uint64             `json:"gas"`
+	GasCost uint64             `json:"gasCost"`
+	Depth   int                `json:"depth"`
+	Error   error              `json:"error,omitempty"`
+	Stack   *[]string          `json:"stack,omitempty"`
+	Memory  *[]string          `json:"memory,omitempty"`
+	Storage *map[string]string `json:"storage,omitempty"`
+}
+
+// PublicDebugAPI is the watcher's debug api
+// It traces historical transactions by re-executing them on top of the parent block's state, which is resolved from
+// the trie nodes in public.blocks
+type PublicDebugAPI struct {
+	B *Backend
+	// upper bound on the timeout of a single transaction trace, whatever the requested timeout; 0 means no limit
+	maxTraceTimeout time.Duration
+}
+
+// NewPublicDebugAPI creates a new PublicDebugAPI with the provided underlying Backend
+// Transaction traces are aborted once they have run for maxTraceTimeout, unless it is 0
+func NewPublicDebugAPI(b *Backend, maxTraceTimeout time.Duration) *PublicDebugAPI {
+	return &PublicDebugAPI{
+		B:               b,
+		maxTraceTimeout: maxTraceTimeout,
+	}
+}
+
+// TraceTransaction returns the structured logs created during the execution of EVM
+// and returns them as a JSON object.
+func (pda *PublicDebugAPI) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (interface{}, error) {
+	tx, blockHash, _, index, err := pda.B.GetTransaction(ctx, hash)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("transaction %#x not found", hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %#x not found", hash)
+	}
+	block, err := pda.B.BlockByHash(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := pda.parentState(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	// Recompute the transactions up to the target index
+	signer := types.MakeSigner(pda.B.ChainConfig, block.Number())
+	for idx, blockTx := range block.Transactions() {
+		msg, err := blockTx.AsMessage(signer)
+		if err != nil {
+			return nil, err
+		}
+		vmctx := core.NewEVMContext(msg, block.Header(), pda.B, &block.Header().Coinbase)
+		if uint64(idx) == index {
+			return pda.traceTx(ctx, msg, vmctx, statedb, config)
+		}
+		if err := pda.applyMessage(msg, vmctx, statedb, block.Number()); err != nil {
+			return nil, fmt.Errorf("transaction %#x failed: %v", blockTx.Hash(), err)
+		}
+	}
+	return nil, fmt.Errorf("transaction index %d out of range for block %#x", index, blockHash)
+}
+
+// TraceBlockByNumber returns the structured logs created during the execution of
+// EVM and returns them as a JSON object.
+func (pda *PublicDebugAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*TxTraceResult, error) {
+	block, err := pda.B.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return pda.traceBlock(ctx, block, config)
+}
+
+// TraceBlockByHash returns the structured logs created during the execution of
+// EVM and returns them as a JSON object.
+func (pda *PublicDebugAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*TxTraceResult, error) {
+	block, err := pda.B.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return pda.traceBlock(ctx, block, config)
+}
+
+// TraceBlock returns the structured logs created during the execution of EVM
+// and returns them as a JSON object; the block is provided as RLP but its parent must be indexed.
+func (pda *PublicDebugAPI) TraceBlock(ctx context.Context, blob []byte, config *TraceConfig) ([]*TxTraceResult, error) {
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(blob, block); err != nil {
+		return nil, fmt.Errorf("could not decode block: %v", err)
+	}
+	return pda.traceBlock(ctx, block, config)
+}
+
+// traceBlock configures a new tracer according to the provided configuration, and
+// executes all the transactions contained within. The return value will be one item
+// per transaction, dependent on the requested tracer.
+func (pda *PublicDebugAPI) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) ([]*TxTraceResult, error) {
+	statedb, err := pda.parentState(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	signer := types.MakeSigner(pda.B.ChainConfig, block.Number())
+	txs := block.Transactions()
+	results := make([]*TxTraceResult, len(txs))
+	for i, tx := range txs {
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			return nil, err
+		}
+		vmctx := core.NewEVMContext(msg, block.Header(), pda.B, &block.Header().Coinbase)
+		// Trace on a copy of the state, so that an aborted trace cannot affect the following transactions
+		res, err := pda.traceTx(ctx, msg, vmctx, statedb.Copy(), config)
+		if err != nil {
+			results[i] = &TxTraceResult{Error: err.Error()}
+		} else {
+			results[i] = &TxTraceResult{Result: res}
+		}
+		// Generate the next state snapshot without tracing
+		if err := pda.applyMessage(msg, vmctx, statedb, block.Number()); err != nil {
+			return nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+		}
+	}
+	return results, nil
+}
+
+// parentState returns the state at the provided block's parent
+func (pda *PublicDebugAPI) parentState(ctx context.Context, block *types.Block) (*state.StateDB, error) {
+	statedb, _, err := pda.B.StateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithHash(block.ParentHash(), false))
+	if err != nil {
+		return nil, fmt.Errorf("state of parent %#x is not available: %v", block.ParentHash(), err)
+	}
+	return statedb, nil
+}
+
+// applyMessage executes the message on top of the provided state without tracing, and finalises the state
+func (pda *PublicDebugAPI) applyMessage(msg core.Message, vmctx vm.Context, statedb *state.StateDB, number *big.Int) error {
+	vmenv := vm.NewEVM(vmctx, statedb, pda.B.ChainConfig, vm.Config{})
+	if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+		return err
+	}
+	// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
+	statedb.Finalise(vmenv.ChainConfig().IsEIP158(number))
+	return nil
+}
+
+// traceTx configures a new tracer according to the provided configuration, and
+// executes the given message in the provided environment. The return value will
+// be tracer dependent.
+func (pda *PublicDebugAPI) traceTx(ctx context.Context, message core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+	// Assemble the structured logger or the JavaScript tracer
+	var (
+		tracer  vm.Tracer
+		timeout = pda.maxTraceTimeout
+		err     error
+	)
+	switch {
+	case config != nil && config.Tracer != nil:
+		// Define a meaningful timeout of a single transaction trace
+		timeout = defaultTraceTimeout
+		// Construct the JavaScript tracer to execute with; this includes the built in tracers such as the callTracer
+		if tracer, err = tracers.New(*config.Tracer); err != nil {
+			return nil, err
+		}
+
+	case config == nil:
+		tracer = vm.NewStructLogger(nil)
+
+	default:
+		tracer = vm.NewStructLogger(config.LogConfig)
+	}
+	if config != nil && config.Timeout != nil {
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	// The requested timeout cannot exceed the server's maximum
+	if pda.maxTraceTimeout > 0 && (timeout <= 0 || timeout > pda.maxTraceTimeout) {
+		timeout = pda.maxTraceTimeout
+	}
+	// Run the transaction with tracing enabled.
+	vmenv := vm.NewEVM(vmctx, statedb, pda.B.ChainConfig, vm.Config{Debug: true, Tracer: tracer})
+
+	// Handle timeouts and RPC cancellations
+	var (
+		deadlineCtx context.Context
+		cancel      context.CancelFunc
+	)
+	if timeout > 0 {
+		deadlineCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		deadlineCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	go func() {
+		<-deadlineCtx.Done()
+		vmenv.Cancel()
+		if jsTracer, ok := tracer.(*tracers.Tracer); ok {
+			jsTracer.Stop(errors.New("execution timeout"))
+		}
+	}()
+
+	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	// A trace which was cut short is incomplete, so it is not returned
+	if err := deadlineCtx.Err(); err == context.Canceled {
+		return nil, err
+	}
+	if deadline, ok := deadlineCtx.Deadline(); ok && !time.Now().Before(deadline) {
+		return nil, fmt.Errorf("execution timeout after %v", timeout)
+	}
+	// Depending on the tracer type, format and return the output
+	switch tracer := tracer.(type) {
+	case *vm.StructLogger:
+		return &ExecutionResult{
+			Gas:         gas,
+			Failed:      failed,
+			ReturnValue: fmt.Sprintf("%x", ret),
+			StructLogs:  FormatLogs(tracer.StructLogs()),
+		}, nil
+
+	case *tracers.Tracer:
+		return tracer.GetResult()
+
+	default:
+		return nil, fmt.Errorf("bad tracer type %T", tracer)
+	}
+}
+
+// FormatLogs formats EVM returned structured logs for json output
+func FormatLogs(logs []vm.StructLog) []StructLogRes {
+	formatted := make([]StructLogRes, len(logs))
+	for index, trace := range logs {
+		formatted[index] = StructLogRes{
+			Pc:      trace.Pc,
+			Op:      trace.Op.String(),
+			Gas:     trace.Gas,
+			GasCost: trace.GasCost,
+			Depth:   trace.Depth,
+			Error:   trace.Err,
+		}
+		if trace.Stack != nil {
+			stack := make([]string, len(trace.Stack))
+			for i, stackValue := range trace.Stack {
+				stack[i] = fmt.Sprintf("%x", math.PaddedBigBytes(stackValue, 32))
+			}
+			formatted[index].Stack = &stack
+		}
+		if trace.Memory != nil {
+			memory := make([]string, 0, (len(trace.Memory)+31)/32)
+			for i := 0; i+32 <= len(trace.Memory); i += 32 {
+				memory = append(memory, fmt.Sprintf("%x", trace.Memory[i:i+32]))
+			}
+			formatted[index].Memory = &memory
+		}
+		if trace.Storage != nil {
+			storage := make(map[string]string)
+			for i, storageValue := range trace.Storage {
+				storage[fmt.Sprintf("%x", i)] = fmt.Sprintf("%x", storageValue)
+			}
+			formatted[index].Storage = &storage
+		}
+	}
+	return formatted
+}
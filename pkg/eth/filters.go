@@ -0,0 +1,344 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// filterTimeout is how long a polling filter is kept after it was last polled
+const filterTimeout = 5 * time.Minute
+
+var errFilterNotFound = errors.New("filter not found")
+
+// filterType distinguishes the kinds of polling filters
+type filterType int
+
+const (
+	logsFilter filterType = iota
+	blocksFilter
+)
+
+// pollFilter is a filter installed with eth_newFilter or eth_newBlockFilter
+type pollFilter struct {
+	typ        filterType
+	crit       filters.FilterCriteria
+	lastBlock  int64     // the highest block number already reported by eth_getFilterChanges
+	lastPolled time.Time // the last time the filter was installed or polled, used to expire it
+}
+
+// filterManager tracks the polling filters installed through the eth api
+// Filters which have not been polled within the timeout are uninstalled the next time the manager is accessed
+type filterManager struct {
+	mu      sync.Mutex
+	filters map[rpc.ID]*pollFilter
+	timeout time.Duration
+}
+
+// newFilterManager creates a new filterManager which expires filters after the provided timeout
+func newFilterManager(timeout time.Duration) *filterManager {
+	return &filterManager{
+		filters: make(map[rpc.ID]*pollFilter),
+		timeout: timeout,
+	}
+}
+
+// install adds the filter and returns its id
+func (fm *filterManager) install(f *pollFilter) rpc.ID {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.expire()
+	id := rpc.NewID()
+	f.lastPolled = time.Now()
+	fm.filters[id] = f
+	return id
+}
+
+// uninstall removes the filter with the provided id, returning whether or not it was installed
+func (fm *filterManager) uninstall(id rpc.ID) bool {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.expire()
+	_, found := fm.filters[id]
+	delete(fm.filters, id)
+	return found
+}
+
+// poll returns a copy of the filter with the provided id and resets its expiry
+func (fm *filterManager) poll(id rpc.ID) (pollFilter, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.expire()
+	f, found := fm.filters[id]
+	if !found {
+		return pollFilter{}, errFilterNotFound
+	}
+	f.lastPolled = time.Now()
+	return *f, nil
+}
+
+// advance records the highest block number reported for the filter with the provided id
+func (fm *filterManager) advance(id rpc.ID, lastBlock int64) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if f, found := fm.filters[id]; found && lastBlock > f.lastBlock {
+		f.lastBlock = lastBlock
+	}
+}
+
+// expire removes the filters which have timed out; the caller must hold the lock
+func (fm *filterManager) expire() {
+	for id, f := range fm.filters {
+		if time.Since(f.lastPolled) > fm.timeout {
+			delete(fm.filters, id)
+		}
+	}
+}
+
+// NewFilter creates a new log filter and returns its id
+// Logs in blocks indexed after the filter is created can be polled for with eth_getFilterChanges
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_newfilter
+func (pea *PublicEthAPI) NewFilter(crit filters.FilterCriteria) (rpc.ID, error) {
+	if crit.BlockHash != nil {
+		return "", errors.New("cannot specify a block hash for a polling filter")
+	}
+	if len(crit.Topics) > 4 {
+		return "", errors.New("cannot specify more than four topics")
+	}
+	head, err := pea.B.Retriever.RetrieveLastBlockNumber()
+	if err != nil {
+		return "", err
+	}
+	return pea.filters.install(&pollFilter{
+		typ:       logsFilter,
+		crit:      crit,
+		lastBlock: head,
+	}), nil
+}
+
+// NewBlockFilter creates a filter which returns the hashes of the blocks indexed after it is created
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_newblockfilter
+func (pea *PublicEthAPI) NewBlockFilter() (rpc.ID, error) {
+	head, err := pea.B.Retriever.RetrieveLastBlockNumber()
+	if err != nil {
+		return "", err
+	}
+	return pea.filters.install(&pollFilter{
+		typ:       blocksFilter,
+		lastBlock: head,
+	}), nil
+}
+
+// UninstallFilter removes the filter with the given id
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_uninstallfilter
+func (pea *PublicEthAPI) UninstallFilter(id rpc.ID) bool {
+	return pea.filters.uninstall(id)
+}
+
+// GetFilterChanges returns the block hashes or logs, depending on the type of filter, for the blocks indexed since
+// the filter was last polled
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getfilterchanges
+func (pea *PublicEthAPI) GetFilterChanges(ctx context.Context, id rpc.ID) (interface{}, error) {
+	f, err := pea.filters.poll(id)
+	if err != nil {
+		return nil, err
+	}
+	head, err := pea.B.Retriever.RetrieveLastBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+	switch f.typ {
+	case blocksFilter:
+		hashes, err := pea.blockHashesInRange(f.lastBlock+1, head)
+		if err != nil {
+			return nil, err
+		}
+		pea.filters.advance(id, head)
+		return hashes, nil
+	case logsFilter:
+		start, end := f.lastBlock+1, head
+		if f.crit.FromBlock != nil && f.crit.FromBlock.Sign() >= 0 && f.crit.FromBlock.Int64() > start {
+			start = f.crit.FromBlock.Int64()
+		}
+		if f.crit.ToBlock != nil && f.crit.ToBlock.Sign() >= 0 && f.crit.ToBlock.Int64() < end {
+			end = f.crit.ToBlock.Int64()
+		}
+		// a filter which has fallen further behind than the maximum range catches up over successive polls
+		next := head
+		if pea.B.MaxLogRange > 0 && end-start+1 > pea.B.MaxLogRange {
+			end = start + pea.B.MaxLogRange - 1
+			next = end
+		}
+		logs, err := pea.logsInRange(ctx, f.crit, start, end)
+		if err != nil {
+			return nil, err
+		}
+		pea.filters.advance(id, next)
+		return logs, nil
+	default:
+		return nil, errFilterNotFound
+	}
+}
+
+// GetFilterLogs returns all of the logs that match the criteria of the log filter with the given id
+// Missing fromBlock and toBlock values default to the latest indexed block
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getfilterlogs
+func (pea *PublicEthAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error) {
+	f, err := pea.filters.poll(id)
+	if err != nil {
+		return nil, err
+	}
+	if f.typ != logsFilter {
+		return nil, errFilterNotFound
+	}
+	start, err := pea.resolveFilterBlock(f.crit.FromBlock, pea.B.Retriever.RetrieveLastBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	end, err := pea.resolveFilterBlock(f.crit.ToBlock, pea.B.Retriever.RetrieveLastBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return pea.logsInRange(ctx, f.crit, start, end)
+}
+
+// resolveFilterBlock converts a filter block number into a block height
+// nil uses the provided default and the latest and pending tags resolve to the latest indexed block
+func (pea *PublicEthAPI) resolveFilterBlock(number *big.Int, defaultNumber func() (int64, error)) (int64, error) {
+	if number == nil {
+		return defaultNumber()
+	}
+	if number.Sign() < 0 {
+		return pea.B.Retriever.RetrieveLastBlockNumber()
+	}
+	return number.Int64(), nil
+}
+
+// blockHashesInRange returns the hashes of the canonical headers indexed at the heights within the provided range
+func (pea *PublicEthAPI) blockHashesInRange(start, end int64) (hashes []common.Hash, err error) {
+	hashes = make([]common.Hash, 0)
+	if start > end {
+		return hashes, nil
+	}
+	tx, err := pea.B.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	headers, err := pea.B.Retriever.RetrieveHeaderBloomsInRange(tx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range headers {
+		hashes = append(hashes, common.HexToHash(header.BlockHash))
+	}
+	return hashes, err
+}
+
+// logsInRange returns the logs, with their derived fields, that match the criteria within the provided block range
+// The blocks with matching receipts are found through the same bloom and receipt index path as eth_getLogs, and are
+// subject to the same range and result limits; only the receipts of those blocks are fetched
+func (pea *PublicEthAPI) logsInRange(ctx context.Context, crit filters.FilterCriteria, start, end int64) ([]*types.Log, error) {
+	logs := make([]*types.Log, 0)
+	if start > end {
+		return logs, nil
+	}
+	if err := pea.checkLogRange(start, end); err != nil {
+		return nil, err
+	}
+	blockHashes, err := pea.blocksWithMatchingReceipts(ethereum.FilterQuery(crit), start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range blockHashes {
+		receipts, err := pea.B.GetReceipts(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, receipt := range receipts {
+			logs = append(logs, filterLogs(receipt.Logs, crit.Addresses, crit.Topics)...)
+		}
+		if err := pea.checkLogResults(len(logs)); err != nil {
+			return nil, err
+		}
+	}
+	return logs, nil
+}
+
+// blocksWithMatchingReceipts returns the hashes of the canonical blocks within the provided range that contain receipts
+// which conform to the criteria
+func (pea *PublicEthAPI) blocksWithMatchingReceipts(crit ethereum.FilterQuery, start, end int64) (hashes []common.Hash, err error) {
+	tx, err := pea.B.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	headers, err := pea.headersMatchingBloom(tx, crit, start, end)
+	if err != nil {
+		return nil, err
+	}
+	headerIDs := make([]int64, len(headers))
+	blockHashes := make(map[int64]common.Hash, len(headers))
+	for i, header := range headers {
+		headerIDs[i] = header.ID
+		blockHashes[header.ID] = common.HexToHash(header.BlockHash)
+	}
+	matchingIDs, err := pea.B.Retriever.RetrieveHeaderIDsWithMatchingRcts(tx, newReceiptFilter(crit.Addresses, crit.Topics), headerIDs)
+	if err != nil {
+		return nil, err
+	}
+	hashes = make([]common.Hash, len(matchingIDs))
+	for i, id := range matchingIDs {
+		hashes[i] = blockHashes[id]
+	}
+	return hashes, err
+}
@@ -0,0 +1,55 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// headFetchTimeout is the amount of time allowed for the upstream node to report its head
+const headFetchTimeout = 10 * time.Second
+
+// CallClient is an interface to a geth rpc client; created to allow mock insertion
+type CallClient interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// HeadFetcher satisfies the HeadFetcher interface for ethereum
+type HeadFetcher struct {
+	client CallClient
+}
+
+// NewHeadFetcher returns a HeadFetcher
+func NewHeadFetcher(c CallClient) *HeadFetcher {
+	return &HeadFetcher{
+		client: c,
+	}
+}
+
+// FetchHead fetches the height of the upstream node's head using eth_blockNumber
+func (fetcher *HeadFetcher) FetchHead() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), headFetchTimeout)
+	defer cancel()
+	var head hexutil.Uint64
+	if err := fetcher.client.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return int64(head), nil
+}
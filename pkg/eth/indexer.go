@@ -17,7 +17,9 @@
 package eth
 
 import (
+	"database/sql"
 	"fmt"
+	"strconv"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/jmoiron/sqlx"
@@ -27,6 +29,9 @@ import (
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 )
 
+// maxReorgDepth is the number of ancestors of a newly indexed header whose canonical status is repaired
+const maxReorgDepth = 100
+
 var (
 	nullHash = common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000")
 )
@@ -90,15 +95,94 @@ func (in *CIDIndexer) Index(cids shared.CIDsForIndexing) error {
 
 func (in *CIDIndexer) indexHeaderCID(tx *sqlx.Tx, header HeaderModel) (int64, error) {
 	var headerID int64
-	err := tx.QueryRowx(`INSERT INTO eth.header_cids (block_number, block_hash, parent_hash, cid, td, node_id, reward, state_root, tx_root, receipt_root, uncle_root, bloom, timestamp, mh_key, times_validated)
-								VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	err := tx.QueryRowx(`INSERT INTO eth.header_cids (block_number, block_hash, parent_hash, cid, td, node_id, reward, state_root, tx_root, receipt_root, uncle_root, bloom, timestamp, mh_key, times_validated, canonical)
+								VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 								ON CONFLICT (block_number, block_hash) DO UPDATE SET (parent_hash, cid, td, node_id, reward, state_root, tx_root, receipt_root, uncle_root, bloom, timestamp, mh_key, times_validated) = ($3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, eth.header_cids.times_validated + 1)
 								RETURNING id`,
 		header.BlockNumber, header.BlockHash, header.ParentHash, header.CID, header.TotalDifficulty, in.db.NodeID, header.Reward, header.StateRoot, header.TxRoot,
-		header.RctRoot, header.UncleRoot, header.Bloom, header.Timestamp, header.MhKey, 1).Scan(&headerID)
-	return headerID, err
+		header.RctRoot, header.UncleRoot, header.Bloom, header.Timestamp, header.MhKey, 1, false).Scan(&headerID)
+	if err != nil {
+		return 0, err
+	}
+	return headerID, in.indexCanonical(tx, header)
 }
 
+// indexCanonical makes the newly indexed header the canonical header at its height and checks its parent-hash links
+// A header only becomes canonical when it is above the canonical head, when the canonical child links to it, or when it
+// fills a gap, so that stale headers indexed late do not replace the canonical chain
+// Ancestors orphaned by a reorg are replaced as canonical by the header's ancestors as far as those have been indexed;
+// a height at which the header's ancestor has not been indexed is left without a canonical header so that it is
+// reported as a gap and the replacement is fetched
+func (in *CIDIndexer) indexCanonical(tx *sqlx.Tx, header HeaderModel) error {
+	number, err := strconv.ParseInt(header.BlockNumber, 10, 64)
+	if err != nil {
+		return err
+	}
+	canonical := struct {
+		Head            sql.NullInt64  `db:"head"`
+		Hash            sql.NullString `db:"hash"`
+		ChildParentHash sql.NullString `db:"child_parent_hash"`
+	}{}
+	if err := tx.Get(&canonical, canonicalNeighboursPgStr, number); err != nil {
+		return err
+	}
+	switch {
+	case !canonical.Head.Valid || number > canonical.Head.Int64:
+		// the header extends the canonical chain, or reorganizes it when its parent is not the canonical head
+	case canonical.ChildParentHash.Valid:
+		if canonical.ChildParentHash.String != header.BlockHash {
+			log.Debugf("eth header %s at height %d is not linked to by the canonical child, it is not made canonical", header.BlockHash, number)
+			return nil
+		}
+	case canonical.Hash.Valid && canonical.Hash.String != header.BlockHash:
+		log.Debugf("eth header %s at height %d competes with the canonical header below the canonical head, it is not made canonical", header.BlockHash, number)
+		return nil
+	}
+	hash, parentHash := header.BlockHash, header.ParentHash
+	for depth := 0; depth < maxReorgDepth; depth++ {
+		if _, err := tx.Exec(`UPDATE eth.header_cids SET canonical = (block_hash = $2)
+							WHERE block_number = $1`, number, hash); err != nil {
+			return err
+		}
+		if number == 0 {
+			return nil
+		}
+		parent := struct {
+			ParentHash string `db:"parent_hash"`
+			Canonical  bool   `db:"canonical"`
+		}{}
+		err := tx.Get(&parent, `SELECT parent_hash, canonical FROM eth.header_cids
+							WHERE block_number = $1 AND block_hash = $2`, number-1, parentHash)
+		if err == sql.ErrNoRows {
+			// The parent has not been indexed, any header at its height belongs to another branch
+			res, err := tx.Exec(`UPDATE eth.header_cids SET canonical = false
+							WHERE block_number = $1 AND canonical`, number-1)
+			if err != nil {
+				return err
+			}
+			if orphaned, _ := res.RowsAffected(); orphaned > 0 {
+				log.Infof("eth reorg detected at height %d, orphaned the header at height %d", number, number-1)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if parent.Canonical {
+			return nil
+		}
+		number, hash, parentHash = number-1, parentHash, parent.ParentHash
+	}
+	log.Warnf("eth reorg is deeper than the %d blocks checked when indexing the header at height %s", maxReorgDepth, header.BlockNumber)
+	return nil
+}
+
+// canonicalNeighboursPgStr selects the canonical head, the hash of the canonical header at a height and the parent hash
+// of the canonical header above it
+const canonicalNeighboursPgStr = `SELECT (SELECT MAX(block_number) FROM eth.header_cids WHERE canonical) AS head,
+							(SELECT block_hash FROM eth.header_cids WHERE block_number = $1 AND canonical) AS hash,
+							(SELECT parent_hash FROM eth.header_cids WHERE block_number = $1 + 1 AND canonical) AS child_parent_hash`
+
 func (in *CIDIndexer) indexUncleCID(tx *sqlx.Tx, uncle UncleModel, headerID int64) error {
 	_, err := tx.Exec(`INSERT INTO eth.uncle_cids (block_hash, header_id, parent_hash, cid, reward, mh_key) VALUES ($1, $2, $3, $4, $5, $6)
 								ON CONFLICT (header_id, block_hash) DO UPDATE SET (parent_hash, cid, reward, mh_key) = ($3, $4, $5, $6)`,
@@ -194,6 +278,13 @@ func (in *CIDIndexer) indexStateAccount(tx *sqlx.Tx, stateAccount StateAccountMo
 	return err
 }
 
+func (in *CIDIndexer) indexContractCode(tx *sqlx.Tx, code ContractCodeModel) error {
+	_, err := tx.Exec(`INSERT INTO eth.contract_codes (code_hash, cid, mh_key) VALUES ($1, $2, $3)
+							  ON CONFLICT (code_hash) DO NOTHING`,
+		code.CodeHash, code.CID, code.MhKey)
+	return err
+}
+
 func (in *CIDIndexer) indexStorageCID(tx *sqlx.Tx, storageCID StorageNodeModel, stateID int64) error {
 	var storageKey string
 	if storageCID.StorageKey != nullHash.String() {
@@ -0,0 +1,129 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var (
+	errReadOnly = errors.New("ipld database is read-only")
+	errNotFound = errors.New("not found")
+)
+
+// IPLDDatabase satisfies the ethdb.KeyValueStore interface for a read-only view of the public.blocks table
+// Keys are keccak256 hashes (trie node hashes and contract code hashes) which are resolved to the keccak256 multihash
+// db key that the corresponding IPLD is stored under
+// This allows it to be used as the disk database underneath a go-ethereum trie.Database and state.Database
+type IPLDDatabase struct {
+	db *postgres.DB
+}
+
+// NewIPLDDatabase returns a pointer to a new IPLDDatabase which satisfies the ethdb.KeyValueStore interface
+func NewIPLDDatabase(db *postgres.DB) *IPLDDatabase {
+	return &IPLDDatabase{
+		db: db,
+	}
+}
+
+// Has satisfies the ethdb.KeyValueReader interface
+// It returns whether or not an IPLD exists for the provided keccak256 hash
+func (d *IPLDDatabase) Has(key []byte) (bool, error) {
+	mhKey, err := shared.MultihashKeyFromKeccak256(common.BytesToHash(key))
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	return exists, d.db.Get(&exists, `SELECT EXISTS(SELECT 1 FROM public.blocks WHERE key = $1)`, mhKey)
+}
+
+// Get satisfies the ethdb.KeyValueReader interface
+// It returns the IPLD for the provided keccak256 hash
+func (d *IPLDDatabase) Get(key []byte) ([]byte, error) {
+	mhKey, err := shared.MultihashKeyFromKeccak256(common.BytesToHash(key))
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if err := d.db.Get(&data, `SELECT data FROM public.blocks WHERE key = $1`, mhKey); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errNotFound
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// Put satisfies the ethdb.KeyValueWriter interface
+// The IPLDDatabase is read-only
+func (d *IPLDDatabase) Put(key []byte, value []byte) error {
+	return errReadOnly
+}
+
+// Delete satisfies the ethdb.KeyValueWriter interface
+// The IPLDDatabase is read-only
+func (d *IPLDDatabase) Delete(key []byte) error {
+	return errReadOnly
+}
+
+// NewBatch satisfies the ethdb.Batcher interface
+// Since the IPLDDatabase is read-only, anything written to the batch is discarded
+func (d *IPLDDatabase) NewBatch() ethdb.Batch {
+	return memorydb.New().NewBatch()
+}
+
+// NewIterator satisfies the ethdb.Iteratee interface
+// Iteration over public.blocks is not supported; an empty iterator is returned
+func (d *IPLDDatabase) NewIterator() ethdb.Iterator {
+	return memorydb.New().NewIterator()
+}
+
+// NewIteratorWithStart satisfies the ethdb.Iteratee interface
+// Iteration over public.blocks is not supported; an empty iterator is returned
+func (d *IPLDDatabase) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return memorydb.New().NewIteratorWithStart(start)
+}
+
+// NewIteratorWithPrefix satisfies the ethdb.Iteratee interface
+// Iteration over public.blocks is not supported; an empty iterator is returned
+func (d *IPLDDatabase) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return memorydb.New().NewIteratorWithPrefix(prefix)
+}
+
+// Stat satisfies the ethdb.Stater interface
+func (d *IPLDDatabase) Stat(property string) (string, error) {
+	return "", errors.New("unknown property")
+}
+
+// Compact satisfies the ethdb.Compacter interface
+func (d *IPLDDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+// Close satisfies the io.Closer interface
+// The underlying Postgres connection is shared and is not closed here
+func (d *IPLDDatabase) Close() error {
+	return nil
+}
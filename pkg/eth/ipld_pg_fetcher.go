@@ -147,16 +147,24 @@ func (f *IPLDPGFetcher) FetchTrxs(tx *sqlx.Tx, cids []TxModel) ([]ipfs.BlockMode
 }
 
 // FetchRcts fetches receipts
+// The receipt iplds are fetched with a single query
 func (f *IPLDPGFetcher) FetchRcts(tx *sqlx.Tx, cids []ReceiptModel) ([]ipfs.BlockModel, error) {
 	log.Debug("fetching receipt iplds")
 	rctIPLDs := make([]ipfs.BlockModel, len(cids))
+	if len(cids) == 0 {
+		return rctIPLDs, nil
+	}
+	mhKeys := make([]string, len(cids))
+	for i, c := range cids {
+		mhKeys[i] = c.MhKey
+	}
+	rctBytes, err := shared.FetchIPLDsByMhKeys(tx, mhKeys)
+	if err != nil {
+		return nil, err
+	}
 	for i, c := range cids {
-		rctBytes, err := shared.FetchIPLDByMhKey(tx, c.MhKey)
-		if err != nil {
-			return nil, err
-		}
 		rctIPLDs[i] = ipfs.BlockModel{
-			Data: rctBytes,
+			Data: rctBytes[c.MhKey],
 			CID:  c.CID,
 		}
 	}
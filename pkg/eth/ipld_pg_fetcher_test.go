@@ -17,6 +17,7 @@
 package eth_test
 
 import (
+	"github.com/ethereum/go-ethereum/params"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -38,7 +39,7 @@ var _ = Describe("IPLDPGFetcher", func() {
 			var err error
 			db, err = shared.SetupDB()
 			Expect(err).ToNot(HaveOccurred())
-			pubAndIndexer = eth.NewIPLDPublisherAndIndexer(db)
+			pubAndIndexer = eth.NewIPLDPublisherAndIndexer(db, params.MainnetChainConfig, nil)
 			_, err = pubAndIndexer.Publish(mocks.MockConvertedPayload)
 			Expect(err).ToNot(HaveOccurred())
 			fetcher = eth.NewIPLDPGFetcher(db)
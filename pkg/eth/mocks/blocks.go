@@ -0,0 +1,49 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+)
+
+// NewMockChildBlock returns a block with the mock transactions and receipts built on top of the provided parent
+func NewMockChildBlock(parent *types.Block) *types.Block {
+	header := MockHeader
+	header.Number = new(big.Int).Add(parent.Number(), common.Big1)
+	header.ParentHash = parent.Hash()
+	return types.NewBlock(&header, MockTransactions, nil, MockReceipts)
+}
+
+// NewMockForkBlock returns a block built on top of the provided parent which competes with the one returned by
+// NewMockChildBlock; blocks built on the same parent with different extra bytes also compete with each other
+func NewMockForkBlock(parent *types.Block, extra byte) *types.Block {
+	header := *NewMockChildBlock(parent).Header()
+	header.Extra = []byte{extra}
+	return types.NewBlock(&header, MockTransactions, nil, MockReceipts)
+}
+
+// NewMockPayload returns a copy of the mock converted payload which carries the provided block
+func NewMockPayload(block *types.Block) eth.ConvertedPayload {
+	payload := MockConvertedPayload
+	payload.Block = block
+	return payload
+}
@@ -0,0 +1,129 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+)
+
+// Test variables for executing calls against state resolved from public.blocks
+var (
+	// CallContractCode returns the word held in storage slot 0
+	// PUSH1 0x00 SLOAD PUSH1 0x00 MSTORE PUSH1 0x20 PUSH1 0x00 RETURN
+	CallContractCode                  = common.Hex2Bytes("60005460005260206000f3")
+	CallContractAddress               = common.HexToAddress("0x5d2a6b3c5A1E41D6c3b3bA7A48E2cFE6A0d5D8a1")
+	CallStorageValue                  = common.HexToHash("0x2a")
+	CallStateRoot, CallStateTrieNodes = createCallState()
+	CallBlockNumber                   = big.NewInt(2)
+	CallHeader                        = types.Header{
+		Time:       1,
+		Number:     new(big.Int).Set(CallBlockNumber),
+		ParentHash: MockBlock.Hash(),
+		Root:       CallStateRoot,
+		Difficulty: big.NewInt(5000000),
+		GasLimit:   8000000,
+		Extra:      []byte{},
+	}
+	CallBlock                = types.NewBlock(&CallHeader, nil, nil, nil)
+	MockCallConvertedPayload = eth.ConvertedPayload{
+		TotalDifficulty: CallBlock.Difficulty(),
+		Block:           CallBlock,
+		Receipts:        types.Receipts{},
+		TxMetaData:      []eth.TxModel{},
+		ReceiptMetaData: []eth.ReceiptModel{},
+		StorageNodes:    map[string][]eth.TrieNode{},
+		StateNodes:      []eth.TrieNode{},
+	}
+
+	// CallTraceKey signs a zero gas price transaction invoking the call contract, in a child of the call block
+	CallTraceKey, _      = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	CallTraceSender      = crypto.PubkeyToAddress(CallTraceKey.PublicKey)
+	CallTraceTransaction = createCallTraceTransaction()
+	CallTraceBlock       = types.NewBlock(&types.Header{
+		Time:       2,
+		Number:     big.NewInt(3),
+		ParentHash: CallBlock.Hash(),
+		Root:       CallStateRoot,
+		Difficulty: big.NewInt(5000000),
+		GasLimit:   8000000,
+		Extra:      []byte{},
+	}, types.Transactions{CallTraceTransaction}, nil, nil)
+	MockCallTraceConvertedPayload = eth.ConvertedPayload{
+		TotalDifficulty: CallTraceBlock.Difficulty(),
+		Block:           CallTraceBlock,
+		Receipts:        types.Receipts{types.NewReceipt(nil, false, 0)},
+		TxMetaData: []eth.TxModel{
+			{
+				Src:    CallTraceSender.Hex(),
+				Dst:    CallContractAddress.String(),
+				Index:  0,
+				TxHash: CallTraceTransaction.Hash().String(),
+			},
+		},
+		ReceiptMetaData: []eth.ReceiptModel{{}},
+		StorageNodes:    map[string][]eth.TrieNode{},
+		StateNodes:      []eth.TrieNode{},
+	}
+)
+
+// createCallState is a helper function to build a state trie holding the call contract
+// It returns the state root and all of the trie nodes and code needed to resolve the contract's state
+func createCallState() (common.Hash, [][]byte) {
+	db := rawdb.NewMemoryDatabase()
+	stateDB, err := state.New(common.Hash{}, state.NewDatabase(db))
+	if err != nil {
+		log.Fatal(err)
+	}
+	stateDB.SetCode(CallContractAddress, CallContractCode)
+	stateDB.SetState(CallContractAddress, common.Hash{}, CallStorageValue)
+	root, err := stateDB.Commit(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := stateDB.Database().TrieDB().Commit(root, false); err != nil {
+		log.Fatal(err)
+	}
+	nodes := make([][]byte, 0)
+	it := db.NewIterator()
+	defer it.Release()
+	for it.Next() {
+		// trie nodes and code are keyed by their keccak256 hash
+		if common.BytesToHash(it.Key()) == crypto.Keccak256Hash(it.Value()) {
+			nodes = append(nodes, common.CopyBytes(it.Value()))
+		}
+	}
+	return root, nodes
+}
+
+// createCallTraceTransaction is a helper function to sign the transaction which invokes the call contract
+func createCallTraceTransaction() *types.Transaction {
+	tx := types.NewTransaction(0, CallContractAddress, big.NewInt(0), 100000, big.NewInt(0), nil)
+	signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, CallTraceKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return signedTx
+}
@@ -0,0 +1,56 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// CodeClient is a mock client which serves eth_getCode and debug_preimage calls from its maps
+type CodeClient struct {
+	Codes     map[common.Address][]byte
+	Preimages map[common.Hash][]byte
+}
+
+// CallContext mockClient method to simulate a call to geth
+func (mc *CodeClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	res, ok := result.(*hexutil.Bytes)
+	if !ok {
+		return fmt.Errorf("unexpected result type %T", result)
+	}
+	switch method {
+	case "eth_getCode":
+		code, ok := mc.Codes[args[0].(common.Address)]
+		if !ok {
+			return fmt.Errorf("no code for address %s", args[0].(common.Address).Hex())
+		}
+		*res = code
+	case "debug_preimage":
+		preimage, ok := mc.Preimages[args[0].(common.Hash)]
+		if !ok {
+			return fmt.Errorf("no preimage for hash %s", args[0].(common.Hash).Hex())
+		}
+		*res = preimage
+	default:
+		return fmt.Errorf("unexpected method %s", method)
+	}
+	return nil
+}
@@ -36,6 +36,7 @@ type HeaderModel struct {
 	Bloom           []byte `db:"bloom"`
 	Timestamp       uint64 `db:"timestamp"`
 	TimesValidated  int64  `db:"times_validated"`
+	Canonical       bool   `db:"canonical"`
 }
 
 // UncleModel is the db model for eth.uncle_cids
@@ -122,3 +123,10 @@ type StateAccountModel struct {
 	CodeHash    []byte `db:"code_hash"`
 	StorageRoot string `db:"storage_root"`
 }
+
+// ContractCodeModel is the db model for eth.contract_codes
+type ContractCodeModel struct {
+	CodeHash []byte `db:"code_hash"`
+	CID      string `db:"cid"`
+	MhKey    string `db:"mh_key"`
+}
@@ -0,0 +1,261 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultProxyMethods are the methods forwarded to the upstream node when no allow-list is configured
+var DefaultProxyMethods = []string{
+	"eth_sendRawTransaction",
+	"eth_gasPrice",
+	"eth_protocolVersion",
+	"eth_mining",
+	"eth_hashrate",
+}
+
+// pendingBlock is the block tag used when forwarding pending block queries upstream
+const pendingBlock = "pending"
+
+// accountMethods are the methods which sign with or expose the accounts of the upstream node, these are never forwarded
+var accountMethods = []string{
+	"eth_accounts",
+	"eth_coinbase",
+	"eth_sign",
+	"eth_signTransaction",
+	"eth_signTypedData",
+	"eth_sendTransaction",
+}
+
+// accountNamespaces are the namespaces whose methods manage the accounts of the upstream node
+var accountNamespaces = []string{"personal_", "clique_", "miner_", "admin_"}
+
+// ValidateProxyMethods returns an error naming the methods in the allow-list which can never be forwarded upstream
+// because they sign with or manage the accounts of the upstream node
+func ValidateProxyMethods(allowedMethods []string) error {
+	unsupported := make([]string, 0)
+	for _, method := range allowedMethods {
+		if isAccountMethod(method) {
+			unsupported = append(unsupported, method)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("ethereum proxy methods %s cannot be forwarded", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+func isAccountMethod(method string) bool {
+	for _, accountMethod := range accountMethods {
+		if method == accountMethod {
+			return true
+		}
+	}
+	for _, namespace := range accountNamespaces {
+		if strings.HasPrefix(method, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// Proxy forwards the requests that the watcher cannot answer from its index to an upstream eth node
+// Only the methods in its allow-list are forwarded
+type Proxy struct {
+	client  *rpc.Client
+	methods map[string]bool
+}
+
+// NewProxy creates a new Proxy which forwards the allowed methods using the provided upstream client
+func NewProxy(client *rpc.Client, allowedMethods []string) *Proxy {
+	methods := make(map[string]bool, len(allowedMethods))
+	for _, method := range allowedMethods {
+		if !isAccountMethod(method) {
+			methods[method] = true
+		}
+	}
+	return &Proxy{
+		client:  client,
+		methods: methods,
+	}
+}
+
+// Forwards returns whether or not the method is forwarded upstream; a nil Proxy forwards nothing
+func (p *Proxy) Forwards(method string) bool {
+	return p != nil && p.methods[method]
+}
+
+// Forward sends the request upstream and decodes the response into result
+// Errors returned by the upstream node are relayed as they are
+func (p *Proxy) Forward(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if !p.Forwards(method) {
+		return fmt.Errorf("the method %s does not exist/is not available", method)
+	}
+	log.Debugf("forwarding %s to the upstream eth node", method)
+	return p.client.CallContext(ctx, result, method, args...)
+}
+
+// methodNotFoundCode is the json-rpc error code returned for methods which the server does not implement
+const methodNotFoundCode = -32601
+
+// proxyErrorCode is the json-rpc error code used for upstream errors which do not carry their own
+const proxyErrorCode = -32000
+
+// jsonrpcMessage is a json-rpc request or response
+type jsonrpcMessage struct {
+	Version string          `json:"jsonrpc,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Error   *jsonError      `json:"error,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+}
+
+type jsonError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// ProxyHandler serves json-rpc over http with the watcher's server and forwards the requests it answers with "method
+// not found" to the upstream node, if the proxy allows their method
+type ProxyHandler struct {
+	proxy *Proxy
+	next  http.Handler
+}
+
+// NewProxyHandler creates a new ProxyHandler which serves requests with next and forwards them using the provided Proxy
+func NewProxyHandler(proxy *Proxy, next http.Handler) *ProxyHandler {
+	return &ProxyHandler{
+		proxy: proxy,
+		next:  next,
+	}
+}
+
+// ServeHTTP satisfies the http.Handler interface
+func (ph *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	requests, batch := parseMessages(body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	recorder := httptest.NewRecorder()
+	ph.next.ServeHTTP(recorder, r)
+	res := recorder.Result()
+	for key, values := range res.Header {
+		w.Header()[key] = values
+	}
+	responses, _ := parseMessages(recorder.Body.Bytes())
+	if res.StatusCode != http.StatusOK || !ph.forwardNotFound(r.Context(), requests, responses) {
+		w.WriteHeader(res.StatusCode)
+		w.Write(recorder.Body.Bytes())
+		return
+	}
+	var out interface{} = responses
+	if !batch {
+		out = responses[0]
+	}
+	encoded, err := json.Marshal(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(encoded)))
+	w.WriteHeader(res.StatusCode)
+	w.Write(encoded)
+}
+
+// forwardNotFound replaces the "method not found" responses to requests for allowed methods with the upstream responses
+// It returns whether or not any response was replaced
+func (ph *ProxyHandler) forwardNotFound(ctx context.Context, requests, responses []*jsonrpcMessage) bool {
+	forwarded := false
+	for i, response := range responses {
+		if response.Error == nil || response.Error.Code != methodNotFoundCode {
+			continue
+		}
+		for _, request := range requests {
+			if bytes.Equal(request.ID, response.ID) && ph.proxy.Forwards(request.Method) {
+				responses[i] = ph.forward(ctx, request)
+				forwarded = true
+				break
+			}
+		}
+	}
+	return forwarded
+}
+
+// forward sends the request upstream and relays its result or error
+func (ph *ProxyHandler) forward(ctx context.Context, request *jsonrpcMessage) *jsonrpcMessage {
+	response := &jsonrpcMessage{Version: "2.0", ID: request.ID}
+	var params []json.RawMessage
+	if len(request.Params) > 0 {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			response.Error = &jsonError{Code: proxyErrorCode, Message: err.Error()}
+			return response
+		}
+	}
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+	var result json.RawMessage
+	if err := ph.proxy.Forward(ctx, &result, request.Method, args...); err != nil {
+		response.Error = &jsonError{Code: proxyErrorCode, Message: err.Error()}
+		if rpcErr, ok := err.(rpc.Error); ok {
+			response.Error.Code = rpcErr.ErrorCode()
+		}
+		return response
+	}
+	response.Result = result
+	return response
+}
+
+// parseMessages decodes a single json-rpc message or a batch of them, it returns no messages if they cannot be decoded
+func parseMessages(raw []byte) ([]*jsonrpcMessage, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var msgs []*jsonrpcMessage
+		if err := json.Unmarshal(raw, &msgs); err != nil {
+			return nil, true
+		}
+		return msgs, true
+	}
+	msg := new(jsonrpcMessage)
+	if err := json.Unmarshal(raw, msg); err != nil {
+		return nil, false
+	}
+	return []*jsonrpcMessage{msg}, false
+}
+
+// isPending returns whether or not the block number or hash refers to the pending block
+func isPending(blockNrOrHash rpc.BlockNumberOrHash) bool {
+	number, ok := blockNrOrHash.Number()
+	return ok && number == rpc.PendingBlockNumber
+}
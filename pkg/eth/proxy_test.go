@@ -0,0 +1,155 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+)
+
+var (
+	upstreamGasPrice = big.NewInt(1000000000)
+	upstreamBalance  = big.NewInt(42)
+	upstreamTxHash   = common.HexToHash("0x0a")
+)
+
+// upstreamAPI is a stand-in for the eth api of the upstream node
+type upstreamAPI struct{}
+
+func (upstreamAPI) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(upstreamGasPrice)
+}
+
+func (upstreamAPI) SendRawTransaction(encodedTx hexutil.Bytes) (common.Hash, error) {
+	if len(encodedTx) == 0 {
+		return common.Hash{}, errors.New("empty transaction")
+	}
+	return upstreamTxHash, nil
+}
+
+func (upstreamAPI) Accounts() []common.Address {
+	return []common.Address{common.HexToAddress("0x01")}
+}
+
+func (upstreamAPI) GetBalance(address common.Address, blockNr rpc.BlockNumber) (*hexutil.Big, error) {
+	if blockNr != rpc.PendingBlockNumber {
+		return nil, errors.New("expected a pending block query")
+	}
+	return (*hexutil.Big)(upstreamBalance), nil
+}
+
+var _ = Describe("Proxy", func() {
+	var (
+		upstream *httptest.Server
+		watcher  *httptest.Server
+		server   *rpc.Server
+		client   *rpc.Client
+	)
+	BeforeEach(func() {
+		upstreamServer := rpc.NewServer()
+		err := upstreamServer.RegisterName(eth.APIName, upstreamAPI{})
+		Expect(err).ToNot(HaveOccurred())
+		upstream = httptest.NewServer(upstreamServer)
+		upstreamClient, err := rpc.DialHTTP(upstream.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		proxy := eth.NewProxy(upstreamClient, []string{"eth_gasPrice", "eth_sendRawTransaction", "eth_getBalance", "eth_accounts"})
+		server = rpc.NewServer()
+		err = server.RegisterName(eth.APIName, eth.NewPublicEthAPI(&eth.Backend{}, nil, nil, proxy))
+		Expect(err).ToNot(HaveOccurred())
+		watcher = httptest.NewServer(eth.NewProxyHandler(proxy, server))
+		client, err = rpc.DialHTTP(watcher.URL)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		client.Close()
+		watcher.Close()
+		server.Stop()
+		upstream.Close()
+	})
+
+	It("Forwards allowed methods which the watcher does not implement", func() {
+		var gasPrice hexutil.Big
+		err := client.Call(&gasPrice, "eth_gasPrice")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gasPrice.ToInt()).To(Equal(upstreamGasPrice))
+
+		var txHash common.Hash
+		err = client.Call(&txHash, "eth_sendRawTransaction", hexutil.Bytes{1})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(txHash).To(Equal(upstreamTxHash))
+	})
+
+	It("Relays the errors returned by the upstream node", func() {
+		var txHash common.Hash
+		err := client.Call(&txHash, "eth_sendRawTransaction", hexutil.Bytes{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("empty transaction"))
+	})
+
+	It("Does not forward methods which are not in the allow-list", func() {
+		var protocolVersion hexutil.Uint
+		err := client.Call(&protocolVersion, "eth_protocolVersion")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("the method eth_protocolVersion does not exist/is not available"))
+	})
+
+	It("Never forwards methods which use the accounts of the upstream node", func() {
+		var accounts []common.Address
+		err := client.Call(&accounts, "eth_accounts")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("the method eth_accounts does not exist/is not available"))
+	})
+
+	It("Forwards the allowed methods of a batch which the watcher does not implement", func() {
+		var gasPrice, protocolVersion hexutil.Big
+		batch := []rpc.BatchElem{
+			{Method: "eth_protocolVersion", Result: &protocolVersion},
+			{Method: "eth_gasPrice", Result: &gasPrice},
+		}
+		err := client.BatchCall(batch)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(batch[0].Error).To(HaveOccurred())
+		Expect(batch[1].Error).ToNot(HaveOccurred())
+		Expect(gasPrice.ToInt()).To(Equal(upstreamGasPrice))
+	})
+
+	It("Forwards allowed pending block queries", func() {
+		var balance hexutil.Big
+		err := client.CallContext(context.Background(), &balance, "eth_getBalance", common.HexToAddress("0x01"), "pending")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(balance.ToInt()).To(Equal(upstreamBalance))
+	})
+
+	It("Rejects allow-lists with methods which use the accounts of the upstream node", func() {
+		Expect(eth.ValidateProxyMethods(eth.DefaultProxyMethods)).To(Succeed())
+		Expect(eth.ValidateProxyMethods([]string{"eth_getBalance", "eth_call", "eth_feeHistory"})).To(Succeed())
+		err := eth.ValidateProxyMethods([]string{"eth_gasPrice", "eth_sign", "eth_sendTransaction", "personal_unlockAccount"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("eth_sign, eth_sendTransaction, personal_unlockAccount"))
+	})
+})
@@ -20,11 +20,12 @@ import (
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/state"
-	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/statediff"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/multiformats/go-multihash"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
@@ -35,13 +36,18 @@ import (
 // It interfaces directly with the public.blocks table of PG-IPFS rather than going through an ipfs intermediary
 // It publishes and indexes IPLDs together in a single sqlx.Tx
 type IPLDPublisherAndIndexer struct {
-	indexer *CIDIndexer
+	indexer     *CIDIndexer
+	chainConfig *params.ChainConfig
+	codeFetcher *CodeFetcher
 }
 
 // NewIPLDPublisherAndIndexer creates a pointer to a new IPLDPublisherAndIndexer which satisfies the IPLDPublisher interface
-func NewIPLDPublisherAndIndexer(db *postgres.DB) *IPLDPublisherAndIndexer {
+// If a CodeFetcher is provided the code of the contracts found in the state diffs is published and indexed alongside them
+func NewIPLDPublisherAndIndexer(db *postgres.DB, chainConfig *params.ChainConfig, codeFetcher *CodeFetcher) *IPLDPublisherAndIndexer {
 	return &IPLDPublisherAndIndexer{
-		indexer: NewCIDIndexer(db),
+		indexer:     NewCIDIndexer(db),
+		chainConfig: chainConfig,
+		codeFetcher: codeFetcher,
 	}
 }
 
@@ -56,6 +62,12 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 	if err != nil {
 		return nil, err
 	}
+	// Fetch the code of the contracts which have not had their code published yet
+	// this is done before the db tx is opened so that the tx is not held open across calls to the node
+	codes, err := pub.fetchContractCodes(ipldPayload)
+	if err != nil {
+		return nil, err
+	}
 
 	// Begin new db tx
 	tx, err := pub.indexer.db.Beginx()
@@ -89,7 +101,7 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 	if err := shared.PublishIPLD(tx, headerNode); err != nil {
 		return nil, err
 	}
-	reward := CalcEthBlockReward(ipldPayload.Block.Header(), ipldPayload.Block.Uncles(), ipldPayload.Block.Transactions(), ipldPayload.Receipts)
+	reward := CalcEthBlockReward(pub.chainConfig, ipldPayload.Block.Header(), ipldPayload.Block.Uncles(), ipldPayload.Block.Transactions(), ipldPayload.Receipts)
 	header := HeaderModel{
 		CID:             headerNode.Cid().String(),
 		MhKey:           shared.MultihashKeyFromCID(headerNode.Cid()),
@@ -115,7 +127,7 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 		if err := shared.PublishIPLD(tx, uncleNode); err != nil {
 			return nil, err
 		}
-		uncleReward := CalcUncleMinerReward(ipldPayload.Block.Number().Int64(), uncleNode.Number.Int64())
+		uncleReward := CalcUncleMinerReward(pub.chainConfig, ipldPayload.Block.Number().Int64(), uncleNode.Number.Int64())
 		uncle := UncleModel{
 			CID:        uncleNode.Cid().String(),
 			MhKey:      shared.MultihashKeyFromCID(uncleNode.Cid()),
@@ -153,13 +165,13 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 	}
 
 	// Publish and index state and storage
-	err = pub.publishAndIndexStateAndStorage(tx, ipldPayload, headerID)
+	err = pub.publishAndIndexStateAndStorage(tx, ipldPayload, headerID, codes)
 
 	// This IPLDPublisher does both publishing and indexing, we do not need to pass anything forward to the indexer
 	return nil, err // return err variable explicitly so that we return the err = tx.Commit() assignment in the defer
 }
 
-func (pub *IPLDPublisherAndIndexer) publishAndIndexStateAndStorage(tx *sqlx.Tx, ipldPayload ConvertedPayload, headerID int64) error {
+func (pub *IPLDPublisherAndIndexer) publishAndIndexStateAndStorage(tx *sqlx.Tx, ipldPayload ConvertedPayload, headerID int64, codes map[common.Hash][]byte) error {
 	// Publish and index state and storage
 	for _, stateNode := range ipldPayload.StateNodes {
 		stateCIDStr, err := shared.PublishRaw(tx, ipld.MEthStateTrie, multihash.KECCAK_256, stateNode.Value)
@@ -180,15 +192,8 @@ func (pub *IPLDPublisherAndIndexer) publishAndIndexStateAndStorage(tx *sqlx.Tx,
 		}
 		// If we have a leaf, decode and index the account data and any associated storage diffs
 		if stateNode.Type == statediff.Leaf {
-			var i []interface{}
-			if err := rlp.DecodeBytes(stateNode.Value, &i); err != nil {
-				return err
-			}
-			if len(i) != 2 {
-				return fmt.Errorf("eth IPLDPublisherAndIndexer expected state leaf node rlp to decode into two elements")
-			}
-			var account state.Account
-			if err := rlp.DecodeBytes(i[1].([]byte), &account); err != nil {
+			account, err := decodeStateLeafAccount(stateNode.Value)
+			if err != nil {
 				return err
 			}
 			accountModel := StateAccountModel{
@@ -200,6 +205,11 @@ func (pub *IPLDPublisherAndIndexer) publishAndIndexStateAndStorage(tx *sqlx.Tx,
 			if err := pub.indexer.indexStateAccount(tx, accountModel, stateID); err != nil {
 				return err
 			}
+			if code, ok := codes[common.BytesToHash(account.CodeHash)]; ok {
+				if err := publishAndIndexContractCode(tx, pub.indexer, common.BytesToHash(account.CodeHash), code); err != nil {
+					return err
+				}
+			}
 			for _, storageNode := range ipldPayload.StorageNodes[common.Bytes2Hex(stateNode.Path)] {
 				storageCIDStr, err := shared.PublishRaw(tx, ipld.MEthStorageTrie, multihash.KECCAK_256, storageNode.Value)
 				if err != nil {
@@ -222,6 +232,68 @@ func (pub *IPLDPublisherAndIndexer) publishAndIndexStateAndStorage(tx *sqlx.Tx,
 	return nil
 }
 
+// fetchContractCodes fetches the code of the contract accounts in the payload whose code is not yet indexed
+// Code which cannot be fetched is skipped, to be picked up by the CodeBackFiller
+func (pub *IPLDPublisherAndIndexer) fetchContractCodes(ipldPayload ConvertedPayload) (map[common.Hash][]byte, error) {
+	codes := make(map[common.Hash][]byte)
+	if pub.codeFetcher == nil {
+		return codes, nil
+	}
+	leafKeys := make(map[common.Hash]common.Hash)
+	for _, stateNode := range ipldPayload.StateNodes {
+		if stateNode.Type != statediff.Leaf {
+			continue
+		}
+		account, err := decodeStateLeafAccount(stateNode.Value)
+		if err != nil {
+			return nil, err
+		}
+		codeHash := common.BytesToHash(account.CodeHash)
+		if codeHash != emptyCodeHash {
+			leafKeys[codeHash] = stateNode.LeafKey
+		}
+	}
+	if len(leafKeys) == 0 {
+		return codes, nil
+	}
+	codeHashes := make(pq.ByteaArray, 0, len(leafKeys))
+	for codeHash := range leafKeys {
+		codeHashes = append(codeHashes, codeHash.Bytes())
+	}
+	var indexed [][]byte
+	pgStr := `SELECT code_hash FROM eth.contract_codes WHERE code_hash = ANY($1)`
+	if err := pub.indexer.db.Select(&indexed, pgStr, codeHashes); err != nil {
+		return nil, err
+	}
+	for _, codeHash := range indexed {
+		delete(leafKeys, common.BytesToHash(codeHash))
+	}
+	candidates := CandidateAddresses(ipldPayload)
+	for codeHash, leafKey := range leafKeys {
+		code, err := pub.codeFetcher.FetchCode(leafKey, codeHash, ipldPayload.Block.Number(), candidates)
+		if err != nil {
+			log.Warnf("eth IPLDPublisherAndIndexer unable to fetch code for hash %s: %v", codeHash.Hex(), err)
+			continue
+		}
+		codes[codeHash] = code
+	}
+	return codes, nil
+}
+
+// publishAndIndexContractCode publishes contract code to public.blocks, keyed by its keccak256 hash, and indexes it
+func publishAndIndexContractCode(tx *sqlx.Tx, indexer *CIDIndexer, codeHash common.Hash, code []byte) error {
+	codeCIDStr, err := shared.PublishRaw(tx, ipld.RawBinary, multihash.KECCAK_256, code)
+	if err != nil {
+		return err
+	}
+	mhKey, _ := shared.MultihashKeyFromCIDString(codeCIDStr)
+	return indexer.indexContractCode(tx, ContractCodeModel{
+		CodeHash: codeHash.Bytes(),
+		CID:      codeCIDStr,
+		MhKey:    mhKey,
+	})
+}
+
 // Index satisfies the shared.CIDIndexer interface
 func (pub *IPLDPublisherAndIndexer) Index(cids shared.CIDsForIndexing) error {
 	return nil
@@ -17,7 +17,10 @@
 package eth_test
 
 import (
+	"context"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-ds-help"
@@ -41,7 +44,7 @@ var _ = Describe("PublishAndIndexer", func() {
 	BeforeEach(func() {
 		db, err = shared.SetupDB()
 		Expect(err).ToNot(HaveOccurred())
-		repo = eth.NewIPLDPublisherAndIndexer(db)
+		repo = eth.NewIPLDPublisherAndIndexer(db, params.MainnetChainConfig, nil)
 	})
 	AfterEach(func() {
 		eth.TearDownDB(db)
@@ -235,4 +238,64 @@ var _ = Describe("PublishAndIndexer", func() {
 			Expect(data).To(Equal(mocks.StorageLeafNode))
 		})
 	})
+
+	Describe("Contract code", func() {
+		var client *mocks.CodeClient
+		BeforeEach(func() {
+			client = &mocks.CodeClient{
+				Codes: map[common.Address][]byte{mocks.ContractAddress: mocks.ContractCode},
+			}
+		})
+
+		It("Fetches, publishes and indexes the code of the contracts in the state diff", func() {
+			repo = eth.NewIPLDPublisherAndIndexer(db, params.MainnetChainConfig, eth.NewCodeFetcher(client))
+			_, err = repo.Publish(mocks.MockConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			var codeHashes [][]byte
+			err = db.Select(&codeHashes, `SELECT code_hash FROM eth.contract_codes`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(codeHashes).To(Equal([][]byte{mocks.ContractCodeHash.Bytes()}))
+			backend := eth.NewEthBackend(db, params.MainnetChainConfig, eth.APIConfig{})
+			code, err := backend.GetCodeByHash(context.Background(), mocks.ContractCodeHash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(code).To(Equal(mocks.ContractCode))
+		})
+
+		It("Backfills the code of indexed contracts using the node's preimages", func() {
+			_, err = repo.Publish(mocks.MockConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			backend := eth.NewEthBackend(db, params.MainnetChainConfig, eth.APIConfig{})
+			_, err = backend.GetCodeByHash(context.Background(), mocks.ContractCodeHash)
+			Expect(err).To(HaveOccurred())
+
+			client.Preimages = map[common.Hash][]byte{common.BytesToHash(mocks.ContractLeafKey): mocks.ContractAddress.Bytes()}
+			filled, err := eth.NewCodeBackFiller(db, eth.NewCodeFetcher(client)).BackFill()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filled).To(Equal(1))
+			code, err := backend.GetCodeByHash(context.Background(), mocks.ContractCodeHash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(code).To(Equal(mocks.ContractCode))
+
+			filled, err = eth.NewCodeBackFiller(db, eth.NewCodeFetcher(client)).BackFill()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filled).To(Equal(0))
+		})
+
+		It("Only checks the accounts indexed since its last pass", func() {
+			_, err = repo.Publish(mocks.MockConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			backFiller := eth.NewCodeBackFiller(db, eth.NewCodeFetcher(client))
+			filled, err := backFiller.BackFill()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filled).To(Equal(0))
+
+			client.Preimages = map[common.Hash][]byte{common.BytesToHash(mocks.ContractLeafKey): mocks.ContractAddress.Bytes()}
+			filled, err = backFiller.BackFill()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filled).To(Equal(0))
+			filled, err = eth.NewCodeBackFiller(db, eth.NewCodeFetcher(client)).BackFill()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filled).To(Equal(1))
+		})
+	})
 })
@@ -22,6 +22,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/statediff"
 
@@ -40,10 +41,11 @@ type IPLDPublisher struct {
 	ReceiptTriePutter     ipfs.DagPutter
 	StatePutter           ipfs.DagPutter
 	StoragePutter         ipfs.DagPutter
+	ChainConfig           *params.ChainConfig
 }
 
 // NewIPLDPublisher creates a pointer to a new IPLDPublisher which satisfies the IPLDPublisher interface
-func NewIPLDPublisher(ipfsPath string) (*IPLDPublisher, error) {
+func NewIPLDPublisher(ipfsPath string, chainConfig *params.ChainConfig) (*IPLDPublisher, error) {
 	node, err := ipfs.InitIPFSNode(ipfsPath)
 	if err != nil {
 		return nil, err
@@ -56,6 +58,7 @@ func NewIPLDPublisher(ipfsPath string) (*IPLDPublisher, error) {
 		ReceiptTriePutter:     dag_putters.NewEthRctTrieDagPutter(node),
 		StatePutter:           dag_putters.NewEthStateDagPutter(node),
 		StoragePutter:         dag_putters.NewEthStorageDagPutter(node),
+		ChainConfig:           chainConfig,
 	}, nil
 }
 
@@ -76,7 +79,7 @@ func (pub *IPLDPublisher) Publish(payload shared.ConvertedData) (shared.CIDsForI
 	if err != nil {
 		return nil, err
 	}
-	reward := CalcEthBlockReward(ipldPayload.Block.Header(), ipldPayload.Block.Uncles(), ipldPayload.Block.Transactions(), ipldPayload.Receipts)
+	reward := CalcEthBlockReward(pub.ChainConfig, ipldPayload.Block.Header(), ipldPayload.Block.Uncles(), ipldPayload.Block.Transactions(), ipldPayload.Receipts)
 	header := HeaderModel{
 		CID:             headerCid,
 		MhKey:           shared.MultihashKeyFromCID(headerNode.Cid()),
@@ -100,7 +103,7 @@ func (pub *IPLDPublisher) Publish(payload shared.ConvertedData) (shared.CIDsForI
 		if err != nil {
 			return nil, err
 		}
-		uncleReward := CalcUncleMinerReward(ipldPayload.Block.Number().Int64(), uncle.Number.Int64())
+		uncleReward := CalcUncleMinerReward(pub.ChainConfig, ipldPayload.Block.Number().Int64(), uncle.Number.Int64())
 		uncleCids[i] = UncleModel{
 			CID:        uncleCid,
 			MhKey:      shared.MultihashKeyFromCID(uncle.Cid()),
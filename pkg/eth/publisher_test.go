@@ -18,6 +18,7 @@ package eth_test
 
 import (
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -77,6 +78,7 @@ var _ = Describe("Publisher", func() {
 				ReceiptTriePutter:     mockRctTrieDagPutter,
 				StatePutter:           mockStateDagPutter,
 				StoragePutter:         mockStorageDagPutter,
+				ChainConfig:           params.MainnetChainConfig,
 			}
 			payload, err := publisher.Publish(mocks.MockConvertedPayload)
 			Expect(err).ToNot(HaveOccurred())
@@ -19,19 +19,21 @@ package eth
 import (
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
 )
 
-func CalcEthBlockReward(header *types.Header, uncles []*types.Header, txs types.Transactions, receipts types.Receipts) *big.Int {
-	staticBlockReward := staticRewardByBlockNumber(header.Number.Int64())
+func CalcEthBlockReward(chainConfig *params.ChainConfig, header *types.Header, uncles []*types.Header, txs types.Transactions, receipts types.Receipts) *big.Int {
+	staticBlockReward := staticRewardByBlockNumber(chainConfig, header.Number.Int64())
 	transactionFees := calcEthTransactionFees(txs, receipts)
-	uncleInclusionRewards := calcEthUncleInclusionRewards(header, uncles)
+	uncleInclusionRewards := calcEthUncleInclusionRewards(chainConfig, header, uncles)
 	tmp := transactionFees.Add(transactionFees, uncleInclusionRewards)
 	return tmp.Add(tmp, staticBlockReward)
 }
 
-func CalcUncleMinerReward(blockNumber, uncleBlockNumber int64) *big.Int {
-	staticBlockReward := staticRewardByBlockNumber(blockNumber)
+func CalcUncleMinerReward(chainConfig *params.ChainConfig, blockNumber, uncleBlockNumber int64) *big.Int {
+	staticBlockReward := staticRewardByBlockNumber(chainConfig, blockNumber)
 	rewardDiv8 := staticBlockReward.Div(staticBlockReward, big.NewInt(8))
 	mainBlock := big.NewInt(blockNumber)
 	uncleBlock := big.NewInt(uncleBlockNumber)
@@ -40,17 +42,20 @@ func CalcUncleMinerReward(blockNumber, uncleBlockNumber int64) *big.Int {
 	return rewardDiv8.Mul(rewardDiv8, uncleBlockPlus8MinusMainBlock)
 }
 
-func staticRewardByBlockNumber(blockNumber int64) *big.Int {
-	staticBlockReward := new(big.Int)
-	//https://blog.ethereum.org/2017/10/12/byzantium-hf-announcement/
-	if blockNumber >= 7280000 {
-		staticBlockReward.SetString("2000000000000000000", 10)
-	} else if blockNumber >= 4370000 {
-		staticBlockReward.SetString("3000000000000000000", 10)
-	} else {
-		staticBlockReward.SetString("5000000000000000000", 10)
+// staticRewardByBlockNumber returns the block reward of the fork the block belongs to
+// Clique chains do not pay a block reward
+func staticRewardByBlockNumber(chainConfig *params.ChainConfig, blockNumber int64) *big.Int {
+	number := big.NewInt(blockNumber)
+	switch {
+	case chainConfig.Clique != nil:
+		return new(big.Int)
+	case chainConfig.IsConstantinople(number):
+		return new(big.Int).Set(ethash.ConstantinopleBlockReward)
+	case chainConfig.IsByzantium(number):
+		return new(big.Int).Set(ethash.ByzantiumBlockReward)
+	default:
+		return new(big.Int).Set(ethash.FrontierBlockReward)
 	}
-	return staticBlockReward
 }
 
 func calcEthTransactionFees(txs types.Transactions, receipts types.Receipts) *big.Int {
@@ -65,10 +70,10 @@ func calcEthTransactionFees(txs types.Transactions, receipts types.Receipts) *bi
 	return transactionFees
 }
 
-func calcEthUncleInclusionRewards(header *types.Header, uncles []*types.Header) *big.Int {
+func calcEthUncleInclusionRewards(chainConfig *params.ChainConfig, header *types.Header, uncles []*types.Header) *big.Int {
 	uncleInclusionRewards := new(big.Int)
 	for range uncles {
-		staticBlockReward := staticRewardByBlockNumber(header.Number.Int64())
+		staticBlockReward := staticRewardByBlockNumber(chainConfig, header.Number.Int64())
 		staticBlockReward.Div(staticBlockReward, big.NewInt(32))
 		uncleInclusionRewards.Add(uncleInclusionRewards, staticBlockReward)
 	}
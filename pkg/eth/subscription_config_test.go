@@ -0,0 +1,77 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+)
+
+// legacySettings is the layout of the subscription settings before confirmations could be requested
+type legacySettings struct {
+	BackFill      bool
+	BackFillOnly  bool
+	Start         *big.Int
+	End           *big.Int
+	HeaderFilter  eth.HeaderFilter
+	TxFilter      eth.TxFilter
+	ReceiptFilter eth.ReceiptFilter
+	StateFilter   eth.StateFilter
+	StorageFilter eth.StorageFilter
+}
+
+var _ = Describe("SubscriptionSettings", func() {
+	It("Decodes and encodes the settings of subscribers which do not request confirmations as before", func() {
+		legacy := legacySettings{
+			Start:        big.NewInt(1),
+			End:          big.NewInt(2),
+			HeaderFilter: eth.HeaderFilter{Uncles: true},
+			TxFilter:     eth.TxFilter{Src: []string{"0xde0B295669a9FD93d5F28D9Ec85E40f4cb697BAe"}},
+		}
+		legacyRLP, err := rlp.EncodeToBytes(legacy)
+		Expect(err).ToNot(HaveOccurred())
+
+		var settings eth.SubscriptionSettings
+		err = rlp.DecodeBytes(legacyRLP, &settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(settings.Start.Int64()).To(Equal(int64(1)))
+		Expect(settings.End.Int64()).To(Equal(int64(2)))
+		Expect(settings.HeaderFilter).To(Equal(legacy.HeaderFilter))
+		Expect(settings.TxFilter.Src).To(Equal(legacy.TxFilter.Src))
+		Expect(settings.ConfirmationDepth()).To(BeZero())
+
+		settingsRLP, err := rlp.EncodeToBytes(&settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(settingsRLP).To(Equal(legacyRLP))
+	})
+
+	It("Appends the requested confirmations to the settings", func() {
+		settings := eth.SubscriptionSettings{Start: big.NewInt(1), End: big.NewInt(2), Confirmations: []uint64{12}}
+		settingsRLP, err := rlp.EncodeToBytes(&settings)
+		Expect(err).ToNot(HaveOccurred())
+
+		var decoded eth.SubscriptionSettings
+		err = rlp.DecodeBytes(settingsRLP, &decoded)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decoded.ConfirmationDepth()).To(Equal(int64(12)))
+	})
+})
@@ -0,0 +1,154 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+const (
+	subscriptionChanBufferSize = 100 // the buffer size of the payload channel for each eth_subscribe subscription
+)
+
+// NewHeads sends a notification each time a new header is served by the watcher
+// Payloads for heights below the highest one already sent (e.g. those being backfilled) are not sent
+func (pea *PublicEthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, rpcSub, err := pea.newSubscription(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go pea.servePayloads(notifier, rpcSub, func(payload ConvertedPayload) {
+		if err := notifier.Notify(rpcSub.ID, payload.Block.Header()); err != nil {
+			log.Errorf("eth newHeads subscription %s notification error: %v", rpcSub.ID, err)
+		}
+	})
+	return rpcSub, nil
+}
+
+// Logs creates a subscription that sends the logs, served by the watcher, which match the given criteria
+// Payloads for heights below the highest one already sent (e.g. those being backfilled) are not sent
+func (pea *PublicEthAPI) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+	notifier, rpcSub, err := pea.newSubscription(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go pea.servePayloads(notifier, rpcSub, func(payload ConvertedPayload) {
+		var logs []*types.Log
+		for _, receipt := range payload.Receipts {
+			logs = append(logs, receipt.Logs...)
+		}
+		for _, l := range filterLogs(logs, crit.Addresses, crit.Topics) {
+			if err := notifier.Notify(rpcSub.ID, l); err != nil {
+				log.Errorf("eth logs subscription %s notification error: %v", rpcSub.ID, err)
+			}
+		}
+	})
+	return rpcSub, nil
+}
+
+// newSubscription creates a new rpc subscription for the notifier in the provided context
+func (pea *PublicEthAPI) newSubscription(ctx context.Context) (*rpc.Notifier, *rpc.Subscription, error) {
+	if pea.payloads == nil {
+		return nil, nil, rpc.ErrNotificationsUnsupported
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, nil, rpc.ErrNotificationsUnsupported
+	}
+	return notifier, notifier.CreateSubscription(), nil
+}
+
+// servePayloads subscribes to the payloads served by the watcher and hands each new eth payload to the provided
+// notify function until the rpc subscription is closed
+func (pea *PublicEthAPI) servePayloads(notifier *rpc.Notifier, rpcSub *rpc.Subscription, notify func(payload ConvertedPayload)) {
+	payloadChan := make(chan shared.ConvertedData, subscriptionChanBufferSize)
+	sub := pea.payloads.SubscribePayloads(payloadChan)
+	defer sub.Unsubscribe()
+	var highest int64
+	for {
+		select {
+		case data := <-payloadChan:
+			payload, ok := data.(ConvertedPayload)
+			if !ok {
+				log.Errorf("eth subscription %s expected payload type %T got %T", rpcSub.ID, ConvertedPayload{}, data)
+				continue
+			}
+			if payload.Height() < highest {
+				continue
+			}
+			highest = payload.Height()
+			notify(payload)
+		case err := <-sub.Err():
+			if err != nil {
+				log.Errorf("eth subscription %s payload feed error: %v", rpcSub.ID, err)
+			}
+			return
+		case <-rpcSub.Err():
+			return
+		case <-notifier.Closed():
+			return
+		}
+	}
+}
+
+// filterLogs returns the logs that match the given addresses and topics
+// This follows the go-ethereum filtering semantics: an empty address list matches any address, an empty topic position
+// matches any topic, and a log needs at least as many topics as there are topic positions in the criteria to match
+func filterLogs(logs []*types.Log, addresses []common.Address, topics [][]common.Hash) []*types.Log {
+	ret := make([]*types.Log, 0)
+Logs:
+	for _, log := range logs {
+		if len(addresses) > 0 && !includesAddress(addresses, log.Address) {
+			continue
+		}
+		// If the to filtered topics is greater than the amount of topics in logs, skip.
+		if len(topics) > len(log.Topics) {
+			continue Logs
+		}
+		for i, sub := range topics {
+			match := len(sub) == 0 // empty rule set == wildcard
+			for _, topic := range sub {
+				if log.Topics[i] == topic {
+					match = true
+					break
+				}
+			}
+			if !match {
+				continue Logs
+			}
+		}
+		ret = append(ret, log)
+	}
+	return ret
+}
+
+func includesAddress(addresses []common.Address, a common.Address) bool {
+	for _, addr := range addresses {
+		if addr == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	mocks2 "github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared/mocks"
+)
+
+var _ = Describe("Subscriptions", func() {
+	var (
+		payloads *mocks2.PayloadSubscriber
+		server   *rpc.Server
+		client   *rpc.Client
+	)
+	BeforeEach(func() {
+		payloads = new(mocks2.PayloadSubscriber)
+		server = rpc.NewServer()
+		err := server.RegisterName(eth.APIName, eth.NewPublicEthAPI(&eth.Backend{}, payloads, nil, nil))
+		Expect(err).ToNot(HaveOccurred())
+		client = rpc.DialInProc(server)
+	})
+	AfterEach(func() {
+		client.Close()
+		server.Stop()
+	})
+
+	// sendWhenSubscribed keeps sending the payload until the subscription has registered with the payload feed
+	sendWhenSubscribed := func() {
+		Eventually(func() int {
+			return payloads.Send(mocks.MockConvertedPayload)
+		}).Should(BeNumerically(">", 0))
+	}
+
+	Describe("NewHeads", func() {
+		It("Sends the headers of the payloads served by the watcher", func() {
+			headerChan := make(chan *types.Header, 10)
+			sub, err := client.EthSubscribe(context.Background(), headerChan, "newHeads")
+			Expect(err).ToNot(HaveOccurred())
+			defer sub.Unsubscribe()
+			sendWhenSubscribed()
+			var header *types.Header
+			Eventually(headerChan, time.Second).Should(Receive(&header))
+			Expect(header.Hash()).To(Equal(mocks.MockBlock.Hash()))
+		})
+	})
+
+	Describe("Logs", func() {
+		It("Sends the logs that match the filter criteria", func() {
+			logChan := make(chan types.Log, 10)
+			crit := ethereum.FilterQuery{
+				Topics: [][]common.Hash{
+					{},
+					{common.HexToHash("0x07")},
+				},
+			}
+			sub, err := client.EthSubscribe(context.Background(), logChan, "logs", toFilterArg(crit))
+			Expect(err).ToNot(HaveOccurred())
+			defer sub.Unsubscribe()
+			sendWhenSubscribed()
+			var log types.Log
+			Eventually(logChan, time.Second).Should(Receive(&log))
+			Expect(log.Address).To(Equal(mocks.MockLog2.Address))
+			Expect(log.Topics).To(Equal(mocks.MockLog2.Topics))
+			Consistently(logChan).ShouldNot(Receive())
+		})
+	})
+
+	It("Does not support subscriptions without a payload source", func() {
+		server := rpc.NewServer()
+		err := server.RegisterName(eth.APIName, eth.NewPublicEthAPI(&eth.Backend{}, nil, nil, nil))
+		Expect(err).ToNot(HaveOccurred())
+		client := rpc.DialInProc(server)
+		defer client.Close()
+		_, err = client.EthSubscribe(context.Background(), make(chan *types.Header), "newHeads")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+// toFilterArg converts the filter query into the json-rpc argument expected by the logs subscription
+func toFilterArg(q ethereum.FilterQuery) interface{} {
+	arg := map[string]interface{}{
+		"topics": q.Topics,
+	}
+	if len(q.Addresses) > 0 {
+		arg["address"] = q.Addresses
+	}
+	return arg
+}
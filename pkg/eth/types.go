@@ -44,6 +44,16 @@ func (i ConvertedPayload) Height() int64 {
 	return i.Block.Number().Int64()
 }
 
+// Hash satisfies the StreamedIPLDs interface
+func (i ConvertedPayload) Hash() string {
+	return i.Block.Hash().Hex()
+}
+
+// ParentHash satisfies the StreamedIPLDs interface
+func (i ConvertedPayload) ParentHash() string {
+	return i.Block.ParentHash().Hex()
+}
+
 // Trie struct used to flag node as leaf or not
 type TrieNode struct {
 	Path    []byte
@@ -0,0 +1,187 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package graphql_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/graphql"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func query(handler http.Handler, q string) response {
+	body, err := json.Marshal(map[string]string{"query": q})
+	Expect(err).ToNot(HaveOccurred())
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	Expect(rec.Code).To(Equal(http.StatusOK))
+	var res response
+	Expect(json.Unmarshal(rec.Body.Bytes(), &res)).To(Succeed())
+	return res
+}
+
+var _ = Describe("GraphQL", func() {
+	var (
+		db      *postgres.DB
+		handler http.Handler
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		backend := &eth.Backend{
+			Retriever:     eth.NewCIDRetriever(db),
+			Fetcher:       eth.NewIPLDPGFetcher(db),
+			DB:            db,
+			ChainConfig:   params.MainnetChainConfig,
+			StateDatabase: state.NewDatabase(rawdb.NewDatabase(eth.NewIPLDDatabase(db))),
+		}
+		_, err = eth.NewIPLDPublisherAndIndexer(db, params.MainnetChainConfig, nil).Publish(mocks.MockConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+		api := eth.NewPublicEthAPI(backend, nil, nil, nil)
+		handler, err = graphql.NewHandler(graphql.NewResolver(api, nil, nil))
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+	})
+
+	Describe("block", func() {
+		It("Resolves a block and its transactions by number or hash", func() {
+			res := query(handler, fmt.Sprintf(`{ block(number: %d) { hash transactionCount transactions { hash index } } }`, mocks.BlockNumber.Int64()))
+			Expect(res.Errors).To(BeEmpty())
+			block := res.Data["block"].(map[string]interface{})
+			Expect(block["hash"]).To(Equal(mocks.MockBlock.Hash().Hex()))
+			Expect(block["transactionCount"]).To(BeNumerically("==", len(mocks.MockTransactions)))
+			txs := block["transactions"].([]interface{})
+			Expect(len(txs)).To(Equal(len(mocks.MockTransactions)))
+			for i, tx := range txs {
+				Expect(tx.(map[string]interface{})["hash"]).To(Equal(mocks.MockTransactions[i].Hash().Hex()))
+				Expect(tx.(map[string]interface{})["index"]).To(BeNumerically("==", i))
+			}
+
+			res = query(handler, fmt.Sprintf(`{ block(hash: "%s") { number totalDifficulty } }`, mocks.MockBlock.Hash().Hex()))
+			Expect(res.Errors).To(BeEmpty())
+			block = res.Data["block"].(map[string]interface{})
+			Expect(block["number"]).To(Equal(hexutil.EncodeBig(mocks.BlockNumber)))
+			Expect(block["totalDifficulty"]).To(Equal(hexutil.EncodeBig(mocks.MockBlock.Difficulty())))
+		})
+
+		It("Refuses a blocks range wider than the maximum", func() {
+			res := query(handler, fmt.Sprintf(`{ blocks(from: 0, to: %d) { number } }`, graphql.MaxBlocksRange))
+			Expect(res.Errors).ToNot(BeEmpty())
+			Expect(res.Errors[0].Message).To(Equal(fmt.Sprintf("exceed maximum block range: %d", graphql.MaxBlocksRange)))
+		})
+	})
+
+	Describe("account", func() {
+		It("Resolves balances, nonces and storage from the state index at the queried block", func() {
+			res := query(handler, fmt.Sprintf(`{ block(number: %d) {
+				account: account(address: "%s") { balance transactionCount }
+				contract: account(address: "%s") { transactionCount storage(slot: "0x0000000000000000000000000000000000000000000000000000000000000000") }
+			} }`, mocks.BlockNumber.Int64(), mocks.AccountAddresss.Hex(), mocks.ContractAddress.Hex()))
+			Expect(res.Errors).To(BeEmpty())
+			block := res.Data["block"].(map[string]interface{})
+			account := block["account"].(map[string]interface{})
+			Expect(account["balance"]).To(Equal("0x3e8"))
+			Expect(account["transactionCount"]).To(Equal("0x0"))
+			contract := block["contract"].(map[string]interface{})
+			Expect(contract["transactionCount"]).To(Equal("0x1"))
+			Expect(contract["storage"]).To(Equal("0x0000000000000000000000000000000000000000000000000000000000000001"))
+		})
+	})
+
+	Describe("transaction", func() {
+		It("Resolves a transaction with its block and logs", func() {
+			tx := mocks.MockTransactions[0]
+			res := query(handler, fmt.Sprintf(`{ transaction(hash: "%s") { hash index block { hash } logs { topics } } }`, tx.Hash().Hex()))
+			Expect(res.Errors).To(BeEmpty())
+			transaction := res.Data["transaction"].(map[string]interface{})
+			Expect(transaction["hash"]).To(Equal(tx.Hash().Hex()))
+			Expect(transaction["index"]).To(BeNumerically("==", 0))
+			Expect(transaction["block"].(map[string]interface{})["hash"]).To(Equal(mocks.MockBlock.Hash().Hex()))
+			logs := transaction["logs"].([]interface{})
+			Expect(len(logs)).To(Equal(1))
+			topics := logs[0].(map[string]interface{})["topics"].([]interface{})
+			Expect(len(topics)).To(Equal(len(mocks.MockLog1.Topics)))
+			Expect(topics[0]).To(Equal(mocks.MockLog1.Topics[0].Hex()))
+		})
+
+		It("Resolves to null for transactions that are not indexed", func() {
+			res := query(handler, `{ transaction(hash: "0x0000000000000000000000000000000000000000000000000000000000000001") { hash } }`)
+			Expect(res.Errors).To(BeEmpty())
+			Expect(res.Data["transaction"]).To(BeNil())
+		})
+	})
+
+	Describe("logs", func() {
+		It("Resolves the logs in the block range", func() {
+			res := query(handler, fmt.Sprintf(`{ logs(filter: { fromBlock: %d, toBlock: %d }) { transaction { hash } } }`, mocks.BlockNumber.Int64(), mocks.BlockNumber.Int64()))
+			Expect(res.Errors).To(BeEmpty())
+			logs := res.Data["logs"].([]interface{})
+			Expect(len(logs)).To(Equal(2))
+			Expect(logs[0].(map[string]interface{})["transaction"].(map[string]interface{})["hash"]).To(Equal(mocks.MockTransactions[0].Hash().Hex()))
+			Expect(logs[1].(map[string]interface{})["transaction"].(map[string]interface{})["hash"]).To(Equal(mocks.MockTransactions[1].Hash().Hex()))
+		})
+		It("Resolves the logs of a block", func() {
+			res := query(handler, fmt.Sprintf(`{ block(number: %d) { logs(filter: {}) { index transaction { hash } } } }`, mocks.BlockNumber.Int64()))
+			Expect(res.Errors).To(BeEmpty())
+			logs := res.Data["block"].(map[string]interface{})["logs"].([]interface{})
+			Expect(len(logs)).To(Equal(2))
+			Expect(logs[1].(map[string]interface{})["transaction"].(map[string]interface{})["hash"]).To(Equal(mocks.MockTransactions[1].Hash().Hex()))
+		})
+		It("Rejects the same block ranges as eth_getLogs", func() {
+			res := query(handler, fmt.Sprintf(`{ logs(filter: { fromBlock: %d, toBlock: %d }) { index } }`, mocks.BlockNumber.Int64()+1, mocks.BlockNumber.Int64()))
+			Expect(res.Errors).ToNot(BeEmpty())
+			Expect(res.Errors[0].Message).To(Equal("invalid block range params"))
+		})
+	})
+
+	Describe("syncing and gasPrice", func() {
+		It("Reports not syncing without a status reporter and cannot answer gasPrice without a proxy", func() {
+			res := query(handler, `{ syncing { currentBlock } }`)
+			Expect(res.Errors).To(BeEmpty())
+			Expect(res.Data["syncing"]).To(BeNil())
+
+			res = query(handler, `{ gasPrice }`)
+			Expect(res.Errors).ToNot(BeEmpty())
+			Expect(res.Errors[0].Message).To(ContainSubstring("eth_gasPrice does not exist/is not available"))
+		})
+	})
+})
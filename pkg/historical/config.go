@@ -20,9 +20,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/spf13/viper"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
@@ -31,11 +34,10 @@ import (
 
 // Env variables
 const (
-	SUPERNODE_CHAIN            = "SUPERNODE_CHAIN"
-	SUPERNODE_FREQUENCY        = "SUPERNODE_FREQUENCY"
-	SUPERNODE_BATCH_SIZE       = "SUPERNODE_BATCH_SIZE"
-	SUPERNODE_BATCH_NUMBER     = "SUPERNODE_BATCH_NUMBER"
-	SUPERNODE_VALIDATION_LEVEL = "SUPERNODE_VALIDATION_LEVEL"
+	SUPERNODE_CHAIN        = "SUPERNODE_CHAIN"
+	SUPERNODE_FREQUENCY    = "SUPERNODE_FREQUENCY"
+	SUPERNODE_BATCH_SIZE   = "SUPERNODE_BATCH_SIZE"
+	SUPERNODE_BATCH_NUMBER = "SUPERNODE_BATCH_NUMBER"
 
 	BACKFILL_MAX_IDLE_CONNECTIONS = "BACKFILL_MAX_IDLE_CONNECTIONS"
 	BACKFILL_MAX_OPEN_CONNECTIONS = "BACKFILL_MAX_OPEN_CONNECTIONS"
@@ -57,6 +59,7 @@ type Config struct {
 	ValidationLevel int
 	Timeout         time.Duration // HTTP connection timeout in seconds
 	NodeInfo        node.Node
+	ChainConfig     interface{} // Network params of the chain, *params.ChainConfig for ethereum and *chaincfg.Params for bitcoin
 }
 
 // NewConfig is used to initialize a historical config from a .toml file
@@ -99,7 +102,7 @@ func (c *Config) init() error {
 	viper.BindEnv("watcher.frequency", SUPERNODE_FREQUENCY)
 	viper.BindEnv("watcher.batchSize", SUPERNODE_BATCH_SIZE)
 	viper.BindEnv("watcher.batchNumber", SUPERNODE_BATCH_NUMBER)
-	viper.BindEnv("watcher.validationLevel", SUPERNODE_VALIDATION_LEVEL)
+	viper.BindEnv("watcher.validationLevel", shared.SUPERNODE_VALIDATION_LEVEL)
 	viper.BindEnv("watcher.timeout", shared.HTTP_TIMEOUT)
 
 	timeout := viper.GetInt("watcher.timeout")
@@ -111,13 +114,27 @@ func (c *Config) init() error {
 	switch c.Chain {
 	case shared.Ethereum:
 		ethHTTP := viper.GetString("ethereum.httpPath")
-		c.NodeInfo, c.HTTPClient, err = shared.GetEthNodeAndClient(fmt.Sprintf("http://%s", ethHTTP))
+		var ethClient *rpc.Client
+		c.NodeInfo, ethClient, err = shared.GetEthNodeAndClient(fmt.Sprintf("http://%s", ethHTTP))
+		if err != nil {
+			return err
+		}
+		c.HTTPClient = ethClient
+		c.ChainConfig, err = eth.ChainConfigFromConfig(c.NodeInfo, ethClient)
 		if err != nil {
 			return err
 		}
 	case shared.Bitcoin:
 		btcHTTP := viper.GetString("bitcoin.httpPath")
 		c.NodeInfo, c.HTTPClient = shared.GetBtcNodeAndClient(btcHTTP)
+		chainParams, err := btc.ParamsFromConfig(c.NodeInfo)
+		if err != nil {
+			return err
+		}
+		c.ChainConfig = chainParams
+		if p2pPath := shared.GetBtcP2PPath(); p2pPath != "" {
+			c.HTTPClient = btc.NewP2PClient(p2pPath, chainParams)
+		}
 	}
 
 	freq := viper.GetInt("watcher.frequency")
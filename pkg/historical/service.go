@@ -23,6 +23,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
 )
@@ -32,6 +33,8 @@ type BackFillInterface interface {
 	// Method for the watcher to periodically check for and fill in gaps in its data using an archival node
 	BackFill(wg *sync.WaitGroup)
 	Stop() error
+	// Method to report the progress of the current backfill pass
+	Progress() shared.BackFillProgress
 }
 
 // BackFillService for filling in gaps in the watcher
@@ -46,6 +49,8 @@ type BackFillService struct {
 	Retriever shared.CIDRetriever
 	// Interface for fetching payloads over at historical blocks; over http
 	Fetcher shared.PayloadFetcher
+	// Fills in the contract code missing for indexed accounts; nil if the chain or ipfs mode has no code to backfill
+	CodeBackFiller *eth.CodeBackFiller
 	// Channel for forwarding backfill payloads to the ScreenAndServe process
 	ScreenAndServeChan chan shared.ConvertedData
 	// Check frequency
@@ -60,11 +65,14 @@ type BackFillService struct {
 	chain shared.ChainType
 	// Headers with times_validated lower than this will be resynced
 	validationLevel int
+	// Progress of the current backfill pass
+	progressLock sync.RWMutex
+	progress     shared.BackFillProgress
 }
 
 // NewBackFillService returns a new BackFillInterface
 func NewBackFillService(settings *Config, screenAndServeChan chan shared.ConvertedData) (BackFillInterface, error) {
-	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.DB, settings.IPFSMode)
+	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.ChainConfig, settings.IPFSPath, settings.DB, settings.IPFSMode, settings.HTTPClient)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +80,7 @@ func NewBackFillService(settings *Config, screenAndServeChan chan shared.Convert
 	if err != nil {
 		return nil, err
 	}
-	converter, err := builders.NewPayloadConverter(settings.Chain)
+	converter, err := builders.NewPayloadConverter(settings.Chain, settings.ChainConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -98,6 +106,7 @@ func NewBackFillService(settings *Config, screenAndServeChan chan shared.Convert
 		Publisher:          publisher,
 		Retriever:          retriever,
 		Fetcher:            fetcher,
+		CodeBackFiller:     builders.NewCodeBackFiller(settings.Chain, settings.DB, settings.IPFSMode, settings.HTTPClient),
 		GapCheckFrequency:  settings.Frequency,
 		BatchSize:          batchSize,
 		BatchNumber:        int64(batchNumber),
@@ -125,6 +134,7 @@ func (bfs *BackFillService) BackFill(wg *sync.WaitGroup) {
 					log.Errorf("%s watcher db backFill RetrieveGapsInData error: %v", bfs.chain.String(), err)
 					continue
 				}
+				bfs.startPass(gaps)
 				// spin up worker goroutines for this search pass
 				// we start and kill a new batch of workers for each pass
 				// so that we know each of the previous workers is done before we search for new gaps
@@ -154,12 +164,29 @@ func (bfs *BackFillService) BackFill(wg *sync.WaitGroup) {
 				for i := 1; i <= int(bfs.BatchNumber); i++ {
 					bfs.QuitChan <- true
 				}
+				bfs.finishPass()
+				bfs.backFillCode()
 			}
 		}
 	}()
 	log.Infof("%s BackFill goroutine successfully spun up", bfs.chain.String())
 }
 
+// backFillCode publishes and indexes any contract code missing for the indexed accounts
+func (bfs *BackFillService) backFillCode() {
+	if bfs.CodeBackFiller == nil {
+		return
+	}
+	filled, err := bfs.CodeBackFiller.BackFill()
+	if err != nil {
+		log.Errorf("%s watcher db code backFill error: %v", bfs.chain.String(), err)
+		return
+	}
+	if filled > 0 {
+		log.Infof("backFilled %s code for %d contracts", bfs.chain.String(), filled)
+	}
+}
+
 func (bfs *BackFillService) backFill(wg *sync.WaitGroup, id int, heightChan chan []uint64) {
 	wg.Add(1)
 	defer wg.Done()
@@ -192,6 +219,7 @@ func (bfs *BackFillService) backFill(wg *sync.WaitGroup, id int, heightChan chan
 					log.Errorf("%s backFill worker %d indexer error: %s", bfs.chain.String(), id, err.Error())
 				}
 			}
+			bfs.addFilled(uint64(len(heights)))
 			log.Infof("%s backFill worker %d finished section from %d to %d", bfs.chain.String(), id, heights[0], heights[len(heights)-1])
 		case <-bfs.QuitChan:
 			log.Infof("%s backFill worker %d shutting down", bfs.chain.String(), id)
@@ -200,6 +228,42 @@ func (bfs *BackFillService) backFill(wg *sync.WaitGroup, id int, heightChan chan
 	}
 }
 
+// Progress returns the progress of the current backfill pass, or of the last one if no pass is in progress
+func (bfs *BackFillService) Progress() shared.BackFillProgress {
+	bfs.progressLock.RLock()
+	defer bfs.progressLock.RUnlock()
+	return bfs.progress
+}
+
+// startPass resets the progress for a new pass over the provided gaps
+func (bfs *BackFillService) startPass(gaps []shared.Gap) {
+	var total uint64
+	for _, gap := range gaps {
+		total += gap.Stop - gap.Start + 1
+	}
+	bfs.progressLock.Lock()
+	defer bfs.progressLock.Unlock()
+	bfs.progress = shared.BackFillProgress{
+		Running:     true,
+		Gaps:        len(gaps),
+		TotalBlocks: total,
+	}
+}
+
+// addFilled records that the provided number of blocks have been processed in the current pass
+func (bfs *BackFillService) addFilled(blocks uint64) {
+	bfs.progressLock.Lock()
+	defer bfs.progressLock.Unlock()
+	bfs.progress.FilledBlocks += blocks
+}
+
+// finishPass records that the current pass has finished
+func (bfs *BackFillService) finishPass() {
+	bfs.progressLock.Lock()
+	defer bfs.progressLock.Unlock()
+	bfs.progress.Running = false
+}
+
 func (bfs *BackFillService) Stop() error {
 	log.Infof("Stopping %s backFill service", bfs.chain.String())
 	close(bfs.QuitChan)
@@ -86,6 +86,11 @@ var _ = Describe("BackFiller", func() {
 			Expect(mockRetriever.CalledTimes).To(Equal(1))
 			Expect(len(mockFetcher.CalledAtBlockHeights)).To(Equal(1))
 			Expect(mockFetcher.CalledAtBlockHeights[0]).To(Equal([]uint64{100, 101}))
+			Expect(backfiller.Progress()).To(Equal(shared.BackFillProgress{
+				Gaps:         1,
+				TotalBlocks:  2,
+				FilledBlocks: 2,
+			}))
 		})
 
 		It("Works for single block `ranges`", func() {
@@ -0,0 +1,117 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	IMPORT_BLOCKS_DIR   = "IMPORT_BLOCKS_DIR"
+	IMPORT_STOP         = "IMPORT_STOP"
+	IMPORT_BATCH_SIZE   = "IMPORT_BATCH_SIZE"
+	IMPORT_BATCH_NUMBER = "IMPORT_BATCH_NUMBER"
+)
+
+// Config holds the parameters needed to import bitcoin blocks from block files
+type Config struct {
+	BlocksDir   string           // The Bitcoin Core blocks directory holding the blk*.dat files
+	Stop        uint64           // Height to stop importing at; 0 imports up to the tip of the block files
+	ChainParams *chaincfg.Params // Parameters of the network the block files belong to
+
+	// DB info
+	DB       *postgres.DB
+	DBConfig config.Database
+	IPFSPath string
+	IPFSMode shared.IPFSMode
+
+	NodeInfo    node.Node // Info for the node that wrote the block files
+	BatchSize   uint64    // Number of blocks each worker reads and publishes at a time
+	BatchNumber uint64    // Number of workers
+}
+
+// NewConfig fills and returns an import config from toml parameters
+func NewConfig() (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("import.blocksDir", IMPORT_BLOCKS_DIR)
+	viper.BindEnv("import.stop", IMPORT_STOP)
+	viper.BindEnv("import.batchSize", IMPORT_BATCH_SIZE)
+	viper.BindEnv("import.batchNumber", IMPORT_BATCH_NUMBER)
+
+	c.BlocksDir, err = getBlocksDir()
+	if err != nil {
+		return nil, err
+	}
+	c.Stop = uint64(viper.GetInt64("import.stop"))
+
+	c.IPFSMode, err = shared.GetIPFSMode()
+	if err != nil {
+		return nil, err
+	}
+	if c.IPFSMode == shared.LocalInterface || c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath, err = shared.GetIPFSPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The node info is loaded from the config, as when retrieving blocks from the node over rpc
+	c.NodeInfo = shared.GetBtcNodeInfo()
+	c.ChainParams, err = btc.ParamsFromConfig(c.NodeInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, c.NodeInfo)
+	c.DB = &db
+
+	c.BatchSize = uint64(viper.GetInt64("import.batchSize"))
+	c.BatchNumber = uint64(viper.GetInt64("import.batchNumber"))
+	return c, nil
+}
+
+// getBlocksDir returns the configured blocks directory, defaulting to that of a Bitcoin Core node in the home directory
+func getBlocksDir() (string, error) {
+	blocksDir := viper.GetString("import.blocksDir")
+	if blocksDir != "" && !strings.HasPrefix(blocksDir, "~/") {
+		return blocksDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if blocksDir == "" {
+		return filepath.Join(home, ".bitcoin", "blocks"), nil
+	}
+	return filepath.Join(home, strings.TrimPrefix(blocksDir, "~/")), nil
+}
@@ -0,0 +1,218 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// maxReorgDepth is how far below the last imported block the import restarts when that block is no longer in the best
+// chain of the block files
+const maxReorgDepth = 100
+
+// BlockSource is a source of the blocks of a bitcoin chain, by height
+type BlockSource interface {
+	shared.PayloadFetcher
+	Height() int64
+	BlockHash(height int64) (chainhash.Hash, error)
+}
+
+// Import is the interface for importing bitcoin blocks from block files
+type Import interface {
+	Import() error
+}
+
+// Service imports bitcoin blocks in height order, in windows of BatchNumber batches of BatchSize blocks which are
+// published and indexed concurrently
+// The position is persisted after each window, so an interrupted import resumes from the last complete window
+type Service struct {
+	// Source of the blocks to import
+	Blocks BlockSource
+	// Interface for converting payloads into IPLD object payloads
+	Converter shared.PayloadConverter
+	// Interface for publishing the IPLD payloads to IPFS
+	Publisher shared.IPLDPublisher
+	// Interface for indexing the CIDs of the published IPLDs in Postgres
+	Indexer shared.CIDIndexer
+	// Interface for persisting the height and hash of the last imported block
+	Positions btc.PositionStore
+	// Size of batches
+	BatchSize uint64
+	// Number of goroutines
+	BatchNumber int64
+	// Height to stop importing at; 0 imports up to the tip of the blocks
+	Stop uint64
+}
+
+// NewImportService creates and returns an import service from the provided settings
+func NewImportService(settings *Config) (Import, error) {
+	publisher, err := builders.NewIPLDPublisher(shared.Bitcoin, settings.ChainParams, settings.IPFSPath, settings.DB, settings.IPFSMode, nil)
+	if err != nil {
+		return nil, err
+	}
+	indexer, err := builders.NewCIDIndexer(shared.Bitcoin, settings.DB, settings.IPFSMode)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := btc.OpenBlockFiles(settings.BlocksDir, settings.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := settings.BatchSize
+	if batchSize == 0 {
+		batchSize = shared.DefaultMaxBatchSize
+	}
+	batchNumber := int64(settings.BatchNumber)
+	if batchNumber == 0 {
+		batchNumber = shared.DefaultMaxBatchNumber
+	}
+	return &Service{
+		Blocks:      blocks,
+		Converter:   btc.NewPayloadConverter(settings.ChainParams),
+		Publisher:   publisher,
+		Indexer:     indexer,
+		Positions:   btc.NewPGImportPositionStore(settings.DB),
+		BatchSize:   batchSize,
+		BatchNumber: batchNumber,
+		Stop:        settings.Stop,
+	}, nil
+}
+
+// Import imports the blocks from the last imported height up to the stop height
+func (is *Service) Import() error {
+	start, err := is.startingHeight()
+	if err != nil {
+		return err
+	}
+	stop := uint64(is.Blocks.Height())
+	if is.Stop != 0 && is.Stop < stop {
+		stop = is.Stop
+	}
+	if start > stop {
+		logrus.Infof("btc blocks up to height %d have already been imported", stop)
+		return nil
+	}
+	logrus.Infof("importing btc blocks from %d to %d", start, stop)
+	window := is.BatchSize * uint64(is.BatchNumber)
+	begin := time.Now()
+	for from := start; from <= stop; from += window {
+		to := from + window - 1
+		if to > stop {
+			to = stop
+		}
+		if err := is.importRange(from, to); err != nil {
+			return err
+		}
+		hash, err := is.Blocks.BlockHash(int64(to))
+		if err != nil {
+			return err
+		}
+		if err := is.Positions.SetPosition(int64(to), hash.String()); err != nil {
+			return err
+		}
+		imported := to - start + 1
+		logrus.Infof("imported btc blocks up to %d (%.1f blocks/s)", to, float64(imported)/time.Since(begin).Seconds())
+	}
+	return nil
+}
+
+// startingHeight returns the height after the last imported block, or a height below it if that block is no longer in
+// the best chain of the blocks
+func (is *Service) startingHeight() (uint64, error) {
+	height, hash, err := is.Positions.Position()
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	current, err := is.Blocks.BlockHash(height)
+	if err == nil && current.String() == hash {
+		return uint64(height + 1), nil
+	}
+	resume := height - maxReorgDepth
+	if resume < 0 {
+		resume = 0
+	}
+	logrus.Warnf("last imported btc block %s at height %d is not in the best chain, resuming from %d", hash, height, resume)
+	return uint64(resume), nil
+}
+
+// importRange imports the blocks in the range with BatchNumber workers
+func (is *Service) importRange(from, to uint64) error {
+	bins, err := utils.GetBlockHeightBins(from, to, is.BatchSize)
+	if err != nil {
+		return err
+	}
+	binChan := make(chan []uint64)
+	errChan := make(chan error, len(bins))
+	wg := new(sync.WaitGroup)
+	for i := 0; i < int(is.BatchNumber) && i < len(bins); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for heights := range binChan {
+				if err := is.importHeights(heights); err != nil {
+					errChan <- err
+				}
+			}
+		}()
+	}
+	for _, heights := range bins {
+		binChan <- heights
+	}
+	close(binChan)
+	wg.Wait()
+	close(errChan)
+	if err, ok := <-errChan; ok {
+		return err
+	}
+	return nil
+}
+
+// importHeights reads, converts, publishes and indexes the blocks at the provided heights
+func (is *Service) importHeights(heights []uint64) error {
+	payloads, err := is.Blocks.FetchAt(heights)
+	if err != nil {
+		return err
+	}
+	for i, payload := range payloads {
+		ipldPayload, err := is.Converter.Convert(payload)
+		if err != nil {
+			return fmt.Errorf("btc import converter error at height %d: %v", heights[i], err)
+		}
+		cidPayload, err := is.Publisher.Publish(ipldPayload)
+		if err != nil {
+			return fmt.Errorf("btc import publisher error at height %d: %v", heights[i], err)
+		}
+		if err := is.Indexer.Index(cidPayload); err != nil {
+			return fmt.Errorf("btc import indexer error at height %d: %v", heights[i], err)
+		}
+	}
+	return nil
+}
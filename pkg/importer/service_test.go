@@ -0,0 +1,107 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package importer_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/importer"
+)
+
+var _ = Describe("Service", func() {
+	var (
+		dir       string
+		chain     []*wire.MsgBlock
+		blocks    *btc.BlockFiles
+		converter *mocks.PayloadConverter
+		indexer   *mocks.CIDIndexer
+		positions *mocks.PositionStore
+		service   *importer.Service
+	)
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "btc-import")
+		Expect(err).ToNot(HaveOccurred())
+		chain = mocks.ExtendWorkChain(nil, 6, 0)
+		genesisHash := chain[0].BlockHash()
+		params := chaincfg.RegressionNetParams
+		params.GenesisHash = &genesisHash
+		files := [][]*wire.MsgBlock{{chain[0], chain[3], chain[1]}, {chain[2], chain[5], chain[4]}}
+		Expect(mocks.WriteBlockFiles(dir, params.Net, files, nil)).To(Succeed())
+		blocks, err = btc.OpenBlockFiles(dir, &params)
+		Expect(err).ToNot(HaveOccurred())
+		converter = &mocks.PayloadConverter{ReturnIPLDPayload: mocks.MockConvertedPayload}
+		indexer = new(mocks.CIDIndexer)
+		positions = new(mocks.PositionStore)
+		// A single worker, since the mocks are not safe for concurrent use
+		service = &importer.Service{
+			Blocks:      blocks,
+			Converter:   converter,
+			Publisher:   &mocks.IPLDPublisher{ReturnCIDPayload: &mocks.MockCIDPayload},
+			Indexer:     indexer,
+			Positions:   positions,
+			BatchSize:   2,
+			BatchNumber: 1,
+		}
+	})
+	AfterEach(func() {
+		blocks.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("Imports every block of the best chain and persists the position after each window", func() {
+		Expect(service.Import()).To(Succeed())
+		Expect(len(indexer.PassedCIDPayload)).To(Equal(6))
+		Expect(converter.PassedStatediffPayload.BlockHeight).To(Equal(int64(5)))
+		height, hash, err := positions.Position()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(height).To(Equal(int64(5)))
+		Expect(hash).To(Equal(chain[5].BlockHash().String()))
+	})
+
+	It("Resumes from the last imported height", func() {
+		Expect(positions.SetPosition(2, chain[2].BlockHash().String())).To(Succeed())
+		Expect(service.Import()).To(Succeed())
+		Expect(len(indexer.PassedCIDPayload)).To(Equal(3))
+		Expect(service.Import()).To(Succeed())
+		Expect(len(indexer.PassedCIDPayload)).To(Equal(3))
+	})
+
+	It("Stops at the stop height", func() {
+		service.Stop = 2
+		Expect(service.Import()).To(Succeed())
+		Expect(len(indexer.PassedCIDPayload)).To(Equal(3))
+		height, _, err := positions.Position()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(height).To(Equal(int64(2)))
+	})
+
+	It("Restarts below the last imported block if it is no longer in the best chain", func() {
+		stale := mocks.ExtendWorkChain(chain[:3], 1, 100)
+		Expect(positions.SetPosition(3, stale[3].BlockHash().String())).To(Succeed())
+		Expect(service.Import()).To(Succeed())
+		Expect(len(indexer.PassedCIDPayload)).To(Equal(6))
+	})
+})
@@ -20,9 +20,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/spf13/viper"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
@@ -56,6 +59,7 @@ type Config struct {
 
 	HTTPClient  interface{}   // Note this client is expected to support the retrieval of the specified data type(s)
 	NodeInfo    node.Node     // Info for the associated node
+	ChainConfig interface{}   // Network params of the chain, *params.ChainConfig for ethereum and *chaincfg.Params for bitcoin
 	Ranges      [][2]uint64   // The block height ranges to resync
 	BatchSize   uint64        // BatchSize for the resync http calls (client has to support batch sizing)
 	Timeout     time.Duration // HTTP connection timeout in seconds
@@ -121,13 +125,27 @@ func NewConfig() (*Config, error) {
 	switch c.Chain {
 	case shared.Ethereum:
 		ethHTTP := viper.GetString("ethereum.httpPath")
-		c.NodeInfo, c.HTTPClient, err = shared.GetEthNodeAndClient(fmt.Sprintf("http://%s", ethHTTP))
+		var ethClient *rpc.Client
+		c.NodeInfo, ethClient, err = shared.GetEthNodeAndClient(fmt.Sprintf("http://%s", ethHTTP))
+		if err != nil {
+			return nil, err
+		}
+		c.HTTPClient = ethClient
+		c.ChainConfig, err = eth.ChainConfigFromConfig(c.NodeInfo, ethClient)
 		if err != nil {
 			return nil, err
 		}
 	case shared.Bitcoin:
 		btcHTTP := viper.GetString("bitcoin.httpPath")
 		c.NodeInfo, c.HTTPClient = shared.GetBtcNodeAndClient(btcHTTP)
+		chainParams, err := btc.ParamsFromConfig(c.NodeInfo)
+		if err != nil {
+			return nil, err
+		}
+		c.ChainConfig = chainParams
+		if p2pPath := shared.GetBtcP2PPath(); p2pPath != "" {
+			c.HTTPClient = btc.NewP2PClient(p2pPath, chainParams)
+		}
 	}
 
 	c.DBConfig.Init()
@@ -63,7 +63,7 @@ type Service struct {
 
 // NewResyncService creates and returns a resync service from the provided settings
 func NewResyncService(settings *Config) (Resync, error) {
-	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.DB, settings.IPFSMode)
+	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.ChainConfig, settings.IPFSPath, settings.DB, settings.IPFSMode, settings.HTTPClient)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +71,7 @@ func NewResyncService(settings *Config) (Resync, error) {
 	if err != nil {
 		return nil, err
 	}
-	converter, err := builders.NewPayloadConverter(settings.Chain)
+	converter, err := builders.NewPayloadConverter(settings.Chain, settings.ChainConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -33,40 +33,59 @@ const (
 	IPFS_MODE    = "IPFS_MODE"
 	HTTP_TIMEOUT = "HTTP_TIMEOUT"
 
+	SUPERNODE_VALIDATION_LEVEL = "SUPERNODE_VALIDATION_LEVEL"
+
 	ETH_WS_PATH       = "ETH_WS_PATH"
 	ETH_HTTP_PATH     = "ETH_HTTP_PATH"
 	ETH_NODE_ID       = "ETH_NODE_ID"
 	ETH_CLIENT_NAME   = "ETH_CLIENT_NAME"
 	ETH_GENESIS_BLOCK = "ETH_GENESIS_BLOCK"
 	ETH_NETWORK_ID    = "ETH_NETWORK_ID"
+	ETH_CHAIN_ID      = "ETH_CHAIN_ID"
+	ETH_NETWORK       = "ETH_NETWORK"
+	ETH_GENESIS_FILE  = "ETH_GENESIS_FILE"
+
+	ETH_MAX_LOG_RANGE     = "ETH_MAX_LOG_RANGE"
+	ETH_MAX_LOG_RESULTS   = "ETH_MAX_LOG_RESULTS"
+	ETH_DEBUG_API         = "ETH_DEBUG_API"
+	ETH_MAX_TRACE_TIMEOUT = "ETH_MAX_TRACE_TIMEOUT"
+	ETH_PROXY             = "ETH_PROXY"
+	ETH_PROXY_METHODS     = "ETH_PROXY_METHODS"
 
 	BTC_WS_PATH       = "BTC_WS_PATH"
 	BTC_HTTP_PATH     = "BTC_HTTP_PATH"
+	BTC_P2P_PATH      = "BTC_P2P_PATH"
 	BTC_NODE_PASSWORD = "BTC_NODE_PASSWORD"
 	BTC_NODE_USER     = "BTC_NODE_USER"
 	BTC_NODE_ID       = "BTC_NODE_ID"
 	BTC_CLIENT_NAME   = "BTC_CLIENT_NAME"
 	BTC_GENESIS_BLOCK = "BTC_GENESIS_BLOCK"
 	BTC_NETWORK_ID    = "BTC_NETWORK_ID"
+	BTC_NETWORK       = "BTC_NETWORK"
 )
 
 // GetEthNodeAndClient returns eth node info and client from path url
 func GetEthNodeAndClient(path string) (node.Node, *rpc.Client, error) {
+	rpcClient, err := rpc.Dial(path)
+	if err != nil {
+		return node.Node{}, nil, err
+	}
+	return GetEthNodeInfo(), rpcClient, nil
+}
+
+// GetEthNodeInfo returns the eth node info from the config or env variables
+func GetEthNodeInfo() node.Node {
 	viper.BindEnv("ethereum.nodeID", ETH_NODE_ID)
 	viper.BindEnv("ethereum.clientName", ETH_CLIENT_NAME)
 	viper.BindEnv("ethereum.genesisBlock", ETH_GENESIS_BLOCK)
 	viper.BindEnv("ethereum.networkID", ETH_NETWORK_ID)
 
-	rpcClient, err := rpc.Dial(path)
-	if err != nil {
-		return node.Node{}, nil, err
-	}
 	return node.Node{
 		ID:           viper.GetString("ethereum.nodeID"),
 		ClientName:   viper.GetString("ethereum.clientName"),
 		GenesisBlock: viper.GetString("ethereum.genesisBlock"),
 		NetworkID:    viper.GetString("ethereum.networkID"),
-	}, rpcClient, nil
+	}
 }
 
 // GetIPFSPath returns the ipfs path from the config or env variable
@@ -93,26 +112,39 @@ func GetIPFSMode() (IPFSMode, error) {
 	return NewIPFSMode(ipfsMode)
 }
 
-// GetBtcNodeAndClient returns btc node info from path url
+// GetBtcNodeAndClient returns btc node info and client config from path url
 func GetBtcNodeAndClient(path string) (node.Node, *rpcclient.ConnConfig) {
+	viper.BindEnv("bitcoin.pass", BTC_NODE_PASSWORD)
+	viper.BindEnv("bitcoin.user", BTC_NODE_USER)
+
+	return GetBtcNodeInfo(), &rpcclient.ConnConfig{
+		Host:         path,
+		HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
+		DisableTLS:   true, // Bitcoin core does not provide TLS by default
+		Pass:         viper.GetString("bitcoin.pass"),
+		User:         viper.GetString("bitcoin.user"),
+	}
+}
+
+// GetBtcNodeInfo returns the btc node info from the config or env variables
+// For bitcoin we load in node info from the config because there is no RPC endpoint to retrieve this from the node
+func GetBtcNodeInfo() node.Node {
 	viper.BindEnv("bitcoin.nodeID", BTC_NODE_ID)
 	viper.BindEnv("bitcoin.clientName", BTC_CLIENT_NAME)
 	viper.BindEnv("bitcoin.genesisBlock", BTC_GENESIS_BLOCK)
 	viper.BindEnv("bitcoin.networkID", BTC_NETWORK_ID)
-	viper.BindEnv("bitcoin.pass", BTC_NODE_PASSWORD)
-	viper.BindEnv("bitcoin.user", BTC_NODE_USER)
 
-	// For bitcoin we load in node info from the config because there is no RPC endpoint to retrieve this from the node
 	return node.Node{
-			ID:           viper.GetString("bitcoin.nodeID"),
-			ClientName:   viper.GetString("bitcoin.clientName"),
-			GenesisBlock: viper.GetString("bitcoin.genesisBlock"),
-			NetworkID:    viper.GetString("bitcoin.networkID"),
-		}, &rpcclient.ConnConfig{
-			Host:         path,
-			HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
-			DisableTLS:   true, // Bitcoin core does not provide TLS by default
-			Pass:         viper.GetString("bitcoin.pass"),
-			User:         viper.GetString("bitcoin.user"),
-		}
+		ID:           viper.GetString("bitcoin.nodeID"),
+		ClientName:   viper.GetString("bitcoin.clientName"),
+		GenesisBlock: viper.GetString("bitcoin.genesisBlock"),
+		NetworkID:    viper.GetString("bitcoin.networkID"),
+	}
+}
+
+// GetBtcP2PPath returns the host:port of the btc node's p2p port, or an empty string if blocks are to be retrieved
+// over rpc
+func GetBtcP2PPath() string {
+	viper.BindEnv("bitcoin.p2pPath", BTC_P2P_PATH)
+	return viper.GetString("bitcoin.p2pPath")
 }
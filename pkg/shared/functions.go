@@ -17,12 +17,16 @@
 package shared
 
 import (
+	"database/sql"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-ds-help"
 	node "github.com/ipfs/go-ipld-format"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/multiformats/go-multihash"
 	"github.com/sirupsen/logrus"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
 )
@@ -77,6 +81,29 @@ func FetchIPLDByMhKey(tx *sqlx.Tx, mhKey string) ([]byte, error) {
 	return block, tx.Get(&block, pgStr, mhKey)
 }
 
+// FetchIPLDsByMhKeys is used to retrieve a set of iplds from Postgres blockstore with a single query, using the provided tx
+// and mhkey strings; the iplds are returned keyed by mhkey and sql.ErrNoRows is returned if any of them is missing
+func FetchIPLDsByMhKeys(tx *sqlx.Tx, mhKeys []string) (map[string][]byte, error) {
+	pgStr := `SELECT key, data FROM public.blocks WHERE key = ANY($1)`
+	var blocks []struct {
+		Key  string `db:"key"`
+		Data []byte `db:"data"`
+	}
+	if err := tx.Select(&blocks, pgStr, pq.Array(mhKeys)); err != nil {
+		return nil, err
+	}
+	iplds := make(map[string][]byte, len(blocks))
+	for _, block := range blocks {
+		iplds[block.Key] = block.Data
+	}
+	for _, mhKey := range mhKeys {
+		if _, ok := iplds[mhKey]; !ok {
+			return nil, sql.ErrNoRows
+		}
+	}
+	return iplds, nil
+}
+
 // MultihashKeyFromCID converts a cid into a blockstore-prefixed multihash db key string
 func MultihashKeyFromCID(c cid.Cid) string {
 	dbKey := dshelp.MultihashToDsKey(c.Hash())
@@ -93,6 +120,16 @@ func MultihashKeyFromCIDString(c string) (string, error) {
 	return blockstore.BlockPrefix.String() + dbKey.String(), nil
 }
 
+// MultihashKeyFromKeccak256 converts a keccak256 hash into a blockstore-prefixed multihash db key string
+func MultihashKeyFromKeccak256(hash common.Hash) (string, error) {
+	mh, err := multihash.Encode(hash.Bytes(), multihash.KECCAK_256)
+	if err != nil {
+		return "", err
+	}
+	dbKey := dshelp.MultihashToDsKey(mh)
+	return blockstore.BlockPrefix.String() + dbKey.String(), nil
+}
+
 // PublishRaw derives a cid from raw bytes and provided codec and multihash type, and writes it to the db tx
 func PublishRaw(tx *sqlx.Tx, codec, mh uint64, raw []byte) (string, error) {
 	c, err := ipld.RawdataToCid(codec, raw, mh)
@@ -18,6 +18,8 @@ package shared
 
 import (
 	"math/big"
+
+	"github.com/ethereum/go-ethereum/event"
 )
 
 // PayloadStreamer streams chain-specific payloads to the provided channel
@@ -69,6 +71,26 @@ type ClientSubscription interface {
 	Unsubscribe()
 }
 
+// PayloadSubscriber is used to subscribe to the converted payloads served by a watcher
+type PayloadSubscriber interface {
+	SubscribePayloads(payloadChan chan<- ConvertedData) event.Subscription
+}
+
+// HeadFetcher fetches the height of the chain head from a node
+type HeadFetcher interface {
+	FetchHead() (int64, error)
+}
+
+// SyncStatusReporter reports how far a watcher's index is behind the chain
+type SyncStatusReporter interface {
+	SyncStatus() (SyncStatus, error)
+}
+
+// BackFillReporter reports the progress of a backfill process
+type BackFillReporter interface {
+	Progress() BackFillProgress
+}
+
 // Cleaner is for cleaning out data from the cache within the given ranges
 type Cleaner interface {
 	Clean(rngs [][2]uint64, t DataType) error
@@ -85,4 +107,5 @@ type SubscriptionSettings interface {
 	ChainType() ChainType
 	HistoricalData() bool
 	HistoricalDataOnly() bool
+	ConfirmationDepth() int64
 }
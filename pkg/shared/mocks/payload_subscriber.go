@@ -0,0 +1,38 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/ethereum/go-ethereum/event"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// PayloadSubscriber is a mock shared.PayloadSubscriber
+type PayloadSubscriber struct {
+	feed shared.PayloadFeed
+}
+
+// SubscribePayloads mock method
+func (ps *PayloadSubscriber) SubscribePayloads(payloadChan chan<- shared.ConvertedData) event.Subscription {
+	return ps.feed.Subscribe(payloadChan)
+}
+
+// Send sends the payload to all of the subscribed channels, returning the number of subscribers it was sent to
+func (ps *PayloadSubscriber) Send(payload shared.ConvertedData) int {
+	return ps.feed.Send(payload)
+}
@@ -28,6 +28,8 @@ type CIDRetriever struct {
 	CalledTimes                 int
 	FirstBlockNumberToReturn    int64
 	RetrieveFirstBlockNumberErr error
+	LastBlockNumberToReturn     int64
+	RetrieveLastBlockNumberErr  error
 }
 
 // RetrieveCIDs mock method
@@ -36,8 +38,8 @@ func (*CIDRetriever) Retrieve(filter shared.SubscriptionSettings, blockNumber in
 }
 
 // RetrieveLastBlockNumber mock method
-func (*CIDRetriever) RetrieveLastBlockNumber() (int64, error) {
-	panic("implement me")
+func (mcr *CIDRetriever) RetrieveLastBlockNumber() (int64, error) {
+	return mcr.LastBlockNumberToReturn, mcr.RetrieveLastBlockNumberErr
 }
 
 // RetrieveFirstBlockNumber mock method
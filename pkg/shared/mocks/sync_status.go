@@ -0,0 +1,53 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mocks
+
+import (
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// HeadFetcher mock for tests
+type HeadFetcher struct {
+	HeadToReturn int64
+	ReturnErr    error
+}
+
+// FetchHead mock method
+func (mhf *HeadFetcher) FetchHead() (int64, error) {
+	return mhf.HeadToReturn, mhf.ReturnErr
+}
+
+// BackFillReporter mock for tests
+type BackFillReporter struct {
+	ProgressToReturn shared.BackFillProgress
+}
+
+// Progress mock method
+func (mbr *BackFillReporter) Progress() shared.BackFillProgress {
+	return mbr.ProgressToReturn
+}
+
+// SyncStatusReporter mock for tests
+type SyncStatusReporter struct {
+	StatusToReturn shared.SyncStatus
+	ReturnErr      error
+}
+
+// SyncStatus mock method
+func (msr *SyncStatusReporter) SyncStatus() (shared.SyncStatus, error) {
+	return msr.StatusToReturn, msr.ReturnErr
+}
@@ -0,0 +1,90 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package shared
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/sirupsen/logrus"
+)
+
+// PayloadFeed delivers converted payloads to a set of subscribed channels
+// Unlike an event.Feed, whose element type is locked to the type of the first value sent or channel subscribed,
+// it accepts any ConvertedData implementation on channels of ConvertedData
+// Sending never blocks: each subscriber is buffered by its own channel, and a payload is dropped for a subscriber whose
+// channel is full so that one slow subscriber cannot hold up the sender or the other subscribers
+// The zero value is ready to use
+type PayloadFeed struct {
+	lock sync.Mutex
+	subs map[*payloadFeedSub]struct{}
+}
+
+type payloadFeedSub struct {
+	feed    *PayloadFeed
+	channel chan<- ConvertedData
+	once    sync.Once
+	err     chan error
+}
+
+// Subscribe adds the channel to the feed; the channel should be buffered as payloads it has no room for are dropped
+func (f *PayloadFeed) Subscribe(channel chan<- ConvertedData) event.Subscription {
+	sub := &payloadFeedSub{
+		feed:    f,
+		channel: channel,
+		err:     make(chan error),
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.subs == nil {
+		f.subs = make(map[*payloadFeedSub]struct{})
+	}
+	f.subs[sub] = struct{}{}
+	return sub
+}
+
+// Send delivers the payload to all of the subscribed channels which have room for it, returning the number of
+// subscribers it was sent to
+func (f *PayloadFeed) Send(payload ConvertedData) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var sent int
+	for sub := range f.subs {
+		select {
+		case sub.channel <- payload:
+			sent++
+		default:
+			logrus.Warnf("dropping payload at height %d for a payload feed subscriber which has fallen behind", payload.Height())
+		}
+	}
+	return sent
+}
+
+// Unsubscribe removes the channel from the feed and closes the error channel
+func (sub *payloadFeedSub) Unsubscribe() {
+	sub.once.Do(func() {
+		sub.feed.lock.Lock()
+		delete(sub.feed.subs, sub)
+		sub.feed.lock.Unlock()
+		close(sub.err)
+	})
+}
+
+// Err returns the subscription's error channel, which is closed on Unsubscribe
+func (sub *payloadFeedSub) Err() <-chan error {
+	return sub.err
+}
@@ -22,6 +22,8 @@ type RawChainData interface{}
 // The concrete type underneath StreamedIPLDs should not be a pointer
 type ConvertedData interface {
 	Height() int64
+	Hash() string
+	ParentHash() string
 }
 
 type CIDsForIndexing interface{}
@@ -36,3 +38,22 @@ type Gap struct {
 	Start uint64
 	Stop  uint64
 }
+
+// BackFillProgress describes the progress of the current backfill pass
+type BackFillProgress struct {
+	Running      bool   `json:"running"`      // whether a backfill pass is in progress
+	Gaps         int    `json:"gaps"`         // the number of gaps being filled by the pass
+	TotalBlocks  uint64 `json:"totalBlocks"`  // the number of blocks in those gaps
+	FilledBlocks uint64 `json:"filledBlocks"` // the number of those blocks which have been processed so far
+}
+
+// SyncStatus describes how far the watcher's index is behind the chain
+type SyncStatus struct {
+	StartingBlock     int64             `json:"startingBlock"`     // the first height streamed since the watcher started
+	LastStreamedBlock int64             `json:"lastStreamedBlock"` // the most recent height streamed
+	ContiguousBlock   int64             `json:"contiguousBlock"`   // the highest indexed height with no gaps below it
+	HeadBlock         int64             `json:"headBlock"`         // the head of the upstream node, or -1 if it is unknown
+	Lag               int64             `json:"lag"`               // the number of blocks between the contiguous height and the head
+	Gaps              []Gap             `json:"gaps"`              // the gaps outstanding in the index
+	BackFill          *BackFillProgress `json:"backFill"`          // the progress of the backfill process, if there is one
+}
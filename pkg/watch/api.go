@@ -18,6 +18,9 @@ package watch
 
 import (
 	"context"
+	"fmt"
+	"math/big"
+	"runtime"
 
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -116,12 +119,27 @@ func (api *PublicWatcherAPI) Chain() shared.ChainType {
 	return api.w.Chain()
 }
 
+// SyncStatus returns the last streamed height, the highest contiguous indexed height, the head of the node the watcher
+// syncs from, the outstanding gaps in the index and the progress of the current backfill pass
+func (api *PublicWatcherAPI) SyncStatus() (shared.SyncStatus, error) {
+	return api.w.SyncStatus()
+}
+
+// ClientVersion is the watcher's client version string, in the same format as geth's
+var ClientVersion = fmt.Sprintf("ipfs-blockchain-watcher/v%s/%s-%s/%s", v.VersionWithMeta, runtime.GOOS, runtime.GOARCH, runtime.Version())
+
 // Struct for holding watcher meta data
-type InfoAPI struct{}
+type InfoAPI struct {
+	chain    shared.ChainType
+	nodeInfo node.Node
+}
 
-// NewInfoAPI creates a new InfoAPI
-func NewInfoAPI() *InfoAPI {
-	return &InfoAPI{}
+// NewInfoAPI creates a new InfoAPI for the node the watcher is configured with
+func NewInfoAPI(chain shared.ChainType, nodeInfo node.Node) *InfoAPI {
+	return &InfoAPI{
+		chain:    chain,
+		nodeInfo: nodeInfo,
+	}
 }
 
 // Modules returns modules supported by this api
@@ -132,11 +150,17 @@ func (iapi *InfoAPI) Modules() map[string]string {
 }
 
 // NodeInfo gathers and returns a collection of metadata for the watcher
+// The ID and protocol info are those of the public.nodes entry the watcher is configured with
 func (iapi *InfoAPI) NodeInfo() *p2p.NodeInfo {
 	return &p2p.NodeInfo{
-		// TODO: formalize this
-		ID:   "vulcanizeDB",
-		Name: "ipfs-blockchain-watcher",
+		ID:   iapi.nodeInfo.ID,
+		Name: ClientVersion,
+		Protocols: map[string]interface{}{
+			iapi.chain.API(): map[string]string{
+				"network": iapi.nodeInfo.NetworkID,
+				"genesis": iapi.nodeInfo.GenesisBlock,
+			},
+		},
 	}
 }
 
@@ -144,3 +168,44 @@ func (iapi *InfoAPI) NodeInfo() *p2p.NodeInfo {
 func (iapi *InfoAPI) Version() string {
 	return v.VersionWithMeta
 }
+
+// NetAPI serves the net namespace for the watcher
+type NetAPI struct {
+	networkID string
+	chainID   *big.Int
+}
+
+// NewNetAPI creates a new NetAPI which reports the provided network id, or the chain id when no network id is provided
+func NewNetAPI(networkID string, chainID *big.Int) *NetAPI {
+	return &NetAPI{
+		networkID: networkID,
+		chainID:   chainID,
+	}
+}
+
+// Version returns the network id of the node the watcher is configured with
+// Falls back to the chain id, which matches the network id on most networks, when the network id is not set
+func (napi *NetAPI) Version() string {
+	if napi.networkID == "" && napi.chainID != nil {
+		return napi.chainID.String()
+	}
+	return napi.networkID
+}
+
+// Listening returns true while the watcher is serving requests
+func (napi *NetAPI) Listening() bool {
+	return true
+}
+
+// Web3API serves the web3 namespace for the watcher
+type Web3API struct{}
+
+// NewWeb3API creates a new Web3API
+func NewWeb3API() *Web3API {
+	return &Web3API{}
+}
+
+// ClientVersion returns the watcher's client version string
+func (wapi *Web3API) ClientVersion() string {
+	return ClientVersion
+}
@@ -0,0 +1,71 @@
+// VulcanizeDB
+// Copyright © 2019 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package watch_test
+
+import (
+	"math/big"
+	"runtime"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/watch"
+)
+
+var _ = Describe("API", func() {
+	nodeInfo := node.Node{
+		ID:           "arch1",
+		ClientName:   "Geth",
+		GenesisBlock: "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+		NetworkID:    "1",
+	}
+
+	Describe("InfoAPI", func() {
+		It("Reports the node info the watcher is configured with", func() {
+			info := watch.NewInfoAPI(shared.Ethereum, nodeInfo).NodeInfo()
+			Expect(info.ID).To(Equal(nodeInfo.ID))
+			Expect(info.Name).To(Equal(watch.ClientVersion))
+			Expect(info.Protocols["eth"]).To(Equal(map[string]string{
+				"network": nodeInfo.NetworkID,
+				"genesis": nodeInfo.GenesisBlock,
+			}))
+		})
+	})
+
+	Describe("NetAPI", func() {
+		It("Reports the network id of the node the watcher is configured with", func() {
+			api := watch.NewNetAPI(nodeInfo.NetworkID, big.NewInt(5))
+			Expect(api.Version()).To(Equal("1"))
+			Expect(api.Listening()).To(BeTrue())
+		})
+		It("Reports the chain id when the network id is not set", func() {
+			Expect(watch.NewNetAPI("", big.NewInt(5)).Version()).To(Equal("5"))
+			Expect(watch.NewNetAPI("", nil).Version()).To(Equal(""))
+		})
+	})
+
+	Describe("Web3API", func() {
+		It("Reports the client version", func() {
+			version := watch.NewWeb3API().ClientVersion()
+			Expect(strings.HasPrefix(version, "ipfs-blockchain-watcher/v")).To(BeTrue())
+			Expect(strings.HasSuffix(version, runtime.Version())).To(BeTrue())
+		})
+	})
+})
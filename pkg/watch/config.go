@@ -18,12 +18,20 @@ package watch
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
@@ -41,6 +49,15 @@ const (
 	SUPERNODE_HTTP_PATH = "SUPERNODE_HTTP_PATH"
 	SUPERNODE_BACKFILL  = "SUPERNODE_BACKFILL"
 
+	SUPERNODE_GRAPHQL      = "SUPERNODE_GRAPHQL"
+	SUPERNODE_GRAPHQL_PATH = "SUPERNODE_GRAPHQL_PATH"
+
+	SUPERNODE_ESPLORA      = "SUPERNODE_ESPLORA"
+	SUPERNODE_ESPLORA_PATH = "SUPERNODE_ESPLORA_PATH"
+
+	SUPERNODE_ELECTRUM      = "SUPERNODE_ELECTRUM"
+	SUPERNODE_ELECTRUM_PATH = "SUPERNODE_ELECTRUM_PATH"
+
 	SYNC_MAX_IDLE_CONNECTIONS = "SYNC_MAX_IDLE_CONNECTIONS"
 	SYNC_MAX_OPEN_CONNECTIONS = "SYNC_MAX_OPEN_CONNECTIONS"
 	SYNC_MAX_CONN_LIFETIME    = "SYNC_MAX_CONN_LIFETIME"
@@ -62,14 +79,32 @@ type Config struct {
 	WSEndpoint   string
 	HTTPEndpoint string
 	IPCEndpoint  string
+	// GraphQL server fields, the server is only available for ethereum
+	GraphQL         bool
+	GraphQLEndpoint string
+	// Esplora REST server fields, the server is only available for bitcoin
+	Esplora         bool
+	EsploraEndpoint string
+	// Electrum server fields, the server is only available for bitcoin
+	Electrum         bool
+	ElectrumEndpoint string
 	// Sync params
 	Sync       bool
 	SyncDBConn *postgres.DB
 	Workers    int
 	WSClient   interface{}
 	NodeInfo   node.Node
+	// Network params of the chain, *params.ChainConfig for ethereum and *chaincfg.Params for bitcoin
+	ChainConfig interface{}
+	// Proxy params, used to forward the eth requests the watcher cannot serve
+	ProxyClient  *rpc.Client
+	ProxyMethods []string
+	// Settings of the eth apis
+	EthAPIConfig eth.APIConfig
 	// Historical switch
 	Historical bool
+	// Headers with times_validated lower than this are reported as gaps in the sync status
+	ValidationLevel int
 }
 
 // NewConfig is used to initialize a watcher config from a .toml file
@@ -88,8 +123,25 @@ func NewConfig() (*Config, error) {
 	viper.BindEnv("watcher.ipcPath", SUPERNODE_IPC_PATH)
 	viper.BindEnv("watcher.httpPath", SUPERNODE_HTTP_PATH)
 	viper.BindEnv("watcher.backFill", SUPERNODE_BACKFILL)
+	viper.BindEnv("watcher.graphql", SUPERNODE_GRAPHQL)
+	viper.BindEnv("watcher.graphqlPath", SUPERNODE_GRAPHQL_PATH)
+	viper.BindEnv("watcher.esplora", SUPERNODE_ESPLORA)
+	viper.BindEnv("watcher.esploraPath", SUPERNODE_ESPLORA_PATH)
+	viper.BindEnv("watcher.electrum", SUPERNODE_ELECTRUM)
+	viper.BindEnv("watcher.electrumPath", SUPERNODE_ELECTRUM_PATH)
+	viper.BindEnv("ethereum.httpPath", shared.ETH_HTTP_PATH)
+	viper.BindEnv("ethereum.proxy", shared.ETH_PROXY)
+	viper.BindEnv("ethereum.proxyMethods", shared.ETH_PROXY_METHODS)
+	viper.BindEnv("ethereum.maxLogRange", shared.ETH_MAX_LOG_RANGE)
+	viper.BindEnv("ethereum.maxLogResults", shared.ETH_MAX_LOG_RESULTS)
+	viper.BindEnv("ethereum.chainID", shared.ETH_CHAIN_ID)
+	viper.BindEnv("ethereum.debugAPI", shared.ETH_DEBUG_API)
+	viper.BindEnv("ethereum.maxTraceTimeout", shared.ETH_MAX_TRACE_TIMEOUT)
+
+	viper.BindEnv("watcher.validationLevel", shared.SUPERNODE_VALIDATION_LEVEL)
 
 	c.Historical = viper.GetBool("watcher.backFill")
+	c.ValidationLevel = viper.GetInt("watcher.validationLevel")
 	chain := viper.GetString("watcher.chain")
 	c.Chain, err = shared.NewChainType(chain)
 	if err != nil {
@@ -119,13 +171,28 @@ func NewConfig() (*Config, error) {
 		switch c.Chain {
 		case shared.Ethereum:
 			ethWS := viper.GetString("ethereum.wsPath")
-			c.NodeInfo, c.WSClient, err = shared.GetEthNodeAndClient(fmt.Sprintf("ws://%s", ethWS))
+			var ethClient *rpc.Client
+			c.NodeInfo, ethClient, err = shared.GetEthNodeAndClient(fmt.Sprintf("ws://%s", ethWS))
+			if err != nil {
+				return nil, err
+			}
+			c.WSClient = ethClient
+			c.ChainConfig, err = ethChainConfig(c.NodeInfo, ethClient)
 			if err != nil {
 				return nil, err
 			}
 		case shared.Bitcoin:
 			btcWS := viper.GetString("bitcoin.wsPath")
 			c.NodeInfo, c.WSClient = shared.GetBtcNodeAndClient(btcWS)
+			chainParams, err := btc.ParamsFromConfig(c.NodeInfo)
+			if err != nil {
+				return nil, err
+			}
+			c.ChainConfig = chainParams
+			// The streamer and head fetcher share a single connection to the node's p2p port
+			if p2pPath := shared.GetBtcP2PPath(); p2pPath != "" {
+				c.WSClient = btc.NewP2PClient(p2pPath, chainParams)
+			}
 		}
 		syncDBConn := overrideDBConnConfig(c.DBConfig, Sync)
 		syncDB := utils.LoadPostgres(syncDBConn, c.NodeInfo)
@@ -153,6 +220,76 @@ func NewConfig() (*Config, error) {
 			httpPath = "127.0.0.1:8081"
 		}
 		c.HTTPEndpoint = httpPath
+		c.GraphQL = viper.GetBool("watcher.graphql")
+		if c.GraphQL {
+			if c.Chain != shared.Ethereum {
+				return nil, fmt.Errorf("graphql server is not supported for chain %s", c.Chain.String())
+			}
+			graphqlPath := viper.GetString("watcher.graphqlPath")
+			if graphqlPath == "" {
+				graphqlPath = "127.0.0.1:8082"
+			}
+			c.GraphQLEndpoint = graphqlPath
+		}
+		c.Esplora = viper.GetBool("watcher.esplora")
+		if c.Esplora {
+			if c.Chain != shared.Bitcoin {
+				return nil, fmt.Errorf("esplora server is not supported for chain %s", c.Chain.String())
+			}
+			esploraPath := viper.GetString("watcher.esploraPath")
+			if esploraPath == "" {
+				esploraPath = "127.0.0.1:3000"
+			}
+			c.EsploraEndpoint = esploraPath
+		}
+		c.Electrum = viper.GetBool("watcher.electrum")
+		if c.Electrum {
+			if c.Chain != shared.Bitcoin {
+				return nil, fmt.Errorf("electrum server is not supported for chain %s", c.Chain.String())
+			}
+			electrumPath := viper.GetString("watcher.electrumPath")
+			if electrumPath == "" {
+				electrumPath = "127.0.0.1:50001"
+			}
+			c.ElectrumEndpoint = electrumPath
+		}
+		// When not syncing, the node info is still loaded from the config so that the served APIs can report it
+		if !c.Sync {
+			switch c.Chain {
+			case shared.Ethereum:
+				c.NodeInfo = shared.GetEthNodeInfo()
+			case shared.Bitcoin:
+				c.NodeInfo = shared.GetBtcNodeInfo()
+				c.ChainConfig, err = btc.ParamsFromConfig(c.NodeInfo)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if c.Chain == shared.Ethereum && viper.GetBool("ethereum.proxy") {
+			ethHTTP := viper.GetString("ethereum.httpPath")
+			c.ProxyClient, err = rpc.Dial(fmt.Sprintf("http://%s", ethHTTP))
+			if err != nil {
+				return nil, err
+			}
+			c.ProxyMethods = viper.GetStringSlice("ethereum.proxyMethods")
+			if len(c.ProxyMethods) == 0 {
+				c.ProxyMethods = eth.DefaultProxyMethods
+			}
+			if err := eth.ValidateProxyMethods(c.ProxyMethods); err != nil {
+				return nil, err
+			}
+		}
+		// Without a sync connection, the chain config can only be auto-detected through the proxy client
+		if !c.Sync && c.Chain == shared.Ethereum {
+			c.ChainConfig, err = ethChainConfig(c.NodeInfo, c.ProxyClient)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if c.Chain == shared.Ethereum {
+			c.EthAPIConfig = ethAPIConfig()
+		}
 		serveDBConn := overrideDBConnConfig(c.DBConfig, Serve)
 		serveDB := utils.LoadPostgres(serveDBConn, c.NodeInfo)
 		c.ServeDBConn = &serveDB
@@ -161,6 +298,60 @@ func NewConfig() (*Config, error) {
 	return c, nil
 }
 
+// ethChainConfig loads the ethereum chain config, taking its chain id from the ethereum.chainID config value when it is
+// set and from the node's eth_chainId otherwise; a configured chain id which differs from the node's is logged
+func ethChainConfig(nodeInfo node.Node, client *rpc.Client) (*params.ChainConfig, error) {
+	chainConfig, err := eth.ChainConfigFromConfig(nodeInfo, client)
+	if err != nil {
+		return nil, err
+	}
+	var chainID *big.Int
+	if configured := viper.GetString("ethereum.chainID"); configured != "" {
+		var ok bool
+		if chainID, ok = new(big.Int).SetString(configured, 10); !ok {
+			return nil, fmt.Errorf("invalid ethereum chain id %s", configured)
+		}
+	}
+	if client != nil {
+		var nodeChainID hexutil.Big
+		if err := client.Call(&nodeChainID, "eth_chainId"); err != nil {
+			// nodes which have not synced past the EIP-155 fork block cannot report their chain id yet
+			log.Warnf("unable to retrieve the chain id from the ethereum node: %v", err)
+		} else if chainID == nil {
+			chainID = nodeChainID.ToInt()
+		} else if chainID.Cmp(nodeChainID.ToInt()) != 0 {
+			log.Warnf("configured ethereum chain id %s does not match the node's chain id %s, using the configured chain id", chainID.String(), nodeChainID.ToInt().String())
+		}
+	}
+	if chainID != nil {
+		withChainID := *chainConfig
+		withChainID.ChainID = chainID
+		chainConfig = &withChainID
+	}
+	return chainConfig, nil
+}
+
+// ethAPIConfig loads the eth api settings; the eth_getLogs and trace limits use the defaults when they are not set, and
+// setting them to 0 removes the limit. The debug api is only served when enabled
+func ethAPIConfig() eth.APIConfig {
+	apiConfig := eth.APIConfig{
+		MaxLogRange:     eth.DefaultMaxLogRange,
+		MaxLogResults:   eth.DefaultMaxLogResults,
+		DebugAPI:        viper.GetBool("ethereum.debugAPI"),
+		MaxTraceTimeout: eth.DefaultMaxTraceTimeout,
+	}
+	if viper.IsSet("ethereum.maxLogRange") {
+		apiConfig.MaxLogRange = viper.GetInt64("ethereum.maxLogRange")
+	}
+	if viper.IsSet("ethereum.maxLogResults") {
+		apiConfig.MaxLogResults = viper.GetInt("ethereum.maxLogResults")
+	}
+	if viper.IsSet("ethereum.maxTraceTimeout") {
+		apiConfig.MaxTraceTimeout = time.Duration(viper.GetInt("ethereum.maxTraceTimeout")) * time.Second
+	}
+	return apiConfig
+}
+
 type mode string
 
 var (
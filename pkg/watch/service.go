@@ -17,18 +17,25 @@
 package watch
 
 import (
+	"database/sql"
 	"fmt"
+	"math/big"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
 	ethnode "github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
@@ -36,6 +43,11 @@ import (
 
 const (
 	PayloadChanBufferSize = 2000
+	// MaxConfirmations is the deepest confirmation depth a subscription can request
+	// Blocks this far below the head are kept to be sent once confirmed and to be retracted if they are orphaned
+	MaxConfirmations = 100
+	// indexStatusTTL is how long the extent of the index and its gaps are cached for when reporting the sync status
+	indexStatusTTL = 30 * time.Second
 )
 
 // Watcher is the top level interface for streaming, converting to IPLDs, publishing,
@@ -56,6 +68,12 @@ type Watcher interface {
 	Node() *node.Node
 	// Method to access chain type
 	Chain() shared.ChainType
+	// Method to subscribe to the converted payloads served by the service
+	SubscribePayloads(payloadChan chan<- shared.ConvertedData) event.Subscription
+	// Method to report how far the service's index is behind the chain
+	SyncStatus() (shared.SyncStatus, error)
+	// Method to attach the backfill process whose progress is included in the sync status
+	SetBackFillReporter(reporter shared.BackFillReporter)
 }
 
 // Service is the underlying struct for the watcher
@@ -76,6 +94,8 @@ type Service struct {
 	IPLDFetcher shared.IPLDFetcher
 	// Interface for searching and retrieving CIDs from Postgres index
 	Retriever shared.CIDRetriever
+	// Interface for fetching the head height of the node the service syncs from
+	HeadFetcher shared.HeadFetcher
 	// Chan the processor uses to subscribe to payloads from the Streamer
 	PayloadChan chan shared.RawChainData
 	// Used to signal shutdown of the service
@@ -90,12 +110,39 @@ type Service struct {
 	WorkerPoolSize int
 	// chain type for this service
 	chain shared.ChainType
+	// Network params of the chain
+	chainConfig interface{}
 	// Path to ipfs data dir
 	ipfsPath string
 	// Underlying db
 	db *postgres.DB
 	// wg for syncing serve processes
 	serveWg *sync.WaitGroup
+	// Feed of the converted payloads served by the service, used by the chain APIs' subscriptions
+	payloadFeed shared.PayloadFeed
+	// Upstream client and allow-list used to forward the requests the chain API cannot serve
+	proxyClient  *rpc.Client
+	proxyMethods []string
+	// Settings of the eth apis
+	ethAPIConfig eth.APIConfig
+	// Headers with times_validated lower than this are reported as gaps
+	validationLevel int
+	// Used to sync access to the sync status fields
+	statusLock sync.RWMutex
+	// Reporter for the progress of the backfill process, if there is one
+	backFill shared.BackFillReporter
+	// Heights streamed by the Sync process
+	startingBlock     int64
+	lastStreamedBlock int64
+	// The extent of the index and its gaps, cached for indexStatusTTL so that status requests do not each scan the index
+	indexStatusLock sync.Mutex
+	indexStatus     indexStatus
+	// Payloads served within MaxConfirmations of the head, mapped to their height
+	recent map[int64]shared.ConvertedData
+	// The height of the head served by the Serve process
+	servedHead int64
+	// The highest height sent to each subscription type by the Serve process
+	delivered map[common.Hash]int64
 }
 
 // NewWatcher creates a new Watcher using an underlying Service struct
@@ -104,15 +151,15 @@ func NewWatcher(settings *Config) (Watcher, error) {
 	var err error
 	// If we are syncing, initialize the needed interfaces
 	if settings.Sync {
-		sn.Streamer, sn.PayloadChan, err = builders.NewPayloadStreamer(settings.Chain, settings.WSClient)
+		sn.Streamer, sn.PayloadChan, err = builders.NewPayloadStreamer(settings.Chain, settings.WSClient, settings.SyncDBConn)
 		if err != nil {
 			return nil, err
 		}
-		sn.Converter, err = builders.NewPayloadConverter(settings.Chain)
+		sn.Converter, err = builders.NewPayloadConverter(settings.Chain, settings.ChainConfig)
 		if err != nil {
 			return nil, err
 		}
-		sn.Publisher, err = builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.SyncDBConn, settings.IPFSMode)
+		sn.Publisher, err = builders.NewIPLDPublisher(settings.Chain, settings.ChainConfig, settings.IPFSPath, settings.SyncDBConn, settings.IPFSMode, settings.WSClient)
 		if err != nil {
 			return nil, err
 		}
@@ -124,6 +171,10 @@ func NewWatcher(settings *Config) (Watcher, error) {
 		if err != nil {
 			return nil, err
 		}
+		sn.HeadFetcher, err = builders.NewHeadFetcher(settings.Chain, settings.WSClient)
+		if err != nil {
+			return nil, err
+		}
 	}
 	// If we are serving, initialize the needed interfaces
 	if settings.Serve {
@@ -136,6 +187,9 @@ func NewWatcher(settings *Config) (Watcher, error) {
 			return nil, err
 		}
 		sn.db = settings.ServeDBConn
+		sn.proxyClient = settings.ProxyClient
+		sn.proxyMethods = settings.ProxyMethods
+		sn.ethAPIConfig = settings.EthAPIConfig
 	}
 	sn.QuitChan = make(chan bool)
 	sn.Subscriptions = make(map[common.Hash]map[rpc.ID]Subscription)
@@ -144,6 +198,8 @@ func NewWatcher(settings *Config) (Watcher, error) {
 	sn.NodeInfo = &settings.NodeInfo
 	sn.ipfsPath = settings.IPFSPath
 	sn.chain = settings.Chain
+	sn.chainConfig = settings.ChainConfig
+	sn.validationLevel = settings.ValidationLevel
 	return sn, nil
 }
 
@@ -154,7 +210,15 @@ func (sap *Service) Protocols() []p2p.Protocol {
 
 // APIs returns the RPC descriptors the watcher service offers
 func (sap *Service) APIs() []rpc.API {
-	ifnoAPI := NewInfoAPI()
+	var nodeInfo node.Node
+	if sap.NodeInfo != nil {
+		nodeInfo = *sap.NodeInfo
+	}
+	ifnoAPI := NewInfoAPI(sap.chain, nodeInfo)
+	var chainID *big.Int
+	if chainConfig, ok := sap.chainConfig.(*params.ChainConfig); ok && chainConfig != nil {
+		chainID = chainConfig.ChainID
+	}
 	apis := []rpc.API{
 		{
 			Namespace: APIName,
@@ -171,7 +235,13 @@ func (sap *Service) APIs() []rpc.API {
 		{
 			Namespace: "net",
 			Version:   APIVersion,
-			Service:   ifnoAPI,
+			Service:   NewNetAPI(nodeInfo.NetworkID, chainID),
+			Public:    true,
+		},
+		{
+			Namespace: "web3",
+			Version:   APIVersion,
+			Service:   NewWeb3API(),
 			Public:    true,
 		},
 		{
@@ -181,12 +251,12 @@ func (sap *Service) APIs() []rpc.API {
 			Public:    true,
 		},
 	}
-	chainAPI, err := builders.NewPublicAPI(sap.chain, sap.db, sap.ipfsPath)
+	chainAPIs, err := builders.NewPublicAPI(sap.chain, sap.chainConfig, sap.db, sap.ipfsPath, sap, sap, sap.proxyClient, sap.proxyMethods, sap.ethAPIConfig)
 	if err != nil {
 		log.Error(err)
 		return apis
 	}
-	return append(apis, chainAPI)
+	return append(apis, chainAPIs...)
 }
 
 // Sync streams incoming raw chain data and converts it for further processing
@@ -216,6 +286,7 @@ func (sap *Service) Sync(wg *sync.WaitGroup, screenAndServePayload chan<- shared
 					continue
 				}
 				log.Infof("%s data streamed at head height %d", sap.chain.String(), ipldPayload.Height())
+				sap.recordStreamed(ipldPayload.Height())
 				// If we have a ScreenAndServe process running, forward the iplds to it
 				select {
 				case screenAndServePayload <- ipldPayload:
@@ -278,7 +349,9 @@ func (sap *Service) Serve(wg *sync.WaitGroup, screenAndServePayload <-chan share
 		for {
 			select {
 			case payload := <-screenAndServePayload:
-				sap.filterAndServe(payload)
+				if sap.filterAndServe(payload) {
+					sap.payloadFeed.Send(payload)
+				}
 			case <-sap.QuitChan:
 				log.Infof("quiting %s Serve process", sap.chain.String())
 				return
@@ -289,12 +362,38 @@ func (sap *Service) Serve(wg *sync.WaitGroup, screenAndServePayload <-chan share
 }
 
 // filterAndServe filters the payload according to each subscription type and sends to the subscriptions
-func (sap *Service) filterAndServe(payload shared.ConvertedData) {
+// Subscription types which request confirmations are sent the recent payloads which the new payload confirms
+// It returns false if the payload is not served, because it has already been served or is below the served head
+func (sap *Service) filterAndServe(payload shared.ConvertedData) bool {
 	log.Debugf("sending %s payload to subscriptions", sap.chain.String())
 	sap.Lock()
 	sap.serveWg.Add(1)
 	defer sap.Unlock()
 	defer sap.serveWg.Done()
+	if sap.recent == nil {
+		sap.recent = make(map[int64]shared.ConvertedData)
+		sap.delivered = make(map[common.Hash]int64)
+	}
+	height := payload.Height()
+	served, ok := sap.recent[height]
+	if ok && served.Hash() == payload.Hash() {
+		log.Debugf("%s payload at height %d has already been served", sap.chain.String(), height)
+		return false
+	}
+	// Payloads below the served head, such as those forwarded by the backfill process, are only served when they
+	// replace a served payload at their height, as that means the chain has reorganized
+	if !ok && height < sap.servedHead {
+		log.Debugf("%s payload at height %d is below the served head at height %d", sap.chain.String(), height, sap.servedHead)
+		return false
+	}
+	sap.retractOrphaned(payload)
+	sap.recent[height] = payload
+	sap.servedHead = height
+	for h := range sap.recent {
+		if h < height-MaxConfirmations {
+			delete(sap.recent, h)
+		}
+	}
 	for ty, subs := range sap.Subscriptions {
 		// Retrieve the subscription parameters for this subscription type
 		subConfig, ok := sap.SubscriptionTypes[ty]
@@ -303,34 +402,102 @@ func (sap *Service) filterAndServe(payload shared.ConvertedData) {
 			sap.closeType(ty)
 			continue
 		}
-		if subConfig.EndingBlock().Int64() > 0 && subConfig.EndingBlock().Int64() < payload.Height() {
-			// We are not out of range for this subscription type
-			// close it, and continue to the next
-			sap.closeType(ty)
-			continue
+		confirmedHeight := height - subConfig.ConfirmationDepth()
+		next := confirmedHeight
+		if delivered, ok := sap.delivered[ty]; ok {
+			next = delivered + 1
 		}
-		response, err := sap.Filterer.Filter(subConfig, payload)
-		if err != nil {
-			log.Errorf("watcher filtering error for chain %s: %v", sap.chain.String(), err)
-			sap.closeType(ty)
-			continue
+		for ; next <= confirmedHeight; next++ {
+			confirmed, ok := sap.recent[next]
+			if !ok {
+				continue
+			}
+			if subConfig.EndingBlock().Int64() > 0 && subConfig.EndingBlock().Int64() < confirmed.Height() {
+				// We are not out of range for this subscription type
+				// close it, and continue to the next
+				sap.closeType(ty)
+				break
+			}
+			sap.delivered[ty] = next
+			response, err := sap.Filterer.Filter(subConfig, confirmed)
+			if err != nil {
+				log.Errorf("watcher filtering error for chain %s: %v", sap.chain.String(), err)
+				sap.closeType(ty)
+				break
+			}
+			responseRLP, err := rlp.EncodeToBytes(response)
+			if err != nil {
+				log.Errorf("watcher rlp encoding error for chain %s: %v", sap.chain.String(), err)
+				continue
+			}
+			for id, sub := range subs {
+				select {
+				case sub.PayloadChan <- SubscriptionPayload{Data: responseRLP, Err: "", Flag: EmptyFlag, Height: response.Height()}:
+					log.Debugf("sending watcher %s payload to subscription %s", sap.chain.String(), id)
+				default:
+					log.Infof("unable to send %s payload to subscription %s; channel has no receiver", sap.chain.String(), id)
+				}
+			}
 		}
-		responseRLP, err := rlp.EncodeToBytes(response)
-		if err != nil {
-			log.Errorf("watcher rlp encoding error for chain %s: %v", sap.chain.String(), err)
+	}
+	return true
+}
+
+// retractOrphaned finds the recent payloads orphaned by the new payload and sends their retraction, from the highest
+// down, to the subscription types which have been sent them
+// retractOrphaned needs to be called with subscription access locked
+func (sap *Service) retractOrphaned(payload shared.ConvertedData) {
+	height := payload.Height()
+	orphaned := make([]int64, 0)
+	for h := range sap.recent {
+		if h >= height {
+			orphaned = append(orphaned, h)
+		}
+	}
+	if parent, ok := sap.recent[height-1]; ok && parent.Hash() != payload.ParentHash() {
+		orphaned = append(orphaned, height-1)
+	}
+	if len(orphaned) == 0 {
+		return
+	}
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i] > orphaned[j] })
+	forkHeight := orphaned[len(orphaned)-1] - 1
+	log.Infof("%s reorg detected at height %d, retracting the served blocks above height %d", sap.chain.String(), height, forkHeight)
+	for ty, subs := range sap.Subscriptions {
+		delivered, ok := sap.delivered[ty]
+		if !ok {
 			continue
 		}
-		for id, sub := range subs {
-			select {
-			case sub.PayloadChan <- SubscriptionPayload{Data: responseRLP, Err: "", Flag: EmptyFlag, Height: response.Height()}:
-				log.Debugf("sending watcher %s payload to subscription %s", sap.chain.String(), id)
-			default:
-				log.Infof("unable to send %s payload to subscription %s; channel has no receiver", sap.chain.String(), id)
+		for _, h := range orphaned {
+			if h > delivered {
+				continue
+			}
+			retraction := SubscriptionPayload{Data: nil, Err: "", Flag: ReorgFlag, Height: h, Hash: sap.recent[h].Hash()}
+			for id, sub := range subs {
+				select {
+				case sub.PayloadChan <- retraction:
+					log.Debugf("sending %s retraction of height %d to subscription %s", sap.chain.String(), h, id)
+				default:
+					log.Infof("unable to send %s retraction of height %d to subscription %s; channel has no receiver", sap.chain.String(), h, id)
+				}
 			}
 		}
+		if delivered > forkHeight {
+			sap.delivered[ty] = forkHeight
+		}
+	}
+	for _, h := range orphaned {
+		delete(sap.recent, h)
 	}
 }
 
+// SubscribePayloads subscribes the provided channel to the converted payloads served by the service
+// It is used to feed the subscriptions of the chain APIs; the channel should be buffered, as the payloads it has no room
+// for are dropped rather than holding up the Serve loop
+func (sap *Service) SubscribePayloads(payloadChan chan<- shared.ConvertedData) event.Subscription {
+	return sap.payloadFeed.Subscribe(payloadChan)
+}
+
 // Subscribe is used by the API to remotely subscribe to the service loop
 // The params must be rlp serializable and satisfy the SubscriptionSettings() interface
 func (sap *Service) Subscribe(id rpc.ID, sub chan<- SubscriptionPayload, quitChan chan<- bool, params shared.SubscriptionSettings) {
@@ -347,6 +514,11 @@ func (sap *Service) Subscribe(id rpc.ID, sub chan<- SubscriptionPayload, quitCha
 		sendNonBlockingQuit(subscription)
 		return
 	}
+	if params.ConfirmationDepth() > MaxConfirmations {
+		sendNonBlockingErr(subscription, fmt.Errorf("subscription %s requests %d confirmations, service supports at most %d", id, params.ConfirmationDepth(), MaxConfirmations))
+		sendNonBlockingQuit(subscription)
+		return
+	}
 	// Subscription type is defined as the hash of the rlp-serialized subscription settings
 	by, err := rlp.EncodeToBytes(params)
 	if err != nil {
@@ -394,6 +566,8 @@ func (sap *Service) sendHistoricalData(sub Subscription, id rpc.ID, params share
 	if err != nil {
 		return err
 	}
+	// Only send the blocks which have the requested number of confirmations
+	endingBlock -= params.ConfirmationDepth()
 	if endingBlock > params.EndingBlock().Int64() && params.EndingBlock().Int64() > 0 && params.EndingBlock().Int64() > startingBlock {
 		endingBlock = params.EndingBlock().Int64()
 	}
@@ -457,6 +631,7 @@ func (sap *Service) Unsubscribe(id rpc.ID) {
 			// If we removed the last subscription of this type, remove the subscription type outright
 			delete(sap.Subscriptions, ty)
 			delete(sap.SubscriptionTypes, ty)
+			delete(sap.delivered, ty)
 		}
 	}
 	sap.Unlock()
@@ -496,6 +671,104 @@ func (sap *Service) Chain() shared.ChainType {
 	return sap.chain
 }
 
+// SyncStatus reports the heights streamed by the Sync process, the extent of the index and its outstanding gaps,
+// the head of the node the service syncs from and the progress of the backfill process
+// The extent of the index and its gaps are refreshed at most once per indexStatusTTL
+func (sap *Service) SyncStatus() (shared.SyncStatus, error) {
+	if sap.Retriever == nil {
+		return shared.SyncStatus{}, fmt.Errorf("%s watcher is not configured to serve its index", sap.chain.String())
+	}
+	index, err := sap.cachedIndexStatus()
+	if err != nil {
+		return shared.SyncStatus{}, err
+	}
+	sap.statusLock.RLock()
+	status := shared.SyncStatus{
+		StartingBlock:     sap.startingBlock,
+		LastStreamedBlock: sap.lastStreamedBlock,
+		ContiguousBlock:   index.contiguousBlock,
+		HeadBlock:         -1,
+		Gaps:              index.gaps,
+	}
+	backFill := sap.backFill
+	sap.statusLock.RUnlock()
+	if sap.HeadFetcher != nil {
+		head, err := sap.HeadFetcher.FetchHead()
+		if err != nil {
+			log.Errorf("%s watcher unable to fetch the head of the node: %v", sap.chain.String(), err)
+		} else {
+			status.HeadBlock = head
+			status.Lag = head - status.ContiguousBlock
+		}
+	}
+	if backFill != nil {
+		progress := backFill.Progress()
+		status.BackFill = &progress
+	}
+	return status, nil
+}
+
+// indexStatus is the part of the sync status which is read from the index
+type indexStatus struct {
+	contiguousBlock int64
+	gaps            []shared.Gap
+	refreshed       time.Time
+}
+
+// cachedIndexStatus returns the extent of the index and its gaps, scanning the index for them at most once per
+// indexStatusTTL; concurrent callers wait on the same scan
+func (sap *Service) cachedIndexStatus() (indexStatus, error) {
+	sap.indexStatusLock.Lock()
+	defer sap.indexStatusLock.Unlock()
+	if !sap.indexStatus.refreshed.IsZero() && time.Since(sap.indexStatus.refreshed) < indexStatusTTL {
+		return sap.indexStatus, nil
+	}
+	status := indexStatus{
+		contiguousBlock: -1,
+		gaps:            []shared.Gap{},
+	}
+	lastBlock, err := sap.Retriever.RetrieveLastBlockNumber()
+	switch {
+	case err == sql.ErrNoRows:
+		// nothing has been indexed yet
+	case err != nil:
+		return indexStatus{}, err
+	default:
+		status.gaps, err = sap.Retriever.RetrieveGapsInData(sap.validationLevel)
+		if err != nil {
+			return indexStatus{}, err
+		}
+		status.contiguousBlock = lastBlock
+		for _, gap := range status.gaps {
+			if int64(gap.Start)-1 < status.contiguousBlock {
+				status.contiguousBlock = int64(gap.Start) - 1
+			}
+		}
+	}
+	status.refreshed = time.Now()
+	sap.indexStatus = status
+	return status, nil
+}
+
+// SetBackFillReporter attaches the backfill process whose progress is included in the sync status
+func (sap *Service) SetBackFillReporter(reporter shared.BackFillReporter) {
+	sap.statusLock.Lock()
+	defer sap.statusLock.Unlock()
+	sap.backFill = reporter
+}
+
+// recordStreamed records a height streamed by the Sync process
+func (sap *Service) recordStreamed(height int64) {
+	sap.statusLock.Lock()
+	defer sap.statusLock.Unlock()
+	if sap.startingBlock == 0 {
+		sap.startingBlock = height
+	}
+	if height > sap.lastStreamedBlock {
+		sap.lastStreamedBlock = height
+	}
+}
+
 // close is used to close all listening subscriptions
 // close needs to be called with subscription access locked
 func (sap *Service) close() {
@@ -506,6 +779,7 @@ func (sap *Service) close() {
 		}
 		delete(sap.Subscriptions, subType)
 		delete(sap.SubscriptionTypes, subType)
+		delete(sap.delivered, subType)
 	}
 }
 
@@ -519,4 +793,5 @@ func (sap *Service) closeType(subType common.Hash) {
 	}
 	delete(sap.Subscriptions, subType)
 	delete(sap.SubscriptionTypes, subType)
+	delete(sap.delivered, subType)
 }
@@ -17,13 +17,18 @@
 package watch_test
 
 import (
+	"errors"
+	"math/big"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 	mocks2 "github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared/mocks"
@@ -75,4 +80,270 @@ var _ = Describe("Service", func() {
 			Expect(mockStreamer.PassedPayloadChan).To(Equal(payloadChan))
 		})
 	})
+
+	Describe("Serve", func() {
+		var (
+			wg            *sync.WaitGroup
+			quitChan      chan bool
+			servePayloads chan shared.ConvertedData
+			headChan      chan watch.SubscriptionPayload
+			confirmedChan chan watch.SubscriptionPayload
+			processor     *watch.Service
+		)
+		BeforeEach(func() {
+			wg = new(sync.WaitGroup)
+			quitChan = make(chan bool)
+			servePayloads = make(chan shared.ConvertedData, 10)
+			headChan = make(chan watch.SubscriptionPayload, 10)
+			confirmedChan = make(chan watch.SubscriptionPayload, 10)
+			headType := common.HexToHash("0x01")
+			confirmedType := common.HexToHash("0x02")
+			processor = &watch.Service{
+				Filterer: eth.NewResponseFilterer(),
+				QuitChan: quitChan,
+				Subscriptions: map[common.Hash]map[rpc.ID]watch.Subscription{
+					headType:      {"head": {ID: "head", PayloadChan: headChan}},
+					confirmedType: {"confirmed": {ID: "confirmed", PayloadChan: confirmedChan}},
+				},
+				SubscriptionTypes: map[common.Hash]shared.SubscriptionSettings{
+					headType:      &eth.SubscriptionSettings{Start: big.NewInt(0), End: big.NewInt(0)},
+					confirmedType: &eth.SubscriptionSettings{Start: big.NewInt(0), End: big.NewInt(0), Confirmations: []uint64{1}},
+				},
+			}
+			processor.Serve(wg, servePayloads)
+		})
+		AfterEach(func() {
+			close(quitChan)
+			wg.Wait()
+		})
+
+		It("Sends blocks to the subscriptions once they have the requested number of confirmations", func() {
+			block1 := mocks.MockBlock
+			block2 := mocks.NewMockChildBlock(block1)
+			block3 := mocks.NewMockChildBlock(block2)
+			for _, block := range []*types.Block{block1, block2, block3} {
+				servePayloads <- mocks.NewMockPayload(block)
+			}
+			for _, height := range []int64{1, 2, 3} {
+				var payload watch.SubscriptionPayload
+				Eventually(headChan).Should(Receive(&payload))
+				Expect(payload.Height).To(Equal(height))
+				Expect(payload.Flag).To(Equal(watch.EmptyFlag))
+			}
+			for _, height := range []int64{1, 2} {
+				var payload watch.SubscriptionPayload
+				Eventually(confirmedChan).Should(Receive(&payload))
+				Expect(payload.Height).To(Equal(height))
+				Expect(payload.Flag).To(Equal(watch.EmptyFlag))
+			}
+			Consistently(confirmedChan).ShouldNot(Receive())
+		})
+
+		It("Retracts the blocks sent to the subscriptions when they are orphaned by a reorg", func() {
+			block1 := mocks.MockBlock
+			block2a := mocks.NewMockChildBlock(block1)
+			block3a := mocks.NewMockChildBlock(block2a)
+			block2b := mocks.NewMockForkBlock(block1, 1)
+			block3b := mocks.NewMockForkBlock(block2b, 1)
+			for _, block := range []*types.Block{block1, block2a, block3a, block2b, block3b} {
+				servePayloads <- mocks.NewMockPayload(block)
+			}
+			expectedHead := []watch.SubscriptionPayload{
+				{Height: 1, Flag: watch.EmptyFlag},
+				{Height: 2, Flag: watch.EmptyFlag},
+				{Height: 3, Flag: watch.EmptyFlag},
+				{Height: 3, Hash: block3a.Hash().Hex(), Flag: watch.ReorgFlag},
+				{Height: 2, Hash: block2a.Hash().Hex(), Flag: watch.ReorgFlag},
+				{Height: 2, Flag: watch.EmptyFlag},
+				{Height: 3, Flag: watch.EmptyFlag},
+			}
+			for _, expected := range expectedHead {
+				var payload watch.SubscriptionPayload
+				Eventually(headChan).Should(Receive(&payload))
+				Expect(payload.Height).To(Equal(expected.Height))
+				Expect(payload.Hash).To(Equal(expected.Hash))
+				Expect(payload.Flag).To(Equal(expected.Flag))
+			}
+			expectedConfirmed := []watch.SubscriptionPayload{
+				{Height: 1, Flag: watch.EmptyFlag},
+				{Height: 2, Flag: watch.EmptyFlag},
+				{Height: 2, Hash: block2a.Hash().Hex(), Flag: watch.ReorgFlag},
+				{Height: 2, Flag: watch.EmptyFlag},
+			}
+			for _, expected := range expectedConfirmed {
+				var payload watch.SubscriptionPayload
+				Eventually(confirmedChan).Should(Receive(&payload))
+				Expect(payload.Height).To(Equal(expected.Height))
+				Expect(payload.Hash).To(Equal(expected.Hash))
+				Expect(payload.Flag).To(Equal(expected.Flag))
+			}
+			Consistently(confirmedChan).ShouldNot(Receive())
+		})
+
+		It("Does not serve or retract anything for payloads below the served head, such as backfilled ones", func() {
+			block1 := mocks.MockBlock
+			block2 := mocks.NewMockChildBlock(block1)
+			block3 := mocks.NewMockChildBlock(block2)
+			block4 := mocks.NewMockChildBlock(block3)
+			servePayloads <- mocks.NewMockPayload(block2)
+			servePayloads <- mocks.NewMockPayload(block3)
+			for _, height := range []int64{2, 3} {
+				var payload watch.SubscriptionPayload
+				Eventually(headChan).Should(Receive(&payload))
+				Expect(payload.Height).To(Equal(height))
+			}
+			var payload watch.SubscriptionPayload
+			Eventually(confirmedChan).Should(Receive(&payload))
+			Expect(payload.Height).To(Equal(int64(2)))
+
+			servePayloads <- mocks.NewMockPayload(block1)
+			Consistently(headChan).ShouldNot(Receive())
+			Consistently(confirmedChan).ShouldNot(Receive())
+
+			servePayloads <- mocks.NewMockPayload(block4)
+			Eventually(headChan).Should(Receive(&payload))
+			Expect(payload.Height).To(Equal(int64(4)))
+			Expect(payload.Flag).To(Equal(watch.EmptyFlag))
+			Eventually(confirmedChan).Should(Receive(&payload))
+			Expect(payload.Height).To(Equal(int64(3)))
+			Expect(payload.Flag).To(Equal(watch.EmptyFlag))
+			Consistently(confirmedChan).ShouldNot(Receive())
+		})
+
+		It("Feeds the served payloads to the payload subscribers whether they subscribe before or after a payload is sent", func() {
+			block1 := mocks.MockBlock
+			block2 := mocks.NewMockChildBlock(block1)
+			block3 := mocks.NewMockChildBlock(block2)
+			block4 := mocks.NewMockChildBlock(block3)
+			servePayloads <- mocks.NewMockPayload(block1)
+			Eventually(headChan).Should(Receive())
+
+			first := make(chan shared.ConvertedData, 10)
+			firstSub := processor.SubscribePayloads(first)
+			defer firstSub.Unsubscribe()
+			servePayloads <- mocks.NewMockPayload(block2)
+			var payload shared.ConvertedData
+			Eventually(first).Should(Receive(&payload))
+			Expect(payload.Height()).To(Equal(int64(2)))
+
+			second := make(chan shared.ConvertedData, 10)
+			secondSub := processor.SubscribePayloads(second)
+			servePayloads <- mocks.NewMockPayload(block3)
+			Eventually(first).Should(Receive(&payload))
+			Expect(payload.Height()).To(Equal(int64(3)))
+			Eventually(second).Should(Receive(&payload))
+			Expect(payload.Height()).To(Equal(int64(3)))
+
+			secondSub.Unsubscribe()
+			Eventually(secondSub.Err()).Should(BeClosed())
+			servePayloads <- mocks.NewMockPayload(block4)
+			Eventually(first).Should(Receive())
+			Consistently(second).ShouldNot(Receive())
+		})
+
+		It("Does not feed payloads which are not served", func() {
+			block1 := mocks.MockBlock
+			block2 := mocks.NewMockChildBlock(block1)
+			payloads := make(chan shared.ConvertedData, 10)
+			sub := processor.SubscribePayloads(payloads)
+			defer sub.Unsubscribe()
+			servePayloads <- mocks.NewMockPayload(block2)
+			Eventually(payloads).Should(Receive())
+
+			// an already served payload and one below the served head
+			servePayloads <- mocks.NewMockPayload(block2)
+			servePayloads <- mocks.NewMockPayload(block1)
+			Consistently(payloads).ShouldNot(Receive())
+		})
+
+		It("Keeps serving while a payload subscriber is not receiving", func() {
+			block1 := mocks.MockBlock
+			block2 := mocks.NewMockChildBlock(block1)
+			block3 := mocks.NewMockChildBlock(block2)
+			stalled := make(chan shared.ConvertedData)
+			stalledSub := processor.SubscribePayloads(stalled)
+			defer stalledSub.Unsubscribe()
+			receiving := make(chan shared.ConvertedData, 10)
+			receivingSub := processor.SubscribePayloads(receiving)
+			defer receivingSub.Unsubscribe()
+			for _, block := range []*types.Block{block1, block2, block3} {
+				servePayloads <- mocks.NewMockPayload(block)
+			}
+			for _, height := range []int64{1, 2, 3} {
+				var payload watch.SubscriptionPayload
+				Eventually(headChan).Should(Receive(&payload))
+				Expect(payload.Height).To(Equal(height))
+				var data shared.ConvertedData
+				Eventually(receiving).Should(Receive(&data))
+				Expect(data.Height()).To(Equal(height))
+			}
+			Consistently(stalled).ShouldNot(Receive())
+		})
+	})
+
+	Describe("SyncStatus", func() {
+		It("Reports the contiguous height, the lag behind the head, the gaps and the backfill progress", func() {
+			gaps := []shared.Gap{{Start: 5, Stop: 6}, {Start: 8, Stop: 8}}
+			progress := shared.BackFillProgress{
+				Running:      true,
+				Gaps:         2,
+				TotalBlocks:  3,
+				FilledBlocks: 1,
+			}
+			processor := &watch.Service{
+				Retriever: &mocks2.CIDRetriever{
+					GapsToRetrieve:          gaps,
+					LastBlockNumberToReturn: 10,
+				},
+				HeadFetcher: &mocks2.HeadFetcher{
+					HeadToReturn: 12,
+				},
+			}
+			processor.SetBackFillReporter(&mocks2.BackFillReporter{
+				ProgressToReturn: progress,
+			})
+			status, err := processor.SyncStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status.ContiguousBlock).To(Equal(int64(4)))
+			Expect(status.HeadBlock).To(Equal(int64(12)))
+			Expect(status.Lag).To(Equal(int64(8)))
+			Expect(status.Gaps).To(Equal(gaps))
+			Expect(*status.BackFill).To(Equal(progress))
+		})
+
+		It("Reports an unknown head if the head cannot be fetched", func() {
+			processor := &watch.Service{
+				Retriever: &mocks2.CIDRetriever{
+					LastBlockNumberToReturn: 10,
+				},
+				HeadFetcher: &mocks2.HeadFetcher{
+					ReturnErr: errors.New("mock error"),
+				},
+			}
+			status, err := processor.SyncStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status.ContiguousBlock).To(Equal(int64(10)))
+			Expect(status.HeadBlock).To(Equal(int64(-1)))
+			Expect(status.BackFill).To(BeNil())
+		})
+
+		It("Does not rescan the index for gaps on every request", func() {
+			retriever := &mocks2.CIDRetriever{
+				GapsToRetrieve:          []shared.Gap{{Start: 5, Stop: 6}},
+				LastBlockNumberToReturn: 10,
+			}
+			processor := &watch.Service{
+				Retriever: retriever,
+			}
+			status, err := processor.SyncStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status.ContiguousBlock).To(Equal(int64(4)))
+			retriever.GapsToRetrieve = nil
+			retriever.LastBlockNumberToReturn = 20
+			status, err = processor.SyncStatus()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(status.ContiguousBlock).To(Equal(int64(4)))
+			Expect(status.Gaps).To(Equal([]shared.Gap{{Start: 5, Stop: 6}}))
+		})
+	})
 })
@@ -27,6 +27,9 @@ type Flag int32
 const (
 	EmptyFlag Flag = iota
 	BackFillCompleteFlag
+	// ReorgFlag marks a payload retracting the block at its Height and Hash, previously sent to the subscription,
+	// which has been orphaned by a reorg
+	ReorgFlag
 )
 
 // Subscription holds the information for an individual client subscription to the watcher
@@ -41,6 +44,7 @@ type Subscription struct {
 type SubscriptionPayload struct {
 	Data   []byte `json:"data"` // e.g. for Ethereum rlp serialized eth.StreamPayload
 	Height int64  `json:"height"`
+	Hash   string `json:"hash"` // hash of the retracted block
 	Err    string `json:"err"`  // field for error
 	Flag   Flag   `json:"flag"` // field for message
 }
@@ -58,3 +62,7 @@ func (sp SubscriptionPayload) BackFillComplete() bool {
 	}
 	return false
 }
+
+func (sp SubscriptionPayload) Retracted() bool {
+	return sp.Flag == ReorgFlag
+}